@@ -1,11 +1,37 @@
 package v1alpha1
 
 import (
+	batchv1 "k8s.io/api/batch/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// RestartStrategy selects how each owner's pods are batched during a restart
+// +kubebuilder:validation:Enum=Rolling;Canary;Rollout;Trickle;Surge
+type RestartStrategy string
+
+const (
+	// RestartStrategyRolling restarts 50% of each owner's pods, then the rest
+	RestartStrategyRolling RestartStrategy = "Rolling"
+	// RestartStrategyCanary restarts one pod per owner, soaks it, then the rest
+	RestartStrategyCanary RestartStrategy = "Canary"
+	// RestartStrategyRollout triggers one rollout restart per owning
+	// Deployment/StatefulSet/DaemonSet and lets its controller pace it
+	RestartStrategyRollout RestartStrategy = "Rollout"
+	// RestartStrategyTrickle restarts a fixed number of pods per interval
+	RestartStrategyTrickle RestartStrategy = "Trickle"
+	// RestartStrategySurge scales each owning Deployment up by a batch, waits
+	// for the extra pods to be Ready, then scales down removing old pods
+	RestartStrategySurge RestartStrategy = "Surge"
 )
 
 // AutoApplyConfigSpec defines the configuration for the operator
 type AutoApplyConfigSpec struct {
+	// ConfigMapSelector limits this config to ConfigMaps with matching labels.
+	// Unset applies the config to all ConfigMaps.
+	// +optional
+	ConfigMapSelector *metav1.LabelSelector `json:"configMapSelector,omitempty"`
+
 	// ExcludePods is a list of regex patterns for pod names to exclude from auto-restart
 	// +optional
 	ExcludePods []string `json:"excludePods,omitempty"`
@@ -14,16 +40,428 @@ type AutoApplyConfigSpec struct {
 	// +optional
 	ExcludeNamespaces []string `json:"excludeNamespaces,omitempty"`
 
-	// YoloMode disables safe rolling restarts - all pods restart at once
+	// IncludeNamespaces limits restarts to these namespaces when set.
+	// ExcludeNamespaces still applies within them. Lists from several
+	// configs are combined.
+	// +optional
+	IncludeNamespaces []string `json:"includeNamespaces,omitempty"`
+
+	// ExcludeConfigMaps is a list of regex patterns for ConfigMap names whose
+	// changes never restart pods. Patterns containing a slash match
+	// "namespace/name", e.g. for ConfigMaps a sidecar injector mounts.
+	// +optional
+	ExcludeConfigMaps []string `json:"excludeConfigMaps,omitempty"`
+
+	// DisableDefaultConfigMapExclusions lets changes to the built-in ignored
+	// ConfigMaps, like kube-root-ca.crt, restart pods again
+	// +optional
+	DisableDefaultConfigMapExclusions bool `json:"disableDefaultConfigMapExclusions,omitempty"`
+
+	// YoloMode disables safe rolling restarts - all pods restart at once. A
+	// namespace config setting it false turns it off for its namespace.
+	// +optional
+	YoloMode *bool `json:"yoloMode,omitempty"`
+
+	// Strategy selects how pods are restarted when not in YoloMode. Defaults to
+	// Rolling; Trickle wins over Canary, then Surge, then Rollout, then Rolling.
+	// +optional
+	Strategy RestartStrategy `json:"strategy,omitempty"`
+
+	// CanarySoakDuration is how long a canary pod must stay Ready before the
+	// rest of its owner's pods restart. Defaults to 1m.
+	// +optional
+	CanarySoakDuration *metav1.Duration `json:"canarySoakDuration,omitempty"`
+
+	// TrickleBatchSize is how many pods the Trickle strategy restarts per
+	// interval. Defaults to 10; the smallest size across configs wins.
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	TrickleBatchSize int32 `json:"trickleBatchSize,omitempty"`
+
+	// TrickleInterval is the time between Trickle restart steps. Defaults to
+	// 1m; the longest interval across configs wins.
+	// +optional
+	TrickleInterval *metav1.Duration `json:"trickleInterval,omitempty"`
+
+	// SkipRefreshableMounts leaves pods alone that only mount the ConfigMap as
+	// a full volume, which kubelet refreshes in place. Pods using it via
+	// subPath mounts or environment variables are always restarted. A
+	// namespace config setting it false turns it off for its namespace.
+	// +optional
+	SkipRefreshableMounts *bool `json:"skipRefreshableMounts,omitempty"`
+
+	// DryRun computes and reports the restart plan without restarting any
+	// pods. Any config setting it enables it; namespace configs can't turn off
+	// a cluster-wide dry run.
+	// +optional
+	DryRun *bool `json:"dryRun,omitempty"`
+
+	// VPAEvictionWindow defers pods that a VerticalPodAutoscaler in Auto/Recreate
+	// mode is about to evict. If VPA replaces them within the window the config
+	// restart is considered done, otherwise they are restarted afterwards.
+	// Unset disables VPA coordination.
+	// +optional
+	VPAEvictionWindow *metav1.Duration `json:"vpaEvictionWindow,omitempty"`
+
+	// RestartTimeout bounds a whole restart operation. Pods not restarted by then
+	// are reported as running stale config. Defaults to 30m; the shortest
+	// timeout across configs wins.
+	// +optional
+	RestartTimeout *metav1.Duration `json:"restartTimeout,omitempty"`
+
+	// DebounceDuration delays restarts until a ConfigMap has stopped changing
+	// for this long, so rapid successive updates cause a single restart.
+	// Unset disables debouncing; the longest duration across configs wins.
+	// +optional
+	DebounceDuration *metav1.Duration `json:"debounceDuration,omitempty"`
+
+	// MaintenanceWindows limits restarts to recurring time ranges. Changes
+	// detected outside every window are queued until one opens. Windows of
+	// namespace configs narrow the cluster ones: restarts run only while one
+	// of each is open.
+	// +optional
+	MaintenanceWindows []MaintenanceWindow `json:"maintenanceWindows,omitempty"`
+
+	// Notifications are sent when restarts begin, complete or fail.
+	// Notifications from all configs are sent; those of namespace configs
+	// only to hosts the operator allows.
+	// +optional
+	Notifications []Notification `json:"notifications,omitempty"`
+
+	// PreRestartJob runs in the ConfigMap's namespace before any pod is
+	// restarted. The restart is aborted unless it succeeds in time. Jobs from
+	// all configs run, one after another. Ignored in namespace configs, as
+	// the operator creates the Job with its own permissions.
+	// +optional
+	PreRestartJob *PreRestartJob `json:"preRestartJob,omitempty"`
+
+	// VerificationProbes check Services in the ConfigMap's namespace after
+	// every restart batch. A failing probe aborts the owner's remaining
+	// batches. Probes from all configs run. Namespace configs' probes must
+	// name a Service, keeping them inside their namespace.
 	// +optional
-	YoloMode bool `json:"yoloMode,omitempty"`
+	VerificationProbes []ServiceProbe `json:"verificationProbes,omitempty"`
+
+	// ChangeDetection selects what counts as a change of a ConfigMap.
+	// Defaults to any change of its data. If configs disagree, the most
+	// sensitive mode wins, Keys lists are combined and comparison is only
+	// semantic if all of them ask for it.
+	// +optional
+	ChangeDetection *ChangeDetection `json:"changeDetection,omitempty"`
+
+	// RecentRolloutWindow leaves pods of a Deployment, StatefulSet or
+	// DaemonSet running if the workload rolled out within the window, as the
+	// rollout likely picked up the current config already. Unset disables it;
+	// the longest window wins.
+	// +optional
+	RecentRolloutWindow *metav1.Duration `json:"recentRolloutWindow,omitempty"`
+
+	// BatchSteps replaces the two halves the Rolling and Canary strategies
+	// restart each owner's pods in with a sequence of pod counts or
+	// percentages of the owner's pods, e.g. [1, 2, 4, 8] or [10%, 30%, 100%].
+	// The last step repeats until all pods are restarted. If configs
+	// disagree, the sequence with the most steps wins.
+	// +optional
+	BatchSteps []intstr.IntOrString `json:"batchSteps,omitempty"`
+
+	// PDBRetryWindow is how long pods that a PodDisruptionBudget kept from
+	// being evicted are retried, with backoff, after the rest of a restart
+	// finished (default 1h, 0s disables retries). The longest window wins.
+	// +optional
+	PDBRetryWindow *metav1.Duration `json:"pdbRetryWindow,omitempty"`
+
+	// CoalesceWindow holds a restart until no ConfigMap the restarted pods
+	// use has changed for this long, so ConfigMaps changed together restart
+	// the pods once. The longest window wins.
+	// +optional
+	CoalesceWindow *metav1.Duration `json:"coalesceWindow,omitempty"`
+
+	// RequireApproval holds each restart in a RestartOperation in phase
+	// AwaitingApproval until its spec.approved is set. Any config setting it
+	// requires approval; namespace configs can't lift it.
+	// +optional
+	RequireApproval *bool `json:"requireApproval,omitempty"`
+
+	// ApprovalTimeout expires restarts not approved within this long, leaving
+	// the change unrestarted. Unset waits indefinitely. The shortest wins.
+	// +optional
+	ApprovalTimeout *metav1.Duration `json:"approvalTimeout,omitempty"`
+
+	// NotifyOnly reports changes without restarting pods: the operator emits
+	// a RestartRecommended Event and sets a RestartRecommended condition on
+	// the affected workloads instead. Any cluster config setting it turns
+	// restarts off for the ConfigMaps it applies to; a namespace config setting
+	// it false turns them back on for its namespace.
+	// +optional
+	NotifyOnly *bool `json:"notifyOnly,omitempty"`
+
+	// PodBackup snapshots pods into ConfigMaps before yolo mode deletes them,
+	// so standalone pods lost by an accidental change can be re-created. The
+	// most retentive settings win.
+	// +optional
+	PodBackup *PodBackup `json:"podBackup,omitempty"`
+
+	// YoloModeNamespaces limits this config's YoloMode to ConfigMaps in these
+	// namespaces. Unset applies it wherever the config does. Ignored in
+	// namespace configs, which only apply to their own namespace.
+	// +optional
+	YoloModeNamespaces []string `json:"yoloModeNamespaces,omitempty"`
+
+	// DisableYoloMode keeps batched restarts for the ConfigMaps this config
+	// applies to, even if other configs or a next-change annotation enable
+	// YoloMode.
+	// +optional
+	DisableYoloMode bool `json:"disableYoloMode,omitempty"`
+
+	// RecreateBarePods creates pods without a controller again after
+	// restarting them, from their spec with server-set fields stripped.
+	// Otherwise nothing brings them back. Any cluster config setting it
+	// enables it; a namespace config setting it false turns it off for its
+	// namespace.
+	// +optional
+	RecreateBarePods *bool `json:"recreateBarePods,omitempty"`
+
+	// ExcludeDaemonSets leaves pods owned by DaemonSets running. Unset uses
+	// the operator's --exclude-daemonsets default, which false lifts. True
+	// from any config wins.
+	// +optional
+	ExcludeDaemonSets *bool `json:"excludeDaemonSets,omitempty"`
+
+	// Critical restarts the ConfigMaps this config applies to even while
+	// the operator pauses restarts for node disruptions, such as many
+	// NotReady nodes or a cluster upgrade draining them. Any cluster config
+	// setting it enables it; a namespace config may only set it false, which
+	// turns it off for its namespace.
+	// +optional
+	Critical *bool `json:"critical,omitempty"`
+
+	// SkipOptionalReferences leaves pods alone whose every reference to the
+	// ConfigMap is marked optional, treating it as a soft dependency such as
+	// feature flags or overrides. Any cluster config setting it enables it;
+	// a namespace config setting it false turns it off for its namespace.
+	// +optional
+	SkipOptionalReferences *bool `json:"skipOptionalReferences,omitempty"`
+
+	// RestartByPriority restarts workloads in ascending order of their pods'
+	// PriorityClass value within each restart wave, each priority healthy
+	// before the next, so critical workloads restart last. Any cluster
+	// config setting it enables it; a namespace config setting it false
+	// turns it off for its namespace.
+	// +optional
+	RestartByPriority *bool `json:"restartByPriority,omitempty"`
+
+	// MaxRestartPriority leaves pods with a higher PriorityClass value
+	// running, such as system-cluster-critical ones. The lowest value
+	// across configs wins, so namespace configs can only lower it.
+	// +optional
+	MaxRestartPriority *int32 `json:"maxRestartPriority,omitempty"`
 }
 
+// ServiceProbe checks that a Service answers
+type ServiceProbe struct {
+	// Service name in the ConfigMap's namespace
+	Service string `json:"service"`
+
+	// Port of the Service
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	Port int32 `json:"port"`
+
+	// Path makes the probe an HTTP GET that must return a 2xx status.
+	// Without it the probe only opens a TCP connection.
+	// +optional
+	Path string `json:"path,omitempty"`
+
+	// Timeout of a probe attempt. Defaults to 5s.
+	// +optional
+	Timeout *metav1.Duration `json:"timeout,omitempty"`
+}
+
+// PreRestartJob is a Job that must succeed before pods are restarted, e.g. to
+// validate the new config or drain a queue
+type PreRestartJob struct {
+	// Template the Job is created from
+	// +kubebuilder:pruning:PreserveUnknownFields
+	// +kubebuilder:validation:Schemaless
+	Template batchv1.JobTemplateSpec `json:"template"`
+
+	// Timeout for the Job to succeed. Defaults to 10m.
+	// +optional
+	Timeout *metav1.Duration `json:"timeout,omitempty"`
+}
+
+// PodBackup sets how pod backups are retained
+type PodBackup struct {
+	// Keep is how many backups are kept per ConfigMap. Defaults to 5.
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	Keep int32 `json:"keep,omitempty"`
+
+	// MaxAge deletes backups older than this. Unset keeps them until Keep
+	// newer ones exist.
+	// +optional
+	MaxAge *metav1.Duration `json:"maxAge,omitempty"`
+}
+
+// ChangeDetectionMode selects how ConfigMap versions are computed
+// +kubebuilder:validation:Enum=Full;Keys
+type ChangeDetectionMode string
+
+const (
+	// ChangeDetectionFull treats any change of data or binaryData as a change
+	ChangeDetectionFull ChangeDetectionMode = "Full"
+	// ChangeDetectionKeys only treats changes of the listed keys as a change
+	ChangeDetectionKeys ChangeDetectionMode = "Keys"
+)
+
+// ChangeDetection configures what counts as a change of a ConfigMap
+type ChangeDetection struct {
+	// Mode defaults to Full
+	// +optional
+	Mode ChangeDetectionMode `json:"mode,omitempty"`
+
+	// Keys of data or binaryData compared in Keys mode
+	// +optional
+	Keys []string `json:"keys,omitempty"`
+
+	// Semantic parses YAML and JSON values in data and compares their
+	// content, ignoring whitespace, comments and key order
+	// +optional
+	Semantic bool `json:"semantic,omitempty"`
+}
+
+// NotificationFormat selects the body posted to a notification URL
+// +kubebuilder:validation:Enum=Webhook;Slack
+type NotificationFormat string
+
+const (
+	// NotificationFormatWebhook posts a JSON summary
+	NotificationFormatWebhook NotificationFormat = "Webhook"
+	// NotificationFormatSlack posts a Slack incoming webhook message
+	NotificationFormatSlack NotificationFormat = "Slack"
+)
+
+// Notification is a URL that restart notifications are posted to
+type Notification struct {
+	// URL receives the notifications
+	// +optional
+	URL string `json:"url,omitempty"`
+
+	// URLSecretRef reads the URL from a Secret instead, for URLs that are
+	// credentials themselves like Slack incoming webhooks
+	// +optional
+	URLSecretRef *SecretKeyRef `json:"urlSecretRef,omitempty"`
+
+	// Format of the posted body, defaults to Webhook
+	// +optional
+	Format NotificationFormat `json:"format,omitempty"`
+
+	// AuthSecretRef reads a token sent as "Authorization: Bearer <token>"
+	// +optional
+	AuthSecretRef *SecretKeyRef `json:"authSecretRef,omitempty"`
+
+	// Template is a Go template rendering the message text: the Slack
+	// message, or the summary field of Webhook bodies. It gets the
+	// notification's fields, e.g. {{.ConfigMap}}, {{.Workloads}},
+	// {{.Duration}} and {{.Operation}}. Defaults to a built-in summary.
+	// +optional
+	Template string `json:"template,omitempty"`
+}
+
+// SecretKeyRef selects a key of a Secret
+type SecretKeyRef struct {
+	// Namespace of the Secret. Defaults to the ConfigMap's namespace; always
+	// the config's own namespace in an AutoApplyNamespaceConfig.
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+
+	// Name of the Secret
+	Name string `json:"name"`
+
+	// Key in the Secret's data
+	Key string `json:"key"`
+}
+
+// MaintenanceWindow is a recurring time range in which restarts may run. It
+// opens at Start on Days, or on a cron Schedule for Duration.
+type MaintenanceWindow struct {
+	// Start is the time of day the window opens (HH:MM)
+	// +optional
+	Start string `json:"start,omitempty"`
+
+	// End is the time of day the window closes (HH:MM). An end before the
+	// start crosses midnight.
+	// +optional
+	End string `json:"end,omitempty"`
+
+	// Days limits the window to the weekdays it opens on (Mon, Tue, ...).
+	// Empty means every day.
+	// +optional
+	Days []string `json:"days,omitempty"`
+
+	// TimeZone is the IANA time zone for Start and End, or Schedule,
+	// defaults to UTC
+	// +optional
+	TimeZone string `json:"timeZone,omitempty"`
+
+	// Schedule is a five-field cron expression for when the window opens,
+	// instead of Start, End and Days
+	// +optional
+	Schedule string `json:"schedule,omitempty"`
+
+	// Duration is how long a window opened by Schedule stays open
+	// +optional
+	Duration *metav1.Duration `json:"duration,omitempty"`
+
+	// Exceptions are dates (YYYY-MM-DD, in TimeZone) the window doesn't
+	// open on, such as holidays
+	// +optional
+	Exceptions []string `json:"exceptions,omitempty"`
+}
+
+// ConditionReady reports whether a config's spec is valid
+const ConditionReady = "Ready"
+
 // AutoApplyConfigStatus defines the observed state
 type AutoApplyConfigStatus struct {
 	// LastUpdated is when the config was last applied
 	// +optional
 	LastUpdated metav1.Time `json:"lastUpdated,omitempty"`
+
+	// Conditions report whether the spec is valid (Ready). An invalid entry,
+	// like a pattern that doesn't compile, is ignored by the operator.
+	// +listType=map
+	// +listMapKey=type
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+
+	// ExcludedPods is how many pods currently match ExcludePods
+	// +optional
+	ExcludedPods int32 `json:"excludedPods,omitempty"`
+
+	// ExcludedNamespaces is how many of ExcludeNamespaces currently exist
+	// +optional
+	ExcludedNamespaces int32 `json:"excludedNamespaces,omitempty"`
+
+	// Rollout summarizes config rollout health for the ConfigMaps an
+	// AutoApplyNamespaceConfig applies to. Not set on AutoApplyConfigs.
+	// +optional
+	Rollout *NamespaceRollout `json:"rollout,omitempty"`
+
+	// UpcomingWindows are the next periods of the config's maintenance
+	// windows, starting with any open now. Changes queued outside every
+	// window restart in the first one.
+	// +optional
+	UpcomingWindows []WindowPeriod `json:"upcomingWindows,omitempty"`
+}
+
+// WindowPeriod is one opening of a maintenance window
+type WindowPeriod struct {
+	// Opens is when the window opens
+	Opens metav1.Time `json:"opens"`
+
+	// Closes is when the window closes
+	Closes metav1.Time `json:"closes"`
 }
 
 // +kubebuilder:object:root=true
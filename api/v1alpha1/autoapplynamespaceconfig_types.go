@@ -0,0 +1,69 @@
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:scope=Namespaced
+
+// AutoApplyNamespaceConfig configures the operator for ConfigMaps in its own
+// namespace. Fields it sets override the cluster-wide AutoApplyConfig values;
+// ExcludeNamespaces and IncludeNamespaces are ignored.
+type AutoApplyNamespaceConfig struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   AutoApplyConfigSpec   `json:"spec,omitempty"`
+	Status AutoApplyConfigStatus `json:"status,omitempty"`
+}
+
+// NamespaceRollout is how the ConfigMap changes a namespace config applies
+// to have rolled out, refreshed by the stale config scan
+type NamespaceRollout struct {
+	// FreshWorkloads is how many workloads using the ConfigMaps run their
+	// last handled versions
+	FreshWorkloads int32 `json:"freshWorkloads"`
+
+	// StaleWorkloads is how many workloads still have pods running config
+	// from before a handled change
+	StaleWorkloads int32 `json:"staleWorkloads"`
+
+	// Stale names up to 10 stale workloads as Kind/Name
+	// +optional
+	Stale []string `json:"stale,omitempty"`
+
+	// RecentRestarts are the latest finished restarts, newest first
+	// +optional
+	RecentRestarts []RestartOutcome `json:"recentRestarts,omitempty"`
+}
+
+// RestartOutcome is how a RestartOperation ended
+type RestartOutcome struct {
+	// Operation is the RestartOperation's name
+	Operation string `json:"operation"`
+
+	// ConfigMapName is the ConfigMap whose change it handled
+	ConfigMapName string `json:"configMapName"`
+
+	// Phase is the phase it finished in
+	Phase RestartOperationPhase `json:"phase"`
+
+	// CompletionTime is when it finished
+	// +optional
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// AutoApplyNamespaceConfigList contains a list of AutoApplyNamespaceConfig
+type AutoApplyNamespaceConfigList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []AutoApplyNamespaceConfig `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&AutoApplyNamespaceConfig{}, &AutoApplyNamespaceConfigList{})
+}
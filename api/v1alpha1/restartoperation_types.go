@@ -0,0 +1,184 @@
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// RestartOperationPhase is how far a restart operation got
+// +kubebuilder:validation:Enum=AwaitingApproval;Expired;Running;Succeeded;PartiallyCompleted;Failed
+type RestartOperationPhase string
+
+const (
+	// RestartOperationAwaitingApproval means the restart waits for spec.approved
+	RestartOperationAwaitingApproval RestartOperationPhase = "AwaitingApproval"
+	// RestartOperationExpired means the restart wasn't approved in time, or a
+	// newer change replaced it, and no pod was restarted
+	RestartOperationExpired RestartOperationPhase = "Expired"
+	// RestartOperationRunning means pods are still being restarted
+	RestartOperationRunning RestartOperationPhase = "Running"
+	// RestartOperationSucceeded means every pod was restarted
+	RestartOperationSucceeded RestartOperationPhase = "Succeeded"
+	// RestartOperationPartiallyCompleted means some pods were restarted before an error
+	RestartOperationPartiallyCompleted RestartOperationPhase = "PartiallyCompleted"
+	// RestartOperationFailed means the operation failed before restarting any pod
+	RestartOperationFailed RestartOperationPhase = "Failed"
+)
+
+// RestartOperationSpec describes the ConfigMap change that triggered a restart
+type RestartOperationSpec struct {
+	// ConfigMapName is the ConfigMap, in the same namespace, whose change triggered the restart
+	ConfigMapName string `json:"configMapName"`
+
+	// ConfigMapVersion is the hash of the ConfigMap contents being rolled out
+	// +optional
+	ConfigMapVersion string `json:"configMapVersion,omitempty"`
+
+	// Strategy is the restart strategy in effect
+	// +optional
+	Strategy RestartStrategy `json:"strategy,omitempty"`
+
+	// YoloMode is set when all pods were restarted at once
+	// +optional
+	YoloMode bool `json:"yoloMode,omitempty"`
+
+	// Pods are the pods selected for restart
+	// +optional
+	Pods []string `json:"pods,omitempty"`
+
+	// ChangedKeys are the ConfigMap keys whose change triggered the restart,
+	// unset if the previous contents aren't known
+	// +optional
+	ChangedKeys *ChangedKeys `json:"changedKeys,omitempty"`
+
+	// Approved lets a restart awaiting approval go ahead
+	// +optional
+	Approved bool `json:"approved,omitempty"`
+
+	// ApprovalDeadline is when a restart awaiting approval expires, unset if
+	// it waits indefinitely
+	// +optional
+	ApprovalDeadline *metav1.Time `json:"approvalDeadline,omitempty"`
+}
+
+// ChangedKeys lists the data and binaryData keys a ConfigMap change touched,
+// without their values
+type ChangedKeys struct {
+	// +optional
+	Added []string `json:"added,omitempty"`
+	// +optional
+	Removed []string `json:"removed,omitempty"`
+	// +optional
+	Modified []string `json:"modified,omitempty"`
+}
+
+// RestartBatch records one batch of restarted pods
+type RestartBatch struct {
+	// Time is when the batch finished
+	Time metav1.Time `json:"time"`
+
+	// Pods are the pods restarted in this batch
+	// +optional
+	Pods []string `json:"pods,omitempty"`
+}
+
+// ConditionDegraded reports that replacement pods failed to start, likely
+// because of the new config
+const ConditionDegraded = "Degraded"
+
+// FailingContainer records a replacement pod's container that failed to start
+type FailingContainer struct {
+	// Pod is the replacement pod name
+	Pod string `json:"pod"`
+
+	// Container is the container name
+	Container string `json:"container"`
+
+	// Reason is the container's waiting reason, e.g. CrashLoopBackOff
+	Reason string `json:"reason"`
+
+	// Message is the container's waiting message
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+// SkippedPod records a pod the operation didn't restart
+type SkippedPod struct {
+	// Name is the pod name
+	Name string `json:"name"`
+
+	// Reason is why the pod was skipped, e.g. blocked by PodDisruptionBudget
+	Reason string `json:"reason"`
+}
+
+// RestartOperationStatus records the progress of a restart operation
+type RestartOperationStatus struct {
+	// Phase is how far the operation got
+	// +optional
+	Phase RestartOperationPhase `json:"phase,omitempty"`
+
+	// StartTime is when the operation started
+	// +optional
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+
+	// CompletionTime is when the operation finished
+	// +optional
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+
+	// Batches are the restarted batches, in completion order
+	// +optional
+	Batches []RestartBatch `json:"batches,omitempty"`
+
+	// SkippedPods are the pods left alone during the operation
+	// +optional
+	SkippedPods []SkippedPod `json:"skippedPods,omitempty"`
+
+	// Message describes why the operation didn't succeed
+	// +optional
+	Message string `json:"message,omitempty"`
+
+	// FailingContainers are the replacement pods' containers that failed to
+	// start and aborted the operation
+	// +optional
+	FailingContainers []FailingContainer `json:"failingContainers,omitempty"`
+
+	// Conditions report whether replacement pods failed to start (Degraded)
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+
+	// PropagationTime is how long after the operator saw the ConfigMap change
+	// every selected pod was restarted with it. Unset if the operation didn't
+	// reach every pod, or pods blocked by a PodDisruptionBudget were retried.
+	// +optional
+	PropagationTime *metav1.Duration `json:"propagationTime,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:scope=Namespaced
+// +kubebuilder:printcolumn:name="ConfigMap",type=string,JSONPath=`.spec.configMapName`
+// +kubebuilder:printcolumn:name="Phase",type=string,JSONPath=`.status.phase`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// RestartOperation records one restart cycle triggered by a ConfigMap change
+type RestartOperation struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   RestartOperationSpec   `json:"spec,omitempty"`
+	Status RestartOperationStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// RestartOperationList contains a list of RestartOperation
+type RestartOperationList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []RestartOperation `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&RestartOperation{}, &RestartOperationList{})
+}
@@ -5,7 +5,9 @@
 package v1alpha1
 
 import (
+	"k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
 )
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
@@ -14,7 +16,7 @@ func (in *AutoApplyConfig) DeepCopyInto(out *AutoApplyConfig) {
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	in.Spec.DeepCopyInto(&out.Spec)
-	out.Status = in.Status
+	in.Status.DeepCopyInto(&out.Status)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AutoApplyConfig.
@@ -70,6 +72,11 @@ func (in *AutoApplyConfigList) DeepCopyObject() runtime.Object {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *AutoApplyConfigSpec) DeepCopyInto(out *AutoApplyConfigSpec) {
 	*out = *in
+	if in.ConfigMapSelector != nil {
+		in, out := &in.ConfigMapSelector, &out.ConfigMapSelector
+		*out = new(v1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
 	if in.ExcludePods != nil {
 		in, out := &in.ExcludePods, &out.ExcludePods
 		*out = make([]string, len(*in))
@@ -80,7 +87,164 @@ func (in *AutoApplyConfigSpec) DeepCopyInto(out *AutoApplyConfigSpec) {
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
-	out.YoloMode = in.YoloMode
+	if in.IncludeNamespaces != nil {
+		in, out := &in.IncludeNamespaces, &out.IncludeNamespaces
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.ExcludeConfigMaps != nil {
+		in, out := &in.ExcludeConfigMaps, &out.ExcludeConfigMaps
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	out.DisableDefaultConfigMapExclusions = in.DisableDefaultConfigMapExclusions
+	if in.YoloMode != nil {
+		in, out := &in.YoloMode, &out.YoloMode
+		*out = new(bool)
+		**out = **in
+	}
+	if in.CanarySoakDuration != nil {
+		in, out := &in.CanarySoakDuration, &out.CanarySoakDuration
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	out.TrickleBatchSize = in.TrickleBatchSize
+	if in.TrickleInterval != nil {
+		in, out := &in.TrickleInterval, &out.TrickleInterval
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.SkipRefreshableMounts != nil {
+		in, out := &in.SkipRefreshableMounts, &out.SkipRefreshableMounts
+		*out = new(bool)
+		**out = **in
+	}
+	if in.DryRun != nil {
+		in, out := &in.DryRun, &out.DryRun
+		*out = new(bool)
+		**out = **in
+	}
+	if in.VPAEvictionWindow != nil {
+		in, out := &in.VPAEvictionWindow, &out.VPAEvictionWindow
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.RestartTimeout != nil {
+		in, out := &in.RestartTimeout, &out.RestartTimeout
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.DebounceDuration != nil {
+		in, out := &in.DebounceDuration, &out.DebounceDuration
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.MaintenanceWindows != nil {
+		in, out := &in.MaintenanceWindows, &out.MaintenanceWindows
+		*out = make([]MaintenanceWindow, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Notifications != nil {
+		in, out := &in.Notifications, &out.Notifications
+		*out = make([]Notification, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.PreRestartJob != nil {
+		in, out := &in.PreRestartJob, &out.PreRestartJob
+		*out = new(PreRestartJob)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.VerificationProbes != nil {
+		in, out := &in.VerificationProbes, &out.VerificationProbes
+		*out = make([]ServiceProbe, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.ChangeDetection != nil {
+		in, out := &in.ChangeDetection, &out.ChangeDetection
+		*out = new(ChangeDetection)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.RecentRolloutWindow != nil {
+		in, out := &in.RecentRolloutWindow, &out.RecentRolloutWindow
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.BatchSteps != nil {
+		in, out := &in.BatchSteps, &out.BatchSteps
+		*out = make([]intstr.IntOrString, len(*in))
+		copy(*out, *in)
+	}
+	if in.PDBRetryWindow != nil {
+		in, out := &in.PDBRetryWindow, &out.PDBRetryWindow
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.CoalesceWindow != nil {
+		in, out := &in.CoalesceWindow, &out.CoalesceWindow
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.RequireApproval != nil {
+		in, out := &in.RequireApproval, &out.RequireApproval
+		*out = new(bool)
+		**out = **in
+	}
+	if in.ApprovalTimeout != nil {
+		in, out := &in.ApprovalTimeout, &out.ApprovalTimeout
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.NotifyOnly != nil {
+		in, out := &in.NotifyOnly, &out.NotifyOnly
+		*out = new(bool)
+		**out = **in
+	}
+	if in.PodBackup != nil {
+		in, out := &in.PodBackup, &out.PodBackup
+		*out = new(PodBackup)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.YoloModeNamespaces != nil {
+		in, out := &in.YoloModeNamespaces, &out.YoloModeNamespaces
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.RecreateBarePods != nil {
+		in, out := &in.RecreateBarePods, &out.RecreateBarePods
+		*out = new(bool)
+		**out = **in
+	}
+	if in.ExcludeDaemonSets != nil {
+		in, out := &in.ExcludeDaemonSets, &out.ExcludeDaemonSets
+		*out = new(bool)
+		**out = **in
+	}
+	if in.Critical != nil {
+		in, out := &in.Critical, &out.Critical
+		*out = new(bool)
+		**out = **in
+	}
+	if in.SkipOptionalReferences != nil {
+		in, out := &in.SkipOptionalReferences, &out.SkipOptionalReferences
+		*out = new(bool)
+		**out = **in
+	}
+	if in.RestartByPriority != nil {
+		in, out := &in.RestartByPriority, &out.RestartByPriority
+		*out = new(bool)
+		**out = **in
+	}
+	if in.MaxRestartPriority != nil {
+		in, out := &in.MaxRestartPriority, &out.MaxRestartPriority
+		*out = new(int32)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AutoApplyConfigSpec.
@@ -96,6 +260,26 @@ func (in *AutoApplyConfigSpec) DeepCopy() *AutoApplyConfigSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *AutoApplyConfigStatus) DeepCopyInto(out *AutoApplyConfigStatus) {
 	*out = *in
+	in.LastUpdated.DeepCopyInto(&out.LastUpdated)
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Rollout != nil {
+		in, out := &in.Rollout, &out.Rollout
+		*out = new(NamespaceRollout)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.UpcomingWindows != nil {
+		in, out := &in.UpcomingWindows, &out.UpcomingWindows
+		*out = make([]WindowPeriod, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AutoApplyConfigStatus.
@@ -107,3 +291,497 @@ func (in *AutoApplyConfigStatus) DeepCopy() *AutoApplyConfigStatus {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AutoApplyNamespaceConfig) DeepCopyInto(out *AutoApplyNamespaceConfig) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AutoApplyNamespaceConfig.
+func (in *AutoApplyNamespaceConfig) DeepCopy() *AutoApplyNamespaceConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(AutoApplyNamespaceConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *AutoApplyNamespaceConfig) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AutoApplyNamespaceConfigList) DeepCopyInto(out *AutoApplyNamespaceConfigList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]AutoApplyNamespaceConfig, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AutoApplyNamespaceConfigList.
+func (in *AutoApplyNamespaceConfigList) DeepCopy() *AutoApplyNamespaceConfigList {
+	if in == nil {
+		return nil
+	}
+	out := new(AutoApplyNamespaceConfigList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *AutoApplyNamespaceConfigList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ChangeDetection) DeepCopyInto(out *ChangeDetection) {
+	*out = *in
+	if in.Keys != nil {
+		in, out := &in.Keys, &out.Keys
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ChangeDetection.
+func (in *ChangeDetection) DeepCopy() *ChangeDetection {
+	if in == nil {
+		return nil
+	}
+	out := new(ChangeDetection)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ChangedKeys) DeepCopyInto(out *ChangedKeys) {
+	*out = *in
+	if in.Added != nil {
+		in, out := &in.Added, &out.Added
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Removed != nil {
+		in, out := &in.Removed, &out.Removed
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Modified != nil {
+		in, out := &in.Modified, &out.Modified
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ChangedKeys.
+func (in *ChangedKeys) DeepCopy() *ChangedKeys {
+	if in == nil {
+		return nil
+	}
+	out := new(ChangedKeys)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FailingContainer) DeepCopyInto(out *FailingContainer) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FailingContainer.
+func (in *FailingContainer) DeepCopy() *FailingContainer {
+	if in == nil {
+		return nil
+	}
+	out := new(FailingContainer)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MaintenanceWindow) DeepCopyInto(out *MaintenanceWindow) {
+	*out = *in
+	if in.Days != nil {
+		in, out := &in.Days, &out.Days
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Duration != nil {
+		in, out := &in.Duration, &out.Duration
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.Exceptions != nil {
+		in, out := &in.Exceptions, &out.Exceptions
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MaintenanceWindow.
+func (in *MaintenanceWindow) DeepCopy() *MaintenanceWindow {
+	if in == nil {
+		return nil
+	}
+	out := new(MaintenanceWindow)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NamespaceRollout) DeepCopyInto(out *NamespaceRollout) {
+	*out = *in
+	if in.Stale != nil {
+		in, out := &in.Stale, &out.Stale
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.RecentRestarts != nil {
+		in, out := &in.RecentRestarts, &out.RecentRestarts
+		*out = make([]RestartOutcome, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NamespaceRollout.
+func (in *NamespaceRollout) DeepCopy() *NamespaceRollout {
+	if in == nil {
+		return nil
+	}
+	out := new(NamespaceRollout)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Notification) DeepCopyInto(out *Notification) {
+	*out = *in
+	if in.URLSecretRef != nil {
+		in, out := &in.URLSecretRef, &out.URLSecretRef
+		*out = new(SecretKeyRef)
+		**out = **in
+	}
+	if in.AuthSecretRef != nil {
+		in, out := &in.AuthSecretRef, &out.AuthSecretRef
+		*out = new(SecretKeyRef)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Notification.
+func (in *Notification) DeepCopy() *Notification {
+	if in == nil {
+		return nil
+	}
+	out := new(Notification)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PodBackup) DeepCopyInto(out *PodBackup) {
+	*out = *in
+	if in.MaxAge != nil {
+		in, out := &in.MaxAge, &out.MaxAge
+		*out = new(v1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PodBackup.
+func (in *PodBackup) DeepCopy() *PodBackup {
+	if in == nil {
+		return nil
+	}
+	out := new(PodBackup)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PreRestartJob) DeepCopyInto(out *PreRestartJob) {
+	*out = *in
+	in.Template.DeepCopyInto(&out.Template)
+	if in.Timeout != nil {
+		in, out := &in.Timeout, &out.Timeout
+		*out = new(v1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PreRestartJob.
+func (in *PreRestartJob) DeepCopy() *PreRestartJob {
+	if in == nil {
+		return nil
+	}
+	out := new(PreRestartJob)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RestartBatch) DeepCopyInto(out *RestartBatch) {
+	*out = *in
+	in.Time.DeepCopyInto(&out.Time)
+	if in.Pods != nil {
+		in, out := &in.Pods, &out.Pods
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RestartBatch.
+func (in *RestartBatch) DeepCopy() *RestartBatch {
+	if in == nil {
+		return nil
+	}
+	out := new(RestartBatch)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RestartOperation) DeepCopyInto(out *RestartOperation) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RestartOperation.
+func (in *RestartOperation) DeepCopy() *RestartOperation {
+	if in == nil {
+		return nil
+	}
+	out := new(RestartOperation)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *RestartOperation) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RestartOperationList) DeepCopyInto(out *RestartOperationList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]RestartOperation, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RestartOperationList.
+func (in *RestartOperationList) DeepCopy() *RestartOperationList {
+	if in == nil {
+		return nil
+	}
+	out := new(RestartOperationList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *RestartOperationList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RestartOperationSpec) DeepCopyInto(out *RestartOperationSpec) {
+	*out = *in
+	if in.Pods != nil {
+		in, out := &in.Pods, &out.Pods
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.ChangedKeys != nil {
+		in, out := &in.ChangedKeys, &out.ChangedKeys
+		*out = new(ChangedKeys)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ApprovalDeadline != nil {
+		in, out := &in.ApprovalDeadline, &out.ApprovalDeadline
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RestartOperationSpec.
+func (in *RestartOperationSpec) DeepCopy() *RestartOperationSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(RestartOperationSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RestartOperationStatus) DeepCopyInto(out *RestartOperationStatus) {
+	*out = *in
+	if in.StartTime != nil {
+		in, out := &in.StartTime, &out.StartTime
+		*out = (*in).DeepCopy()
+	}
+	if in.CompletionTime != nil {
+		in, out := &in.CompletionTime, &out.CompletionTime
+		*out = (*in).DeepCopy()
+	}
+	if in.Batches != nil {
+		in, out := &in.Batches, &out.Batches
+		*out = make([]RestartBatch, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.SkippedPods != nil {
+		in, out := &in.SkippedPods, &out.SkippedPods
+		*out = make([]SkippedPod, len(*in))
+		copy(*out, *in)
+	}
+	if in.FailingContainers != nil {
+		in, out := &in.FailingContainers, &out.FailingContainers
+		*out = make([]FailingContainer, len(*in))
+		copy(*out, *in)
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.PropagationTime != nil {
+		in, out := &in.PropagationTime, &out.PropagationTime
+		*out = new(v1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RestartOperationStatus.
+func (in *RestartOperationStatus) DeepCopy() *RestartOperationStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(RestartOperationStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RestartOutcome) DeepCopyInto(out *RestartOutcome) {
+	*out = *in
+	if in.CompletionTime != nil {
+		in, out := &in.CompletionTime, &out.CompletionTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RestartOutcome.
+func (in *RestartOutcome) DeepCopy() *RestartOutcome {
+	if in == nil {
+		return nil
+	}
+	out := new(RestartOutcome)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SecretKeyRef) DeepCopyInto(out *SecretKeyRef) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SecretKeyRef.
+func (in *SecretKeyRef) DeepCopy() *SecretKeyRef {
+	if in == nil {
+		return nil
+	}
+	out := new(SecretKeyRef)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ServiceProbe) DeepCopyInto(out *ServiceProbe) {
+	*out = *in
+	if in.Timeout != nil {
+		in, out := &in.Timeout, &out.Timeout
+		*out = new(v1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ServiceProbe.
+func (in *ServiceProbe) DeepCopy() *ServiceProbe {
+	if in == nil {
+		return nil
+	}
+	out := new(ServiceProbe)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SkippedPod) DeepCopyInto(out *SkippedPod) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SkippedPod.
+func (in *SkippedPod) DeepCopy() *SkippedPod {
+	if in == nil {
+		return nil
+	}
+	out := new(SkippedPod)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *WindowPeriod) DeepCopyInto(out *WindowPeriod) {
+	*out = *in
+	in.Opens.DeepCopyInto(&out.Opens)
+	in.Closes.DeepCopyInto(&out.Closes)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WindowPeriod.
+func (in *WindowPeriod) DeepCopy() *WindowPeriod {
+	if in == nil {
+		return nil
+	}
+	out := new(WindowPeriod)
+	in.DeepCopyInto(out)
+	return out
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/config"
+
+	autoapplyv1alpha1 "github.com/manos/k8s-autoapply-operator/api/v1alpha1"
+)
+
+// workloadBudget is one workload's disruption headroom
+type workloadBudget struct {
+	kind, name     string
+	ready, desired int32
+	// pdbs cover the workload's pods, none if it has no PodDisruptionBudget
+	pdbs []string
+	// allowed is how many of its pods may be disrupted now, the lowest of
+	// its PDBs' or all ready pods without one
+	allowed int32
+}
+
+// budgetReport is what `kubectl autoapply budget` prints for a namespace
+type budgetReport struct {
+	workloads []workloadBudget
+	// inFlight are restarts running or awaiting approval
+	inFlight []autoapplyv1alpha1.RestartOperation
+	// Pods that may be disrupted now, counting each PDB's headroom once
+	pdbDisruptable       int32
+	unprotectedReadyPods int32
+}
+
+func runBudget(args []string) int {
+	if len(args) != 1 {
+		fmt.Fprintln(os.Stderr, "Usage: kubectl autoapply budget <namespace>")
+		return 2
+	}
+
+	cfg, err := config.GetConfig()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+	c, err := client.New(cfg, client.Options{Scheme: scheme})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	report, err := buildBudgetReport(ctx, c, args[0])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+	report.print(os.Stdout, time.Now())
+	return 0
+}
+
+// buildBudgetReport collects the namespace's workloads with the PDBs covering
+// them, and the restarts in flight
+func buildBudgetReport(ctx context.Context, c client.Reader, namespace string) (*budgetReport, error) {
+	var pdbs policyv1.PodDisruptionBudgetList
+	if err := c.List(ctx, &pdbs, client.InNamespace(namespace)); err != nil {
+		return nil, fmt.Errorf("listing PodDisruptionBudgets: %w", err)
+	}
+
+	var deployments appsv1.DeploymentList
+	if err := c.List(ctx, &deployments, client.InNamespace(namespace)); err != nil {
+		return nil, fmt.Errorf("listing Deployments: %w", err)
+	}
+	var statefulSets appsv1.StatefulSetList
+	if err := c.List(ctx, &statefulSets, client.InNamespace(namespace)); err != nil {
+		return nil, fmt.Errorf("listing StatefulSets: %w", err)
+	}
+	var daemonSets appsv1.DaemonSetList
+	if err := c.List(ctx, &daemonSets, client.InNamespace(namespace)); err != nil {
+		return nil, fmt.Errorf("listing DaemonSets: %w", err)
+	}
+
+	report := &budgetReport{}
+	usedPDBs := make(map[string]int32)
+	add := func(kind, name string, template *corev1.PodTemplateSpec, ready, desired int32) {
+		workload := workloadBudget{kind: kind, name: name, ready: ready, desired: desired, allowed: ready}
+		for i := range pdbs.Items {
+			pdb := &pdbs.Items[i]
+			selector, err := metav1.LabelSelectorAsSelector(pdb.Spec.Selector)
+			if err != nil || selector.Empty() || !selector.Matches(labels.Set(template.Labels)) {
+				continue
+			}
+			workload.pdbs = append(workload.pdbs, pdb.Name)
+			workload.allowed = min(workload.allowed, pdb.Status.DisruptionsAllowed)
+			usedPDBs[pdb.Name] = pdb.Status.DisruptionsAllowed
+		}
+		if len(workload.pdbs) == 0 {
+			report.unprotectedReadyPods += ready
+		}
+		report.workloads = append(report.workloads, workload)
+	}
+
+	for i := range deployments.Items {
+		d := &deployments.Items[i]
+		add("Deployment", d.Name, &d.Spec.Template, d.Status.ReadyReplicas, replicas(d.Spec.Replicas))
+	}
+	for i := range statefulSets.Items {
+		s := &statefulSets.Items[i]
+		add("StatefulSet", s.Name, &s.Spec.Template, s.Status.ReadyReplicas, replicas(s.Spec.Replicas))
+	}
+	for i := range daemonSets.Items {
+		d := &daemonSets.Items[i]
+		add("DaemonSet", d.Name, &d.Spec.Template, d.Status.NumberReady, d.Status.DesiredNumberScheduled)
+	}
+	for _, allowed := range usedPDBs {
+		report.pdbDisruptable += allowed
+	}
+
+	var ops autoapplyv1alpha1.RestartOperationList
+	if err := c.List(ctx, &ops, client.InNamespace(namespace)); err != nil {
+		return nil, fmt.Errorf("listing RestartOperations: %w", err)
+	}
+	for _, op := range ops.Items {
+		switch op.Status.Phase {
+		case autoapplyv1alpha1.RestartOperationRunning, autoapplyv1alpha1.RestartOperationAwaitingApproval:
+			report.inFlight = append(report.inFlight, op)
+		}
+	}
+	sort.Slice(report.inFlight, func(i, j int) bool {
+		return report.inFlight[i].CreationTimestamp.Before(&report.inFlight[j].CreationTimestamp)
+	})
+	return report, nil
+}
+
+// replicas returns a workload's desired replicas, defaulting to 1 like the API server
+func replicas(r *int32) int32 {
+	if r == nil {
+		return 1
+	}
+	return *r
+}
+
+func (b *budgetReport) print(out io.Writer, now time.Time) {
+	w := tabwriter.NewWriter(out, 0, 4, 3, ' ', 0)
+
+	fmt.Fprintln(w, "WORKLOAD\tREADY\tPDB\tDISRUPTIONS ALLOWED")
+	for _, workload := range b.workloads {
+		pdbs := "<none>"
+		if len(workload.pdbs) > 0 {
+			pdbs = strings.Join(workload.pdbs, ",")
+		}
+		fmt.Fprintf(w, "%s/%s\t%d/%d\t%s\t%d\n",
+			workload.kind, workload.name, workload.ready, workload.desired, pdbs, workload.allowed)
+	}
+	_ = w.Flush()
+
+	fmt.Fprintln(out)
+	if len(b.inFlight) == 0 {
+		fmt.Fprintln(out, "No restarts in flight.")
+	} else {
+		fmt.Fprintln(w, "RESTART\tCONFIGMAP\tPHASE\tRESTARTED\tAGE")
+		for _, op := range b.inFlight {
+			restarted := 0
+			for _, batch := range op.Status.Batches {
+				restarted += len(batch.Pods)
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%d/%d\t%s\n", op.Name, op.Spec.ConfigMapName, op.Status.Phase,
+				restarted, len(op.Spec.Pods), now.Sub(op.CreationTimestamp.Time).Round(time.Second))
+		}
+		_ = w.Flush()
+	}
+
+	fmt.Fprintln(out)
+	fmt.Fprintf(out, "Pods that can be disrupted right now: %d (%d within PodDisruptionBudgets, %d ready pods without one)\n",
+		b.pdbDisruptable+b.unprotectedReadyPods, b.pdbDisruptable, b.unprotectedReadyPods)
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	autoapplyv1alpha1 "github.com/manos/k8s-autoapply-operator/api/v1alpha1"
+)
+
+func deployment(name string, ready int32) *appsv1.Deployment {
+	replicas := int32(3)
+	return &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "shop"},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: &replicas,
+			Template: corev1.PodTemplateSpec{ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"app": name}}},
+		},
+		Status: appsv1.DeploymentStatus{ReadyReplicas: ready},
+	}
+}
+
+func TestBuildBudgetReport(t *testing.T) {
+	objects := []client.Object{
+		deployment("web", 3),
+		deployment("api", 2),
+		deployment("worker", 3),
+		&policyv1.PodDisruptionBudget{
+			ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "shop"},
+			Spec:       policyv1.PodDisruptionBudgetSpec{Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}}},
+			Status:     policyv1.PodDisruptionBudgetStatus{DisruptionsAllowed: 1},
+		},
+		&policyv1.PodDisruptionBudget{
+			ObjectMeta: metav1.ObjectMeta{Name: "api", Namespace: "shop"},
+			Spec:       policyv1.PodDisruptionBudgetSpec{Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "api"}}},
+		},
+		&autoapplyv1alpha1.RestartOperation{
+			ObjectMeta: metav1.ObjectMeta{Name: "web-config-x2k4p", Namespace: "shop"},
+			Spec:       autoapplyv1alpha1.RestartOperationSpec{ConfigMapName: "web-config", Pods: []string{"web-1", "web-2", "web-3"}},
+			Status: autoapplyv1alpha1.RestartOperationStatus{
+				Phase:   autoapplyv1alpha1.RestartOperationRunning,
+				Batches: []autoapplyv1alpha1.RestartBatch{{Pods: []string{"web-1"}}},
+			},
+		},
+		&autoapplyv1alpha1.RestartOperation{
+			ObjectMeta: metav1.ObjectMeta{Name: "web-config-done", Namespace: "shop"},
+			Status:     autoapplyv1alpha1.RestartOperationStatus{Phase: autoapplyv1alpha1.RestartOperationSucceeded},
+		},
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).Build()
+
+	report, err := buildBudgetReport(context.Background(), c, "shop")
+	if err != nil {
+		t.Fatalf("buildBudgetReport failed: %v", err)
+	}
+
+	allowed := make(map[string]int32)
+	for _, workload := range report.workloads {
+		allowed[workload.name] = workload.allowed
+	}
+	if allowed["web"] != 1 || allowed["api"] != 0 || allowed["worker"] != 3 {
+		t.Errorf("Unexpected disruptions allowed: %v", allowed)
+	}
+	if report.pdbDisruptable != 1 || report.unprotectedReadyPods != 3 {
+		t.Errorf("Expected 1 pod within PDBs and 3 without, got %d and %d", report.pdbDisruptable, report.unprotectedReadyPods)
+	}
+	if len(report.inFlight) != 1 || report.inFlight[0].Name != "web-config-x2k4p" {
+		t.Errorf("Expected only the running restart in flight, got %d", len(report.inFlight))
+	}
+
+	var out bytes.Buffer
+	report.print(&out, time.Now())
+	for _, want := range []string{"Deployment/worker", "<none>", "web-config-x2k4p", "1/3", "disrupted right now: 4"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("Expected %q in output:\n%s", want, out.String())
+		}
+	}
+}
@@ -0,0 +1,49 @@
+// kubectl-autoapply is a kubectl plugin for checking on the operator. Put it
+// on PATH and run `kubectl autoapply <command>`.
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	_ "k8s.io/client-go/plugin/pkg/client/auth"
+
+	autoapplyv1alpha1 "github.com/manos/k8s-autoapply-operator/api/v1alpha1"
+)
+
+var scheme = runtime.NewScheme()
+
+func init() {
+	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
+	utilruntime.Must(autoapplyv1alpha1.AddToScheme(scheme))
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage(os.Stderr)
+		os.Exit(2)
+	}
+
+	switch os.Args[1] {
+	case "budget":
+		os.Exit(runBudget(os.Args[2:]))
+	case "help", "-h", "--help":
+		usage(os.Stdout)
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n", os.Args[1])
+		usage(os.Stderr)
+		os.Exit(2)
+	}
+}
+
+func usage(w io.Writer) {
+	fmt.Fprint(w, `Usage: kubectl autoapply <command>
+
+Commands:
+  budget <namespace>   Show how many pods restarts could disrupt right now
+`)
+}
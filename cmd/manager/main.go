@@ -2,15 +2,23 @@ package main
 
 import (
 	"flag"
+	"fmt"
 	"os"
+	"strings"
+	"time"
 
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
 
 	autoapplyv1alpha1 "github.com/manos/k8s-autoapply-operator/api/v1alpha1"
 	"github.com/manos/k8s-autoapply-operator/internal/controller"
@@ -30,12 +38,97 @@ func main() {
 	var metricsAddr string
 	var probeAddr string
 	var enableLeaderElection bool
+	var notifyWebhookURL string
+	var verificationURL string
+	var maxConcurrentReconciles int
+	var recordOperations bool
+	var preflightChecks bool
+	var serializeNamespaces bool
+	var staleConfigScanInterval time.Duration
+	var staleConfigEvents bool
+	var activityLog bool
+	var maxPodRestartsPerMinute int
+	var maxConcurrentRestarts int
+	var impactWebhook bool
+	var impactWarnPods int
+	var checksumWebhook bool
+	var highChurnChanges int
+	var highChurnWindow time.Duration
+	var restartPolicyURL string
+	var restartPolicyFailOpen bool
+	var excludeDaemonSets bool
+	var maxNotReadyNodes int
+	var maxCordonedNodes int
+	var upgradeConfigMap string
+	var upgradeLease string
+	var detectNodeUpgrades bool
+	var forceDeleteTerminatingAfter time.Duration
+	var metricsLabels string
+	var metricsMaxLabelValues int
+	var namespaceNotificationHosts string
 
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
 	flag.BoolVar(&enableLeaderElection, "leader-elect", false,
 		"Enable leader election for controller manager. "+
 			"Enabling this will ensure there is only one active controller manager.")
+	flag.StringVar(&notifyWebhookURL, "notify-webhook-url", "",
+		"If set, a JSON summary is POSTed to this URL whenever a restart operation completes.")
+	flag.StringVar(&verificationURL, "verification-url", "",
+		"If set, this URL is probed after every restart batch and a non-2xx response aborts the restart.")
+	flag.StringVar(&restartPolicyURL, "restart-policy-url", "",
+		"If set, every restart batch is POSTed to this URL, which allows it, denies it or drops pods from it.")
+	flag.BoolVar(&restartPolicyFailOpen, "restart-policy-fail-open", false,
+		"Restart batches the --restart-policy-url endpoint couldn't decide on instead of denying them.")
+	flag.IntVar(&maxConcurrentReconciles, "max-concurrent-reconciles", 1,
+		"How many ConfigMap changes are handled in parallel. A single ConfigMap is never handled concurrently.")
+	flag.BoolVar(&recordOperations, "record-restart-operations", true,
+		"Record every restart as a RestartOperation in the ConfigMap's namespace.")
+	flag.BoolVar(&preflightChecks, "preflight-checks", true,
+		"Check the permissions a restart needs before starting it and report all missing ones at once.")
+	flag.BoolVar(&serializeNamespaces, "serialize-namespace-restarts", true,
+		"Run at most one restart per namespace at a time, even with --max-concurrent-reconciles above 1.")
+	flag.IntVar(&maxPodRestartsPerMinute, "max-pod-restarts-per-minute", 0,
+		"Restart at most this many pods per minute across the whole cluster. 0 means unlimited.")
+	flag.IntVar(&maxConcurrentRestarts, "max-concurrent-restarts", 0,
+		"Run at most this many restart operations at once across all namespaces. 0 means unlimited.")
+	flag.BoolVar(&impactWebhook, "configmap-impact-webhook", false,
+		"Serve a validating webhook that warns about the restarts a ConfigMap edit will trigger. Needs serving certificates.")
+	flag.IntVar(&impactWarnPods, "configmap-impact-warn-pods", 1,
+		"Warn about ConfigMap edits that restart at least this many pods.")
+	flag.BoolVar(&checksumWebhook, "config-checksum-webhook", false,
+		"Serve a mutating webhook that stamps Deployment and StatefulSet pod templates with a checksum of their ConfigMaps and Secrets. Needs serving certificates.")
+	flag.IntVar(&highChurnChanges, "high-churn-changes", 10,
+		"Suppress restarts for ConfigMaps changing more than this many times within --high-churn-window "+
+			"until they settle. 0 disables suppression.")
+	flag.DurationVar(&highChurnWindow, "high-churn-window", 5*time.Minute,
+		"Window in which ConfigMap changes are counted for --high-churn-changes.")
+	flag.DurationVar(&staleConfigScanInterval, "stale-config-scan-interval", 10*time.Minute,
+		"How often to look for pods still running config from before a handled change. 0 disables the scan.")
+	flag.BoolVar(&staleConfigEvents, "stale-config-events", false,
+		"Emit a StaleConfig Event on ConfigMaps whose pods still run stale config.")
+	flag.BoolVar(&excludeDaemonSets, "exclude-daemonsets", false,
+		"Never restart DaemonSet pods, unless a config sets excludeDaemonSets to false.")
+	flag.IntVar(&maxNotReadyNodes, "max-not-ready-nodes", 0,
+		"Pause restarts of non-critical configs while more nodes than this are NotReady. 0 disables the check.")
+	flag.IntVar(&maxCordonedNodes, "max-cordoned-nodes", 0,
+		"Pause restarts of non-critical configs while more nodes than this are cordoned, as during a cluster upgrade. 0 disables the check.")
+	flag.StringVar(&upgradeConfigMap, "upgrade-configmap", "",
+		"namespace/name of a ConfigMap whose existence signals a cluster upgrade, pausing restarts of non-critical configs.")
+	flag.StringVar(&upgradeLease, "upgrade-lease", "",
+		"namespace/name of a Lease that, while held, signals a cluster upgrade, pausing restarts of non-critical configs.")
+	flag.BoolVar(&detectNodeUpgrades, "detect-node-upgrades", false,
+		"Treat nodes running different kubelet versions as a cluster upgrade, pausing restarts of non-critical configs.")
+	flag.DurationVar(&forceDeleteTerminatingAfter, "force-delete-terminating-after", 0,
+		"Force-delete restarted pods still terminating this long past their grace period, e.g. on an unreachable node. 0 never does.")
+	flag.StringVar(&metricsLabels, "metrics-labels", "namespace,configmap",
+		"Comma-separated labels per-ConfigMap metrics keep, out of namespace and configmap. Dropped labels aggregate their series.")
+	flag.IntVar(&metricsMaxLabelValues, "metrics-max-label-values", 0,
+		"Most distinct values per metrics label; further ones are aggregated into \"other\". 0 means unlimited.")
+	flag.BoolVar(&activityLog, "activity-log", false,
+		"Write every restart action as a versioned JSON line to stdout, separate from the operator's logs on stderr.")
+	flag.StringVar(&namespaceNotificationHosts, "namespace-notification-hosts", "",
+		"Comma-separated hosts AutoApplyNamespaceConfig notifications may be sent to. Notifications of namespace configs to other hosts are dropped.")
 
 	opts := zap.Options{
 		Development: true,
@@ -45,22 +138,114 @@ func main() {
 
 	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
 
+	var labels []string
+	if metricsLabels != "" {
+		labels = strings.Split(metricsLabels, ",")
+	}
+	if err := controller.ConfigureMetrics(labels, metricsMaxLabelValues); err != nil {
+		setupLog.Error(err, "invalid metrics flags")
+		os.Exit(1)
+	}
+	var notificationHosts []string
+	if namespaceNotificationHosts != "" {
+		notificationHosts = strings.Split(namespaceNotificationHosts, ",")
+	}
+	upgradeConfigMapKey, err := parseNamespacedName(upgradeConfigMap)
+	if err != nil {
+		setupLog.Error(err, "invalid --upgrade-configmap")
+		os.Exit(1)
+	}
+	upgradeLeaseKey, err := parseNamespacedName(upgradeLease)
+	if err != nil {
+		setupLog.Error(err, "invalid --upgrade-lease")
+		os.Exit(1)
+	}
+
 	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
 		Scheme:                 scheme,
 		HealthProbeBindAddress: probeAddr,
 		LeaderElection:         enableLeaderElection,
 		LeaderElectionID:       "autoapply.io",
+		// Secrets are only read for notifications, don't cache them cluster-wide
+		Client: client.Options{
+			Cache: &client.CacheOptions{DisableFor: []client.Object{&corev1.Secret{}}},
+		},
 	})
 	if err != nil {
 		setupLog.Error(err, "unable to start manager")
 		os.Exit(1)
 	}
 
-	if err = (&controller.ConfigMapReconciler{
+	var hooks []controller.RestartHook
+	if notifyWebhookURL != "" {
+		hooks = append(hooks, &controller.WebhookNotifier{URL: notifyWebhookURL})
+	}
+	if verificationURL != "" {
+		hooks = append(hooks, &controller.VerificationProbe{URL: verificationURL})
+	}
+	if activityLog {
+		hooks = append(hooks, &controller.ActivityLog{})
+	}
+
+	var policy *controller.RestartPolicy
+	if restartPolicyURL != "" {
+		policy = &controller.RestartPolicy{URL: restartPolicyURL, FailOpen: restartPolicyFailOpen}
+	}
+
+	reconciler := &controller.ConfigMapReconciler{
+		Client:   mgr.GetClient(),
+		Scheme:   mgr.GetScheme(),
+		Recorder: mgr.GetEventRecorderFor("autoapply-controller"),
+		Hooks:    hooks,
+		Policy:   policy,
+
+		MaxConcurrentReconciles:     maxConcurrentReconciles,
+		RecordOperations:            recordOperations,
+		PreflightChecks:             preflightChecks,
+		SerializeNamespaces:         serializeNamespaces,
+		StaleConfigScanInterval:     staleConfigScanInterval,
+		StaleConfigEvents:           staleConfigEvents,
+		MaxPodRestartsPerMinute:     maxPodRestartsPerMinute,
+		MaxConcurrentRestarts:       maxConcurrentRestarts,
+		HighChurnChanges:            highChurnChanges,
+		HighChurnWindow:             highChurnWindow,
+		ExcludeDaemonSets:           excludeDaemonSets,
+		MaxNotReadyNodes:            maxNotReadyNodes,
+		MaxCordonedNodes:            maxCordonedNodes,
+		UpgradeConfigMap:            upgradeConfigMapKey,
+		UpgradeLease:                upgradeLeaseKey,
+		DetectNodeUpgrades:          detectNodeUpgrades,
+		ForceDeleteTerminatingAfter: forceDeleteTerminatingAfter,
+		NamespaceNotificationHosts:  notificationHosts,
+	}
+	if err = reconciler.SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "ConfigMap")
+		os.Exit(1)
+	}
+
+	if impactWebhook {
+		mgr.GetWebhookServer().Register(controller.ImpactWebhookPath, &webhook.Admission{
+			Handler: &controller.ConfigMapImpactWarner{
+				Reconciler: reconciler,
+				Decoder:    admission.NewDecoder(mgr.GetScheme()),
+				WarnPods:   impactWarnPods,
+			},
+		})
+	}
+	if checksumWebhook {
+		mgr.GetWebhookServer().Register(controller.ChecksumWebhookPath, &webhook.Admission{
+			Handler: &controller.ChecksumInjector{
+				Client:  mgr.GetClient(),
+				Decoder: admission.NewDecoder(mgr.GetScheme()),
+			},
+		})
+	}
+
+	if err = (&controller.AutoApplyConfigReconciler{
 		Client: mgr.GetClient(),
 		Scheme: mgr.GetScheme(),
 	}).SetupWithManager(mgr); err != nil {
-		setupLog.Error(err, "unable to create controller", "controller", "ConfigMap")
+		setupLog.Error(err, "unable to create controller", "controller", "AutoApplyConfig")
 		os.Exit(1)
 	}
 
@@ -79,3 +264,15 @@ func main() {
 		os.Exit(1)
 	}
 }
+
+// parseNamespacedName parses a namespace/name flag, empty if unset
+func parseNamespacedName(value string) (types.NamespacedName, error) {
+	if value == "" {
+		return types.NamespacedName{}, nil
+	}
+	namespace, name, ok := strings.Cut(value, "/")
+	if !ok || namespace == "" || name == "" {
+		return types.NamespacedName{}, fmt.Errorf("expected namespace/name, got %q", value)
+	}
+	return types.NamespacedName{Namespace: namespace, Name: name}, nil
+}
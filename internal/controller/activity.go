@@ -0,0 +1,97 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// ActivitySchemaVersion identifies the layout of ActivityLog records. It only
+// changes when fields are removed or change meaning; new optional fields
+// keep the version.
+const ActivitySchemaVersion = "autoapply.io/activity/v1"
+
+// Actions reported in ActivityLog records
+const (
+	ActivityBatchStarted     = "BatchStarted"
+	ActivityBatchRestarted   = "BatchRestarted"
+	ActivityPodSkipped       = "PodSkipped"
+	ActivityRestartCompleted = "RestartCompleted"
+	ActivityRestartFailed    = "RestartFailed"
+)
+
+// ActivityRecord is one line of the activity stream
+type ActivityRecord struct {
+	SchemaVersion string    `json:"schemaVersion"`
+	Time          time.Time `json:"time"`
+	Action        string    `json:"action"`
+	Namespace     string    `json:"namespace"`
+	ConfigMap     string    `json:"configMap"`
+	Pods          []string  `json:"pods,omitempty"`
+	Reason        string    `json:"reason,omitempty"`
+	Error         string    `json:"error,omitempty"`
+}
+
+// ActivityLog writes every restart action as a JSON line to Writer, so log
+// pipelines can parse them without matching human-oriented log messages
+type ActivityLog struct {
+	// Writer receives the records, os.Stdout if nil
+	Writer io.Writer
+
+	mu sync.Mutex
+}
+
+func (a *ActivityLog) BeforeBatch(ctx context.Context, configMap *corev1.ConfigMap, pods []corev1.Pod) error {
+	a.write(ctx, configMap, ActivityRecord{Action: ActivityBatchStarted, Pods: podNames(pods)})
+	return nil
+}
+
+func (a *ActivityLog) AfterBatch(ctx context.Context, configMap *corev1.ConfigMap, restarted []corev1.Pod) error {
+	a.write(ctx, configMap, ActivityRecord{Action: ActivityBatchRestarted, Pods: podNames(restarted)})
+	return nil
+}
+
+func (a *ActivityLog) OnSkip(ctx context.Context, configMap *corev1.ConfigMap, pod *corev1.Pod, reason string) {
+	a.write(ctx, configMap, ActivityRecord{Action: ActivityPodSkipped, Pods: []string{pod.Name}, Reason: reason})
+}
+
+func (a *ActivityLog) OnComplete(ctx context.Context, configMap *corev1.ConfigMap, err error) {
+	record := ActivityRecord{Action: ActivityRestartCompleted}
+	if err != nil {
+		record.Action = ActivityRestartFailed
+		record.Error = err.Error()
+	}
+	a.write(ctx, configMap, record)
+}
+
+// write stamps and encodes one record. Records are written whole, one per
+// line, even when restarts run concurrently.
+func (a *ActivityLog) write(ctx context.Context, configMap *corev1.ConfigMap, record ActivityRecord) {
+	record.SchemaVersion = ActivitySchemaVersion
+	record.Time = time.Now().UTC()
+	record.Namespace = configMap.Namespace
+	record.ConfigMap = configMap.Name
+
+	line, err := json.Marshal(record)
+	if err != nil {
+		log.FromContext(ctx).Error(err, "Failed to encode activity record")
+		return
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	w := a.Writer
+	if w == nil {
+		w = os.Stdout
+	}
+	if _, err := w.Write(append(line, '\n')); err != nil {
+		log.FromContext(ctx).Error(err, "Failed to write activity record")
+	}
+}
@@ -0,0 +1,57 @@
+package controller
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestActivityLog(t *testing.T) {
+	var out bytes.Buffer
+	activity := &ActivityLog{Writer: &out}
+	ctx := context.Background()
+
+	cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "app-config", Namespace: "default"}}
+	pods := []corev1.Pod{{ObjectMeta: metav1.ObjectMeta{Name: "app-1"}}}
+
+	_ = activity.BeforeBatch(ctx, cm, pods)
+	_ = activity.AfterBatch(ctx, cm, pods)
+	activity.OnSkip(ctx, cm, &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "app-2"}}, "excluded by pattern")
+	activity.OnComplete(ctx, cm, errors.New("boom"))
+
+	var records []ActivityRecord
+	scanner := bufio.NewScanner(&out)
+	for scanner.Scan() {
+		var record ActivityRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			t.Fatalf("Line is not JSON: %q", scanner.Text())
+		}
+		records = append(records, record)
+	}
+
+	expected := []string{ActivityBatchStarted, ActivityBatchRestarted, ActivityPodSkipped, ActivityRestartFailed}
+	if len(records) != len(expected) {
+		t.Fatalf("Expected %d records, got %d", len(expected), len(records))
+	}
+	for i, record := range records {
+		if record.Action != expected[i] {
+			t.Errorf("Record %d: expected action %s, got %s", i, expected[i], record.Action)
+		}
+		if record.SchemaVersion != ActivitySchemaVersion || record.Namespace != "default" ||
+			record.ConfigMap != "app-config" || record.Time.IsZero() {
+			t.Errorf("Record %d missing common fields: %+v", i, record)
+		}
+	}
+	if records[2].Reason != "excluded by pattern" || records[2].Pods[0] != "app-2" {
+		t.Errorf("Unexpected skip record: %+v", records[2])
+	}
+	if records[3].Error != "boom" {
+		t.Errorf("Expected error in failure record, got %q", records[3].Error)
+	}
+}
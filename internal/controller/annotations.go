@@ -0,0 +1,145 @@
+package controller
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	// excludeAnnotation opts a pod or workload out of auto-restarts when set to "true"
+	excludeAnnotation = "autoapply.io/exclude"
+	// lastSeenVersionAnnotation records the last handled data hash on a ConfigMap
+	lastSeenVersionAnnotation = "autoapply.io/last-seen-version"
+	// appliedAtAnnotation records when the last handled version began rolling
+	// out; pods using the ConfigMap created before it run stale config
+	appliedAtAnnotation = "autoapply.io/applied-at"
+	// reloadStrategyAnnotation set to "none" on a pod or workload means it
+	// reloads mounted config itself and shouldn't be restarted
+	reloadStrategyAnnotation = "autoapply.io/reload-strategy"
+	// nextChangeStrategyAnnotation on a ConfigMap overrides the restart strategy
+	// for the next detected change only
+	nextChangeStrategyAnnotation = "autoapply.io/next-change-strategy"
+	// protectedAnnotation set to "true" on a pod or workload makes the impact
+	// webhook warn about every ConfigMap edit that restarts it
+	protectedAnnotation = "autoapply.io/protected"
+	// drainSecondsAnnotation on a pod or workload is how long to let an
+	// evicted pod drain connections before the next pod of its batch goes
+	drainSecondsAnnotation = "autoapply.io/drain-seconds"
+	// configChecksumAnnotation on a pod template holds the hash of the
+	// ConfigMaps and Secrets it references, set by the checksum webhook
+	configChecksumAnnotation = "autoapply.io/config-checksum"
+	// coexistenceAnnotation on a pod or workload decides what happens when
+	// another restart operator also acts on its ConfigMaps: "defer" (default)
+	// leaves the restart to it, "warn" restarts with a Warning Event, "ignore"
+	// restarts silently
+	coexistenceAnnotation = "autoapply.io/coexistence"
+)
+
+// +kubebuilder:rbac:groups=apps,resources=deployments;statefulsets;daemonsets,verbs=get;list;watch
+// +kubebuilder:rbac:groups=batch,resources=jobs,verbs=get;list;watch
+
+// workloadAnnotationCache caches resolved workload annotations for one reconcile
+type workloadAnnotationCache map[workloadRef]map[string]string
+
+// resolvePodAnnotations returns the autoapply settings that apply to a pod.
+// Annotations are merged from the owning workload's metadata, its pod template,
+// and the pod itself, with the most specific one winning.
+func (r *ConfigMapReconciler) resolvePodAnnotations(ctx context.Context, pod *corev1.Pod, cache workloadAnnotationCache) map[string]string {
+	result := make(map[string]string)
+
+	workload, err := r.resolveWorkload(ctx, pod)
+	if err == nil && workload != nil {
+		annotations, ok := cache[*workload]
+		if !ok {
+			annotations = r.workloadAnnotations(ctx, pod.Namespace, workload)
+			cache[*workload] = annotations
+		}
+		for k, v := range annotations {
+			result[k] = v
+		}
+	}
+
+	for k, v := range pod.Annotations {
+		result[k] = v
+	}
+
+	return result
+}
+
+// workloadAnnotations returns a workload's own annotations overlaid with its
+// pod template annotations. Unknown kinds or lookup failures return nil.
+func (r *ConfigMapReconciler) workloadAnnotations(ctx context.Context, namespace string, workload *workloadRef) map[string]string {
+	key := types.NamespacedName{Namespace: namespace, Name: workload.Name}
+
+	var objMeta, templateMeta metav1.ObjectMeta
+	switch workload.Kind {
+	case "Deployment":
+		var obj appsv1.Deployment
+		if err := r.Get(ctx, key, &obj); err != nil {
+			return nil
+		}
+		objMeta, templateMeta = obj.ObjectMeta, obj.Spec.Template.ObjectMeta
+	case "StatefulSet":
+		var obj appsv1.StatefulSet
+		if err := r.Get(ctx, key, &obj); err != nil {
+			return nil
+		}
+		objMeta, templateMeta = obj.ObjectMeta, obj.Spec.Template.ObjectMeta
+	case "DaemonSet":
+		var obj appsv1.DaemonSet
+		if err := r.Get(ctx, key, &obj); err != nil {
+			return nil
+		}
+		objMeta, templateMeta = obj.ObjectMeta, obj.Spec.Template.ObjectMeta
+	case "ReplicaSet":
+		var obj appsv1.ReplicaSet
+		if err := r.Get(ctx, key, &obj); err != nil {
+			return nil
+		}
+		objMeta, templateMeta = obj.ObjectMeta, obj.Spec.Template.ObjectMeta
+	case "Job":
+		var obj batchv1.Job
+		if err := r.Get(ctx, key, &obj); err != nil {
+			return nil
+		}
+		objMeta, templateMeta = obj.ObjectMeta, obj.Spec.Template.ObjectMeta
+	default:
+		return nil
+	}
+
+	result := make(map[string]string)
+	for k, v := range objMeta.Annotations {
+		result[k] = v
+	}
+	for k, v := range templateMeta.Annotations {
+		result[k] = v
+	}
+	return result
+}
+
+// isAnnotatedExcluded checks if resolved annotations opt the pod out of restarts
+func isAnnotatedExcluded(annotations map[string]string) bool {
+	return annotations[excludeAnnotation] == "true"
+}
+
+// reloadsConfigItself checks if resolved annotations mark the pod as hot reloading
+func reloadsConfigItself(annotations map[string]string) bool {
+	return annotations[reloadStrategyAnnotation] == "none"
+}
+
+// drainDelay returns how long resolved annotations ask to wait after evicting
+// the pod; invalid or negative values mean no delay
+func drainDelay(annotations map[string]string) time.Duration {
+	seconds, err := strconv.Atoi(annotations[drainSecondsAnnotation])
+	if err != nil || seconds <= 0 {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
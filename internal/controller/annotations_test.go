@@ -0,0 +1,251 @@
+package controller
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// createDeploymentWithReplicaSet creates a Deployment and a ReplicaSet it controls,
+// returning owner references for pods of that ReplicaSet
+func createDeploymentWithReplicaSet(ctx context.Context, c client.Client, deploy *appsv1.Deployment) []metav1.OwnerReference {
+	trueVal := true
+	_ = c.Create(ctx, deploy)
+
+	rs := &appsv1.ReplicaSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      deploy.Name + "-abc",
+			Namespace: deploy.Namespace,
+			OwnerReferences: []metav1.OwnerReference{
+				{APIVersion: "apps/v1", Kind: "Deployment", Name: deploy.Name, UID: deploy.UID, Controller: &trueVal},
+			},
+		},
+	}
+	_ = c.Create(ctx, rs)
+
+	return []metav1.OwnerReference{
+		{APIVersion: "apps/v1", Kind: "ReplicaSet", Name: rs.Name, UID: rs.UID, Controller: &trueVal},
+	}
+}
+
+func TestResolvePodAnnotations(t *testing.T) {
+	r, fakeClient := setupTestReconciler()
+	ctx := context.Background()
+
+	deploy := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "web",
+			Namespace:   "default",
+			Annotations: map[string]string{excludeAnnotation: "true", "from": "deployment"},
+		},
+		Spec: appsv1.DeploymentSpec{
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{"from": "template", "template-only": "yes"},
+				},
+			},
+		},
+	}
+	ownerRefs := createDeploymentWithReplicaSet(ctx, fakeClient, deploy)
+
+	tests := []struct {
+		name           string
+		podAnnotations map[string]string
+		expected       map[string]string
+	}{
+		{
+			name: "inherits workload and template annotations",
+			expected: map[string]string{
+				excludeAnnotation: "true",
+				"from":            "template",
+				"template-only":   "yes",
+			},
+		},
+		{
+			name:           "pod annotations win",
+			podAnnotations: map[string]string{excludeAnnotation: "false", "from": "pod"},
+			expected: map[string]string{
+				excludeAnnotation: "false",
+				"from":            "pod",
+				"template-only":   "yes",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pod := &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:            "web-abc-1",
+					Namespace:       "default",
+					Annotations:     tt.podAnnotations,
+					OwnerReferences: ownerRefs,
+				},
+			}
+
+			result := r.resolvePodAnnotations(ctx, pod, make(workloadAnnotationCache))
+			if len(result) != len(tt.expected) {
+				t.Errorf("resolvePodAnnotations() = %v, expected %v", result, tt.expected)
+			}
+			for k, v := range tt.expected {
+				if result[k] != v {
+					t.Errorf("annotation %s = %q, expected %q", k, result[k], v)
+				}
+			}
+		})
+	}
+}
+
+func TestFindPodsUsingConfigMap_WorkloadExcludeAnnotation(t *testing.T) {
+	r, fakeClient := setupTestReconciler()
+	ctx := context.Background()
+
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-config", Namespace: "default"},
+	}
+
+	deploy := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "excluded",
+			Namespace:   "default",
+			Annotations: map[string]string{excludeAnnotation: "true"},
+		},
+	}
+	ownerRefs := createDeploymentWithReplicaSet(ctx, fakeClient, deploy)
+
+	spec := corev1.PodSpec{
+		Containers: []corev1.Container{{Name: "app", Image: "nginx"}},
+		Volumes: []corev1.Volume{{
+			Name: "config",
+			VolumeSource: corev1.VolumeSource{
+				ConfigMap: &corev1.ConfigMapVolumeSource{
+					LocalObjectReference: corev1.LocalObjectReference{Name: "test-config"},
+				},
+			},
+		}},
+	}
+
+	_ = fakeClient.Create(ctx, &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "excluded-abc-1", Namespace: "default", OwnerReferences: ownerRefs},
+		Spec:       spec,
+		Status:     corev1.PodStatus{Phase: corev1.PodRunning},
+	})
+	_ = fakeClient.Create(ctx, &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "opted-out",
+			Namespace:   "default",
+			Annotations: map[string]string{excludeAnnotation: "true"},
+		},
+		Spec:   spec,
+		Status: corev1.PodStatus{Phase: corev1.PodRunning},
+	})
+	_ = fakeClient.Create(ctx, &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "normal", Namespace: "default"},
+		Spec:       spec,
+		Status:     corev1.PodStatus{Phase: corev1.PodRunning},
+	})
+
+	pods := r.findPodsUsingConfigMap(ctx, cm, operatorConfig{})
+
+	if len(pods) != 1 || pods[0].Name != "normal" {
+		t.Errorf("Expected only normal pod, got %v", podNames(pods))
+	}
+}
+
+func TestFindPodsUsingConfigMap_HotReload(t *testing.T) {
+	r, fakeClient := setupTestReconciler()
+	ctx := context.Background()
+
+	deploy := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "nginx", Namespace: "default"},
+		Spec: appsv1.DeploymentSpec{
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{reloadStrategyAnnotation: "none"},
+				},
+			},
+		},
+	}
+	ownerRefs := createDeploymentWithReplicaSet(ctx, fakeClient, deploy)
+
+	reloading := configVolumePod("nginx-1", "")
+	reloading.OwnerReferences = ownerRefs
+	_ = fakeClient.Create(ctx, reloading)
+	_ = fakeClient.Create(ctx, configVolumePod("app-1", ""))
+
+	cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "my-config", Namespace: "default"}}
+	pods := r.findPodsUsingConfigMap(ctx, cm, operatorConfig{})
+	if len(pods) != 1 || pods[0].Name != "app-1" {
+		t.Errorf("Expected only app-1 to be restarted, got %v", podNames(pods))
+	}
+
+	if skipped := testutil.ToFloat64(hotReloadPods.WithLabelValues("default", "my-config")); skipped != 1 {
+		t.Errorf("Expected 1 hot reloading pod in metrics, got %v", skipped)
+	}
+	if event := <-r.Recorder.(*record.FakeRecorder).Events; !strings.HasPrefix(event, "Normal SkippedHotReload") {
+		t.Errorf("Unexpected event: %s", event)
+	}
+}
+
+func TestDrainDelay(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected time.Duration
+	}{
+		{"30", 30 * time.Second},
+		{"", 0},
+		{"0", 0},
+		{"-5", 0},
+		{"30s", 0},
+	}
+	for _, tt := range tests {
+		if got := drainDelay(map[string]string{drainSecondsAnnotation: tt.value}); got != tt.expected {
+			t.Errorf("drainDelay(%q) = %s, expected %s", tt.value, got, tt.expected)
+		}
+	}
+}
+
+func TestEvictPods_WaitsForDrain(t *testing.T) {
+	r, fakeClient := setupTestReconciler()
+	ctx := context.Background()
+
+	deploy := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "sessions",
+			Namespace:   "default",
+			Annotations: map[string]string{drainSecondsAnnotation: "30"},
+		},
+	}
+	ownerRefs := createDeploymentWithReplicaSet(ctx, fakeClient, deploy)
+
+	var pods []corev1.Pod
+	for _, name := range []string{"sessions-a", "sessions-b"} {
+		pod := podUsingConfigMap(name, "test-config", time.Now())
+		pod.OwnerReferences = ownerRefs
+		_ = fakeClient.Create(ctx, pod)
+		pods = append(pods, *pod)
+	}
+
+	// The first pod drains before the second goes
+	pass := r.evictPods(ctx, &corev1.ConfigMap{}, pods, false)
+	if len(pass.restarted) != 1 || pass.restarted[0].Name != "sessions-a" {
+		t.Fatalf("Expected only sessions-a to be restarted, got %v", podNames(pass.restarted))
+	}
+	if len(pass.held) != 1 || pass.held[0].Name != "sessions-b" || pass.wait != 30*time.Second {
+		t.Errorf("Expected sessions-b to be held for 30s, got %v for %s", podNames(pass.held), pass.wait)
+	}
+
+	// The last pod of a batch holds nothing back
+	pass = r.evictPods(ctx, &corev1.ConfigMap{}, pass.held, false)
+	if len(pass.restarted) != 1 || len(pass.held) != 0 || pass.wait != 0 {
+		t.Errorf("Expected sessions-b to be restarted without a wait, got %v held for %s", podNames(pass.held), pass.wait)
+	}
+}
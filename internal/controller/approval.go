@@ -0,0 +1,170 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	autoapplyv1alpha1 "github.com/manos/k8s-autoapply-operator/api/v1alpha1"
+)
+
+const (
+	// approvedAnnotation set to "true" on a RestartOperation approves it, like spec.approved
+	approvedAnnotation = "autoapply.io/approved"
+	// How often a restart awaiting approval checks whether it was approved
+	approvalPollInterval = 15 * time.Second
+)
+
+// awaitApproval holds the change to the ConfigMap version until a
+// RestartOperation listing the planned pods is approved. It returns true once
+// approved, with the operation taking over as the in-progress one; otherwise
+// the result says when to check again.
+func (r *ConfigMapReconciler) awaitApproval(ctx context.Context, cfg operatorConfig, configMap *corev1.ConfigMap, version string) (bool, ctrl.Result) {
+	logger := log.FromContext(ctx)
+	key := client.ObjectKeyFromObject(configMap).String()
+
+	// Approved earlier, and held up since by a busy namespace or the like
+	if value, ok := r.operations.Load(key); ok && value.(*operationRecord).op.Spec.ConfigMapVersion == version {
+		return true, ctrl.Result{}
+	}
+
+	if !r.RecordOperations {
+		logger.Info("Approval required but RestartOperations aren't recorded, holding restart")
+		r.Recorder.Event(configMap, corev1.EventTypeWarning, "ApprovalUnavailable",
+			"Restarts require approval, which needs --record-restart-operations")
+		return false, ctrl.Result{}
+	}
+
+	var ops autoapplyv1alpha1.RestartOperationList
+	if err := r.List(ctx, &ops, client.InNamespace(configMap.Namespace),
+		client.MatchingLabels{operationConfigMapLabel: configMap.Name}); err != nil {
+		logger.Error(err, "Failed to list RestartOperations")
+		return false, ctrl.Result{RequeueAfter: approvalPollInterval}
+	}
+
+	var request *autoapplyv1alpha1.RestartOperation
+	for i := range ops.Items {
+		op := &ops.Items[i]
+		if op.Status.Phase != autoapplyv1alpha1.RestartOperationAwaitingApproval {
+			continue
+		}
+		if op.Spec.ConfigMapVersion != version {
+			r.expireApproval(ctx, op, "Superseded by a newer change to the ConfigMap")
+			continue
+		}
+		request = op
+	}
+
+	if request == nil {
+		pods := r.plannedPods(ctx, cfg, configMap)
+		if len(pods) == 0 {
+			// Nothing to approve
+			return true, ctrl.Result{}
+		}
+		request = r.requestApproval(ctx, cfg, configMap, version, pods)
+		if request == nil {
+			return false, ctrl.Result{RequeueAfter: approvalPollInterval}
+		}
+	}
+
+	if request.Spec.Approved || request.Annotations[approvedAnnotation] == "true" {
+		logger.Info("Restart approved", "operation", request.Name)
+		now := metav1.Now()
+		request.Status.Phase = autoapplyv1alpha1.RestartOperationRunning
+		request.Status.StartTime = &now
+		r.operations.Store(key, &operationRecord{op: request})
+		r.patchOperationStatus(ctx, request)
+		return true, ctrl.Result{}
+	}
+
+	wait := approvalPollInterval
+	if deadline := request.Spec.ApprovalDeadline; deadline != nil {
+		if !time.Now().Before(deadline.Time) {
+			logger.Info("Restart not approved in time, dropping the change", "operation", request.Name)
+			r.expireApproval(ctx, request, fmt.Sprintf("Not approved within %s", cfg.approvalTimeout))
+			r.Recorder.Eventf(configMap, corev1.EventTypeWarning, "ApprovalExpired",
+				"Restart %s was not approved in time, pods keep running the previous config", request.Name)
+			r.persistVersion(ctx, cfg, configMap, version, time.Time{})
+			return false, ctrl.Result{}
+		}
+		wait = min(wait, time.Until(deadline.Time))
+	}
+	return false, ctrl.Result{RequeueAfter: wait}
+}
+
+// requestApproval creates the RestartOperation awaiting approval for a change
+func (r *ConfigMapReconciler) requestApproval(ctx context.Context, cfg operatorConfig, configMap *corev1.ConfigMap, version string, pods []corev1.Pod) *autoapplyv1alpha1.RestartOperation {
+	logger := log.FromContext(ctx)
+
+	op := &autoapplyv1alpha1.RestartOperation{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: configMap.Name + "-",
+			Namespace:    configMap.Namespace,
+			Labels:       map[string]string{operationConfigMapLabel: configMap.Name},
+		},
+		Spec: autoapplyv1alpha1.RestartOperationSpec{
+			ConfigMapName:    configMap.Name,
+			ConfigMapVersion: version,
+			Strategy:         cfg.strategy,
+			YoloMode:         cfg.yoloMode,
+			Pods:             podNames(pods),
+			ChangedKeys:      r.changedKeys(cfg, configMap).apiChangedKeys(),
+		},
+	}
+	if cfg.approvalTimeout > 0 {
+		deadline := metav1.NewTime(time.Now().Add(cfg.approvalTimeout))
+		op.Spec.ApprovalDeadline = &deadline
+	}
+	if err := controllerutil.SetOwnerReference(configMap, op, r.Scheme); err != nil {
+		logger.Error(err, "Failed to set RestartOperation owner")
+	}
+	if err := r.Create(ctx, op); err != nil {
+		logger.Error(err, "Failed to create RestartOperation")
+		return nil
+	}
+
+	// Without its phase the request is never found again, and every poll
+	// would create another one
+	op.Status.Phase = autoapplyv1alpha1.RestartOperationAwaitingApproval
+	if err := r.patchOperationStatus(ctx, op); err != nil {
+		if err := r.Delete(ctx, op); client.IgnoreNotFound(err) != nil {
+			logger.Error(err, "Failed to delete RestartOperation", "operation", op.Name)
+		}
+		return nil
+	}
+	r.pruneOperations(ctx, configMap)
+
+	logger.Info("Restart awaits approval", "operation", op.Name, "pods", len(pods))
+	r.Recorder.Eventf(configMap, corev1.EventTypeNormal, "AwaitingApproval",
+		"Restart of %d pods awaits approval, set spec.approved on RestartOperation %s", len(pods), op.Name)
+	return op
+}
+
+// expireApproval ends a RestartOperation awaiting approval without restarting pods
+func (r *ConfigMapReconciler) expireApproval(ctx context.Context, op *autoapplyv1alpha1.RestartOperation, message string) {
+	now := metav1.Now()
+	op.Status.Phase = autoapplyv1alpha1.RestartOperationExpired
+	op.Status.CompletionTime = &now
+	op.Status.Message = message
+	r.patchOperationStatus(ctx, op)
+}
+
+// plannedPods returns the pods a restart for the ConfigMap's latest change
+// would select, without findPodsUsingConfigMap's Events and records
+func (r *ConfigMapReconciler) plannedPods(ctx context.Context, cfg operatorConfig, configMap *corev1.ConfigMap) []corev1.Pod {
+	pods, _ := r.restartImpact(ctx, configMap, cfg)
+	var planned []corev1.Pod
+	for _, pod := range pods {
+		if !r.startedWithChange(configMap, &pod) {
+			planned = append(planned, pod)
+		}
+	}
+	return planned
+}
@@ -0,0 +1,185 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/ptr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+
+	autoapplyv1alpha1 "github.com/manos/k8s-autoapply-operator/api/v1alpha1"
+)
+
+func setupApprovalTest(t *testing.T, timeout time.Duration) (*ConfigMapReconciler, client.Client, *corev1.ConfigMap, ctrl.Request) {
+	t.Helper()
+	r, fakeClient := setupTestReconciler()
+	r.RecordOperations = true
+	ctx := context.Background()
+
+	spec := autoapplyv1alpha1.AutoApplyConfigSpec{RequireApproval: ptr.To(true)}
+	if timeout > 0 {
+		spec.ApprovalTimeout = &metav1.Duration{Duration: timeout}
+	}
+	_ = fakeClient.Create(ctx, &autoapplyv1alpha1.AutoApplyConfig{ObjectMeta: metav1.ObjectMeta{Name: "approval"}, Spec: spec})
+
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-config", Namespace: "default", UID: "cm-uid"},
+		Data:       map[string]string{"key": "new"},
+	}
+	_ = fakeClient.Create(ctx, cm)
+	_ = fakeClient.Create(ctx, podUsingConfigMap("test-pod", "test-config", time.Now().Add(-time.Hour)))
+
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: "test-config", Namespace: "default"}}
+	r.configMapVersions.Store(req.String(), "old-version")
+	return r, fakeClient, cm, req
+}
+
+func countDefaultPods(t *testing.T, c client.Client) int {
+	t.Helper()
+	var pods corev1.PodList
+	_ = c.List(context.Background(), &pods, client.InNamespace("default"))
+	return len(pods.Items)
+}
+
+func TestReconcile_RequiresApproval(t *testing.T) {
+	r, fakeClient, _, req := setupApprovalTest(t, 0)
+	ctx := context.Background()
+
+	for range 2 {
+		result, err := r.Reconcile(ctx, req)
+		if err != nil {
+			t.Fatalf("Reconcile failed: %v", err)
+		}
+		if result.RequeueAfter != approvalPollInterval {
+			t.Errorf("Expected to check for approval again, got %v", result.RequeueAfter)
+		}
+	}
+	if countDefaultPods(t, fakeClient) != 1 {
+		t.Fatal("Expected no restart before approval")
+	}
+	ops := listOperations(t, fakeClient)
+	if len(ops) != 1 {
+		t.Fatalf("Expected one RestartOperation awaiting approval, got %d", len(ops))
+	}
+	op := ops[0]
+	if op.Status.Phase != autoapplyv1alpha1.RestartOperationAwaitingApproval || len(op.Spec.Pods) != 1 || op.Spec.ApprovalDeadline != nil {
+		t.Errorf("Expected an operation awaiting approval of test-pod, got %+v %+v", op.Spec, op.Status)
+	}
+
+	op.Spec.Approved = true
+	_ = fakeClient.Update(ctx, &op)
+	if _, err := r.Reconcile(ctx, req); err != nil {
+		t.Fatalf("Reconcile failed: %v", err)
+	}
+	if countDefaultPods(t, fakeClient) != 0 {
+		t.Error("Expected the pod to be restarted once approved")
+	}
+	ops = listOperations(t, fakeClient)
+	if len(ops) != 1 || ops[0].Status.Phase != autoapplyv1alpha1.RestartOperationSucceeded || ops[0].Status.StartTime == nil {
+		t.Errorf("Expected the approved operation to record the restart, got %+v", ops)
+	}
+}
+
+func TestReconcile_ApprovalExpires(t *testing.T) {
+	r, fakeClient, _, req := setupApprovalTest(t, time.Hour)
+	ctx := context.Background()
+
+	if result, _ := r.Reconcile(ctx, req); result.RequeueAfter != approvalPollInterval {
+		t.Errorf("Expected to check for approval again, got %v", result.RequeueAfter)
+	}
+	op := listOperations(t, fakeClient)[0]
+	if op.Spec.ApprovalDeadline == nil {
+		t.Fatal("Expected an approval deadline")
+	}
+
+	past := metav1.NewTime(time.Now().Add(-time.Minute))
+	op.Spec.ApprovalDeadline = &past
+	_ = fakeClient.Update(ctx, &op)
+	if result, _ := r.Reconcile(ctx, req); result.RequeueAfter != 0 {
+		t.Errorf("Expected no more checks once expired, got %v", result.RequeueAfter)
+	}
+	// The change is dropped, not asked about again
+	if _, err := r.Reconcile(ctx, req); err != nil {
+		t.Fatalf("Reconcile failed: %v", err)
+	}
+
+	if countDefaultPods(t, fakeClient) != 1 {
+		t.Error("Expected no restart after the approval expired")
+	}
+	ops := listOperations(t, fakeClient)
+	if len(ops) != 1 || ops[0].Status.Phase != autoapplyv1alpha1.RestartOperationExpired {
+		t.Errorf("Expected one expired operation, got %+v", ops)
+	}
+}
+
+func TestReconcile_NewerChangeSupersedesApproval(t *testing.T) {
+	r, fakeClient, cm, req := setupApprovalTest(t, 0)
+	ctx := context.Background()
+
+	_, _ = r.Reconcile(ctx, req)
+	cm.Data["key"] = "newer"
+	_ = fakeClient.Update(ctx, cm)
+	_, _ = r.Reconcile(ctx, req)
+
+	ops := listOperations(t, fakeClient)
+	if len(ops) != 2 {
+		t.Fatalf("Expected two RestartOperations, got %d", len(ops))
+	}
+	phases := map[autoapplyv1alpha1.RestartOperationPhase]int{}
+	for _, op := range ops {
+		phases[op.Status.Phase]++
+	}
+	if phases[autoapplyv1alpha1.RestartOperationExpired] != 1 || phases[autoapplyv1alpha1.RestartOperationAwaitingApproval] != 1 {
+		t.Errorf("Expected the older request to expire, got %v", phases)
+	}
+
+	// Annotating approves too
+	for _, op := range ops {
+		if op.Status.Phase == autoapplyv1alpha1.RestartOperationAwaitingApproval {
+			op.Annotations = map[string]string{approvedAnnotation: "true"}
+			_ = fakeClient.Update(ctx, &op)
+		}
+	}
+	_, _ = r.Reconcile(ctx, req)
+	if countDefaultPods(t, fakeClient) != 0 {
+		t.Error("Expected the pod to be restarted once approved")
+	}
+}
+
+func TestReconcile_ApprovalRequestPatchFails(t *testing.T) {
+	r, fakeClient, _, req := setupApprovalTest(t, 0)
+	ctx := context.Background()
+
+	failPatch := true
+	r.Client = interceptor.NewClient(fakeClient.(client.WithWatch), interceptor.Funcs{
+		SubResourcePatch: func(ctx context.Context, c client.Client, subResourceName string, obj client.Object, patch client.Patch, opts ...client.SubResourcePatchOption) error {
+			if failPatch {
+				return errors.New("conflict")
+			}
+			return c.SubResource(subResourceName).Patch(ctx, obj, patch, opts...)
+		},
+	})
+
+	if result, _ := r.Reconcile(ctx, req); result.RequeueAfter != approvalPollInterval {
+		t.Errorf("Expected to request approval again, got %v", result.RequeueAfter)
+	}
+	if ops := listOperations(t, fakeClient); len(ops) != 0 {
+		t.Fatalf("Expected the request without a phase to be deleted, got %+v", ops)
+	}
+
+	failPatch = false
+	for range 2 {
+		_, _ = r.Reconcile(ctx, req)
+	}
+	ops := listOperations(t, fakeClient)
+	if len(ops) != 1 || ops[0].Status.Phase != autoapplyv1alpha1.RestartOperationAwaitingApproval {
+		t.Errorf("Expected a single RestartOperation awaiting approval, got %+v", ops)
+	}
+}
@@ -0,0 +1,103 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// +kubebuilder:rbac:groups="",resources=pods,verbs=create
+
+// recreateBarePods creates the restarted pods that have no controller again
+// once the old pod is gone, since nothing else would. Pods with a controller
+// are left to it. Each pod is checked once; those still terminating are
+// returned to be tried again, until podGoneTimeout after since.
+func (r *ConfigMapReconciler) recreateBarePods(ctx context.Context, configMap *corev1.ConfigMap, restarted []corev1.Pod, since time.Time) ([]corev1.Pod, error) {
+	var terminating []corev1.Pod
+	var errs []error
+	for i := range restarted {
+		pod := &restarted[i]
+		if metav1.GetControllerOf(pod) != nil {
+			continue
+		}
+
+		gone, err := r.podGone(ctx, pod)
+		if !gone && err == nil {
+			if time.Since(since) < r.podGoneTimeout(pod) {
+				terminating = append(terminating, *pod)
+				continue
+			}
+			err = fmt.Errorf("timeout waiting for pod %s to terminate", pod.Name)
+		}
+		if err := r.recreateBarePod(ctx, configMap, pod, err); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return terminating, errors.Join(errs...)
+}
+
+// recreateBarePod creates a restarted bare pod again unless waiting for the
+// old one to go failed with goneErr, and reports the outcome
+func (r *ConfigMapReconciler) recreateBarePod(ctx context.Context, configMap *corev1.ConfigMap, pod *corev1.Pod, goneErr error) error {
+	logger := log.FromContext(ctx)
+
+	err := goneErr
+	if err == nil && len(pod.Spec.Containers) == 0 {
+		// Rebuilt from the recorded progress of a resumed restart
+		err = fmt.Errorf("spec of pod %s unknown after the operator restarted", pod.Name)
+	}
+	if err == nil {
+		err = r.Create(ctx, restorablePod(pod))
+	}
+	if apierrors.IsAlreadyExists(err) {
+		logger.V(1).Info("Pod was re-created by someone else", "pod", pod.Name)
+		return nil
+	}
+	if err != nil {
+		logger.Error(err, "Failed to re-create bare pod", "pod", pod.Name)
+		r.Recorder.Eventf(configMap, corev1.EventTypeWarning, "BarePodRecreateFailed",
+			"Failed to re-create pod %s, which has no controller: %v", pod.Name, err)
+		return fmt.Errorf("re-creating pod %s: %w", pod.Name, err)
+	}
+
+	logger.Info("Re-created bare pod", "pod", pod.Name)
+	r.Recorder.Eventf(configMap, corev1.EventTypeNormal, "BarePodRecreated",
+		"Re-created pod %s, which has no controller", pod.Name)
+	return nil
+}
+
+// podGone checks once if a deleted pod finished terminating. A pod of the
+// same name created meanwhile is reported as an AlreadyExists error.
+func (r *ConfigMapReconciler) podGone(ctx context.Context, pod *corev1.Pod) (bool, error) {
+	var current corev1.Pod
+	err := r.Get(ctx, client.ObjectKeyFromObject(pod), &current)
+	if apierrors.IsNotFound(err) {
+		return true, nil
+	}
+	if err != nil {
+		return false, nil
+	}
+	if current.UID != pod.UID {
+		return true, apierrors.NewAlreadyExists(corev1.Resource("pods"), pod.Name)
+	}
+	r.forceDeleteIfStuck(ctx, &current)
+	return false, nil
+}
+
+// podGoneTimeout is how long a deleted pod may take to terminate: its
+// termination grace period plus podReadyTimeout and the time before it's
+// force-deleted
+func (r *ConfigMapReconciler) podGoneTimeout(pod *corev1.Pod) time.Duration {
+	grace := int64(corev1.DefaultTerminationGracePeriodSeconds)
+	if pod.Spec.TerminationGracePeriodSeconds != nil {
+		grace = *pod.Spec.TerminationGracePeriodSeconds
+	}
+	return time.Duration(grace)*time.Second + podReadyTimeout + r.ForceDeleteTerminatingAfter
+}
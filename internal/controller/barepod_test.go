@@ -0,0 +1,67 @@
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestRecreateBarePods(t *testing.T) {
+	r, fakeClient := setupTestReconciler()
+	ctx := context.Background()
+
+	cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "test-config", Namespace: "default"}}
+	bare := podUsingConfigMap("bare", "test-config", metav1.Now().Time)
+	bare.UID = "old-uid"
+	bare.Spec.NodeName = "node-1"
+	owned := podUsingConfigMap("owned", "test-config", metav1.Now().Time)
+	owned.OwnerReferences = []metav1.OwnerReference{{
+		APIVersion: "apps/v1", Kind: "ReplicaSet", Name: "web-abc", UID: "rs-uid", Controller: ptr.To(true),
+	}}
+	for _, pod := range []*corev1.Pod{bare, owned} {
+		_ = fakeClient.Create(ctx, pod)
+		_ = fakeClient.Get(ctx, client.ObjectKeyFromObject(pod), pod)
+		_ = fakeClient.Delete(ctx, pod)
+	}
+
+	lingering := podUsingConfigMap("lingering", "test-config", metav1.Now().Time)
+	_ = fakeClient.Create(ctx, lingering)
+	_ = fakeClient.Get(ctx, client.ObjectKeyFromObject(lingering), lingering)
+
+	terminating, err := r.recreateBarePods(ctx, cm, []corev1.Pod{*bare, *owned, *lingering}, time.Now())
+	if err != nil {
+		t.Fatalf("recreateBarePods failed: %v", err)
+	}
+	if len(terminating) != 1 || terminating[0].Name != "lingering" {
+		t.Errorf("Expected only the pod still there to be left terminating, got %v", listPodNames(terminating))
+	}
+
+	var recreated corev1.Pod
+	if err := fakeClient.Get(ctx, client.ObjectKeyFromObject(bare), &recreated); err != nil {
+		t.Fatalf("Expected the bare pod to be re-created: %v", err)
+	}
+	if recreated.Spec.NodeName != "" || len(recreated.Spec.Volumes) != 1 {
+		t.Errorf("Expected the pod's spec without its node, got %+v", recreated.Spec)
+	}
+	if err := fakeClient.Get(ctx, types.NamespacedName{Name: "owned", Namespace: "default"}, &corev1.Pod{}); err == nil {
+		t.Error("Expected the pod with a controller to be left to it")
+	}
+
+	// The re-created pod must be Ready before it counts as healthy
+	healthy, err := r.checkOwnerPodsHealthy(ctx, bare, 0)
+	if err != nil || healthy {
+		t.Errorf("Expected the re-created pod not to be healthy yet, got %v %v", healthy, err)
+	}
+
+	// A pod that never goes away fails once it had time to terminate
+	since := time.Now().Add(-r.podGoneTimeout(lingering) - time.Second)
+	if terminating, err := r.recreateBarePods(ctx, cm, terminating, since); err == nil || len(terminating) != 0 {
+		t.Errorf("Expected a timeout for the lingering pod, got %v %v", listPodNames(terminating), err)
+	}
+}
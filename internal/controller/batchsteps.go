@@ -0,0 +1,61 @@
+package controller
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	autoapplyv1alpha1 "github.com/manos/k8s-autoapply-operator/api/v1alpha1"
+)
+
+// splitSteps splits one owner's pods by the configured batch steps. Each step
+// is a pod count or a percentage of all the owner's pods, rounded up; the
+// last step repeats until no pods are left. The canary strategy still
+// restarts a single pod before the first step.
+func splitSteps(strategy autoapplyv1alpha1.RestartStrategy, steps []intstr.IntOrString, pods []corev1.Pod) [][]corev1.Pod {
+	var parts [][]corev1.Pod
+	remaining := pods
+	if strategy == autoapplyv1alpha1.RestartStrategyCanary && len(remaining) > 0 {
+		parts = append(parts, remaining[:1])
+		remaining = remaining[1:]
+	}
+
+	for i := 0; len(remaining) > 0; i++ {
+		size := min(stepSize(steps[min(i, len(steps)-1)], len(pods)), len(remaining))
+		parts = append(parts, remaining[:size])
+		remaining = remaining[size:]
+	}
+	return parts
+}
+
+// stepSize returns how many of total pods a batch step restarts, at least one
+func stepSize(step intstr.IntOrString, total int) int {
+	size, err := intstr.GetScaledValueFromIntOrPercent(&step, total, true)
+	if err != nil || size < 1 {
+		return 1
+	}
+	return size
+}
+
+// validateBatchStep rejects steps that would restart no pods
+func validateBatchStep(step intstr.IntOrString) error {
+	if step.Type == intstr.Int {
+		if step.IntVal < 1 {
+			return fmt.Errorf("pod count must be at least 1, got %d", step.IntVal)
+		}
+		return nil
+	}
+
+	value, isPercent := strings.CutSuffix(step.StrVal, "%")
+	percent, err := strconv.Atoi(value)
+	if !isPercent || err != nil {
+		return fmt.Errorf("%q is neither a pod count nor a percentage", step.StrVal)
+	}
+	if percent < 1 || percent > 100 {
+		return fmt.Errorf("percentage must be between 1%% and 100%%, got %s", step.StrVal)
+	}
+	return nil
+}
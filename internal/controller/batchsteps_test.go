@@ -0,0 +1,74 @@
+package controller
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	autoapplyv1alpha1 "github.com/manos/k8s-autoapply-operator/api/v1alpha1"
+)
+
+func TestOwnerBatches_Steps(t *testing.T) {
+	tests := []struct {
+		name     string
+		strategy autoapplyv1alpha1.RestartStrategy
+		steps    []intstr.IntOrString
+		pods     int
+		limit    int
+		expected []int
+	}{
+		{"exponential", autoapplyv1alpha1.RestartStrategyRolling,
+			[]intstr.IntOrString{intstr.FromInt32(1), intstr.FromInt32(2), intstr.FromInt32(4)}, 12, 0, []int{1, 2, 4, 4, 1}},
+		{"percentages then rest", autoapplyv1alpha1.RestartStrategyRolling,
+			[]intstr.IntOrString{intstr.FromString("10%"), intstr.FromString("30%"), intstr.FromString("100%")}, 20, 0, []int{2, 6, 12}},
+		{"percentages round up", autoapplyv1alpha1.RestartStrategyRolling,
+			[]intstr.IntOrString{intstr.FromString("10%")}, 3, 0, []int{1, 1, 1}},
+		{"canary before steps", autoapplyv1alpha1.RestartStrategyCanary,
+			[]intstr.IntOrString{intstr.FromString("50%")}, 6, 0, []int{1, 3, 2}},
+		{"limit cuts steps", autoapplyv1alpha1.RestartStrategyRolling,
+			[]intstr.IntOrString{intstr.FromInt32(1), intstr.FromInt32(5)}, 8, 2, []int{1, 2, 2, 1, 2}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			batches := ownerBatches(tt.strategy, tt.steps, make([]corev1.Pod, tt.pods), tt.limit)
+
+			sizes := make([]int, len(batches))
+			for i, batch := range batches {
+				sizes[i] = len(batch)
+			}
+			if len(sizes) != len(tt.expected) {
+				t.Fatalf("Expected batches %v, got %v", tt.expected, sizes)
+			}
+			for i := range sizes {
+				if sizes[i] != tt.expected[i] {
+					t.Fatalf("Expected batches %v, got %v", tt.expected, sizes)
+				}
+			}
+		})
+	}
+}
+
+func TestValidateBatchStep(t *testing.T) {
+	tests := []struct {
+		step  intstr.IntOrString
+		valid bool
+	}{
+		{intstr.FromInt32(1), true},
+		{intstr.FromString("25%"), true},
+		{intstr.FromString("100%"), true},
+		{intstr.FromInt32(0), false},
+		{intstr.FromString("0%"), false},
+		{intstr.FromString("150%"), false},
+		{intstr.FromString("half"), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.step.String(), func(t *testing.T) {
+			if err := validateBatchStep(tt.step); (err == nil) != tt.valid {
+				t.Errorf("validateBatchStep(%s) = %v, expected valid %v", tt.step.String(), err, tt.valid)
+			}
+		})
+	}
+}
@@ -0,0 +1,109 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// soakCanary checks that the owner's replacement pods stay Ready for the
+// soak duration before its next batch
+func (r *ConfigMapReconciler) soakCanary(ctx context.Context, cfg operatorConfig, configMap *corev1.ConfigMap, state *restartState, owner *ownerProgress) (time.Duration, error) {
+	if err := r.replacementsReady(ctx, configMap.Namespace, owner.UID, state.ownerPods(owner)); err != nil {
+		log.FromContext(ctx).Error(err, "Canary failed soak, aborting remaining pods", "owner", ownerName(owner.UID))
+		return 0, fmt.Errorf("canary failed soak: %w", err)
+	}
+	if wait := time.Until(owner.StepTime.Add(cfg.canarySoakDuration)); wait > 0 {
+		return min(wait, pollInterval), nil
+	}
+	nextBatch(owner)
+	return 0, nil
+}
+
+// replacementsReady checks that the owner has replacement pods and all of
+// them are Ready. Replacements are the owner's pods that weren't part of the
+// original set being restarted.
+func (r *ConfigMapReconciler) replacementsReady(ctx context.Context, namespace string, ownerUID types.UID, original []corev1.Pod) error {
+	known := make(map[types.UID]bool, len(original))
+	for _, pod := range original {
+		known[pod.UID] = true
+	}
+
+	selector, err := r.ownerSelector(ctx, namespace, original)
+	if err != nil {
+		return err
+	}
+	var pods corev1.PodList
+	if err := r.List(ctx, &pods, client.InNamespace(namespace), client.MatchingLabelsSelector{Selector: selector}); err != nil {
+		return err
+	}
+
+	replacements := 0
+	for _, pod := range pods.Items {
+		owner := metav1.GetControllerOf(&pod)
+		if owner == nil || owner.UID != ownerUID || known[pod.UID] || pod.DeletionTimestamp != nil {
+			continue
+		}
+		if !isPodReady(&pod) {
+			return fmt.Errorf("replacement pod %s stopped being ready", pod.Name)
+		}
+		replacements++
+	}
+
+	if replacements == 0 {
+		return fmt.Errorf("no replacement pods found")
+	}
+	return nil
+}
+
+// ownerSelector returns the label selector of the original pods' controller,
+// so only its pods are listed. Controllers other than ReplicaSets,
+// StatefulSets and DaemonSets, and controllers already gone, select
+// everything and are matched by UID only.
+func (r *ConfigMapReconciler) ownerSelector(ctx context.Context, namespace string, original []corev1.Pod) (labels.Selector, error) {
+	if len(original) == 0 {
+		return labels.Everything(), nil
+	}
+	ref := metav1.GetControllerOf(&original[0])
+	if ref == nil {
+		return labels.Everything(), nil
+	}
+
+	var obj client.Object
+	switch ref.Kind {
+	case "ReplicaSet":
+		obj = &appsv1.ReplicaSet{}
+	case "StatefulSet":
+		obj = &appsv1.StatefulSet{}
+	case "DaemonSet":
+		obj = &appsv1.DaemonSet{}
+	default:
+		return labels.Everything(), nil
+	}
+	if err := r.Get(ctx, types.NamespacedName{Namespace: namespace, Name: ref.Name}, obj); err != nil {
+		if apierrors.IsNotFound(err) {
+			return labels.Everything(), nil
+		}
+		return nil, err
+	}
+
+	var selector *metav1.LabelSelector
+	switch owner := obj.(type) {
+	case *appsv1.ReplicaSet:
+		selector = owner.Spec.Selector
+	case *appsv1.StatefulSet:
+		selector = owner.Spec.Selector
+	case *appsv1.DaemonSet:
+		selector = owner.Spec.Selector
+	}
+	return metav1.LabelSelectorAsSelector(selector)
+}
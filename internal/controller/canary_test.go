@@ -0,0 +1,175 @@
+package controller
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	autoapplyv1alpha1 "github.com/manos/k8s-autoapply-operator/api/v1alpha1"
+)
+
+func TestSplitOwnerPods_Canary(t *testing.T) {
+	pods := make([]corev1.Pod, 4)
+
+	first, second := splitOwnerPods(autoapplyv1alpha1.RestartStrategyCanary, pods)
+	if len(first) != 1 || len(second) != 3 {
+		t.Errorf("Expected 1/3 split, got %d/%d", len(first), len(second))
+	}
+
+	first, second = splitOwnerPods(autoapplyv1alpha1.RestartStrategyRolling, pods)
+	if len(first) != 2 || len(second) != 2 {
+		t.Errorf("Expected 2/2 split, got %d/%d", len(first), len(second))
+	}
+}
+
+func TestLoadConfig_Canary(t *testing.T) {
+	r, fakeClient := setupTestReconciler()
+	ctx := context.Background()
+
+	_ = fakeClient.Create(ctx, &autoapplyv1alpha1.AutoApplyConfig{
+		ObjectMeta: metav1.ObjectMeta{Name: "rolling"},
+		Spec:       autoapplyv1alpha1.AutoApplyConfigSpec{Strategy: autoapplyv1alpha1.RestartStrategyRolling},
+	})
+	_ = fakeClient.Create(ctx, &autoapplyv1alpha1.AutoApplyConfig{
+		ObjectMeta: metav1.ObjectMeta{Name: "canary"},
+		Spec: autoapplyv1alpha1.AutoApplyConfigSpec{
+			Strategy:           autoapplyv1alpha1.RestartStrategyCanary,
+			CanarySoakDuration: &metav1.Duration{Duration: 5 * time.Minute},
+		},
+	})
+
+	cfg := r.loadConfig(ctx, nil)
+	if cfg.strategy != autoapplyv1alpha1.RestartStrategyCanary {
+		t.Errorf("Expected Canary to win, got %q", cfg.strategy)
+	}
+	if cfg.canarySoakDuration != 5*time.Minute {
+		t.Errorf("Expected 5m soak, got %v", cfg.canarySoakDuration)
+	}
+}
+
+func TestReplacementsReady(t *testing.T) {
+	ownerUID := types.UID("rs-uid")
+	owned := func(name string, ready bool) *corev1.Pod {
+		status := corev1.ConditionFalse
+		if ready {
+			status = corev1.ConditionTrue
+		}
+		isController := true
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "default",
+				UID:       types.UID(name + "-uid"),
+				Labels:    map[string]string{"app": "app"},
+				OwnerReferences: []metav1.OwnerReference{{
+					Kind: "ReplicaSet", Name: "app", UID: ownerUID, Controller: &isController,
+				}},
+			},
+			Status: corev1.PodStatus{
+				Phase:      corev1.PodRunning,
+				Conditions: []corev1.PodCondition{{Type: corev1.PodReady, Status: status}},
+			},
+		}
+	}
+
+	unlabeled := func(pod *corev1.Pod) *corev1.Pod {
+		pod.Labels = nil
+		return pod
+	}
+
+	tests := []struct {
+		name        string
+		replacement *corev1.Pod
+		expectErr   bool
+	}{
+		{"ready replacement", owned("new", true), false},
+		{"unready replacement", owned("new", false), true},
+		{"no replacement", nil, true},
+		{"replacement outside the selector", unlabeled(owned("new", true)), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, fakeClient := setupTestReconciler()
+			ctx := context.Background()
+
+			_ = fakeClient.Create(ctx, &appsv1.ReplicaSet{
+				ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "default", UID: ownerUID},
+				Spec:       appsv1.ReplicaSetSpec{Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "app"}}},
+			})
+			original := owned("old", true)
+			_ = fakeClient.Create(ctx, original)
+			if tt.replacement != nil {
+				_ = fakeClient.Create(ctx, tt.replacement)
+			}
+
+			err := r.replacementsReady(ctx, "default", ownerUID, []corev1.Pod{*original})
+			if (err != nil) != tt.expectErr {
+				t.Errorf("replacementsReady() error = %v, expectErr %v", err, tt.expectErr)
+			}
+		})
+	}
+}
+
+func TestSoakCanary(t *testing.T) {
+	tests := []struct {
+		name string
+		// replacementReady is whether the canary's replacement stays Ready
+		replacementReady bool
+		wantStep         ownerRestartStep
+		wantRemaining    []string
+	}{
+		{
+			name:             "clean soak restarts the rest",
+			replacementReady: true,
+			wantStep:         ownerRestartDone,
+			wantRemaining:    []string{"deploy-a-ready"},
+		},
+		{
+			name:          "replacement not Ready aborts the rest",
+			wantStep:      ownerRestartFailed,
+			wantRemaining: []string{"deploy-a-2", "deploy-a-ready"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			r, fakeClient, plan := replicaSetFixture(t, ctx)
+			cfg := r.loadConfig(ctx, nil)
+			cfg.strategy = autoapplyv1alpha1.RestartStrategyCanary
+			cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "test-config", Namespace: "default"}}
+
+			// The canary was restarted and deploy-a-ready replaced it
+			deletePods(ctx, fakeClient, "deploy-a-1")
+			if !tt.replacementReady {
+				var replacement corev1.Pod
+				_ = fakeClient.Get(ctx, types.NamespacedName{Name: "deploy-a-ready", Namespace: "default"}, &replacement)
+				replacement.Status.Conditions[0].Status = corev1.ConditionFalse
+				_ = fakeClient.Status().Update(ctx, &replacement)
+			}
+			state := ownerRestart(plan, ownerRestartSoaking)
+			owner := &state.progress.Owners[0]
+			owner.Restarted = []string{"deploy-a-1"}
+			backdate(owner, defaultCanarySoakDuration)
+
+			r.advanceOwner(ctx, cfg, cm, state, 0)
+			if owner.Step != tt.wantStep {
+				t.Errorf("Expected step %s, got %s: %s", tt.wantStep, owner.Step, owner.Message)
+			}
+			if tt.wantStep == ownerRestartFailed && !strings.Contains(owner.Message, "canary failed soak") {
+				t.Errorf("Expected the soak to fail the owner, got %q", owner.Message)
+			}
+			if remaining := remainingPods(ctx, fakeClient); !slices.Equal(remaining, tt.wantRemaining) {
+				t.Errorf("Expected %v left running, found %v", tt.wantRemaining, remaining)
+			}
+		})
+	}
+}
@@ -0,0 +1,135 @@
+package controller
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	autoapplyv1alpha1 "github.com/manos/k8s-autoapply-operator/api/v1alpha1"
+)
+
+// Maximum number of keys named per kind of change in an Event
+const maxListedKeys = 10
+
+// keyChanges lists the data and binaryData keys that differ between two
+// versions of a ConfigMap. Values are never kept, only their hashes, so a
+// summary can't leak what the keys hold.
+type keyChanges struct {
+	added    []string
+	removed  []string
+	modified []string
+}
+
+func (c keyChanges) empty() bool {
+	return len(c.added) == 0 && len(c.removed) == 0 && len(c.modified) == 0
+}
+
+// String summarizes the changes for Events, e.g. "modified: app.yaml; added: extra.yaml"
+func (c keyChanges) String() string {
+	var parts []string
+	for _, change := range []struct {
+		kind string
+		keys []string
+	}{{"added", c.added}, {"removed", c.removed}, {"modified", c.modified}} {
+		if len(change.keys) == 0 {
+			continue
+		}
+		keys := change.keys
+		if len(keys) > maxListedKeys {
+			keys = append(keys[:maxListedKeys:maxListedKeys], fmt.Sprintf("and %d more", len(change.keys)-maxListedKeys))
+		}
+		parts = append(parts, change.kind+": "+strings.Join(keys, ", "))
+	}
+	return strings.Join(parts, "; ")
+}
+
+// touches checks if any of the keys was added, removed or modified
+func (c keyChanges) touches(keys map[string]bool) bool {
+	for _, changed := range [][]string{c.added, c.removed, c.modified} {
+		for _, key := range changed {
+			if keys[key] {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// apiChangedKeys converts the changes for a RestartOperation, nil when none are known
+func (c keyChanges) apiChangedKeys() *autoapplyv1alpha1.ChangedKeys {
+	if c.empty() {
+		return nil
+	}
+	return &autoapplyv1alpha1.ChangedKeys{Added: c.added, Removed: c.removed, Modified: c.modified}
+}
+
+// describeChange appends the changed keys, when known, to an Event message
+func describeChange(message string, changes keyChanges) string {
+	if changes.empty() {
+		return message
+	}
+	return message + " (" + changes.String() + ")"
+}
+
+// keyHashes hashes every data and binaryData value of a ConfigMap by key,
+// each as the detector sees it on its own, so keys compare the way the
+// ConfigMap's versions do. Keys the detector ignores are left out.
+func keyHashes(detector ChangeDetector, configMap *corev1.ConfigMap) map[string][sha256.Size]byte {
+	ignored := detector.Version(&corev1.ConfigMap{})
+	hashes := make(map[string][sha256.Size]byte, len(configMap.Data)+len(configMap.BinaryData))
+	add := func(key string, single *corev1.ConfigMap) {
+		if version := detector.Version(single); version != ignored {
+			hashes[key] = sha256.Sum256([]byte(version))
+		}
+	}
+	for key, value := range configMap.Data {
+		add(key, &corev1.ConfigMap{Data: map[string]string{key: value}})
+	}
+	for key, value := range configMap.BinaryData {
+		add(key, &corev1.ConfigMap{BinaryData: map[string][]byte{key: value}})
+	}
+	return hashes
+}
+
+// diffKeys compares the key hashes of two versions of a ConfigMap
+func diffKeys(old, current map[string][sha256.Size]byte) keyChanges {
+	var changes keyChanges
+	for key, hash := range current {
+		oldHash, ok := old[key]
+		switch {
+		case !ok:
+			changes.added = append(changes.added, key)
+		case oldHash != hash:
+			changes.modified = append(changes.modified, key)
+		}
+	}
+	for key := range old {
+		if _, ok := current[key]; !ok {
+			changes.removed = append(changes.removed, key)
+		}
+	}
+	sort.Strings(changes.added)
+	sort.Strings(changes.removed)
+	sort.Strings(changes.modified)
+	return changes
+}
+
+// recordHandledKeys remembers the ConfigMap's key hashes as the baseline the
+// next change is compared against
+func (r *ConfigMapReconciler) recordHandledKeys(detector ChangeDetector, configMap *corev1.ConfigMap) {
+	r.handledKeys.Store(client.ObjectKeyFromObject(configMap).String(), keyHashes(detector, configMap))
+}
+
+// changedKeys returns the keys changed since the ConfigMap's last handled
+// version. Nothing is known about changes made before the operator started.
+func (r *ConfigMapReconciler) changedKeys(cfg operatorConfig, configMap *corev1.ConfigMap) keyChanges {
+	old, ok := r.handledKeys.Load(client.ObjectKeyFromObject(configMap).String())
+	if !ok {
+		return keyChanges{}
+	}
+	return diffKeys(old.(map[string][sha256.Size]byte), keyHashes(r.changeDetector(cfg), configMap))
+}
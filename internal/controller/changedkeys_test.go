@@ -0,0 +1,199 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+func TestDiffKeys(t *testing.T) {
+	old := &corev1.ConfigMap{
+		Data:       map[string]string{"app.yaml": "a: 1", "log.yaml": "level: info", "gone": "x"},
+		BinaryData: map[string][]byte{"cert.der": {1, 2}},
+	}
+	current := &corev1.ConfigMap{
+		Data:       map[string]string{"app.yaml": "a: 2", "log.yaml": "level: info", "new": "y"},
+		BinaryData: map[string][]byte{"cert.der": {1, 3}},
+	}
+
+	detector := ChangeDetectorFunc(configMapVersion)
+	changes := diffKeys(keyHashes(detector, old), keyHashes(detector, current))
+	expected := keyChanges{added: []string{"new"}, removed: []string{"gone"}, modified: []string{"app.yaml", "cert.der"}}
+	if !reflect.DeepEqual(changes, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, changes)
+	}
+	if s := changes.String(); s != "added: new; removed: gone; modified: app.yaml, cert.der" {
+		t.Errorf("Unexpected summary %q", s)
+	}
+
+	if changes := diffKeys(keyHashes(detector, old), keyHashes(detector, old)); !changes.empty() {
+		t.Errorf("Expected no changes, got %+v", changes)
+	}
+}
+
+func TestDiffKeys_FollowsDetector(t *testing.T) {
+	old := &corev1.ConfigMap{Data: map[string]string{
+		"app.yaml": "a: 1\nb: 2\n", "log.yaml": "level: info", "notes.txt": "x",
+	}}
+	current := &corev1.ConfigMap{Data: map[string]string{
+		"app.yaml": "# reordered\nb: 2\na: 1\n", "log.yaml": "level: debug", "notes.txt": "y",
+	}}
+
+	tests := []struct {
+		name     string
+		detector ChangeDetector
+		expected keyChanges
+	}{
+		{"raw", ChangeDetectorFunc(configMapVersion), keyChanges{modified: []string{"app.yaml", "log.yaml", "notes.txt"}}},
+		{"semantic", semanticDetector{next: ChangeDetectorFunc(configMapVersion)}, keyChanges{modified: []string{"log.yaml", "notes.txt"}}},
+		{"keys", keysDetector{keys: []string{"app.yaml", "log.yaml"}}, keyChanges{modified: []string{"app.yaml", "log.yaml"}}},
+		{"semantic keys", semanticDetector{next: keysDetector{keys: []string{"app.yaml", "log.yaml"}}}, keyChanges{modified: []string{"log.yaml"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			changes := diffKeys(keyHashes(tt.detector, old), keyHashes(tt.detector, current))
+			if !reflect.DeepEqual(changes, tt.expected) {
+				t.Errorf("Expected %+v, got %+v", tt.expected, changes)
+			}
+		})
+	}
+
+	// Keys outside the detector's scope are neither added nor removed
+	keys := keysDetector{keys: []string{"app.yaml"}}
+	added := &corev1.ConfigMap{Data: map[string]string{"app.yaml": "a: 1\nb: 2\n", "extra.yaml": "c: 3"}}
+	if changes := diffKeys(keyHashes(keys, old), keyHashes(keys, added)); !changes.empty() {
+		t.Errorf("Expected no changes in scope, got %+v", changes)
+	}
+}
+
+func TestKeyChanges_StringTruncates(t *testing.T) {
+	var changes keyChanges
+	for i := range maxListedKeys + 3 {
+		changes.modified = append(changes.modified, fmt.Sprintf("k%02d", i))
+	}
+
+	expected := "modified: k00, k01, k02, k03, k04, k05, k06, k07, k08, k09, and 3 more"
+	if s := changes.String(); s != expected {
+		t.Errorf("Expected %q, got %q", expected, s)
+	}
+	if len(changes.modified) != maxListedKeys+3 {
+		t.Error("String must not modify the changes")
+	}
+}
+
+func TestReconcile_ReportsChangedKeys(t *testing.T) {
+	r, fakeClient := setupTestReconciler()
+	r.RecordOperations = true
+	ctx := context.Background()
+
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: "test-config", Namespace: "default"}}
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-config", Namespace: "default"},
+		Data:       map[string]string{"app.yaml": "a: 1", "old.yaml": "b: 1"},
+	}
+	_ = fakeClient.Create(ctx, cm)
+	_ = fakeClient.Create(ctx, podUsingConfigMap("test-pod", "test-config", metav1.Now().Time))
+
+	// The first reconcile records the baseline
+	if _, err := r.Reconcile(ctx, req); err != nil {
+		t.Fatalf("Reconcile failed: %v", err)
+	}
+
+	_ = fakeClient.Get(ctx, req.NamespacedName, cm)
+	cm.Data = map[string]string{"app.yaml": "a: 2", "new.yaml": "secret-value"}
+	_ = fakeClient.Update(ctx, cm)
+	if _, err := r.Reconcile(ctx, req); err != nil {
+		t.Fatalf("Reconcile failed: %v", err)
+	}
+
+	recorder := r.Recorder.(*record.FakeRecorder)
+	expected := "Normal TriggeredRestart Restarting 1 pods due to ConfigMap change (added: new.yaml; removed: old.yaml; modified: app.yaml)"
+	if event := <-recorder.Events; event != expected {
+		t.Errorf("Expected event %q, got %q", expected, event)
+	}
+
+	ops := listOperations(t, fakeClient)
+	if len(ops) != 1 {
+		t.Fatalf("Expected one RestartOperation, got %d", len(ops))
+	}
+	changed := ops[0].Spec.ChangedKeys
+	if changed == nil || !reflect.DeepEqual(changed.Added, []string{"new.yaml"}) ||
+		!reflect.DeepEqual(changed.Removed, []string{"old.yaml"}) || !reflect.DeepEqual(changed.Modified, []string{"app.yaml"}) {
+		t.Errorf("Unexpected changed keys %+v", changed)
+	}
+}
+
+func TestReconcile_ChangedKeysUnknownWithoutBaseline(t *testing.T) {
+	r, fakeClient := setupTestReconciler()
+	r.RecordOperations = true
+	ctx := context.Background()
+
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: "test-config", Namespace: "default"}}
+	// Seen before the operator restarted, so the previous keys are unknown
+	r.configMapVersions.Store(req.String(), "old-version")
+	_ = fakeClient.Create(ctx, &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-config", Namespace: "default"},
+		Data:       map[string]string{"app.yaml": "a: 2"},
+	})
+	_ = fakeClient.Create(ctx, podUsingConfigMap("test-pod", "test-config", metav1.Now().Time))
+
+	if _, err := r.Reconcile(ctx, req); err != nil {
+		t.Fatalf("Reconcile failed: %v", err)
+	}
+
+	ops := listOperations(t, fakeClient)
+	if len(ops) != 1 || ops[0].Spec.ChangedKeys != nil {
+		t.Errorf("Expected one operation without changed keys, got %+v", ops)
+	}
+}
+
+func TestReconcile_RestartsOnlyConsumersOfChangedKeys(t *testing.T) {
+	r, fakeClient := setupTestReconciler()
+	ctx := context.Background()
+
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: "test-config", Namespace: "default"}}
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-config", Namespace: "default"},
+		Data:       map[string]string{"app.yaml": "a: 1", "log.yaml": "level: info"},
+	}
+	_ = fakeClient.Create(ctx, cm)
+
+	appPod := podUsingConfigMap("app-pod", "test-config", metav1.Now().Time)
+	appPod.Spec.Volumes[0].ConfigMap.Items = []corev1.KeyToPath{{Key: "app.yaml", Path: "app.yaml"}}
+	logPod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "log-pod", Namespace: "default"},
+		Spec: corev1.PodSpec{Containers: []corev1.Container{{
+			Name: "app",
+			Env: []corev1.EnvVar{{Name: "LOG", ValueFrom: &corev1.EnvVarSource{ConfigMapKeyRef: &corev1.ConfigMapKeySelector{
+				LocalObjectReference: corev1.LocalObjectReference{Name: "test-config"}, Key: "log.yaml",
+			}}}},
+		}}},
+		Status: corev1.PodStatus{Phase: corev1.PodRunning},
+	}
+	for _, pod := range []*corev1.Pod{appPod, logPod, podUsingConfigMap("all-pod", "test-config", metav1.Now().Time)} {
+		_ = fakeClient.Create(ctx, pod)
+	}
+
+	// The first reconcile records the baseline
+	if _, err := r.Reconcile(ctx, req); err != nil {
+		t.Fatalf("Reconcile failed: %v", err)
+	}
+
+	_ = fakeClient.Get(ctx, req.NamespacedName, cm)
+	cm.Data["app.yaml"] = "a: 2"
+	_ = fakeClient.Update(ctx, cm)
+	reconcileRestart(t, r, req)
+
+	var pods corev1.PodList
+	_ = fakeClient.List(ctx, &pods)
+	if names := podNames(pods.Items); !reflect.DeepEqual(names, []string{"log-pod"}) {
+		t.Errorf("Expected only log-pod to keep running, got %v", names)
+	}
+}
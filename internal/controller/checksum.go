@@ -0,0 +1,106 @@
+package controller
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"sort"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// ChecksumWebhookPath is where the config checksum webhook is served
+const ChecksumWebhookPath = "/inject-config-checksum"
+
+// ChecksumInjector is a mutating admission webhook for Deployments and
+// StatefulSets. It stamps their pod template with a hash of the ConfigMaps
+// and Secrets it references, so applying a workload after its config changed
+// rolls it out through the workload's own controller.
+type ChecksumInjector struct {
+	Client  client.Reader
+	Decoder admission.Decoder
+}
+
+func (i *ChecksumInjector) Handle(ctx context.Context, req admission.Request) admission.Response {
+	var obj client.Object
+	var template *corev1.PodTemplateSpec
+	switch req.Kind.Kind {
+	case "Deployment":
+		deployment := &appsv1.Deployment{}
+		obj, template = deployment, &deployment.Spec.Template
+	case "StatefulSet":
+		statefulSet := &appsv1.StatefulSet{}
+		obj, template = statefulSet, &statefulSet.Spec.Template
+	default:
+		return admission.Allowed("")
+	}
+	if err := i.Decoder.Decode(req, obj); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+
+	checksum, err := i.configChecksum(ctx, req.Namespace, template)
+	if err != nil {
+		// A stale checksum only delays a rollout, so the workload still goes through
+		log.FromContext(ctx).Error(err, "Failed to compute config checksum", "workload", req.Name)
+		return admission.Allowed("").WithWarnings("config checksum not updated: " + err.Error())
+	}
+	if template.Annotations[configChecksumAnnotation] == checksum {
+		return admission.Allowed("")
+	}
+
+	if checksum == "" {
+		delete(template.Annotations, configChecksumAnnotation)
+	} else {
+		if template.Annotations == nil {
+			template.Annotations = make(map[string]string)
+		}
+		template.Annotations[configChecksumAnnotation] = checksum
+	}
+	marshaled, err := json.Marshal(obj)
+	if err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+	return admission.PatchResponseFromRaw(req.Object.Raw, marshaled)
+}
+
+// configChecksum hashes the contents of the ConfigMaps and Secrets a pod
+// template references, empty if it references none. Missing ones count as
+// empty, so creating them later changes the checksum.
+func (i *ChecksumInjector) configChecksum(ctx context.Context, namespace string, template *corev1.PodTemplateSpec) (string, error) {
+	pod := &corev1.Pod{Spec: template.Spec}
+
+	var entries []string
+	for _, name := range podConfigMapNames(pod) {
+		var configMap corev1.ConfigMap
+		if err := i.Client.Get(ctx, types.NamespacedName{Namespace: namespace, Name: name}, &configMap); client.IgnoreNotFound(err) != nil {
+			return "", err
+		}
+		entries = append(entries, "configmap/"+name+"="+configMapVersion(&configMap))
+	}
+	for _, name := range podSecretNames(pod) {
+		var secret corev1.Secret
+		if err := i.Client.Get(ctx, types.NamespacedName{Namespace: namespace, Name: name}, &secret); err != nil && !apierrors.IsNotFound(err) {
+			return "", err
+		}
+		// Hashed like a ConfigMap's binaryData
+		entries = append(entries, "secret/"+name+"="+configMapVersion(&corev1.ConfigMap{BinaryData: secret.Data}))
+	}
+	if len(entries) == 0 {
+		return "", nil
+	}
+
+	sort.Strings(entries)
+	h := sha256.New()
+	for _, entry := range entries {
+		h.Write([]byte(entry + "\x00"))
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
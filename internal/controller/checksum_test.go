@@ -0,0 +1,153 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+func deploymentRequest(t *testing.T, deployment *appsv1.Deployment) admission.Request {
+	t.Helper()
+	raw, err := json.Marshal(deployment)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+		Kind:      metav1.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"},
+		Namespace: deployment.Namespace,
+		Name:      deployment.Name,
+		Operation: admissionv1.Update,
+		Object:    runtime.RawExtension{Raw: raw},
+	}}
+}
+
+// injectedChecksum returns the checksum the webhook patched in, if any
+func injectedChecksum(t *testing.T, resp admission.Response) (string, bool) {
+	t.Helper()
+	if !resp.Allowed {
+		t.Fatalf("Expected the workload to be allowed, got %v", resp.Result)
+	}
+	for _, op := range resp.Patches {
+		if op.Path == "/spec/template/metadata/annotations" {
+			value, _ := op.Value.(map[string]any)[configChecksumAnnotation].(string)
+			return value, true
+		}
+		if op.Path == "/spec/template/metadata/annotations/autoapply.io~1config-checksum" {
+			if op.Operation == "remove" {
+				return "", true
+			}
+			return op.Value.(string), true
+		}
+	}
+	if len(resp.Patches) != 0 {
+		t.Fatalf("Unexpected patches %v", resp.Patches)
+	}
+	return "", false
+}
+
+func TestPodSecretNames(t *testing.T) {
+	pod := &corev1.Pod{Spec: corev1.PodSpec{
+		ImagePullSecrets: []corev1.LocalObjectReference{{Name: "registry"}},
+		Volumes: []corev1.Volume{
+			{Name: "tls", VolumeSource: corev1.VolumeSource{Secret: &corev1.SecretVolumeSource{SecretName: "tls"}}},
+			{Name: "projected", VolumeSource: corev1.VolumeSource{Projected: &corev1.ProjectedVolumeSource{
+				Sources: []corev1.VolumeProjection{{Secret: &corev1.SecretProjection{
+					LocalObjectReference: corev1.LocalObjectReference{Name: "tls"},
+				}}},
+			}}},
+		},
+		Containers: []corev1.Container{{
+			EnvFrom: []corev1.EnvFromSource{{SecretRef: &corev1.SecretEnvSource{
+				LocalObjectReference: corev1.LocalObjectReference{Name: "env"},
+			}}},
+			Env: []corev1.EnvVar{{Name: "TOKEN", ValueFrom: &corev1.EnvVarSource{SecretKeyRef: &corev1.SecretKeySelector{
+				LocalObjectReference: corev1.LocalObjectReference{Name: "token"}, Key: "token",
+			}}}},
+		}},
+	}}
+
+	names := podSecretNames(pod)
+	expected := []string{"tls", "env", "token"}
+	if len(names) != len(expected) {
+		t.Fatalf("Expected %v, got %v", expected, names)
+	}
+	for i := range expected {
+		if names[i] != expected[i] {
+			t.Errorf("Expected %v, got %v", expected, names)
+		}
+	}
+}
+
+func TestChecksumInjector(t *testing.T) {
+	r, fakeClient := setupTestReconciler()
+	ctx := context.Background()
+	injector := &ChecksumInjector{Client: fakeClient, Decoder: admission.NewDecoder(r.Scheme)}
+
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-config", Namespace: "default"},
+		Data:       map[string]string{"key": "value"},
+	}
+	_ = fakeClient.Create(ctx, configMap)
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-secret", Namespace: "default"},
+		Data:       map[string][]byte{"password": []byte("hunter2")},
+	}
+	_ = fakeClient.Create(ctx, secret)
+
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"},
+		Spec: appsv1.DeploymentSpec{Template: corev1.PodTemplateSpec{
+			Spec: podUsingConfigMap("web", "test-config", time.Now()).Spec,
+		}},
+	}
+	deployment.Spec.Template.Spec.Containers[0].EnvFrom = append(deployment.Spec.Template.Spec.Containers[0].EnvFrom,
+		corev1.EnvFromSource{SecretRef: &corev1.SecretEnvSource{LocalObjectReference: corev1.LocalObjectReference{Name: "test-secret"}}})
+
+	checksum, ok := injectedChecksum(t, injector.Handle(ctx, deploymentRequest(t, deployment)))
+	if !ok || checksum == "" {
+		t.Fatal("Expected a checksum to be injected")
+	}
+
+	// Applying the workload unchanged keeps the checksum
+	deployment.Spec.Template.Annotations = map[string]string{configChecksumAnnotation: checksum}
+	if _, ok := injectedChecksum(t, injector.Handle(ctx, deploymentRequest(t, deployment))); ok {
+		t.Error("Expected no patch while the config is unchanged")
+	}
+
+	// A Secret change changes the checksum
+	secret.Data["password"] = []byte("correct horse")
+	_ = fakeClient.Update(ctx, secret)
+	changed, ok := injectedChecksum(t, injector.Handle(ctx, deploymentRequest(t, deployment)))
+	if !ok || changed == checksum || changed == "" {
+		t.Errorf("Expected a new checksum after the Secret changed, got %q", changed)
+	}
+
+	// A missing ConfigMap is hashed as empty
+	_ = fakeClient.Delete(ctx, configMap)
+	missing, ok := injectedChecksum(t, injector.Handle(ctx, deploymentRequest(t, deployment)))
+	if !ok || missing == changed || missing == "" {
+		t.Errorf("Expected a new checksum for the missing ConfigMap, got %q", missing)
+	}
+
+	// Without references the checksum is removed
+	deployment.Spec.Template.Spec = corev1.PodSpec{Containers: []corev1.Container{{Name: "app", Image: "nginx"}}}
+	resp := injector.Handle(ctx, deploymentRequest(t, deployment))
+	if len(resp.Patches) != 1 || resp.Patches[0].Operation != "remove" {
+		t.Errorf("Expected the checksum to be removed, got %v", resp.Patches)
+	}
+
+	// Other kinds are let through untouched
+	req := deploymentRequest(t, deployment)
+	req.Kind.Kind = "DaemonSet"
+	if resp := injector.Handle(ctx, req); !resp.Allowed || len(resp.Patches) != 0 {
+		t.Errorf("Expected other kinds to be allowed untouched, got %v", resp.Patches)
+	}
+}
@@ -0,0 +1,67 @@
+package controller
+
+import (
+	"context"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// churnState records the recent changes of one ConfigMap
+type churnState struct {
+	changes []time.Time
+	// suppressed is set while the ConfigMap changes too often to restart pods;
+	// its latest change is handled once it settles
+	suppressed bool
+}
+
+// churnSuppressed checks whether the ConfigMap changed more than
+// HighChurnChanges times within HighChurnWindow, recording a change first if
+// changed is set. Entering and leaving suppression is reported as an Event.
+func (r *ConfigMapReconciler) churnSuppressed(ctx context.Context, key string, configMap *corev1.ConfigMap, changed bool) bool {
+	if r.HighChurnChanges <= 0 || r.HighChurnWindow <= 0 {
+		return false
+	}
+	logger := log.FromContext(ctx)
+
+	now := time.Now()
+	value, _ := r.churn.LoadOrStore(key, &churnState{})
+	state := value.(*churnState)
+
+	cutoff := now.Add(-r.HighChurnWindow)
+	recent := state.changes[:0]
+	for _, at := range state.changes {
+		if at.After(cutoff) {
+			recent = append(recent, at)
+		}
+	}
+	if changed {
+		recent = append(recent, now)
+	}
+	state.changes = recent
+
+	high := len(recent) > r.HighChurnChanges
+	switch {
+	case high && !state.suppressed:
+		logger.Info("ConfigMap changes too often, suppressing restarts", "changes", len(recent), "window", r.HighChurnWindow)
+		r.Recorder.Eventf(configMap, corev1.EventTypeWarning, "HighChurnSuppressed",
+			"ConfigMap changed %d times within %s, restarts wait until it changes less often",
+			len(recent), r.HighChurnWindow)
+	case !high && state.suppressed:
+		logger.Info("ConfigMap changes less often again, resuming restarts")
+		r.Recorder.Event(configMap, corev1.EventTypeNormal, "HighChurnResumed",
+			"ConfigMap changes less often again, handling its latest change")
+	}
+	state.suppressed = high
+	if len(recent) == 0 {
+		r.churn.Delete(key)
+	}
+	return high
+}
+
+// churnSuppressing checks if restarts for the ConfigMap are being suppressed
+func (r *ConfigMapReconciler) churnSuppressing(key string) bool {
+	value, ok := r.churn.Load(key)
+	return ok && value.(*churnState).suppressed
+}
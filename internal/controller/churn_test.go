@@ -0,0 +1,101 @@
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestChurnSuppressed(t *testing.T) {
+	r := &ConfigMapReconciler{Recorder: record.NewFakeRecorder(10), HighChurnChanges: 2, HighChurnWindow: time.Hour}
+	ctx := context.Background()
+	cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "leader", Namespace: "default"}}
+
+	for i := range 2 {
+		if r.churnSuppressed(ctx, "default/leader", cm, true) {
+			t.Fatalf("Change %d should not be suppressed", i+1)
+		}
+	}
+	if !r.churnSuppressed(ctx, "default/leader", cm, true) || !r.churnSuppressing("default/leader") {
+		t.Fatal("Expected the third change within the window to be suppressed")
+	}
+
+	// The changes age out of the window
+	value, _ := r.churn.Load("default/leader")
+	state := value.(*churnState)
+	for i := range state.changes {
+		state.changes[i] = time.Now().Add(-2 * time.Hour)
+	}
+	if r.churnSuppressed(ctx, "default/leader", cm, false) || r.churnSuppressing("default/leader") {
+		t.Error("Expected suppression to end once the ConfigMap settled")
+	}
+
+	recorder := r.Recorder.(*record.FakeRecorder)
+	expected := []string{
+		"Warning HighChurnSuppressed ConfigMap changed 3 times within 1h0m0s, restarts wait until it changes less often",
+		"Normal HighChurnResumed ConfigMap changes less often again, handling its latest change",
+	}
+	for _, want := range expected {
+		if event := <-recorder.Events; event != want {
+			t.Errorf("Expected event %q, got %q", want, event)
+		}
+	}
+
+	disabled := &ConfigMapReconciler{}
+	for range 100 {
+		if disabled.churnSuppressed(ctx, "default/leader", cm, true) {
+			t.Fatal("Expected no suppression when disabled")
+		}
+	}
+}
+
+func TestReconcile_HandlesLatestChangeAfterChurnSettles(t *testing.T) {
+	r, fakeClient := setupTestReconciler()
+	r.HighChurnChanges = 1
+	r.HighChurnWindow = time.Hour
+	ctx := context.Background()
+
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: "test-config", Namespace: "default"}}
+	r.configMapVersions.Store(req.String(), "old-version")
+	r.churn.Store(req.String(), &churnState{changes: []time.Time{time.Now()}})
+
+	_ = fakeClient.Create(ctx, &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-config", Namespace: "default"},
+		Data:       map[string]string{"key": "value"},
+	})
+	_ = fakeClient.Create(ctx, podUsingConfigMap("test-pod", "test-config", time.Now()))
+
+	result, err := r.Reconcile(ctx, req)
+	if err != nil {
+		t.Fatalf("Reconcile failed: %v", err)
+	}
+	if result.RequeueAfter != time.Hour {
+		t.Errorf("Expected a requeue after the churn window, got %v", result.RequeueAfter)
+	}
+	var pods corev1.PodList
+	_ = fakeClient.List(ctx, &pods, client.InNamespace("default"))
+	if len(pods.Items) != 1 {
+		t.Fatal("Expected no restart while the ConfigMap churns")
+	}
+
+	// Settled: the latest change is handled without another update
+	value, _ := r.churn.Load(req.String())
+	state := value.(*churnState)
+	for i := range state.changes {
+		state.changes[i] = time.Now().Add(-2 * time.Hour)
+	}
+	if _, err := r.Reconcile(ctx, req); err != nil {
+		t.Fatalf("Reconcile failed: %v", err)
+	}
+	_ = fakeClient.List(ctx, &pods, client.InNamespace("default"))
+	if len(pods.Items) != 0 {
+		t.Error("Expected the pod to be restarted once the ConfigMap settled")
+	}
+}
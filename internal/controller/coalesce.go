@@ -0,0 +1,55 @@
+package controller
+
+import (
+	"context"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// coalesceWait returns how much longer to hold the ConfigMap's restart, so
+// that changes to other ConfigMaps its pods use within the coalesce window
+// are picked up by the same restart, or 0 to go ahead
+func (r *ConfigMapReconciler) coalesceWait(ctx context.Context, cfg operatorConfig, configMap *corev1.ConfigMap) time.Duration {
+	if cfg.coalesceWindow <= 0 {
+		return 0
+	}
+
+	var pods corev1.PodList
+	if err := r.List(ctx, &pods, client.InNamespace(configMap.Namespace),
+		client.MatchingFields{podConfigMapIndex: configMap.Name}); err != nil {
+		log.FromContext(ctx).Error(err, "Failed to list pods")
+		return 0
+	}
+
+	// Latest change of any ConfigMap the pods use, this one included
+	var latest time.Time
+	checked := make(map[string]bool)
+	for i := range pods.Items {
+		for _, name := range podConfigMapNames(&pods.Items[i]) {
+			if checked[name] {
+				continue
+			}
+			checked[name] = true
+			key := types.NamespacedName{Namespace: configMap.Namespace, Name: name}.String()
+			if value, ok := r.changesSeen.Load(key); ok && value.(seenChange).at.After(latest) {
+				latest = value.(seenChange).at
+			}
+		}
+	}
+	if latest.IsZero() {
+		return 0
+	}
+	return max(cfg.coalesceWindow-time.Since(latest), 0)
+}
+
+// startedWithChange checks if the pod was created after the ConfigMap's latest
+// change was seen, so it already runs it. This is how pods restarted for one
+// ConfigMap aren't restarted again for others that changed alongside it.
+func (r *ConfigMapReconciler) startedWithChange(configMap *corev1.ConfigMap, pod *corev1.Pod) bool {
+	value, ok := r.changesSeen.Load(client.ObjectKeyFromObject(configMap).String())
+	return ok && pod.CreationTimestamp.Time.After(value.(seenChange).at)
+}
@@ -0,0 +1,145 @@
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	autoapplyv1alpha1 "github.com/manos/k8s-autoapply-operator/api/v1alpha1"
+)
+
+// podUsingConfigMaps returns a pod mounting every given ConfigMap
+func podUsingConfigMaps(name string, created time.Time, configMaps ...string) *corev1.Pod {
+	pod := podUsingConfigMap(name, configMaps[0], created)
+	for _, configMap := range configMaps[1:] {
+		pod.Spec.Volumes = append(pod.Spec.Volumes, corev1.Volume{
+			Name: configMap,
+			VolumeSource: corev1.VolumeSource{ConfigMap: &corev1.ConfigMapVolumeSource{
+				LocalObjectReference: corev1.LocalObjectReference{Name: configMap},
+			}},
+		})
+	}
+	return pod
+}
+
+func TestCoalesceWait(t *testing.T) {
+	r, fakeClient := setupTestReconciler()
+	ctx := context.Background()
+	_ = fakeClient.Create(ctx, podUsingConfigMaps("web", time.Now(), "app", "features"))
+	_ = fakeClient.Create(ctx, podUsingConfigMaps("other", time.Now(), "unrelated"))
+
+	app := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "default"}}
+	cfg := operatorConfig{coalesceWindow: 30 * time.Second}
+	r.changesSeen.Store("default/app", seenChange{version: "v2", at: time.Now().Add(-time.Minute)})
+
+	if wait := r.coalesceWait(ctx, cfg, app); wait != 0 {
+		t.Errorf("Expected no wait once the window passed, got %v", wait)
+	}
+
+	// A ConfigMap used by other pods doesn't hold the restart
+	r.changesSeen.Store("default/unrelated", seenChange{version: "v2", at: time.Now()})
+	if wait := r.coalesceWait(ctx, cfg, app); wait != 0 {
+		t.Errorf("Expected no wait for unrelated changes, got %v", wait)
+	}
+
+	// One used by the same pods does
+	r.changesSeen.Store("default/features", seenChange{version: "v2", at: time.Now().Add(-10 * time.Second)})
+	if wait := r.coalesceWait(ctx, cfg, app); wait <= 15*time.Second || wait > 20*time.Second {
+		t.Errorf("Expected to wait out the rest of the window, got %v", wait)
+	}
+
+	if wait := r.coalesceWait(ctx, operatorConfig{}, app); wait != 0 {
+		t.Errorf("Expected no wait when disabled, got %v", wait)
+	}
+}
+
+func TestFindPodsUsingConfigMap_SkipsPodsStartedAfterChange(t *testing.T) {
+	r, fakeClient := setupTestReconciler()
+	ctx := context.Background()
+
+	r.noteChangeSeen("default/features", "v2")
+	_ = fakeClient.Create(ctx, podUsingConfigMaps("web-old", time.Now().Add(-time.Hour), "app", "features"))
+	// Replaced by the restart for the app ConfigMap
+	_ = fakeClient.Create(ctx, podUsingConfigMaps("web-new", time.Now().Add(time.Minute), "app", "features"))
+
+	features := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "features", Namespace: "default"}}
+	pods := r.findPodsUsingConfigMap(ctx, features, operatorConfig{})
+	if len(pods) != 1 || pods[0].Name != "web-old" {
+		t.Errorf("Expected only web-old to be restarted, got %v", podNames(pods))
+	}
+
+	// Without a known change time every pod is restarted
+	app := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "default"}}
+	if pods := r.findPodsUsingConfigMap(ctx, app, operatorConfig{}); len(pods) != 2 {
+		t.Errorf("Expected both pods to be restarted, got %v", podNames(pods))
+	}
+}
+
+func TestReconcile_CoalescesChangedConfigMaps(t *testing.T) {
+	r, fakeClient := setupTestReconciler()
+	ctx := context.Background()
+
+	_ = fakeClient.Create(ctx, &autoapplyv1alpha1.AutoApplyConfig{
+		ObjectMeta: metav1.ObjectMeta{Name: "coalesce"},
+		Spec: autoapplyv1alpha1.AutoApplyConfigSpec{
+			CoalesceWindow: &metav1.Duration{Duration: time.Minute},
+		},
+	})
+	for _, name := range []string{"app", "features"} {
+		_ = fakeClient.Create(ctx, &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+			Data:       map[string]string{"key": "new"},
+		})
+		r.configMapVersions.Store("default/"+name, "old-version")
+	}
+	_ = fakeClient.Create(ctx, podUsingConfigMaps("web", time.Now().Add(-time.Hour), "app", "features"))
+
+	countPods := func() int {
+		var pods corev1.PodList
+		_ = fakeClient.List(ctx, &pods, client.InNamespace("default"))
+		return len(pods.Items)
+	}
+
+	for _, name := range []string{"app", "features"} {
+		req := ctrl.Request{NamespacedName: types.NamespacedName{Name: name, Namespace: "default"}}
+		result, err := r.Reconcile(ctx, req)
+		if err != nil {
+			t.Fatalf("Reconcile failed: %v", err)
+		}
+		if result.RequeueAfter <= 0 || result.RequeueAfter > time.Minute {
+			t.Errorf("Expected %s to wait for the coalesce window, got %v", name, result.RequeueAfter)
+		}
+	}
+	if countPods() != 1 {
+		t.Fatal("Expected the pod to be kept while changes coalesce")
+	}
+
+	// The window passes: the first ConfigMap restarts the pod for both
+	for _, name := range []string{"app", "features"} {
+		value, _ := r.changesSeen.Load("default/" + name)
+		seen := value.(seenChange)
+		seen.at = seen.at.Add(-2 * time.Minute)
+		r.changesSeen.Store("default/"+name, seen)
+	}
+	if _, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: types.NamespacedName{Name: "app", Namespace: "default"}}); err != nil {
+		t.Fatalf("Reconcile failed: %v", err)
+	}
+	if countPods() != 0 {
+		t.Fatal("Expected the pod to be restarted")
+	}
+
+	// Its replacement started with both changes
+	_ = fakeClient.Create(ctx, podUsingConfigMaps("web-2", time.Now().Add(time.Minute), "app", "features"))
+	if _, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: types.NamespacedName{Name: "features", Namespace: "default"}}); err != nil {
+		t.Fatalf("Reconcile failed: %v", err)
+	}
+	if countPods() != 1 {
+		t.Error("Expected the replacement not to be restarted again")
+	}
+}
@@ -0,0 +1,84 @@
+package controller
+
+import (
+	"context"
+	"slices"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// Values of coexistenceAnnotation
+const (
+	coexistenceDefer  = "defer"
+	coexistenceWarn   = "warn"
+	coexistenceIgnore = "ignore"
+)
+
+// Annotations other restart operators are configured with
+const (
+	reloaderAutoAnnotation          = "reloader.stakater.com/auto"
+	reloaderConfigMapAutoAnnotation = "configmap.reloader.stakater.com/auto"
+	reloaderReloadAnnotation        = "configmap.reloader.stakater.com/reload"
+	reloaderSearchAnnotation        = "reloader.stakater.com/search"
+	// reloaderMatchAnnotation on a ConfigMap selects it for search mode
+	reloaderMatchAnnotation = "reloader.stakater.com/match"
+	// reloaderIgnoreAnnotation on a ConfigMap hides it from Reloader
+	reloaderIgnoreAnnotation = "reloader.stakater.com/ignore"
+	waveAnnotation           = "wave.pusher.com/update-on-config-change"
+)
+
+// coexistencePolicy returns the resolved coexistence policy, defer unless
+// warn or ignore is set
+func coexistencePolicy(annotations map[string]string) string {
+	switch policy := annotations[coexistenceAnnotation]; policy {
+	case coexistenceWarn, coexistenceIgnore:
+		return policy
+	default:
+		return coexistenceDefer
+	}
+}
+
+// competingRestarter returns the name of another system that restarts the pod
+// when the ConfigMap changes, judging by its resolved annotations, or "" if
+// there is none
+func competingRestarter(annotations map[string]string, configMap *corev1.ConfigMap) string {
+	if reloaderRestarts(annotations, configMap) {
+		return "Reloader"
+	}
+	if annotations[waveAnnotation] == "true" {
+		return "Wave"
+	}
+	return ""
+}
+
+// reloaderRestarts checks if Stakater Reloader restarts the workload for the ConfigMap
+func reloaderRestarts(annotations map[string]string, configMap *corev1.ConfigMap) bool {
+	if configMap.Annotations[reloaderIgnoreAnnotation] == "true" {
+		return false
+	}
+	if annotations[reloaderAutoAnnotation] == "true" || annotations[reloaderConfigMapAutoAnnotation] == "true" {
+		return true
+	}
+	if annotations[reloaderSearchAnnotation] == "true" && configMap.Annotations[reloaderMatchAnnotation] == "true" {
+		return true
+	}
+	names := strings.Split(annotations[reloaderReloadAnnotation], ",")
+	for i := range names {
+		names[i] = strings.TrimSpace(names[i])
+	}
+	return slices.Contains(names, configMap.Name)
+}
+
+// warnCompetingRestarter records a Warning Event on a pod about to be
+// restarted that another system restarts too, if its policy is warn
+func (r *ConfigMapReconciler) warnCompetingRestarter(ctx context.Context, configMap *corev1.ConfigMap, pod *corev1.Pod, annotationCache workloadAnnotationCache) {
+	annotations := r.resolvePodAnnotations(ctx, pod, annotationCache)
+	if coexistencePolicy(annotations) != coexistenceWarn {
+		return
+	}
+	if competitor := competingRestarter(annotations, configMap); competitor != "" {
+		r.Recorder.Eventf(pod, corev1.EventTypeWarning, "CompetingRestarter",
+			"Restarting for change in ConfigMap %s, which %s restarts this pod for too", configMap.Name, competitor)
+	}
+}
@@ -0,0 +1,98 @@
+package controller
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+func TestCompetingRestarter(t *testing.T) {
+	configMap := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "app-config"}}
+	matched := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{
+		Name:        "app-config",
+		Annotations: map[string]string{reloaderMatchAnnotation: "true"},
+	}}
+	ignored := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{
+		Name:        "app-config",
+		Annotations: map[string]string{reloaderIgnoreAnnotation: "true"},
+	}}
+
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		configMap   *corev1.ConfigMap
+		expected    string
+	}{
+		{"none", nil, configMap, ""},
+		{"reloader auto", map[string]string{reloaderAutoAnnotation: "true"}, configMap, "Reloader"},
+		{"reloader configmap auto", map[string]string{reloaderConfigMapAutoAnnotation: "true"}, configMap, "Reloader"},
+		{"reloader listed", map[string]string{reloaderReloadAnnotation: "other, app-config"}, configMap, "Reloader"},
+		{"reloader not listed", map[string]string{reloaderReloadAnnotation: "other"}, configMap, ""},
+		{"reloader search", map[string]string{reloaderSearchAnnotation: "true"}, matched, "Reloader"},
+		{"reloader search unmatched", map[string]string{reloaderSearchAnnotation: "true"}, configMap, ""},
+		{"reloader ignored", map[string]string{reloaderAutoAnnotation: "true"}, ignored, ""},
+		{"wave", map[string]string{waveAnnotation: "true"}, configMap, "Wave"},
+		// Our own checksum only rolls the workload out when it's re-applied
+		{"checksum webhook", map[string]string{configChecksumAnnotation: "abc"}, configMap, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := competingRestarter(tt.annotations, tt.configMap); got != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestFindPodsUsingConfigMap_Coexistence(t *testing.T) {
+	tests := []struct {
+		policy   string
+		restart  bool
+		expected string
+	}{
+		{"", false, "Normal DeferredRestart Not restarted for change in ConfigMap my-config, left to Reloader"},
+		{coexistenceDefer, false, "Normal DeferredRestart Not restarted for change in ConfigMap my-config, left to Reloader"},
+		{coexistenceWarn, true, "Warning CompetingRestarter Restarting for change in ConfigMap my-config, which Reloader restarts this pod for too"},
+		{coexistenceIgnore, true, ""},
+	}
+	for _, tt := range tests {
+		t.Run("policy "+tt.policy, func(t *testing.T) {
+			r, fakeClient := setupTestReconciler()
+			ctx := context.Background()
+
+			annotations := map[string]string{reloaderAutoAnnotation: "true"}
+			if tt.policy != "" {
+				annotations[coexistenceAnnotation] = tt.policy
+			}
+			ownerRefs := createDeploymentWithReplicaSet(ctx, fakeClient, &appsv1.Deployment{
+				ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default", Annotations: annotations},
+			})
+			pod := configVolumePod("web-1", "")
+			pod.OwnerReferences = ownerRefs
+			_ = fakeClient.Create(ctx, pod)
+
+			cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "my-config", Namespace: "default"}}
+			pods := r.findPodsUsingConfigMap(ctx, cm, operatorConfig{})
+			if restarted := len(pods) == 1; restarted != tt.restart {
+				t.Errorf("Expected restart %v, got pods %v", tt.restart, podNames(pods))
+			}
+
+			events := r.Recorder.(*record.FakeRecorder).Events
+			var got []string
+			for len(events) > 0 {
+				got = append(got, <-events)
+			}
+			if tt.expected == "" && len(got) != 0 {
+				t.Errorf("Expected no events, got %v", got)
+			}
+			if tt.expected != "" && (len(got) != 1 || !strings.HasPrefix(got[0], tt.expected)) {
+				t.Errorf("Expected event %q, got %v", tt.expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,194 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	autoapplyv1alpha1 "github.com/manos/k8s-autoapply-operator/api/v1alpha1"
+)
+
+// How often excluded pod and namespace counts and upcoming maintenance
+// windows are refreshed
+const configStatusRefreshInterval = 5 * time.Minute
+
+// AutoApplyConfigReconciler validates AutoApplyConfigs and reports the result,
+// what they currently exclude and their upcoming maintenance windows in their
+// status
+type AutoApplyConfigReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+}
+
+// +kubebuilder:rbac:groups=autoapply.io,resources=autoapplyconfigs/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups="",resources=namespaces,verbs=get;list;watch
+
+func (r *AutoApplyConfigReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	var config autoapplyv1alpha1.AutoApplyConfig
+	if err := r.Get(ctx, req.NamespacedName, &config); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	excludedPods, err := r.countExcludedPods(ctx, &config.Spec)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	excludedNamespaces, err := r.countExcludedNamespaces(ctx, &config.Spec)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	status := config.Status.DeepCopy()
+	status.ExcludedPods = excludedPods
+	status.ExcludedNamespaces = excludedNamespaces
+	status.UpcomingWindows = upcomingMaintenanceWindows(&config.Spec, time.Now())
+	meta.SetStatusCondition(&status.Conditions, readyCondition(&config))
+
+	if !equality.Semantic.DeepEqual(status, &config.Status) {
+		status.LastUpdated = metav1.Now()
+		config.Status = *status
+		if err := r.Status().Update(ctx, &config); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	return ctrl.Result{RequeueAfter: configStatusRefreshInterval}, nil
+}
+
+// readyCondition reports whether the config's spec is valid
+func readyCondition(config *autoapplyv1alpha1.AutoApplyConfig) metav1.Condition {
+	condition := metav1.Condition{
+		Type:               autoapplyv1alpha1.ConditionReady,
+		Status:             metav1.ConditionTrue,
+		Reason:             "Valid",
+		Message:            "Config is valid",
+		ObservedGeneration: config.Generation,
+	}
+	if problems := validateConfigSpec(&config.Spec); len(problems) > 0 {
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = "Invalid"
+		condition.Message = strings.Join(problems, "; ")
+	}
+	return condition
+}
+
+// validateConfigSpec returns the entries of a spec the operator would
+// silently ignore
+func validateConfigSpec(spec *autoapplyv1alpha1.AutoApplyConfigSpec) []string {
+	var problems []string
+
+	for i, pattern := range spec.ExcludePods {
+		if _, err := regexp.Compile(pattern); err != nil {
+			problems = append(problems, fmt.Sprintf("excludePods[%d]: %v", i, err))
+		}
+	}
+	for i, pattern := range spec.ExcludeConfigMaps {
+		if _, err := regexp.Compile(pattern); err != nil {
+			problems = append(problems, fmt.Sprintf("excludeConfigMaps[%d]: %v", i, err))
+		}
+	}
+	if spec.ConfigMapSelector != nil {
+		if _, err := metav1.LabelSelectorAsSelector(spec.ConfigMapSelector); err != nil {
+			problems = append(problems, fmt.Sprintf("configMapSelector: %v", err))
+		}
+	}
+	for i, window := range spec.MaintenanceWindows {
+		if _, err := parseMaintenanceWindow(window); err != nil {
+			problems = append(problems, fmt.Sprintf("maintenanceWindows[%d]: %v", i, err))
+		}
+	}
+	for i, notification := range spec.Notifications {
+		if notification.URL == "" && notification.URLSecretRef == nil {
+			problems = append(problems, fmt.Sprintf("notifications[%d]: url or urlSecretRef is required", i))
+		}
+		if notification.Template != "" {
+			if _, err := parseNotificationTemplate(notification.Template); err != nil {
+				problems = append(problems, fmt.Sprintf("notifications[%d]: %v", i, err))
+			}
+		}
+	}
+	if detection := spec.ChangeDetection; detection != nil && detection.Mode == autoapplyv1alpha1.ChangeDetectionKeys && len(detection.Keys) == 0 {
+		problems = append(problems, "changeDetection: keys are required in Keys mode")
+	}
+	for i, step := range spec.BatchSteps {
+		if err := validateBatchStep(step); err != nil {
+			problems = append(problems, fmt.Sprintf("batchSteps[%d]: %v", i, err))
+		}
+	}
+	if job := spec.PreRestartJob; job != nil && len(job.Template.Spec.Template.Spec.Containers) == 0 {
+		problems = append(problems, "preRestartJob: template has no containers")
+	}
+
+	return problems
+}
+
+// countExcludedPods counts the pods whose name matches the spec's ExcludePods
+func (r *AutoApplyConfigReconciler) countExcludedPods(ctx context.Context, spec *autoapplyv1alpha1.AutoApplyConfigSpec) (int32, error) {
+	var patterns []*regexp.Regexp
+	for _, pattern := range spec.ExcludePods {
+		if re, err := regexp.Compile(pattern); err == nil {
+			patterns = append(patterns, re)
+		}
+	}
+	if len(patterns) == 0 {
+		return 0, nil
+	}
+
+	var pods corev1.PodList
+	if err := r.List(ctx, &pods); err != nil {
+		return 0, err
+	}
+
+	var count int32
+	for _, pod := range pods.Items {
+		for _, re := range patterns {
+			if re.MatchString(pod.Name) {
+				count++
+				break
+			}
+		}
+	}
+	return count, nil
+}
+
+// countExcludedNamespaces counts the spec's ExcludeNamespaces that exist
+func (r *AutoApplyConfigReconciler) countExcludedNamespaces(ctx context.Context, spec *autoapplyv1alpha1.AutoApplyConfigSpec) (int32, error) {
+	if len(spec.ExcludeNamespaces) == 0 {
+		return 0, nil
+	}
+
+	var namespaces corev1.NamespaceList
+	if err := r.List(ctx, &namespaces); err != nil {
+		return 0, err
+	}
+
+	excluded := make(map[string]bool)
+	for _, ns := range spec.ExcludeNamespaces {
+		excluded[ns] = true
+	}
+	var count int32
+	for _, ns := range namespaces.Items {
+		if excluded[ns.Name] {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (r *AutoApplyConfigReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&autoapplyv1alpha1.AutoApplyConfig{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Complete(r)
+}
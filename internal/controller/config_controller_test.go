@@ -0,0 +1,102 @@
+package controller
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	autoapplyv1alpha1 "github.com/manos/k8s-autoapply-operator/api/v1alpha1"
+)
+
+func TestValidateConfigSpec(t *testing.T) {
+	tests := []struct {
+		name     string
+		spec     autoapplyv1alpha1.AutoApplyConfigSpec
+		expected string
+	}{
+		{"valid", autoapplyv1alpha1.AutoApplyConfigSpec{ExcludePods: []string{"^db-.*"}}, ""},
+		{"bad pattern", autoapplyv1alpha1.AutoApplyConfigSpec{ExcludePods: []string{"^db-.*", "(["}}, "excludePods[1]"},
+		{"bad window", autoapplyv1alpha1.AutoApplyConfigSpec{
+			MaintenanceWindows: []autoapplyv1alpha1.MaintenanceWindow{{Start: "25:00", End: "03:00"}},
+		}, "maintenanceWindows[0]"},
+		{"bad selector", autoapplyv1alpha1.AutoApplyConfigSpec{
+			ConfigMapSelector: &metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{{Key: "app", Operator: "Bogus"}}},
+		}, "configMapSelector"},
+		{"notification without url", autoapplyv1alpha1.AutoApplyConfigSpec{
+			Notifications: []autoapplyv1alpha1.Notification{{Format: autoapplyv1alpha1.NotificationFormatSlack}},
+		}, "notifications[0]"},
+		{"bad notification template", autoapplyv1alpha1.AutoApplyConfigSpec{
+			Notifications: []autoapplyv1alpha1.Notification{{URL: "http://hooks", Template: "{{.Pods"}},
+		}, "notifications[0]"},
+		{"keys mode without keys", autoapplyv1alpha1.AutoApplyConfigSpec{
+			ChangeDetection: &autoapplyv1alpha1.ChangeDetection{Mode: autoapplyv1alpha1.ChangeDetectionKeys},
+		}, "changeDetection"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			problems := strings.Join(validateConfigSpec(&tt.spec), "; ")
+			if tt.expected == "" && problems != "" {
+				t.Errorf("Expected no problems, got %q", problems)
+			}
+			if !strings.Contains(problems, tt.expected) {
+				t.Errorf("Expected a problem with %s, got %q", tt.expected, problems)
+			}
+		})
+	}
+}
+
+func TestAutoApplyConfigReconciler_Status(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = autoapplyv1alpha1.AddToScheme(scheme)
+
+	config := &autoapplyv1alpha1.AutoApplyConfig{
+		ObjectMeta: metav1.ObjectMeta{Name: "exclusions"},
+		Spec: autoapplyv1alpha1.AutoApplyConfigSpec{
+			ExcludePods:       []string{"^db-.*", "(["},
+			ExcludeNamespaces: []string{"monitoring", "does-not-exist"},
+		},
+	}
+	fakeClient := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithStatusSubresource(&autoapplyv1alpha1.AutoApplyConfig{}).
+		WithObjects(
+			config,
+			&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "monitoring"}},
+			&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "db-0", Namespace: "default"}},
+			&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "web-0", Namespace: "default"}},
+		).
+		Build()
+	r := &AutoApplyConfigReconciler{Client: fakeClient, Scheme: scheme}
+	ctx := context.Background()
+
+	result, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: types.NamespacedName{Name: "exclusions"}})
+	if err != nil {
+		t.Fatalf("Reconcile failed: %v", err)
+	}
+	if result.RequeueAfter != configStatusRefreshInterval {
+		t.Errorf("Expected a refresh after %v, got %v", configStatusRefreshInterval, result.RequeueAfter)
+	}
+
+	var updated autoapplyv1alpha1.AutoApplyConfig
+	_ = fakeClient.Get(ctx, types.NamespacedName{Name: "exclusions"}, &updated)
+
+	ready := meta.FindStatusCondition(updated.Status.Conditions, autoapplyv1alpha1.ConditionReady)
+	if ready == nil || ready.Status != metav1.ConditionFalse || ready.Reason != "Invalid" {
+		t.Errorf("Expected an Invalid Ready condition, got %+v", ready)
+	}
+	if updated.Status.ExcludedPods != 1 || updated.Status.ExcludedNamespaces != 1 {
+		t.Errorf("Expected 1 excluded pod and namespace, got %d and %d",
+			updated.Status.ExcludedPods, updated.Status.ExcludedNamespaces)
+	}
+}
@@ -4,19 +4,27 @@ import (
 	"context"
 	"fmt"
 	"regexp"
+	"slices"
+	"strings"
 	"sync"
 	"time"
 
+	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	policyv1 "k8s.io/api/policy/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/ptr"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
 
 	autoapplyv1alpha1 "github.com/manos/k8s-autoapply-operator/api/v1alpha1"
 )
@@ -28,23 +36,148 @@ const (
 	podReadyTimeout = 120 * time.Second
 	// Poll interval when waiting for pods or PDB
 	pollInterval = 1 * time.Second
-	// Max time to wait for PDB to allow a deletion
+	// Max time to keep retrying evictions blocked by a PDB
 	pdbWaitTimeout = 5 * time.Minute
+	// Max owners (Deployments/StatefulSets/...) restarting at once
+	maxConcurrentOwners = 5
+	// Default deadline for a whole restart operation
+	defaultRestartTimeout = 30 * time.Minute
+	// Max pod names listed in an Event message
+	maxListedPods = 10
+	// Default time a canary must stay Ready before the rest of its owner restarts
+	defaultCanarySoakDuration = 1 * time.Minute
 )
 
 // ConfigMapReconciler watches ConfigMaps and restarts pods that use them
 type ConfigMapReconciler struct {
 	client.Client
-	Scheme *runtime.Scheme
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
 
-	// configMapVersions tracks the last seen ResourceVersion for each ConfigMap
+	// Hooks run custom logic around restarts, see RestartHook
+	Hooks []RestartHook
+
+	// Policy, if set, decides on every batch before it's restarted
+	Policy *RestartPolicy
+
+	// MaxConcurrentReconciles is how many ConfigMaps are handled in parallel (default 1)
+	MaxConcurrentReconciles int
+
+	// RecordOperations creates a RestartOperation for every restart
+	RecordOperations bool
+
+	// PreflightChecks verifies the operator's permissions before restarting
+	PreflightChecks bool
+
+	// SerializeNamespaces allows only one restart per namespace at a time
+	// when several ConfigMaps are reconciled concurrently
+	SerializeNamespaces bool
+
+	// StaleConfigScanInterval is how often pods still running config from
+	// before a handled change are looked for; zero disables the scan
+	StaleConfigScanInterval time.Duration
+
+	// StaleConfigEvents emits a StaleConfig Event for ConfigMaps with stale pods
+	StaleConfigEvents bool
+
+	// ChangeDetector, if set, replaces the configured change detection for
+	// every ConfigMap
+	ChangeDetector ChangeDetector
+
+	// MaxPodRestartsPerMinute limits pod restarts across all ConfigMaps;
+	// zero means unlimited
+	MaxPodRestartsPerMinute int
+
+	// MaxConcurrentRestarts limits restart operations running at once across
+	// all namespaces; zero means unlimited
+	MaxConcurrentRestarts int
+
+	// HighChurnChanges and HighChurnWindow suppress restarts for ConfigMaps
+	// changing more than HighChurnChanges times within HighChurnWindow until
+	// they settle; zero disables it
+	HighChurnChanges int
+	HighChurnWindow  time.Duration
+
+	// ExcludeDaemonSets leaves DaemonSet pods running unless a config sets
+	// excludeDaemonSets to false
+	ExcludeDaemonSets bool
+
+	// MaxNotReadyNodes and MaxCordonedNodes pause restarts of non-critical
+	// configs while more nodes than these are NotReady or cordoned, as
+	// during an outage or a cluster upgrade; zero disables each check
+	MaxNotReadyNodes int
+	MaxCordonedNodes int
+
+	// UpgradeConfigMap and UpgradeLease, if set, signal a cluster upgrade
+	// while the ConfigMap exists or the Lease is held. DetectNodeUpgrades
+	// also treats nodes running different kubelet versions as one. Restarts
+	// of non-critical configs are paused like for node disruptions.
+	UpgradeConfigMap   types.NamespacedName
+	UpgradeLease       types.NamespacedName
+	DetectNodeUpgrades bool
+
+	// ForceDeleteTerminatingAfter force-deletes restarted pods still
+	// terminating this long past their grace period; zero never does
+	ForceDeleteTerminatingAfter time.Duration
+
+	// NamespaceNotificationHosts are the hosts notifications from namespace
+	// configs may be sent to. Tenants can write those configs, so their
+	// notifications to any other host are dropped.
+	NamespaceNotificationHosts []string
+
+	// restartBudget enforces the limits above, see budget
+	restartBudget *restartBudget
+	budgetOnce    sync.Once
+
+	// configMapVersions tracks the last seen data hash for each ConfigMap
 	configMapVersions sync.Map
+
+	// handledKeys holds the key hashes of each ConfigMap's last handled version
+	handledKeys sync.Map
+
+	// pendingRestarts tracks ConfigMaps whose change is waiting for a maintenance
+	// window, permissions or another restart in the namespace
+	pendingRestarts sync.Map
+
+	// debouncing tracks ConfigMaps whose change is waiting to settle (debounceEntry)
+	debouncing sync.Map
+
+	// trickles tracks in-progress trickle restarts (trickleState)
+	trickles sync.Map
+
+	// restarts tracks in-progress restarts spanning reconciles (*restartState)
+	restarts sync.Map
+
+	// churn tracks recent changes per ConfigMap (*churnState)
+	churn sync.Map
+
+	// changesSeen tracks when the latest change of each ConfigMap was first
+	// seen (seenChange), until it reached every selected pod
+	changesSeen sync.Map
+
+	// pdbRetries tracks pods a PDB kept from being restarted (*pdbRetry)
+	pdbRetries sync.Map
+
+	// restartRecommended tracks workloads given a RestartRecommended condition
+	// in notify-only mode (namespacedWorkload), until their pods are current
+	restartRecommended sync.Map
+
+	// disruptionPaused tracks ConfigMaps whose restart is paused for a node
+	// disruption, with the reason
+	disruptionPaused sync.Map
+
+	// operations tracks the RestartOperation of in-progress restarts (*operationRecord)
+	operations sync.Map
+
+	// namespaceLocks holds a restart lock per namespace (*sync.Mutex)
+	namespaceLocks sync.Map
 }
 
-// +kubebuilder:rbac:groups="",resources=configmaps,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=configmaps,verbs=get;list;watch;patch
 // +kubebuilder:rbac:groups="",resources=pods,verbs=get;list;watch;delete
-// +kubebuilder:rbac:groups=policy,resources=poddisruptionbudgets,verbs=get;list;watch
-// +kubebuilder:rbac:groups=autoapply.io,resources=autoapplyconfigs,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=pods/eviction,verbs=create
+// +kubebuilder:rbac:groups=autoapply.io,resources=autoapplyconfigs;autoapplynamespaceconfigs,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=events,verbs=create;patch
 
 func (r *ConfigMapReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 	logger := log.FromContext(ctx)
@@ -54,98 +187,405 @@ func (r *ConfigMapReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 	if err := r.Get(ctx, req.NamespacedName, &configMap); err != nil {
 		// ConfigMap deleted, clean up tracking
 		r.configMapVersions.Delete(req.String())
+		r.handledKeys.Delete(req.String())
+		r.pendingRestarts.Delete(req.String())
+		r.debouncing.Delete(req.String())
+		r.trickles.Delete(req.String())
+		r.abandonRestart(ctx, req.Namespace, req.String())
+		r.pdbRetries.Delete(req.String())
+		r.churn.Delete(req.String())
+		r.changesSeen.Delete(req.String())
+		r.operations.Delete(req.String())
+		r.disruptionPaused.Delete(req.String())
+		deleteConfigMapMetrics(req.Namespace, req.Name)
 		return ctrl.Result{}, client.IgnoreNotFound(err)
 	}
 
-	// Check if this is an update (not first time seeing it)
+	// Load config
+	cfg := r.loadConfig(ctx, &configMap)
+
+	// A restart in progress takes its next steps before any newer change is
+	// looked at, which is handled once it's done
 	key := req.String()
+	if state := r.restartInProgress(ctx, &configMap, key); state != nil {
+		return r.stepRestart(ctx, cfg, &configMap, key, state), nil
+	}
+
+	// Check if this is an update (not first time seeing it)
+	version := r.changeDetector(cfg).Version(&configMap)
 	lastVersion, seen := r.configMapVersions.Load(key)
-	r.configMapVersions.Store(key, configMap.ResourceVersion)
+	if !seen {
+		// After an operator restart, fall back to the version persisted on the ConfigMap
+		if persisted, ok := persistedVersion(&configMap); ok {
+			lastVersion, seen = persisted, true
+		}
+	}
+	if seen && versionScheme(lastVersion.(string)) != versionScheme(version) {
+		// Versions computed differently can't be compared
+		logger.Info("Change detection changed, tracking the ConfigMap's new version", "configmap", req.NamespacedName)
+		seen = false
+	}
+	r.configMapVersions.Store(key, version)
 
 	if !seen {
-		// First time seeing this ConfigMap, just track it
+		// First time seeing this ConfigMap, just track it. Only ConfigMaps
+		// pods use need a baseline persisted; patching every ConfigMap in
+		// the cluster on startup would be a write for each of them.
 		logger.V(1).Info("Tracking ConfigMap", "configmap", req.NamespacedName)
+		if !cfg.isNamespaceExcluded(configMap.Namespace) && !cfg.isConfigMapExcluded(configMap.Namespace, configMap.Name) &&
+			r.configMapInUse(ctx, &configMap) {
+			r.persistVersion(ctx, cfg, &configMap, version, time.Time{})
+		}
 		return ctrl.Result{}, nil
 	}
 
-	if lastVersion == configMap.ResourceVersion {
+	_, pending := r.pendingRestarts.Load(key)
+	_, settling := r.debouncing.Load(key)
+	_, trickling := r.trickles.Load(key)
+	if !trickling {
+		// A trickle a restarted operator was in the middle of
+		_, trickling = persistedTrickleStart(&configMap, version)
+	}
+	retrying := r.retryingBlockedPods(key, version)
+	suppressing := r.churnSuppressing(key)
+	if lastVersion == version && !pending && !settling && !trickling && !retrying && !suppressing {
 		// No change
 		return ctrl.Result{}, nil
 	}
+	changed := lastVersion != version
+	if changed {
+		configMapChanged(configMap.Namespace, configMap.Name)
+		r.noteChangeSeen(key, version)
+	}
 
 	logger.Info("ConfigMap changed, finding affected pods", "configmap", req.NamespacedName)
 
-	// Load config
-	cfg := r.loadConfig(ctx)
-
 	// Skip if namespace is excluded
-	for _, ns := range cfg.excludeNamespaces {
-		if ns == configMap.Namespace {
-			logger.Info("Namespace excluded, skipping", "namespace", configMap.Namespace)
+	if cfg.isNamespaceExcluded(configMap.Namespace) {
+		logger.Info("Namespace excluded, skipping", "namespace", configMap.Namespace)
+		return ctrl.Result{}, nil
+	}
+
+	// Skip ConfigMaps whose changes are ignored
+	if cfg.isConfigMapExcluded(configMap.Namespace, configMap.Name) {
+		logger.Info("ConfigMap excluded, skipping")
+		return ctrl.Result{}, nil
+	}
+
+	// ConfigMaps rewritten all the time (leader election, status dumps) only
+	// get their latest change handled once they settle
+	if r.churnSuppressed(ctx, key, &configMap, changed) {
+		r.debouncing.Delete(key)
+		r.pendingRestarts.Delete(key)
+		return ctrl.Result{RequeueAfter: r.HighChurnWindow}, nil
+	}
+
+	// Wait for rapid successive updates to settle
+	if wait := r.debounce(key, version, cfg.debounceDuration); wait > 0 {
+		logger.Info("Waiting for ConfigMap to stop changing", "delay", wait)
+		return ctrl.Result{RequeueAfter: wait}, nil
+	}
+
+	// Let changes to other ConfigMaps of the same pods join this restart
+	if wait := r.coalesceWait(ctx, cfg, &configMap); wait > 0 {
+		logger.Info("Waiting for other ConfigMaps of the pods to stop changing", "delay", wait)
+		r.pendingRestarts.Store(key, struct{}{})
+		return ctrl.Result{RequeueAfter: wait}, nil
+	}
+
+	// Notify-only mode recommends restarts, which needs no window or approval
+	if cfg.notifyOnly {
+		return r.notifyOnly(ctx, cfg, &configMap, key, version)
+	}
+
+	// Queue the change until a maintenance window opens
+	if open, next := cfg.restartWindowOpen(time.Now()); !open {
+		r.pendingRestarts.Store(key, struct{}{})
+		if next.IsZero() {
+			logger.Info("Outside maintenance windows and none will open, restart stays queued")
 			return ctrl.Result{}, nil
 		}
+		logger.Info("Outside maintenance windows, queueing restart", "windowOpens", next)
+		r.Recorder.Eventf(&configMap, corev1.EventTypeNormal, "RestartQueued",
+			"Change queued until a maintenance window opens at %s", next.Format(time.RFC3339))
+		return ctrl.Result{RequeueAfter: time.Until(next)}, nil
+	}
+	r.pendingRestarts.Delete(key)
+
+	// Wait out node disruptions unless the change is critical
+	if !cfg.critical && !cfg.dryRun {
+		if paused, result := r.pauseForNodeDisruption(ctx, &configMap, key); paused {
+			r.pendingRestarts.Store(key, struct{}{})
+			return result, nil
+		}
+	}
+
+	// Hold the restart until someone approves it. Retries of blocked pods and
+	// trickles already in progress were approved before.
+	if cfg.requireApproval && !cfg.dryRun && !retrying && !trickling {
+		if approved, result := r.awaitApproval(ctx, cfg, &configMap, version); !approved {
+			if result.RequeueAfter > 0 {
+				r.pendingRestarts.Store(key, struct{}{})
+			}
+			return result, nil
+		}
+	}
+
+	// Check permissions up front and retry the change until they're granted
+	var release func()
+	if !cfg.dryRun {
+		if ok, err := r.preflight(ctx, cfg, &configMap); !ok {
+			if err != nil {
+				logger.Error(err, "Permission preflight failed")
+			}
+			r.pendingRestarts.Store(key, struct{}{})
+			return ctrl.Result{RequeueAfter: preflightRetryInterval}, nil
+		}
+
+		var wait time.Duration
+		if release, wait = r.lockRestart(ctx, configMap.Namespace); release == nil {
+			r.pendingRestarts.Store(key, struct{}{})
+			return ctrl.Result{RequeueAfter: wait}, nil
+		}
+		// Held until this reconcile returns, unless a restart takes it over
+		defer func() {
+			if release != nil {
+				release()
+			}
+		}()
+	}
+
+	// Pods a PDB blocked during the last restart are retried on their own
+	if retrying {
+		return r.retryBlockedPods(ctx, cfg, &configMap, key)
 	}
+	r.pdbRetries.Delete(key)
+
+	// A one-shot annotation may override the strategy for this change
+	cfg = r.consumeNextChangeStrategy(ctx, &configMap, cfg)
+
+	// Trickle restarts span many reconciles and persist the version when done.
+	// One in progress keeps going even if the strategy changed meanwhile.
+	if (trickling || cfg.strategy == autoapplyv1alpha1.RestartStrategyTrickle) && !cfg.yoloMode && !cfg.dryRun {
+		return r.trickleStep(ctx, cfg, &configMap, key, version)
+	}
+	r.trickles.Delete(key)
 
 	// Find pods that use this ConfigMap
-	podsToRestart := r.findPodsUsingConfigMap(ctx, &configMap, cfg.excludePodPatterns)
+	podsToRestart := r.findPodsUsingConfigMap(ctx, &configMap, cfg)
 	if len(podsToRestart) == 0 {
 		logger.Info("No pods to restart")
+		r.finishOperation(ctx, &configMap, nil, 0)
+		r.persistVersion(ctx, cfg, &configMap, version, time.Now())
 		return ctrl.Result{}, nil
 	}
 
 	logger.Info("Found pods to restart", "count", len(podsToRestart))
 
-	if cfg.yoloMode {
-		// YOLO MODE: restart everything at once, no batching, no health checks
-		logger.Info("YOLO MODE: restarting all pods at once")
-		r.yoloRestart(ctx, podsToRestart)
-	} else {
-		// Safe mode: 50% per owner -> wait -> check health -> remaining 50%
-		if err := r.rollingRestart(ctx, configMap.Namespace, podsToRestart); err != nil {
-			logger.Error(err, "Rolling restart encountered errors")
+	if cfg.dryRun {
+		r.reportDryRun(ctx, cfg, &configMap, podsToRestart)
+		r.persistVersion(ctx, cfg, &configMap, version, time.Now())
+		return ctrl.Result{}, nil
+	}
+
+	// The restart spans reconciles from here and persists the version when
+	// done. Until then a restarted operator resumes it from the progress
+	// recorded on the ConfigMap, or sees the old version and handles the
+	// change again.
+	state := newRestart(version, podsToRestart)
+	state.changes = r.changedKeys(cfg, &configMap)
+	state.release, release = release, nil
+	r.restarts.Store(key, state)
+	return r.stepRestart(ctx, cfg, &configMap, key, state), nil
+}
+
+// reportRestartTimedOut reports pods still running stale config after the restart deadline
+func (r *ConfigMapReconciler) reportRestartTimedOut(ctx context.Context, cfg operatorConfig, configMap *corev1.ConfigMap, pods []corev1.Pod) {
+	logger := log.FromContext(ctx)
+
+	stale := r.podsStillRunning(ctx, pods)
+	names := podNames(stale)
+
+	logger.Error(context.DeadlineExceeded, "Restart timed out",
+		"timeout", cfg.restartTimeout,
+		"stalePods", names)
+
+	if len(names) > maxListedPods {
+		names = append(names[:maxListedPods], fmt.Sprintf("and %d more", len(stale)-maxListedPods))
+	}
+	r.Recorder.Eventf(configMap, corev1.EventTypeWarning, "RestartTimedOut",
+		"Restart did not finish within %s, %d pods still running stale config: %s",
+		cfg.restartTimeout, len(stale), strings.Join(names, ", "))
+}
+
+// podsStillRunning returns the given pods that still exist and aren't being deleted
+func (r *ConfigMapReconciler) podsStillRunning(ctx context.Context, pods []corev1.Pod) []corev1.Pod {
+	var running []corev1.Pod
+	for _, pod := range pods {
+		var current corev1.Pod
+		if err := r.Get(ctx, client.ObjectKeyFromObject(&pod), &current); err != nil {
+			continue
+		}
+		if current.UID != pod.UID || current.DeletionTimestamp != nil {
+			continue
 		}
+		running = append(running, current)
 	}
+	return running
+}
 
-	return ctrl.Result{}, nil
+// podNames returns the names of the given pods
+func podNames(pods []corev1.Pod) []string {
+	names := make([]string, 0, len(pods))
+	for _, pod := range pods {
+		names = append(names, pod.Name)
+	}
+	return names
 }
 
 // findPodsUsingConfigMap returns pods that reference the given ConfigMap
-func (r *ConfigMapReconciler) findPodsUsingConfigMap(ctx context.Context, configMap *corev1.ConfigMap, excludePatterns []*regexp.Regexp) []corev1.Pod {
+func (r *ConfigMapReconciler) findPodsUsingConfigMap(ctx context.Context, configMap *corev1.ConfigMap, cfg operatorConfig) []corev1.Pod {
 	logger := log.FromContext(ctx)
 
+	// Only pods referencing the ConfigMap, via the field index
 	var pods corev1.PodList
-	if err := r.List(ctx, &pods, client.InNamespace(configMap.Namespace)); err != nil {
+	if err := r.List(ctx, &pods, client.InNamespace(configMap.Namespace),
+		client.MatchingFields{podConfigMapIndex: configMap.Name}); err != nil {
 		logger.Error(err, "Failed to list pods")
 		return nil
 	}
 
 	var result []corev1.Pod
+	hotReloading := 0
+	annotationCache := make(workloadAnnotationCache)
+	rolloutCache := make(workloadRolloutCache)
+	changes := r.changedKeys(cfg, configMap)
 	for _, pod := range pods.Items {
-		// Skip completed/failed pods
-		if pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+		reason, ok := r.podSkipReason(ctx, configMap, &pod, cfg, annotationCache)
+		if !ok {
 			continue
 		}
-
-		// Skip pods being deleted
-		if pod.DeletionTimestamp != nil {
-			continue
+		// Pods that only take some keys wait for a change to one of them.
+		// Without a known baseline every consumer is restarted.
+		if reason == "" && !changes.empty() {
+			if keys, all := podConfigMapKeys(&pod, configMap.Name); !all && !changes.touches(keys) {
+				reason = skipReasonUnchangedKeys
+			}
 		}
-
-		// Check if pod is excluded
-		if r.isPodExcluded(pod.Name, excludePatterns) {
-			logger.V(1).Info("Pod excluded by pattern", "pod", pod.Name)
+		// Left out of podSkipReason on purpose, so pods the rollout created
+		// before the change still count as stale
+		if reason == "" && cfg.recentRolloutWindow > 0 && r.rolledOutRecently(ctx, &pod, cfg.recentRolloutWindow, rolloutCache) {
+			reason = skipReasonRollout
+		}
+		// Pods a restart for another ConfigMap already replaced
+		if reason == "" && r.startedWithChange(configMap, &pod) {
+			reason = skipReasonCurrent
+		}
+		if reason != "" {
+			logger.V(1).Info("Pod not restarted", "pod", pod.Name, "reason", reason)
+			if reason == skipReasonHotReload {
+				r.Recorder.Eventf(&pod, corev1.EventTypeNormal, "SkippedHotReload",
+					"Not restarted for change in ConfigMap %s, pod reloads config itself", configMap.Name)
+				hotReloading++
+			}
+			if competitor, ok := strings.CutPrefix(reason, skipReasonCompetitor); ok {
+				r.Recorder.Eventf(&pod, corev1.EventTypeNormal, "DeferredRestart",
+					"Not restarted for change in ConfigMap %s, left to %s", configMap.Name, competitor)
+			}
+			r.skipPod(ctx, configMap, &pod, reason)
 			continue
 		}
+		r.warnCompetingRestarter(ctx, configMap, &pod, annotationCache)
 
-		// Check if pod uses this ConfigMap
-		if r.podUsesConfigMap(&pod, configMap.Name) {
-			result = append(result, pod)
-		}
+		result = append(result, pod)
 	}
 
+	hotReloadPods.set(configMap.Namespace, configMap.Name, float64(hotReloading))
+
 	return result
 }
 
+// Reasons a pod using a changed ConfigMap is left running
+const (
+	skipReasonRefreshable   = "refreshed by kubelet"
+	skipReasonPattern       = "excluded by pattern"
+	skipReasonAnnotation    = "excluded by annotation"
+	skipReasonHotReload     = "reloads config itself"
+	skipReasonRollout       = "workload rolled out recently"
+	skipReasonCurrent       = "started after the change"
+	skipReasonUnchangedKeys = "uses no changed keys"
+	skipReasonDaemonSet     = "DaemonSet pods excluded"
+	skipReasonOptional      = "only optional references"
+	skipReasonPriority      = "priority above maxRestartPriority"
+	// followed by the competing restarter's name
+	skipReasonCompetitor = "left to "
+)
+
+// podSkipReason decides whether a change to the ConfigMap concerns the pod.
+// ok is false for pods that are finished, terminating or don't use the
+// ConfigMap; otherwise reason says why the pod isn't restarted, or is empty
+// if it should be.
+func (r *ConfigMapReconciler) podSkipReason(ctx context.Context, configMap *corev1.ConfigMap, pod *corev1.Pod, cfg operatorConfig, annotationCache workloadAnnotationCache) (reason string, ok bool) {
+	// Skip completed/failed pods
+	if pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+		return "", false
+	}
+
+	// Skip pods being deleted
+	if pod.DeletionTimestamp != nil {
+		return "", false
+	}
+
+	// Check if pod uses this ConfigMap
+	usage := podConfigMapUsage(pod, configMap.Name)
+	if !usage.any() {
+		return "", false
+	}
+
+	// Full volume mounts are refreshed by kubelet, subPath mounts and env are not
+	if cfg.skipRefreshableMounts && !usage.restartRequired() {
+		return skipReasonRefreshable, true
+	}
+
+	// Optional references are soft dependencies the pod copes without
+	if cfg.skipOptionalReferences && !usage.required {
+		return skipReasonOptional, true
+	}
+
+	// Check if pod is excluded
+	if r.isPodExcluded(pod.Name, cfg.excludePodPatterns) {
+		return skipReasonPattern, true
+	}
+
+	if cfg.excludeDaemonSets {
+		if owner := metav1.GetControllerOf(pod); owner != nil && owner.Kind == "DaemonSet" {
+			return skipReasonDaemonSet, true
+		}
+	}
+
+	if cfg.maxRestartPriority != nil && podPriority(pod) > *cfg.maxRestartPriority {
+		return skipReasonPriority, true
+	}
+
+	// Check if pod or its workload opted out via annotation
+	annotations := r.resolvePodAnnotations(ctx, pod, annotationCache)
+	if isAnnotatedExcluded(annotations) {
+		return skipReasonAnnotation, true
+	}
+
+	// Pods that reload config themselves only get the change recorded
+	if reloadsConfigItself(annotations) {
+		return skipReasonHotReload, true
+	}
+
+	// Pods another restart operator restarts for this change
+	if competitor := competingRestarter(annotations, configMap); competitor != "" && coexistencePolicy(annotations) == coexistenceDefer {
+		return skipReasonCompetitor + competitor, true
+	}
+
+	return "", true
+}
+
 // podsByOwner groups pods by their controller owner UID
 // Pods without an owner are grouped under a zero UID
 func podsByOwner(pods []corev1.Pod) map[types.UID][]corev1.Pod {
@@ -165,104 +605,128 @@ func podsByOwner(pods []corev1.Pod) map[types.UID][]corev1.Pod {
 	return groups
 }
 
-// rollingRestart performs a 50/50 rolling restart PER OWNER with health checks
-// It waits for PDBs to allow deletion rather than skipping pods
-func (r *ConfigMapReconciler) rollingRestart(ctx context.Context, namespace string, pods []corev1.Pod) error {
-	logger := log.FromContext(ctx)
+// workloadRef identifies the top-level workload that manages a pod
+type workloadRef struct {
+	Kind string
+	Name string
+}
 
-	if len(pods) == 0 {
-		return nil
+// resolveWorkload walks the pod's controller owner up to the top-level workload
+// (ReplicaSet -> Deployment). Returns nil for pods without a controller.
+func (r *ConfigMapReconciler) resolveWorkload(ctx context.Context, pod *corev1.Pod) (*workloadRef, error) {
+	owner := metav1.GetControllerOf(pod)
+	if owner == nil {
+		return nil, nil
 	}
 
-	// Group pods by owner (Deployment/StatefulSet/ReplicaSet)
-	ownerGroups := podsByOwner(pods)
-
-	logger.Info("Grouped pods by owner", "ownerCount", len(ownerGroups), "totalPods", len(pods))
-
-	// Split each owner's pods into two batches (50/50)
-	var firstBatch, secondBatch []corev1.Pod
-
-	for ownerUID, ownerPods := range ownerGroups {
-		midpoint := (len(ownerPods) + 1) / 2 // Round up for first batch
-		firstBatch = append(firstBatch, ownerPods[:midpoint]...)
-		secondBatch = append(secondBatch, ownerPods[midpoint:]...)
-
-		ownerName := "standalone"
-		if ownerUID != "" {
-			ownerName = string(ownerUID)
+	if owner.Kind == "ReplicaSet" {
+		var rs appsv1.ReplicaSet
+		if err := r.Get(ctx, types.NamespacedName{Namespace: pod.Namespace, Name: owner.Name}, &rs); err != nil {
+			return nil, err
+		}
+		if rsOwner := metav1.GetControllerOf(&rs); rsOwner != nil {
+			return &workloadRef{Kind: rsOwner.Kind, Name: rsOwner.Name}, nil
 		}
-		logger.V(1).Info("Split owner pods",
-			"owner", ownerName,
-			"total", len(ownerPods),
-			"firstBatch", midpoint,
-			"secondBatch", len(ownerPods)-midpoint)
 	}
 
-	logger.Info("Starting rolling restart",
-		"total", len(pods),
-		"firstBatch", len(firstBatch),
-		"secondBatch", len(secondBatch))
+	return &workloadRef{Kind: owner.Kind, Name: owner.Name}, nil
+}
 
-	// Restart first batch (waits for PDB to allow each deletion)
-	restartedPods, err := r.restartBatchWithPDBWait(ctx, namespace, firstBatch)
-	if err != nil {
-		return fmt.Errorf("first batch failed: %w", err)
+// splitOwnerPods splits one owner's pods in half, rounding up for the first batch.
+// The canary strategy puts a single pod in the first batch instead.
+func splitOwnerPods(strategy autoapplyv1alpha1.RestartStrategy, pods []corev1.Pod) (first, second []corev1.Pod) {
+	midpoint := (len(pods) + 1) / 2
+	if strategy == autoapplyv1alpha1.RestartStrategyCanary && len(pods) > 0 {
+		midpoint = 1
 	}
+	return pods[:midpoint], pods[midpoint:]
+}
 
-	if len(restartedPods) == 0 {
-		logger.Info("No pods were restarted in first batch")
-		return nil
+// ownerName returns a printable name for an owner UID from podsByOwner
+func ownerName(ownerUID types.UID) string {
+	if ownerUID == "" {
+		return "standalone"
 	}
+	return string(ownerUID)
+}
 
-	// If there's a second batch, wait and check health before continuing
-	if len(secondBatch) > 0 {
-		logger.Info("Waiting before second batch", "duration", batchWaitDuration)
-		time.Sleep(batchWaitDuration)
-
-		// Wait for first batch pods to be replaced and healthy
-		if err := r.waitForPodsHealthy(ctx, restartedPods); err != nil {
-			logger.Error(err, "First batch pods not healthy, aborting second batch")
-			return fmt.Errorf("first batch unhealthy: %w", err)
-		}
+// ownerBatches splits one owner's pods into restart batches: the halves from
+// splitOwnerPods or the parts from splitSteps when steps are set, each cut
+// into batches of at most limit pods when limit is positive
+func ownerBatches(strategy autoapplyv1alpha1.RestartStrategy, steps []intstr.IntOrString, pods []corev1.Pod, limit int) [][]corev1.Pod {
+	var parts [][]corev1.Pod
+	if len(steps) > 0 {
+		parts = splitSteps(strategy, steps, pods)
+	} else {
+		first, second := splitOwnerPods(strategy, pods)
+		parts = [][]corev1.Pod{first, second}
+	}
 
-		logger.Info("First batch healthy, restarting second batch")
-		if _, err := r.restartBatchWithPDBWait(ctx, namespace, secondBatch); err != nil {
-			return fmt.Errorf("second batch failed: %w", err)
+	var batches [][]corev1.Pod
+	for _, part := range parts {
+		for len(part) > 0 {
+			size := len(part)
+			if limit > 0 {
+				size = min(size, limit)
+			}
+			batches = append(batches, part[:size])
+			part = part[size:]
 		}
 	}
-
-	return nil
+	return batches
 }
 
-// yoloRestart deletes all pods at once without batching or health checks
-func (r *ConfigMapReconciler) yoloRestart(ctx context.Context, pods []corev1.Pod) {
+// yoloRestart deletes pods without batching or health checks, as many as
+// the restart budget allows
+func (r *ConfigMapReconciler) yoloRestart(ctx context.Context, configMap *corev1.ConfigMap, pods []corev1.Pod) (evictionPass, error) {
 	logger := log.FromContext(ctx)
+	var pass evictionPass
 
-	for _, pod := range pods {
+	for i, pod := range pods {
+		if wait := r.restartBudgetDelay(1); wait > 0 {
+			pass.held, pass.wait = pods[i:], wait
+			break
+		}
 		logger.Info("YOLO: Restarting pod", "pod", pod.Name)
 		if err := r.Delete(ctx, &pod); err != nil {
 			logger.Error(err, "Failed to delete pod", "pod", pod.Name)
+			continue
 		}
+		r.recordPodRestarted(&pod, configMap)
+		pass.restarted = append(pass.restarted, pod)
 	}
 
-	logger.Info("YOLO: All pods restarted", "count", len(pods))
+	logger.Info("YOLO: Pods restarted", "count", len(pass.restarted), "held", len(pass.held))
+	return pass, r.afterBatch(ctx, configMap, pass.restarted)
+}
+
+// evictionPass is the outcome of one pass evicting a batch's pods
+type evictionPass struct {
+	restarted []corev1.Pod
+	// blocked are pods whose eviction a PodDisruptionBudget rejected
+	blocked []corev1.Pod
+	// held are pods not tried yet, since the restart budget has no room or
+	// the last evicted pod drains until wait has passed
+	held []corev1.Pod
+	wait time.Duration
 }
 
-// restartBatchWithPDBWait deletes pods in a batch, waiting for PDB to allow each deletion
-func (r *ConfigMapReconciler) restartBatchWithPDBWait(ctx context.Context, namespace string, pods []corev1.Pod) ([]corev1.Pod, error) {
+// evictPods evicts pods through the Eviction API so the API server enforces
+// PodDisruptionBudgets atomically. Pods that are gone, replaced or already
+// terminating are skipped. Unless retrying pods a PDB blocked, which already
+// took their share, each eviction takes from the restart budget. A pod's
+// drain-seconds annotation holds back the rest of the pods.
+func (r *ConfigMapReconciler) evictPods(ctx context.Context, configMap *corev1.ConfigMap, pods []corev1.Pod, retrying bool) evictionPass {
 	logger := log.FromContext(ctx)
-	var restarted []corev1.Pod
+	var pass evictionPass
 
-	for _, pod := range pods {
-		// Wait for PDB to allow deletion
-		if err := r.waitForPDBAllowsDeletion(ctx, namespace, &pod); err != nil {
-			logger.Error(err, "Timeout waiting for PDB, skipping pod", "pod", pod.Name)
-			continue
-		}
+	// Evicted pods may ask for time to drain connections before the next goes
+	annotationCache := make(workloadAnnotationCache)
 
+	for i, pod := range pods {
 		// Re-fetch pod to make sure it still exists and hasn't changed
 		var currentPod corev1.Pod
-		if err := r.Get(ctx, client.ObjectKeyFromObject(&pod), &currentPod); err != nil {
+		if err := r.Get(ctx, client.ObjectKeyFromObject(&pod), &currentPod); err != nil || currentPod.UID != pod.UID {
 			logger.V(1).Info("Pod no longer exists, skipping", "pod", pod.Name)
 			continue
 		}
@@ -273,130 +737,129 @@ func (r *ConfigMapReconciler) restartBatchWithPDBWait(ctx context.Context, names
 			continue
 		}
 
-		logger.Info("Restarting pod", "pod", pod.Name)
-		if err := r.Delete(ctx, &currentPod); err != nil {
-			logger.Error(err, "Failed to delete pod", "pod", pod.Name)
-			continue
+		if !retrying {
+			if wait := r.restartBudgetDelay(1); wait > 0 {
+				logger.V(1).Info("Waiting for the cluster-wide restart budget", "count", len(pods)-i, "delay", wait)
+				pass.held, pass.wait = pods[i:], wait
+				break
+			}
 		}
-		restarted = append(restarted, currentPod)
-	}
 
-	return restarted, nil
-}
-
-// waitForPDBAllowsDeletion waits until PDB allows deleting the pod
-func (r *ConfigMapReconciler) waitForPDBAllowsDeletion(ctx context.Context, namespace string, pod *corev1.Pod) error {
-	logger := log.FromContext(ctx)
-	deadline := time.Now().Add(pdbWaitTimeout)
-
-	for time.Now().Before(deadline) {
-		// Reload PDBs to get current status
-		pdbs, err := r.loadPDBs(ctx, namespace)
-		if err != nil {
-			logger.Error(err, "Failed to load PDBs")
-			// If we can't load PDBs, proceed anyway
-			return nil
+		logger.Info("Restarting pod", "pod", pod.Name)
+		if err := r.evictPod(ctx, &currentPod); err != nil {
+			if apierrors.IsTooManyRequests(err) {
+				// PDB doesn't allow this disruption right now
+				logger.V(1).Info("Eviction blocked by PodDisruptionBudget", "pod", pod.Name)
+				pass.blocked = append(pass.blocked, currentPod)
+				continue
+			}
+			if apierrors.IsNotFound(err) {
+				logger.V(1).Info("Pod no longer exists, skipping", "pod", pod.Name)
+				continue
+			}
+			logger.Error(err, "Failed to evict pod", "pod", pod.Name)
+			continue
 		}
+		r.recordPodRestarted(&currentPod, configMap)
+		pass.restarted = append(pass.restarted, currentPod)
 
-		if r.canDeletePod(ctx, pod, pdbs) {
-			return nil
+		if delay := drainDelay(r.resolvePodAnnotations(ctx, &currentPod, annotationCache)); delay > 0 && i < len(pods)-1 {
+			logger.Info("Waiting for restarted pod to drain", "pod", pod.Name, "duration", delay)
+			pass.held, pass.wait = pods[i+1:], delay
+			break
 		}
-
-		logger.V(1).Info("Waiting for PDB to allow deletion", "pod", pod.Name)
-		time.Sleep(pollInterval)
 	}
 
-	return fmt.Errorf("timeout waiting for PDB to allow deletion of pod %s", pod.Name)
+	return pass
 }
 
-// canDeletePod checks if deleting a pod would violate any PDB
-func (r *ConfigMapReconciler) canDeletePod(ctx context.Context, pod *corev1.Pod, pdbs []policyv1.PodDisruptionBudget) bool {
-	logger := log.FromContext(ctx)
-
-	for _, pdb := range pdbs {
-		if pdb.Spec.Selector == nil {
-			continue
-		}
-
-		// Check if PDB selects this pod
-		selector, err := metav1.LabelSelectorAsSelector(pdb.Spec.Selector)
-		if err != nil {
-			continue
-		}
-
-		if !selector.Matches(labels.Set(pod.Labels)) {
-			continue
-		}
+// recordPodRestarted emits an Event on a restarted pod naming the ConfigMap that triggered it
+func (r *ConfigMapReconciler) recordPodRestarted(pod *corev1.Pod, configMap *corev1.ConfigMap) {
+	r.Recorder.Eventf(pod, corev1.EventTypeNormal, "RestartedDueToConfigChange",
+		"Restarted due to change in ConfigMap %s", configMap.Name)
+}
 
-		// PDB applies to this pod - check if we can disrupt
-		// DisruptionsAllowed tells us how many more disruptions are allowed
-		if pdb.Status.DisruptionsAllowed <= 0 {
-			logger.V(1).Info("PDB would be violated",
-				"pdb", pdb.Name,
-				"pod", pod.Name,
-				"disruptionsAllowed", pdb.Status.DisruptionsAllowed)
-			return false
-		}
-
-		// Also check minAvailable if set
-		if pdb.Spec.MinAvailable != nil {
-			currentHealthy := pdb.Status.CurrentHealthy
-			minAvailable := getIntOrPercentValue(pdb.Spec.MinAvailable, int(pdb.Status.ExpectedPods))
-			if currentHealthy-1 < int32(minAvailable) {
-				logger.V(1).Info("PDB minAvailable would be violated",
-					"pdb", pdb.Name,
-					"pod", pod.Name,
-					"currentHealthy", currentHealthy,
-					"minAvailable", minAvailable)
-				return false
-			}
-		}
+// evictPod creates a policy/v1 Eviction for the pod
+func (r *ConfigMapReconciler) evictPod(ctx context.Context, pod *corev1.Pod) error {
+	eviction := &policyv1.Eviction{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      pod.Name,
+			Namespace: pod.Namespace,
+		},
 	}
-
-	return true
+	return r.SubResource("eviction").Create(ctx, pod, eviction)
 }
 
-// waitForPodsHealthy waits for replacement pods to be ready
-func (r *ConfigMapReconciler) waitForPodsHealthy(ctx context.Context, deletedPods []corev1.Pod) error {
-	logger := log.FromContext(ctx)
-
+// awaitHealthy checks once whether the replacements of the deleted pods are
+// healthy. It returns how long to wait before checking again, 0 once they
+// are. It fails as soon as a replacement is crash looping or can't pull its
+// image, or once they aren't healthy in time counting from since.
+func (r *ConfigMapReconciler) awaitHealthy(ctx context.Context, deletedPods []corev1.Pod, since time.Time) (time.Duration, error) {
 	if len(deletedPods) == 0 {
-		return nil
+		return 0, nil
 	}
 
 	// We need to wait for the owning controllers to create new pods
-	// and for those pods to become ready
-	deadline := time.Now().Add(podReadyTimeout)
+	// and for those pods to become ready, and available if their workload
+	// sets minReadySeconds
+	minReady := r.ownerMinReady(ctx, deletedPods)
+	longest := time.Duration(0)
+	for _, d := range minReady {
+		longest = max(longest, d)
+	}
+	healthy, err := r.podsHealthy(ctx, deletedPods, minReady)
+	if err != nil {
+		return 0, err
+	}
+	if healthy {
+		log.FromContext(ctx).Info("All replacement pods are healthy")
+		return 0, nil
+	}
+	if time.Since(since) >= podReadyTimeout+longest+r.ForceDeleteTerminatingAfter {
+		return 0, fmt.Errorf("timeout waiting for pods to become healthy")
+	}
+	return pollInterval, nil
+}
 
-	for time.Now().Before(deadline) {
-		allHealthy := true
+// podsHealthy checks if the owners of the deleted pods run available pods
+// again, having been Ready for their owner's minReady. Replacements that
+// can't start fail it right away.
+func (r *ConfigMapReconciler) podsHealthy(ctx context.Context, deletedPods []corev1.Pod, minReady map[types.UID]time.Duration) (bool, error) {
+	logger := log.FromContext(ctx)
+	allHealthy := true
 
-		for _, oldPod := range deletedPods {
-			// Find pods with the same owner
-			healthy, err := r.checkOwnerPodsHealthy(ctx, &oldPod)
-			if err != nil {
-				logger.V(1).Info("Error checking pod health", "pod", oldPod.Name, "error", err)
-				allHealthy = false
-				continue
-			}
-			if !healthy {
-				allHealthy = false
-			}
+	replacements, err := r.replacementPods(ctx, deletedPods)
+	if err != nil {
+		logger.V(1).Info("Error listing replacement pods", "error", err)
+		allHealthy = false
+	}
+	if failing := failingContainers(replacements); len(failing) > 0 {
+		return false, &ReplacementFailedError{Containers: failing}
+	}
+	for _, pod := range replacements {
+		if !isPodAvailable(&pod, minReady[controllerUID(&pod)], time.Now()) {
+			allHealthy = false
 		}
+	}
 
-		if allHealthy {
-			logger.Info("All replacement pods are healthy")
-			return nil
+	for _, owner := range r.restartedOwners(ctx, deletedPods) {
+		healthy, err := r.ownerHealthy(ctx, owner, minReady[owner.uid])
+		if err != nil {
+			logger.V(1).Info("Error checking pod health", "owner", ownerName(owner.uid), "error", err)
+			allHealthy = false
+			continue
+		}
+		if !healthy {
+			allHealthy = false
 		}
-
-		time.Sleep(pollInterval)
 	}
-
-	return fmt.Errorf("timeout waiting for pods to become healthy")
+	return allHealthy, nil
 }
 
-// checkOwnerPodsHealthy checks if pods owned by the same controller are healthy
-func (r *ConfigMapReconciler) checkOwnerPodsHealthy(ctx context.Context, oldPod *corev1.Pod) (bool, error) {
+// checkOwnerPodsHealthy checks if pods owned by the same controller are
+// healthy, having been Ready for at least minReady. It scans the namespace,
+// so it's only used for controllers without a rollout status.
+func (r *ConfigMapReconciler) checkOwnerPodsHealthy(ctx context.Context, oldPod *corev1.Pod, minReady time.Duration) (bool, error) {
 	// Get the controller owner reference
 	var ownerRef *metav1.OwnerReference
 	for i := range oldPod.OwnerReferences {
@@ -407,8 +870,15 @@ func (r *ConfigMapReconciler) checkOwnerPodsHealthy(ctx context.Context, oldPod
 	}
 
 	if ownerRef == nil {
-		// No controller - pod won't be recreated, consider it "healthy" (done)
-		return true, nil
+		// No controller - done, unless the operator re-created the pod
+		var current corev1.Pod
+		if err := r.Get(ctx, client.ObjectKeyFromObject(oldPod), &current); err != nil {
+			return apierrors.IsNotFound(err), client.IgnoreNotFound(err)
+		}
+		if current.UID == oldPod.UID {
+			return true, nil
+		}
+		return isPodAvailable(&current, minReady, time.Now()), nil
 	}
 
 	// List pods in the same namespace
@@ -421,8 +891,8 @@ func (r *ConfigMapReconciler) checkOwnerPodsHealthy(ctx context.Context, oldPod
 	for _, pod := range pods.Items {
 		for _, ref := range pod.OwnerReferences {
 			if ref.UID == ownerRef.UID {
-				// Check if this pod is ready
-				if isPodReady(&pod) {
+				// Check if this pod is available
+				if isPodAvailable(&pod, minReady, time.Now()) {
 					return true, nil
 				}
 			}
@@ -445,98 +915,150 @@ func isPodReady(pod *corev1.Pod) bool {
 	return false
 }
 
-// loadPDBs loads PodDisruptionBudgets for a namespace
-func (r *ConfigMapReconciler) loadPDBs(ctx context.Context, namespace string) ([]policyv1.PodDisruptionBudget, error) {
-	var pdbList policyv1.PodDisruptionBudgetList
-	if err := r.List(ctx, &pdbList, client.InNamespace(namespace)); err != nil {
-		return nil, err
-	}
-	return pdbList.Items, nil
-}
-
-// getIntOrPercentValue converts IntOrString to an int value
-func getIntOrPercentValue(val *intstr.IntOrString, total int) int {
-	if val.Type == intstr.Int {
-		return val.IntValue()
-	}
-	// Percentage
-	percent, _ := intstr.GetScaledValueFromIntOrPercent(val, total, true)
-	return percent
-}
-
 // podUsesConfigMap checks if a pod references the given ConfigMap
 func (r *ConfigMapReconciler) podUsesConfigMap(pod *corev1.Pod, configMapName string) bool {
-	// Check volumes
-	for _, vol := range pod.Spec.Volumes {
-		if vol.ConfigMap != nil && vol.ConfigMap.Name == configMapName {
-			return true
-		}
-		if vol.Projected != nil {
-			for _, src := range vol.Projected.Sources {
-				if src.ConfigMap != nil && src.ConfigMap.Name == configMapName {
-					return true
-				}
-			}
-		}
-	}
-
-	// Check containers for envFrom
-	for _, container := range pod.Spec.Containers {
-		for _, envFrom := range container.EnvFrom {
-			if envFrom.ConfigMapRef != nil && envFrom.ConfigMapRef.Name == configMapName {
-				return true
-			}
-		}
-		// Check individual env vars
-		for _, env := range container.Env {
-			if env.ValueFrom != nil && env.ValueFrom.ConfigMapKeyRef != nil {
-				if env.ValueFrom.ConfigMapKeyRef.Name == configMapName {
-					return true
-				}
-			}
-		}
-	}
-
-	// Check init containers
-	for _, container := range pod.Spec.InitContainers {
-		for _, envFrom := range container.EnvFrom {
-			if envFrom.ConfigMapRef != nil && envFrom.ConfigMapRef.Name == configMapName {
-				return true
-			}
-		}
-		for _, env := range container.Env {
-			if env.ValueFrom != nil && env.ValueFrom.ConfigMapKeyRef != nil {
-				if env.ValueFrom.ConfigMapKeyRef.Name == configMapName {
-					return true
-				}
-			}
-		}
-	}
+	return podConfigMapUsage(pod, configMapName).any()
+}
 
-	return false
+// strategyPriority orders restart strategies when several configs disagree
+var strategyPriority = map[autoapplyv1alpha1.RestartStrategy]int{
+	autoapplyv1alpha1.RestartStrategyRolling: 0,
+	autoapplyv1alpha1.RestartStrategyRollout: 1,
+	autoapplyv1alpha1.RestartStrategySurge:   2,
+	autoapplyv1alpha1.RestartStrategyCanary:  3,
+	autoapplyv1alpha1.RestartStrategyTrickle: 4,
 }
 
 // operatorConfig holds the merged configuration from all AutoApplyConfig resources
 type operatorConfig struct {
 	excludePodPatterns []*regexp.Regexp
 	excludeNamespaces  []string
+	includeNamespaces  []string
 	yoloMode           bool
+	// yoloDisabled keeps yoloMode off, whatever enables it
+	yoloDisabled       bool
+	dryRun             bool
+	vpaEvictionWindow  time.Duration
+	restartTimeout     time.Duration
+	maintenanceWindows []maintenanceWindow
+	// namespaceMaintenanceWindows narrow maintenanceWindows: restarts run
+	// only while one of each is open
+	namespaceMaintenanceWindows []maintenanceWindow
+	strategy                    autoapplyv1alpha1.RestartStrategy
+	canarySoakDuration          time.Duration
+	debounceDuration            time.Duration
+	trickleBatchSize            int
+	trickleInterval             time.Duration
+	// skipRefreshableMounts leaves pods alone whose only usage kubelet refreshes
+	skipRefreshableMounts bool
+	// notifications have their Secret namespaces resolved.
+	// namespaceNotifications come from namespace configs and are only sent to
+	// NamespaceNotificationHosts.
+	notifications          []autoapplyv1alpha1.Notification
+	namespaceNotifications []autoapplyv1alpha1.Notification
+	preRestartJobs         []autoapplyv1alpha1.PreRestartJob
+	verificationProbes     []autoapplyv1alpha1.ServiceProbe
+	// excludeConfigMapPatterns match ConfigMaps whose changes are ignored
+	excludeConfigMapPatterns []*regexp.Regexp
+	// disableDefaultConfigMapExclusions stops ignoring defaultExcludeConfigMapPatterns
+	disableDefaultConfigMapExclusions bool
+	// changeDetection is empty for the default Full mode
+	changeDetection     autoapplyv1alpha1.ChangeDetectionMode
+	changeDetectionKeys []string
+	// semanticChangeDetection compares parsed YAML instead of raw text
+	semanticChangeDetection bool
+	// recentRolloutWindow leaves pods of recently rolled out workloads running
+	recentRolloutWindow time.Duration
+	// batchSteps replace the two halves of each owner's pods when set
+	batchSteps []intstr.IntOrString
+	// pdbRetryWindow bounds retries of pods a PDB kept from being evicted
+	pdbRetryWindow time.Duration
+	// coalesceWindow holds restarts while other ConfigMaps of the pods change
+	coalesceWindow time.Duration
+	// requireApproval holds restarts until their RestartOperation is approved,
+	// expiring them after approvalTimeout if it's set
+	requireApproval bool
+	approvalTimeout time.Duration
+	// notifyOnly recommends restarts instead of performing them
+	notifyOnly bool
+	// podBackupKeep backups of pods yolo mode deletes are kept per ConfigMap,
+	// none are taken when 0. podBackupMaxAge deletes older ones when set.
+	podBackupKeep   int
+	podBackupMaxAge time.Duration
+	// recreateBarePods creates restarted pods without a controller again
+	recreateBarePods bool
+	// excludeDaemonSets leaves DaemonSet pods running. includeDaemonSets is
+	// set by a config lifting the operator default.
+	excludeDaemonSets bool
+	includeDaemonSets bool
+	// critical restarts even during node disruptions
+	critical bool
+	// skipOptionalReferences leaves pods alone that only reference the
+	// ConfigMap optionally
+	skipOptionalReferences bool
+	// restartByPriority restarts lower priority workloads first
+	restartByPriority bool
+	// maxRestartPriority leaves pods of a higher priority running when set
+	maxRestartPriority *int32
 }
 
 // Default safe exclusions - always applied
 var (
-	defaultExcludeNamespaces = []string{"kube-system"}
+	defaultExcludeNamespaces  = []string{"kube-system"}
 	defaultExcludePodPatterns = []string{
-		`^coredns-.*`,  // CoreDNS - cluster DNS
-		`.*-csi-.*`,    // CSI drivers - storage
+		`^coredns-.*`, // CoreDNS - cluster DNS
+		`.*-csi-.*`,   // CSI drivers - storage
+	}
+	// ConfigMaps clusters publish and rotate themselves; pods read them live
+	defaultExcludeConfigMapPatterns = []*regexp.Regexp{
+		regexp.MustCompile(`^kube-root-ca\.crt$`),            // API server CA, in every namespace
+		regexp.MustCompile(`^istio-ca-root-cert$`),           // Istio mesh CA
+		regexp.MustCompile(`^openshift-service-ca\.crt$`),    // OpenShift service CA
+		regexp.MustCompile(`^linkerd-identity-trust-roots$`), // Linkerd trust anchors
+		regexp.MustCompile(`-pod-backup-[a-z0-9]{5}$`),       // Our own pod backups
 	}
 )
 
-// loadConfig loads and merges all AutoApplyConfig resources with defaults
-func (r *ConfigMapReconciler) loadConfig(ctx context.Context) operatorConfig {
+// isNamespaceExcluded checks if the namespace is excluded from restarts,
+// including by not being in a configured allowlist
+func (c operatorConfig) isNamespaceExcluded(namespace string) bool {
+	if len(c.includeNamespaces) > 0 && !slices.Contains(c.includeNamespaces, namespace) {
+		return true
+	}
+	return slices.Contains(c.excludeNamespaces, namespace)
+}
+
+// isConfigMapExcluded checks if changes to the named ConfigMap are ignored.
+// Patterns containing a slash match "namespace/name" instead of the name.
+func (c operatorConfig) isConfigMapExcluded(namespace, name string) bool {
+	patterns := c.excludeConfigMapPatterns
+	if !c.disableDefaultConfigMapExclusions {
+		patterns = append(slices.Clone(defaultExcludeConfigMapPatterns), patterns...)
+	}
+	for _, re := range patterns {
+		subject := name
+		if strings.Contains(re.String(), "/") {
+			subject = namespace + "/" + name
+		}
+		if re.MatchString(subject) {
+			return true
+		}
+	}
+	return false
+}
+
+// loadConfig loads and merges the AutoApplyConfig resources that apply to the
+// ConfigMap with defaults
+func (r *ConfigMapReconciler) loadConfig(ctx context.Context, configMap *corev1.ConfigMap) operatorConfig {
 	// Start with defaults
 	cfg := operatorConfig{
-		excludeNamespaces: append([]string{}, defaultExcludeNamespaces...),
+		excludeNamespaces:  append([]string{}, defaultExcludeNamespaces...),
+		restartTimeout:     defaultRestartTimeout,
+		strategy:           autoapplyv1alpha1.RestartStrategyRolling,
+		canarySoakDuration: defaultCanarySoakDuration,
+		trickleBatchSize:   defaultTrickleBatchSize,
+		trickleInterval:    defaultTrickleInterval,
+		pdbRetryWindow:     defaultPDBRetryWindow,
 	}
 	for _, pattern := range defaultExcludePodPatterns {
 		if re, err := regexp.Compile(pattern); err == nil {
@@ -550,24 +1072,189 @@ func (r *ConfigMapReconciler) loadConfig(ctx context.Context) operatorConfig {
 		return cfg
 	}
 
+	restartTimeoutSet := false
+	trickleBatchSizeSet := false
+	pdbRetryWindowSet := false
 	for _, item := range configList.Items {
+		if !configAppliesTo(ctx, item.Name, &item.Spec, configMap) {
+			continue
+		}
 		for _, pattern := range item.Spec.ExcludePods {
 			if re, err := regexp.Compile(pattern); err == nil {
 				cfg.excludePodPatterns = append(cfg.excludePodPatterns, re)
 			}
 		}
 		cfg.excludeNamespaces = append(cfg.excludeNamespaces, item.Spec.ExcludeNamespaces...)
-		if item.Spec.YoloMode {
+		cfg.includeNamespaces = append(cfg.includeNamespaces, item.Spec.IncludeNamespaces...)
+		for _, pattern := range item.Spec.ExcludeConfigMaps {
+			if re, err := regexp.Compile(pattern); err == nil {
+				cfg.excludeConfigMapPatterns = append(cfg.excludeConfigMapPatterns, re)
+			}
+		}
+		if item.Spec.DisableDefaultConfigMapExclusions {
+			cfg.disableDefaultConfigMapExclusions = true
+		}
+		if ptr.Deref(item.Spec.YoloMode, false) && yoloModeAppliesIn(item.Spec.YoloModeNamespaces, configMap) {
 			cfg.yoloMode = true
 		}
+		if item.Spec.DisableYoloMode {
+			cfg.yoloDisabled = true
+		}
+		if ptr.Deref(item.Spec.DryRun, false) {
+			cfg.dryRun = true
+		}
+		if ptr.Deref(item.Spec.SkipRefreshableMounts, false) {
+			cfg.skipRefreshableMounts = true
+		}
+		// Longest window wins
+		if w := item.Spec.VPAEvictionWindow; w != nil && w.Duration > cfg.vpaEvictionWindow {
+			cfg.vpaEvictionWindow = w.Duration
+		}
+		// The strategy ranked highest in strategyPriority wins: Trickle, then
+		// Canary, Surge, Rollout and Rolling
+		if strategyPriority[item.Spec.Strategy] > strategyPriority[cfg.strategy] {
+			cfg.strategy = item.Spec.Strategy
+		}
+		// Longest soak wins
+		if d := item.Spec.CanarySoakDuration; d != nil && d.Duration > cfg.canarySoakDuration {
+			cfg.canarySoakDuration = d.Duration
+		}
+		// Slowest trickle wins
+		if n := item.Spec.TrickleBatchSize; n > 0 && (!trickleBatchSizeSet || int(n) < cfg.trickleBatchSize) {
+			cfg.trickleBatchSize = int(n)
+			trickleBatchSizeSet = true
+		}
+		if d := item.Spec.TrickleInterval; d != nil && d.Duration > cfg.trickleInterval {
+			cfg.trickleInterval = d.Duration
+		}
+		// Longest debounce wins
+		if d := item.Spec.DebounceDuration; d != nil && d.Duration > cfg.debounceDuration {
+			cfg.debounceDuration = d.Duration
+		}
+		// Restarts may run in any configured window
+		for _, window := range item.Spec.MaintenanceWindows {
+			if mw, err := parseMaintenanceWindow(window); err == nil {
+				cfg.maintenanceWindows = append(cfg.maintenanceWindows, mw)
+			}
+		}
+		// Shortest configured deadline wins
+		if t := item.Spec.RestartTimeout; t != nil && t.Duration > 0 && (!restartTimeoutSet || t.Duration < cfg.restartTimeout) {
+			cfg.restartTimeout = t.Duration
+			restartTimeoutSet = true
+		}
+		// Every config's notifications are sent
+		for _, notification := range item.Spec.Notifications {
+			if configMap != nil {
+				notification = withSecretNamespace(notification, configMap.Namespace, false)
+			}
+			cfg.notifications = append(cfg.notifications, notification)
+		}
+		// Every config's pre-restart Job runs
+		if job := item.Spec.PreRestartJob; job != nil {
+			cfg.preRestartJobs = append(cfg.preRestartJobs, *job)
+		}
+		cfg.verificationProbes = append(cfg.verificationProbes, item.Spec.VerificationProbes...)
+		// The most sensitive change detection wins
+		if detection := item.Spec.ChangeDetection; detection != nil {
+			cfg.mergeChangeDetection(detection)
+		}
+		// Longest window wins
+		if w := item.Spec.RecentRolloutWindow; w != nil && w.Duration > cfg.recentRolloutWindow {
+			cfg.recentRolloutWindow = w.Duration
+		}
+		// The most gradual sequence wins
+		if len(item.Spec.BatchSteps) > len(cfg.batchSteps) {
+			cfg.batchSteps = item.Spec.BatchSteps
+		}
+		// Longest configured retry window wins
+		if w := item.Spec.PDBRetryWindow; w != nil && (!pdbRetryWindowSet || w.Duration > cfg.pdbRetryWindow) {
+			cfg.pdbRetryWindow = w.Duration
+			pdbRetryWindowSet = true
+		}
+		// Longest window wins
+		if w := item.Spec.CoalesceWindow; w != nil && w.Duration > cfg.coalesceWindow {
+			cfg.coalesceWindow = w.Duration
+		}
+		if ptr.Deref(item.Spec.RequireApproval, false) {
+			cfg.requireApproval = true
+		}
+		// Shortest configured expiry wins
+		if t := item.Spec.ApprovalTimeout; t != nil && t.Duration > 0 && (cfg.approvalTimeout == 0 || t.Duration < cfg.approvalTimeout) {
+			cfg.approvalTimeout = t.Duration
+		}
+		if ptr.Deref(item.Spec.NotifyOnly, false) {
+			cfg.notifyOnly = true
+		}
+		if ptr.Deref(item.Spec.RecreateBarePods, false) {
+			cfg.recreateBarePods = true
+		}
+		cfg.mergeExcludeDaemonSets(item.Spec.ExcludeDaemonSets)
+		if ptr.Deref(item.Spec.Critical, false) {
+			cfg.critical = true
+		}
+		if ptr.Deref(item.Spec.SkipOptionalReferences, false) {
+			cfg.skipOptionalReferences = true
+		}
+		if ptr.Deref(item.Spec.RestartByPriority, false) {
+			cfg.restartByPriority = true
+		}
+		if p := item.Spec.MaxRestartPriority; p != nil && (cfg.maxRestartPriority == nil || *p < *cfg.maxRestartPriority) {
+			cfg.maxRestartPriority = p
+		}
+		if backup := item.Spec.PodBackup; backup != nil {
+			cfg.mergePodBackup(backup, false)
+		}
+	}
+
+	// Namespace configs take precedence over cluster-wide ones
+	if configMap != nil {
+		r.applyNamespaceConfigs(ctx, &cfg, configMap)
+	}
+
+	// Disabling yolo mode wins over enabling it, wherever either is set
+	if cfg.yoloDisabled {
+		cfg.yoloMode = false
+	}
+
+	// The operator default applies unless a config lifted it
+	if r.ExcludeDaemonSets && !cfg.includeDaemonSets {
+		cfg.excludeDaemonSets = true
 	}
 
 	return cfg
 }
 
+// yoloModeAppliesIn checks if a config's yoloMode applies to the ConfigMap
+// given its yoloModeNamespaces. Without a ConfigMap only unrestricted
+// yoloMode applies.
+func yoloModeAppliesIn(namespaces []string, configMap *corev1.ConfigMap) bool {
+	if len(namespaces) == 0 {
+		return true
+	}
+	return configMap != nil && slices.Contains(namespaces, configMap.Namespace)
+}
+
+// configAppliesTo checks if a config's configMapSelector matches the ConfigMap.
+// Without a ConfigMap only unscoped configs apply.
+func configAppliesTo(ctx context.Context, name string, spec *autoapplyv1alpha1.AutoApplyConfigSpec, configMap *corev1.ConfigMap) bool {
+	if spec.ConfigMapSelector == nil {
+		return true
+	}
+	if configMap == nil {
+		return false
+	}
+
+	selector, err := metav1.LabelSelectorAsSelector(spec.ConfigMapSelector)
+	if err != nil {
+		log.FromContext(ctx).Error(err, "Invalid configMapSelector, ignoring config", "config", name)
+		return false
+	}
+	return selector.Matches(labels.Set(configMap.Labels))
+}
+
 // loadExclusionConfig loads exclusion patterns from AutoApplyConfig (legacy helper)
 func (r *ConfigMapReconciler) loadExclusionConfig(ctx context.Context) (podPatterns []*regexp.Regexp, namespaces []string) {
-	cfg := r.loadConfig(ctx)
+	cfg := r.loadConfig(ctx, nil)
 	return cfg.excludePodPatterns, cfg.excludeNamespaces
 }
 
@@ -582,7 +1269,17 @@ func (r *ConfigMapReconciler) isPodExcluded(podName string, patterns []*regexp.R
 }
 
 func (r *ConfigMapReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	if err := mgr.GetFieldIndexer().IndexField(context.Background(), &corev1.Pod{}, podConfigMapIndex, indexPodConfigMaps); err != nil {
+		return err
+	}
+	if r.StaleConfigScanInterval > 0 {
+		if err := mgr.Add(manager.RunnableFunc(r.runStaleConfigScanner)); err != nil {
+			return err
+		}
+	}
+
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&corev1.ConfigMap{}).
+		WithOptions(controller.Options{MaxConcurrentReconciles: r.MaxConcurrentReconciles}).
 		Complete(r)
 }
@@ -3,18 +3,23 @@ package controller
 import (
 	"context"
 	"regexp"
+	"strings"
 	"testing"
+	"time"
 
 	corev1 "k8s.io/api/core/v1"
 	policyv1 "k8s.io/api/policy/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
-	"k8s.io/apimachinery/pkg/util/intstr"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/ptr"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
 
 	autoapplyv1alpha1 "github.com/manos/k8s-autoapply-operator/api/v1alpha1"
 )
@@ -350,47 +355,6 @@ func TestIsPodReady(t *testing.T) {
 	}
 }
 
-func TestGetIntOrPercentValue(t *testing.T) {
-	tests := []struct {
-		name     string
-		val      *intstr.IntOrString
-		total    int
-		expected int
-	}{
-		{
-			name:     "integer value",
-			val:      intstrPtr(intstr.FromInt(3)),
-			total:    10,
-			expected: 3,
-		},
-		{
-			name:     "percentage value 50%",
-			val:      intstrPtr(intstr.FromString("50%")),
-			total:    10,
-			expected: 5,
-		},
-		{
-			name:     "percentage value 30%",
-			val:      intstrPtr(intstr.FromString("30%")),
-			total:    10,
-			expected: 3,
-		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			result := getIntOrPercentValue(tt.val, tt.total)
-			if result != tt.expected {
-				t.Errorf("getIntOrPercentValue() = %v, expected %v", result, tt.expected)
-			}
-		})
-	}
-}
-
-func intstrPtr(val intstr.IntOrString) *intstr.IntOrString {
-	return &val
-}
-
 // ============================================================================
 // Integration Tests with fake client
 // ============================================================================
@@ -403,16 +367,35 @@ func setupTestReconciler() (*ConfigMapReconciler, client.Client) {
 
 	fakeClient := fake.NewClientBuilder().
 		WithScheme(scheme).
+		WithIndex(&corev1.Pod{}, podConfigMapIndex, indexPodConfigMaps).
+		WithStatusSubresource(&autoapplyv1alpha1.RestartOperation{}, &autoapplyv1alpha1.AutoApplyNamespaceConfig{}).
 		Build()
 
 	reconciler := &ConfigMapReconciler{
-		Client: fakeClient,
-		Scheme: scheme,
+		Client:   fakeClient,
+		Scheme:   scheme,
+		Recorder: record.NewFakeRecorder(100),
 	}
 
 	return reconciler, fakeClient
 }
 
+// reconcileRestart reconciles until the restart a change started is done,
+// waiting between reconciles as long as the requeues ask
+func reconcileRestart(t *testing.T, r *ConfigMapReconciler, req ctrl.Request) {
+	t.Helper()
+	for {
+		result, err := r.Reconcile(context.Background(), req)
+		if err != nil {
+			t.Fatalf("Reconcile failed: %v", err)
+		}
+		if _, running := r.restarts.Load(req.String()); !running {
+			return
+		}
+		time.Sleep(result.RequeueAfter)
+	}
+}
+
 func TestReconcile_FirstTimeConfigMap(t *testing.T) {
 	r, fakeClient := setupTestReconciler()
 	ctx := context.Background()
@@ -522,6 +505,168 @@ func TestReconcile_ConfigMapChange_RestartsPods(t *testing.T) {
 	}
 }
 
+func TestReconcile_EmitsEvents(t *testing.T) {
+	r, fakeClient := setupTestReconciler()
+	ctx := context.Background()
+
+	req := ctrl.Request{
+		NamespacedName: types.NamespacedName{Name: "test-config", Namespace: "default"},
+	}
+	r.configMapVersions.Store(req.String(), "old-version")
+
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-config", Namespace: "default"},
+	}
+	_ = fakeClient.Create(ctx, cm)
+
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-pod", Namespace: "default"},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{Name: "app", Image: "nginx"}},
+			Volumes: []corev1.Volume{{
+				Name: "config",
+				VolumeSource: corev1.VolumeSource{
+					ConfigMap: &corev1.ConfigMapVolumeSource{
+						LocalObjectReference: corev1.LocalObjectReference{Name: "test-config"},
+					},
+				},
+			}},
+		},
+		Status: corev1.PodStatus{Phase: corev1.PodRunning},
+	}
+	_ = fakeClient.Create(ctx, pod)
+
+	if _, err := r.Reconcile(ctx, req); err != nil {
+		t.Fatalf("Reconcile failed: %v", err)
+	}
+
+	recorder := r.Recorder.(*record.FakeRecorder)
+	close(recorder.Events)
+
+	var events []string
+	for event := range recorder.Events {
+		events = append(events, event)
+	}
+
+	expected := []string{
+		"Normal TriggeredRestart Restarting 1 pods due to ConfigMap change",
+		"Normal RestartedDueToConfigChange Restarted due to change in ConfigMap test-config",
+	}
+	if len(events) != len(expected) {
+		t.Fatalf("Expected events %v, got %v", expected, events)
+	}
+	for i := range expected {
+		if events[i] != expected[i] {
+			t.Errorf("Event %d = %q, expected %q", i, events[i], expected[i])
+		}
+	}
+}
+
+func TestRollingRestart_MultipleOwners(t *testing.T) {
+	r, fakeClient := setupTestReconciler()
+	ctx := context.Background()
+	trueVal := true
+
+	var podsToRestart []corev1.Pod
+	for _, owner := range []string{"deploy-a", "deploy-b"} {
+		ownerRefs := []metav1.OwnerReference{
+			{APIVersion: "apps/v1", Kind: "ReplicaSet", Name: owner, UID: types.UID(owner), Controller: &trueVal},
+		}
+
+		// A ready pod of the same owner lets the health check pass between batches
+		_ = fakeClient.Create(ctx, &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: owner + "-ready", Namespace: "default", OwnerReferences: ownerRefs},
+			Status: corev1.PodStatus{
+				Phase:      corev1.PodRunning,
+				Conditions: []corev1.PodCondition{{Type: corev1.PodReady, Status: corev1.ConditionTrue}},
+			},
+		})
+
+		for _, suffix := range []string{"-1", "-2"} {
+			pod := corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Name: owner + suffix, Namespace: "default", OwnerReferences: ownerRefs},
+				Status:     corev1.PodStatus{Phase: corev1.PodRunning},
+			}
+			_ = fakeClient.Create(ctx, &pod)
+			podsToRestart = append(podsToRestart, pod)
+		}
+	}
+
+	cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "test-config", Namespace: "default"}}
+	if err := runRestart(ctx, r, r.loadConfig(ctx, nil), cm, "v2", podsToRestart); err != nil {
+		t.Fatalf("Restart failed: %v", err)
+	}
+
+	var pods corev1.PodList
+	_ = fakeClient.List(ctx, &pods, client.InNamespace("default"))
+	remaining := podNames(pods.Items)
+	if len(remaining) != 2 {
+		t.Errorf("Expected only the ready pods to remain, found %v", remaining)
+	}
+}
+
+func TestReconcile_RestartTimeout(t *testing.T) {
+	r, fakeClient := setupTestReconciler()
+	ctx := context.Background()
+
+	req := ctrl.Request{
+		NamespacedName: types.NamespacedName{Name: "test-config", Namespace: "default"},
+	}
+	r.configMapVersions.Store(req.String(), "old-version")
+
+	// A deadline that has passed before the first eviction
+	_ = fakeClient.Create(ctx, &autoapplyv1alpha1.AutoApplyConfig{
+		ObjectMeta: metav1.ObjectMeta{Name: "timeout"},
+		Spec: autoapplyv1alpha1.AutoApplyConfigSpec{
+			RestartTimeout: &metav1.Duration{Duration: time.Nanosecond},
+		},
+	})
+	_ = fakeClient.Create(ctx, &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-config", Namespace: "default"},
+	})
+	_ = fakeClient.Create(ctx, &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-pod", Namespace: "default"},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{Name: "app", Image: "nginx"}},
+			Volumes: []corev1.Volume{{
+				Name: "config",
+				VolumeSource: corev1.VolumeSource{
+					ConfigMap: &corev1.ConfigMapVolumeSource{
+						LocalObjectReference: corev1.LocalObjectReference{Name: "test-config"},
+					},
+				},
+			}},
+		},
+		Status: corev1.PodStatus{Phase: corev1.PodRunning},
+	})
+
+	if _, err := r.Reconcile(ctx, req); err != nil {
+		t.Fatalf("Reconcile failed: %v", err)
+	}
+
+	var pods corev1.PodList
+	_ = fakeClient.List(ctx, &pods, client.InNamespace("default"))
+	if len(pods.Items) != 1 {
+		t.Errorf("Expected pod to remain after timeout, found %d pods", len(pods.Items))
+	}
+
+	recorder := r.Recorder.(*record.FakeRecorder)
+	close(recorder.Events)
+
+	var timedOut string
+	for event := range recorder.Events {
+		if strings.Contains(event, "RestartTimedOut") {
+			timedOut = event
+		}
+	}
+	if timedOut == "" {
+		t.Fatal("Expected a RestartTimedOut event")
+	}
+	if !strings.Contains(timedOut, "1 pods still running stale config: test-pod") {
+		t.Errorf("Expected timed out event to list the stale pod, got %s", timedOut)
+	}
+}
+
 func TestReconcile_ExcludedNamespace(t *testing.T) {
 	r, fakeClient := setupTestReconciler()
 	ctx := context.Background()
@@ -611,7 +756,7 @@ func TestReconcile_YoloMode(t *testing.T) {
 			Name: "yolo",
 		},
 		Spec: autoapplyv1alpha1.AutoApplyConfigSpec{
-			YoloMode: true,
+			YoloMode: ptr.To(true),
 		},
 	}
 	_ = fakeClient.Create(ctx, cfg)
@@ -743,63 +888,53 @@ func TestReconcile_ExcludedPodPattern(t *testing.T) {
 	}
 }
 
-func TestCanDeletePod_NoPDB(t *testing.T) {
-	r, _ := setupTestReconciler()
-	ctx := context.Background()
-
-	pod := &corev1.Pod{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:   "test-pod",
-			Labels: map[string]string{"app": "test"},
-		},
-	}
-
-	// No PDBs - should always allow deletion
-	canDelete := r.canDeletePod(ctx, pod, nil)
-	if !canDelete {
-		t.Error("Should allow deletion when no PDBs exist")
-	}
-}
+func TestRestart_RetriesEvictionBlockedByPDB(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = autoapplyv1alpha1.AddToScheme(scheme)
+	_ = policyv1.AddToScheme(scheme)
 
-func TestCanDeletePod_WithPDB(t *testing.T) {
-	r, _ := setupTestReconciler()
+	// First eviction attempt is rejected as a PDB would do, later ones go through
+	attempts := 0
+	fakeClient := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithInterceptorFuncs(interceptor.Funcs{
+			SubResourceCreate: func(ctx context.Context, c client.Client, subResourceName string, obj client.Object, subResource client.Object, opts ...client.SubResourceCreateOption) error {
+				if subResourceName == "eviction" {
+					attempts++
+					if attempts == 1 {
+						return apierrors.NewTooManyRequests("Cannot evict pod as it would violate the pod's disruption budget.", 0)
+					}
+				}
+				return c.SubResource(subResourceName).Create(ctx, obj, subResource, opts...)
+			},
+		}).
+		Build()
+	r := &ConfigMapReconciler{Client: fakeClient, Scheme: scheme, Recorder: record.NewFakeRecorder(10)}
 	ctx := context.Background()
 
-	pod := &corev1.Pod{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:   "test-pod",
-			Labels: map[string]string{"app": "test"},
+	pod := corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-pod", Namespace: "default"},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{Name: "app", Image: "nginx"}},
 		},
 	}
+	_ = fakeClient.Create(ctx, &pod)
 
-	tests := []struct {
-		name               string
-		disruptionsAllowed int32
-		expected           bool
-	}{
-		{"disruptions allowed", 1, true},
-		{"no disruptions allowed", 0, false},
+	// The blocked pod is retried on the next step
+	cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "test-config", Namespace: "default"}}
+	if err := runRestart(ctx, r, r.loadConfig(ctx, nil), cm, "v2", []corev1.Pod{pod}); err != nil {
+		t.Fatalf("Restart failed: %v", err)
 	}
 
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			pdb := policyv1.PodDisruptionBudget{
-				ObjectMeta: metav1.ObjectMeta{Name: "test-pdb"},
-				Spec: policyv1.PodDisruptionBudgetSpec{
-					Selector: &metav1.LabelSelector{
-						MatchLabels: map[string]string{"app": "test"},
-					},
-				},
-				Status: policyv1.PodDisruptionBudgetStatus{
-					DisruptionsAllowed: tt.disruptionsAllowed,
-				},
-			}
+	if attempts != 2 {
+		t.Errorf("Expected 2 eviction attempts, got %d", attempts)
+	}
 
-			canDelete := r.canDeletePod(ctx, pod, []policyv1.PodDisruptionBudget{pdb})
-			if canDelete != tt.expected {
-				t.Errorf("canDeletePod() = %v, expected %v", canDelete, tt.expected)
-			}
-		})
+	var pods corev1.PodList
+	_ = fakeClient.List(ctx, &pods, client.InNamespace("default"))
+	if len(pods.Items) != 0 {
+		t.Errorf("Expected pod to be evicted, found %d pods", len(pods.Items))
 	}
 }
 
@@ -861,7 +996,7 @@ func TestFindPodsUsingConfigMap(t *testing.T) {
 	_ = fakeClient.Create(ctx, notUsingPod)
 	_ = fakeClient.Create(ctx, completedPod)
 
-	pods := r.findPodsUsingConfigMap(ctx, cm, nil)
+	pods := r.findPodsUsingConfigMap(ctx, cm, operatorConfig{})
 
 	if len(pods) != 1 {
 		t.Errorf("Expected 1 pod, found %d", len(pods))
@@ -888,14 +1023,14 @@ func TestLoadConfig(t *testing.T) {
 		Spec: autoapplyv1alpha1.AutoApplyConfigSpec{
 			ExcludePods:       []string{".*-job$"},
 			ExcludeNamespaces: []string{"cert-manager"},
-			YoloMode:          true,
+			YoloMode:          ptr.To(true),
 		},
 	}
 
 	_ = fakeClient.Create(ctx, cfg1)
 	_ = fakeClient.Create(ctx, cfg2)
 
-	config := r.loadConfig(ctx)
+	config := r.loadConfig(ctx, nil)
 
 	// Should merge defaults + user configs
 	// Defaults: 2 pod patterns (coredns, csi) + 1 namespace (kube-system)
@@ -916,7 +1051,7 @@ func TestLoadConfig_DefaultsOnly(t *testing.T) {
 	ctx := context.Background()
 
 	// No user configs - should still have defaults
-	config := r.loadConfig(ctx)
+	config := r.loadConfig(ctx, nil)
 
 	// Defaults: 2 pod patterns (coredns, csi) + 1 namespace (kube-system)
 	if len(config.excludePodPatterns) != 2 {
@@ -930,6 +1065,233 @@ func TestLoadConfig_DefaultsOnly(t *testing.T) {
 	}
 }
 
+func TestLoadConfig_IncludeNamespaces(t *testing.T) {
+	r, fakeClient := setupTestReconciler()
+	ctx := context.Background()
+
+	for name, namespaces := range map[string][]string{"staged": {"team-a"}, "more": {"team-b", "kube-system"}} {
+		_ = fakeClient.Create(ctx, &autoapplyv1alpha1.AutoApplyConfig{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec:       autoapplyv1alpha1.AutoApplyConfigSpec{IncludeNamespaces: namespaces},
+		})
+	}
+
+	config := r.loadConfig(ctx, nil)
+
+	tests := map[string]bool{
+		"team-a":      false,
+		"team-b":      false,
+		"default":     true, // Not in the allowlist
+		"kube-system": true, // Still excluded by default
+	}
+	for namespace, expected := range tests {
+		if excluded := config.isNamespaceExcluded(namespace); excluded != expected {
+			t.Errorf("isNamespaceExcluded(%q) = %v, expected %v", namespace, excluded, expected)
+		}
+	}
+}
+
+func TestLoadConfig_ExcludeConfigMaps(t *testing.T) {
+	r, fakeClient := setupTestReconciler()
+	ctx := context.Background()
+
+	_ = fakeClient.Create(ctx, &autoapplyv1alpha1.AutoApplyConfig{
+		ObjectMeta: metav1.ObjectMeta{Name: "generated"},
+		Spec: autoapplyv1alpha1.AutoApplyConfigSpec{ExcludeConfigMaps: []string{
+			`-generated$`,
+			`^mesh-.*/sidecar-`,
+		}},
+	})
+
+	config := r.loadConfig(ctx, nil)
+	tests := map[string]bool{
+		"default/app-config":                   false,
+		"default/kube-root-ca.crt":             true, // Built-in
+		"default/istio-ca-root-cert":           true, // Built-in
+		"default/linkerd-identity-trust-roots": true, // Built-in
+		"default/kube-root-ca-crt":             false,
+		"default/app-generated":                true,
+		"mesh-prod/sidecar-bootstrap":          true,
+		"default/sidecar-bootstrap":            false,
+		"mesh-prod/app-config":                 false,
+	}
+	for key, expected := range tests {
+		namespace, name, _ := strings.Cut(key, "/")
+		if excluded := config.isConfigMapExcluded(namespace, name); excluded != expected {
+			t.Errorf("isConfigMapExcluded(%q) = %v, expected %v", key, excluded, expected)
+		}
+	}
+
+	_ = fakeClient.Create(ctx, &autoapplyv1alpha1.AutoApplyConfig{
+		ObjectMeta: metav1.ObjectMeta{Name: "ca-aware"},
+		Spec:       autoapplyv1alpha1.AutoApplyConfigSpec{DisableDefaultConfigMapExclusions: true},
+	})
+
+	config = r.loadConfig(ctx, nil)
+	if config.isConfigMapExcluded("default", "kube-root-ca.crt") {
+		t.Error("Expected built-in exclusions to be disabled")
+	}
+	if !config.isConfigMapExcluded("default", "app-generated") {
+		t.Error("Expected configured exclusions to still apply")
+	}
+}
+
+func TestReconcile_IgnoresExcludedConfigMap(t *testing.T) {
+	r, fakeClient := setupTestReconciler()
+	ctx := context.Background()
+
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: "kube-root-ca.crt", Namespace: "default"}}
+	r.configMapVersions.Store(req.String(), "old-version")
+
+	_ = fakeClient.Create(ctx, &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "kube-root-ca.crt", Namespace: "default"},
+		Data:       map[string]string{"ca.crt": "rotated"},
+	})
+	_ = fakeClient.Create(ctx, &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-pod", Namespace: "default"},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{Name: "app", Image: "nginx"}},
+			Volumes: []corev1.Volume{{
+				Name: "ca",
+				VolumeSource: corev1.VolumeSource{
+					ConfigMap: &corev1.ConfigMapVolumeSource{
+						LocalObjectReference: corev1.LocalObjectReference{Name: "kube-root-ca.crt"},
+					},
+				},
+			}},
+		},
+		Status: corev1.PodStatus{Phase: corev1.PodRunning},
+	})
+
+	if _, err := r.Reconcile(ctx, req); err != nil {
+		t.Fatalf("Reconcile failed: %v", err)
+	}
+
+	var pods corev1.PodList
+	_ = fakeClient.List(ctx, &pods, client.InNamespace("default"))
+	if len(pods.Items) != 1 {
+		t.Error("Expected pod using kube-root-ca.crt not to be restarted")
+	}
+}
+
+func TestLoadConfig_ConfigMapSelector(t *testing.T) {
+	r, fakeClient := setupTestReconciler()
+	ctx := context.Background()
+
+	_ = fakeClient.Create(ctx, &autoapplyv1alpha1.AutoApplyConfig{
+		ObjectMeta: metav1.ObjectMeta{Name: "team-a"},
+		Spec: autoapplyv1alpha1.AutoApplyConfigSpec{
+			ConfigMapSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"team": "a"}},
+			YoloMode:          ptr.To(true),
+		},
+	})
+
+	teamA := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"team": "a"}}}
+	if !r.loadConfig(ctx, teamA).yoloMode {
+		t.Error("Expected the config to apply to matching ConfigMaps")
+	}
+
+	teamB := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"team": "b"}}}
+	if r.loadConfig(ctx, teamB).yoloMode {
+		t.Error("Expected the config to be ignored for other ConfigMaps")
+	}
+
+	if r.loadConfig(ctx, nil).yoloMode {
+		t.Error("Expected scoped configs to be ignored without a ConfigMap")
+	}
+}
+
+func TestLoadConfig_YoloModeNamespaces(t *testing.T) {
+	r, fakeClient := setupTestReconciler()
+	ctx := context.Background()
+
+	_ = fakeClient.Create(ctx, &autoapplyv1alpha1.AutoApplyConfig{
+		ObjectMeta: metav1.ObjectMeta{Name: "staging-yolo"},
+		Spec: autoapplyv1alpha1.AutoApplyConfigSpec{
+			YoloMode:           ptr.To(true),
+			YoloModeNamespaces: []string{"staging"},
+		},
+	})
+
+	staging := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "staging"}}
+	if !r.loadConfig(ctx, staging).yoloMode {
+		t.Error("Expected yolo mode in staging")
+	}
+	production := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "production"}}
+	if r.loadConfig(ctx, production).yoloMode {
+		t.Error("Expected batched restarts outside staging")
+	}
+	if r.loadConfig(ctx, nil).yoloMode {
+		t.Error("Expected restricted yolo mode to be ignored without a ConfigMap")
+	}
+}
+
+func TestLoadConfig_DisableYoloModeWins(t *testing.T) {
+	r, fakeClient := setupTestReconciler()
+	ctx := context.Background()
+
+	_ = fakeClient.Create(ctx, &autoapplyv1alpha1.AutoApplyConfig{
+		ObjectMeta: metav1.ObjectMeta{Name: "yolo"},
+		Spec:       autoapplyv1alpha1.AutoApplyConfigSpec{YoloMode: ptr.To(true)},
+	})
+	_ = fakeClient.Create(ctx, &autoapplyv1alpha1.AutoApplyNamespaceConfig{
+		ObjectMeta: metav1.ObjectMeta{Name: "careful", Namespace: "production"},
+		Spec:       autoapplyv1alpha1.AutoApplyConfigSpec{DisableYoloMode: true},
+	})
+
+	production := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "production"}}
+	cfg := r.loadConfig(ctx, production)
+	if cfg.yoloMode {
+		t.Error("Expected the namespace to keep batched restarts")
+	}
+	if !r.loadConfig(ctx, &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "dev"}}).yoloMode {
+		t.Error("Expected yolo mode in other namespaces")
+	}
+
+	// Nor can a one-shot override turn it on
+	production.Name = "app"
+	production.Annotations = map[string]string{nextChangeStrategyAnnotation: "yolo"}
+	_ = fakeClient.Create(ctx, production)
+	if r.consumeNextChangeStrategy(ctx, production, cfg).yoloMode {
+		t.Error("Expected the next-change yolo override to be refused")
+	}
+}
+
+func TestLoadConfig_ExcludeDaemonSets(t *testing.T) {
+	r, fakeClient := setupTestReconciler()
+	r.ExcludeDaemonSets = true
+	ctx := context.Background()
+
+	_ = fakeClient.Create(ctx, &autoapplyv1alpha1.AutoApplyNamespaceConfig{
+		ObjectMeta: metav1.ObjectMeta{Name: "nodes", Namespace: "node-agents"},
+		Spec:       autoapplyv1alpha1.AutoApplyConfigSpec{ExcludeDaemonSets: ptr.To(false)},
+	})
+
+	cfg := r.loadConfig(ctx, &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "default"}})
+	if !cfg.excludeDaemonSets {
+		t.Error("Expected the operator default to exclude DaemonSets")
+	}
+	if r.loadConfig(ctx, &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "node-agents"}}).excludeDaemonSets {
+		t.Error("Expected the namespace config to lift the default")
+	}
+
+	pod := podUsingConfigMap("agent-x7k2p", "test-config", time.Now())
+	pod.OwnerReferences = []metav1.OwnerReference{{Kind: "DaemonSet", Name: "agent", UID: "ds-uid", Controller: ptr.To(true)}}
+	cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "test-config", Namespace: "default"}}
+	if reason, ok := r.podSkipReason(ctx, cm, pod, cfg, make(workloadAnnotationCache)); !ok || reason != skipReasonDaemonSet {
+		t.Errorf("Expected the DaemonSet pod to be skipped, got %q", reason)
+	}
+
+	// Excluding them from a cluster-wide config wins over the namespace config
+	_ = fakeClient.Create(ctx, &autoapplyv1alpha1.AutoApplyConfig{
+		ObjectMeta: metav1.ObjectMeta{Name: "no-daemonsets"},
+		Spec:       autoapplyv1alpha1.AutoApplyConfigSpec{ExcludeDaemonSets: ptr.To(true)},
+	})
+	if !r.loadConfig(ctx, &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "node-agents"}}).excludeDaemonSets {
+		t.Error("Expected excludeDaemonSets: true to win")
+	}
+}
+
 // ============================================================================
 // Benchmark Tests
 // ============================================================================
@@ -985,4 +1347,3 @@ func BenchmarkIsPodExcluded(b *testing.B) {
 		r.isPodExcluded("nginx-deployment-abc123", patterns)
 	}
 }
-
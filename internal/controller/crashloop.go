@@ -0,0 +1,134 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	autoapplyv1alpha1 "github.com/manos/k8s-autoapply-operator/api/v1alpha1"
+)
+
+// replacementFailureReasons are container waiting reasons that won't resolve
+// by waiting longer and likely come from the new config
+var replacementFailureReasons = map[string]bool{
+	"CrashLoopBackOff":           true,
+	"ImagePullBackOff":           true,
+	"ErrImagePull":               true,
+	"CreateContainerConfigError": true,
+	"CreateContainerError":       true,
+	"RunContainerError":          true,
+}
+
+// ReplacementFailedError aborts a restart because pods replacing restarted
+// ones failed to start. Restart errors passed to RestartHook.OnComplete wrap
+// it within the owner's OwnerRestartError.
+type ReplacementFailedError struct {
+	Containers []autoapplyv1alpha1.FailingContainer
+}
+
+func (e *ReplacementFailedError) Error() string {
+	return "replacement pods failing: " + describeFailingContainers(e.Containers)
+}
+
+// describeFailingContainers lists containers as pod/container: Reason
+func describeFailingContainers(containers []autoapplyv1alpha1.FailingContainer) string {
+	descriptions := make([]string, len(containers))
+	for i, c := range containers {
+		descriptions[i] = fmt.Sprintf("%s/%s: %s", c.Pod, c.Container, c.Reason)
+	}
+	return strings.Join(descriptions, ", ")
+}
+
+// replacementPods returns the pods the owners of deletedPods created to
+// replace them: pods of the same owner created after the newest deleted pod
+// of that owner
+func (r *ConfigMapReconciler) replacementPods(ctx context.Context, deletedPods []corev1.Pod) ([]corev1.Pod, error) {
+	deleted := make(map[string]bool)
+	newest := make(map[types.UID]metav1.Time)
+	for _, pod := range deletedPods {
+		deleted[pod.Name] = true
+		if owner := metav1.GetControllerOf(&pod); owner != nil {
+			if created, ok := newest[owner.UID]; !ok || created.Before(&pod.CreationTimestamp) {
+				newest[owner.UID] = pod.CreationTimestamp
+			}
+		}
+	}
+	if len(newest) == 0 {
+		return nil, nil
+	}
+
+	var pods corev1.PodList
+	if err := r.List(ctx, &pods, client.InNamespace(deletedPods[0].Namespace)); err != nil {
+		return nil, err
+	}
+
+	var result []corev1.Pod
+	for _, pod := range pods.Items {
+		owner := metav1.GetControllerOf(&pod)
+		if owner == nil || deleted[pod.Name] || pod.DeletionTimestamp != nil {
+			continue
+		}
+		if created, ok := newest[owner.UID]; ok && created.Before(&pod.CreationTimestamp) {
+			result = append(result, pod)
+		}
+	}
+	return result, nil
+}
+
+// failingContainers returns the containers of pods stuck in a failure that
+// waiting won't fix
+func failingContainers(pods []corev1.Pod) []autoapplyv1alpha1.FailingContainer {
+	var result []autoapplyv1alpha1.FailingContainer
+	for _, pod := range pods {
+		statuses := append(append([]corev1.ContainerStatus{}, pod.Status.InitContainerStatuses...), pod.Status.ContainerStatuses...)
+		for _, status := range statuses {
+			if waiting := status.State.Waiting; waiting != nil && replacementFailureReasons[waiting.Reason] {
+				result = append(result, autoapplyv1alpha1.FailingContainer{
+					Pod:       pod.Name,
+					Container: status.Name,
+					Reason:    waiting.Reason,
+					Message:   waiting.Message,
+				})
+			}
+		}
+	}
+	return result
+}
+
+// replacementFailures returns the failing containers within err, looking
+// through joined errors. Later waves repeat the failure that stopped them,
+// so containers are listed once.
+func replacementFailures(err error) []autoapplyv1alpha1.FailingContainer {
+	if err == nil {
+		return nil
+	}
+
+	var errs []error
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		errs = joined.Unwrap()
+	} else {
+		errs = []error{err}
+	}
+
+	seen := make(map[autoapplyv1alpha1.FailingContainer]bool)
+	var result []autoapplyv1alpha1.FailingContainer
+	for _, e := range errs {
+		var failed *ReplacementFailedError
+		if !errors.As(e, &failed) {
+			continue
+		}
+		for _, c := range failed.Containers {
+			if !seen[c] {
+				seen[c] = true
+				result = append(result, c)
+			}
+		}
+	}
+	return result
+}
@@ -0,0 +1,130 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+
+	autoapplyv1alpha1 "github.com/manos/k8s-autoapply-operator/api/v1alpha1"
+)
+
+func waitingPod(name, reason string) corev1.Pod {
+	return corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Status: corev1.PodStatus{ContainerStatuses: []corev1.ContainerStatus{
+			{Name: "app", State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: reason, Message: "back-off"}}},
+		}},
+	}
+}
+
+func TestFailingContainers(t *testing.T) {
+	initFailing := waitingPod("init", "")
+	initFailing.Status.InitContainerStatuses = []corev1.ContainerStatus{
+		{Name: "migrate", State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "CreateContainerConfigError"}}},
+	}
+
+	pods := []corev1.Pod{
+		waitingPod("crashing", "CrashLoopBackOff"),
+		waitingPod("pulling", "ImagePullBackOff"),
+		waitingPod("starting", "ContainerCreating"),
+		initFailing,
+	}
+
+	failing := failingContainers(pods)
+	expected := []autoapplyv1alpha1.FailingContainer{
+		{Pod: "crashing", Container: "app", Reason: "CrashLoopBackOff", Message: "back-off"},
+		{Pod: "pulling", Container: "app", Reason: "ImagePullBackOff", Message: "back-off"},
+		{Pod: "init", Container: "migrate", Reason: "CreateContainerConfigError"},
+	}
+	if len(failing) != len(expected) {
+		t.Fatalf("Expected %v, got %v", expected, failing)
+	}
+	for i := range expected {
+		if failing[i] != expected[i] {
+			t.Errorf("Container %d = %+v, expected %+v", i, failing[i], expected[i])
+		}
+	}
+}
+
+func TestAwaitHealthy_ReplacementFailing(t *testing.T) {
+	r, fakeClient := setupTestReconciler()
+	ctx := context.Background()
+	trueVal := true
+	ownerRefs := []metav1.OwnerReference{
+		{APIVersion: "apps/v1", Kind: "ReplicaSet", Name: "web-abc", UID: "rs-uid", Controller: &trueVal},
+	}
+
+	// Ready, but older than the restarted pod, so not a replacement
+	sibling := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "web-abc-old", Namespace: "default", OwnerReferences: ownerRefs,
+			CreationTimestamp: metav1.NewTime(time.Now().Add(-2 * time.Hour))},
+		Status: corev1.PodStatus{Conditions: []corev1.PodCondition{{Type: corev1.PodReady, Status: corev1.ConditionTrue}}},
+	}
+	replacement := waitingPod("web-abc-new", "CrashLoopBackOff")
+	replacement.Namespace = "default"
+	replacement.OwnerReferences = ownerRefs
+	replacement.CreationTimestamp = metav1.Now()
+	_ = fakeClient.Create(ctx, sibling)
+	_ = fakeClient.Create(ctx, &replacement)
+
+	restarted := corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "web-abc-1", Namespace: "default", OwnerReferences: ownerRefs,
+		CreationTimestamp: metav1.NewTime(time.Now().Add(-time.Hour))}}
+
+	_, err := r.awaitHealthy(ctx, []corev1.Pod{restarted}, time.Now())
+	var failed *ReplacementFailedError
+	if !errors.As(err, &failed) {
+		t.Fatalf("Expected a ReplacementFailedError, got %v", err)
+	}
+	if len(failed.Containers) != 1 || failed.Containers[0].Pod != "web-abc-new" {
+		t.Errorf("Expected the replacement to be reported, got %+v", failed.Containers)
+	}
+}
+
+func TestReplacementFailures_Reported(t *testing.T) {
+	r, fakeClient := setupTestReconciler()
+	r.RecordOperations = true
+	ctx := context.Background()
+
+	crashing := autoapplyv1alpha1.FailingContainer{Pod: "web-abc-new", Container: "app", Reason: "CrashLoopBackOff"}
+	cause := &ReplacementFailedError{Containers: []autoapplyv1alpha1.FailingContainer{crashing}}
+	owner := []corev1.Pod{{ObjectMeta: metav1.ObjectMeta{Name: "web-abc-1"}}}
+	// A later wave repeats the failure that stopped it
+	err := errors.Join(
+		newOwnerRestartError(owner, cause),
+		newOwnerRestartError(owner, errors.Join(errors.New("restart wave 0 unhealthy"), cause)),
+	)
+
+	if failing := replacementFailures(err); len(failing) != 1 || failing[0] != crashing {
+		t.Fatalf("Expected the failing container once, got %+v", failing)
+	}
+
+	cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "test-config", Namespace: "default", UID: "cm-uid"}}
+	r.startOperation(ctx, operatorConfig{}, cm, "v2", keyChanges{}, owner)
+	r.recordBatch(ctx, cm, owner)
+	r.completeRestart(ctx, cm, err)
+
+	op := listOperations(t, fakeClient)[0]
+	if len(op.Status.FailingContainers) != 1 || op.Status.FailingContainers[0] != crashing {
+		t.Errorf("Expected the failing container in the status, got %+v", op.Status.FailingContainers)
+	}
+	if !meta.IsStatusConditionTrue(op.Status.Conditions, autoapplyv1alpha1.ConditionDegraded) {
+		t.Errorf("Expected a Degraded condition, got %+v", op.Status.Conditions)
+	}
+
+	r.reportRestartFailures(cm, err)
+	events := r.Recorder.(*record.FakeRecorder).Events
+	var last string
+	for len(events) > 0 {
+		last = <-events
+	}
+	if !strings.HasPrefix(last, "Warning ReplacementPodsFailing") || !strings.Contains(last, "web-abc-new/app: CrashLoopBackOff") {
+		t.Errorf("Expected a ReplacementPodsFailing event, got %q", last)
+	}
+}
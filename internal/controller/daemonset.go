@@ -0,0 +1,133 @@
+package controller
+
+import (
+	"context"
+	"sort"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	autoapplyv1alpha1 "github.com/manos/k8s-autoapply-operator/api/v1alpha1"
+)
+
+// +kubebuilder:rbac:groups="",resources=nodes,verbs=get;list;watch
+
+// daemonSetBatches splits a DaemonSet's pods into batches of at most
+// maxUnavailable nodes, a single node first with the canary strategy. Pods on
+// cordoned nodes are returned apart and left running.
+func (r *ConfigMapReconciler) daemonSetBatches(ctx context.Context, cfg operatorConfig, owner *metav1.OwnerReference, pods []corev1.Pod) (batches [][]corev1.Pod, cordoned []corev1.Pod) {
+	maxUnavailable := r.daemonSetMaxUnavailable(ctx, pods[0].Namespace, owner.Name)
+
+	var schedulable []corev1.Pod
+	for _, pod := range pods {
+		if r.isNodeCordoned(ctx, pod.Spec.NodeName) {
+			cordoned = append(cordoned, pod)
+			continue
+		}
+		schedulable = append(schedulable, pod)
+	}
+
+	sort.Slice(schedulable, func(i, j int) bool {
+		return schedulable[i].Spec.NodeName < schedulable[j].Spec.NodeName
+	})
+
+	for start := 0; start < len(schedulable); {
+		size := maxUnavailable
+		if start == 0 && cfg.strategy == autoapplyv1alpha1.RestartStrategyCanary {
+			size = 1
+		}
+		end := min(start+size, len(schedulable))
+		batches = append(batches, schedulable[start:end])
+		start = end
+	}
+
+	log.FromContext(ctx).V(1).Info("Planned DaemonSet restart node by node",
+		"daemonset", owner.Name,
+		"total", len(schedulable),
+		"cordoned", len(cordoned),
+		"maxUnavailable", maxUnavailable)
+	return batches, cordoned
+}
+
+// daemonSetMaxUnavailable returns how many nodes may restart at once, following
+// the DaemonSet's updateStrategy. Defaults to 1 if unset or the lookup fails.
+func (r *ConfigMapReconciler) daemonSetMaxUnavailable(ctx context.Context, namespace, name string) int {
+	var ds appsv1.DaemonSet
+	if err := r.Get(ctx, types.NamespacedName{Namespace: namespace, Name: name}, &ds); err != nil {
+		return 1
+	}
+
+	rolling := ds.Spec.UpdateStrategy.RollingUpdate
+	if rolling == nil || rolling.MaxUnavailable == nil {
+		return 1
+	}
+
+	value, err := intstr.GetScaledValueFromIntOrPercent(rolling.MaxUnavailable, int(ds.Status.DesiredNumberScheduled), true)
+	if err != nil || value < 1 {
+		return 1
+	}
+	return value
+}
+
+// isNodeCordoned checks if a node is marked unschedulable
+func (r *ConfigMapReconciler) isNodeCordoned(ctx context.Context, nodeName string) bool {
+	if nodeName == "" {
+		return false
+	}
+
+	var node corev1.Node
+	if err := r.Get(ctx, types.NamespacedName{Name: nodeName}, &node); err != nil {
+		return false
+	}
+	return node.Spec.Unschedulable
+}
+
+// nodesHealthy checks if every node of the restarted pods runs a new, Ready
+// pod of the DaemonSet
+func (r *ConfigMapReconciler) nodesHealthy(ctx context.Context, ownerUID types.UID, restartedPods []corev1.Pod) (bool, error) {
+	if len(restartedPods) == 0 {
+		return true, nil
+	}
+
+	restarted := make(map[types.UID]bool, len(restartedPods))
+	nodes := make(map[string]bool, len(restartedPods))
+	for _, pod := range restartedPods {
+		restarted[pod.UID] = true
+		nodes[pod.Spec.NodeName] = true
+	}
+
+	var pods corev1.PodList
+	if err := r.List(ctx, &pods, client.InNamespace(restartedPods[0].Namespace)); err != nil {
+		return false, err
+	}
+
+	ready := make(map[string]bool)
+	for _, pod := range pods.Items {
+		owner := metav1.GetControllerOf(&pod)
+		if owner == nil || owner.UID != ownerUID || restarted[pod.UID] {
+			continue
+		}
+		if nodes[pod.Spec.NodeName] && isPodReady(&pod) {
+			ready[pod.Spec.NodeName] = true
+		}
+	}
+	return len(ready) == len(nodes), nil
+}
+
+// mergeExcludeDaemonSets merges a config's excludeDaemonSets into c. Excluding
+// them from any config wins; false only lifts the operator default.
+func (c *operatorConfig) mergeExcludeDaemonSets(exclude *bool) {
+	if exclude == nil {
+		return
+	}
+	if *exclude {
+		c.excludeDaemonSets = true
+	} else {
+		c.includeDaemonSets = true
+	}
+}
@@ -0,0 +1,170 @@
+package controller
+
+import (
+	"context"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestDaemonSetMaxUnavailable(t *testing.T) {
+	tests := []struct {
+		name           string
+		maxUnavailable *intstr.IntOrString
+		expected       int
+	}{
+		{"unset", nil, 1},
+		{"absolute", &intstr.IntOrString{Type: intstr.Int, IntVal: 3}, 3},
+		{"percent rounds up", &intstr.IntOrString{Type: intstr.String, StrVal: "25%"}, 2},
+		{"zero", &intstr.IntOrString{Type: intstr.Int, IntVal: 0}, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, fakeClient := setupTestReconciler()
+			ctx := context.Background()
+
+			ds := &appsv1.DaemonSet{
+				ObjectMeta: metav1.ObjectMeta{Name: "agent", Namespace: "default"},
+				Status:     appsv1.DaemonSetStatus{DesiredNumberScheduled: 5},
+			}
+			if tt.maxUnavailable != nil {
+				ds.Spec.UpdateStrategy.RollingUpdate = &appsv1.RollingUpdateDaemonSet{MaxUnavailable: tt.maxUnavailable}
+			}
+			_ = fakeClient.Create(ctx, ds)
+
+			if result := r.daemonSetMaxUnavailable(ctx, "default", "agent"); result != tt.expected {
+				t.Errorf("daemonSetMaxUnavailable() = %d, expected %d", result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestRestart_DaemonSetSkipsCordonedNodes(t *testing.T) {
+	r, fakeClient := setupTestReconciler()
+	ctx := context.Background()
+
+	cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "test-config", Namespace: "default"}}
+
+	maxUnavailable := intstr.FromInt32(2)
+	ds := &appsv1.DaemonSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "agent", Namespace: "default", UID: "agent-uid"},
+		Spec: appsv1.DaemonSetSpec{
+			UpdateStrategy: appsv1.DaemonSetUpdateStrategy{
+				RollingUpdate: &appsv1.RollingUpdateDaemonSet{MaxUnavailable: &maxUnavailable},
+			},
+		},
+	}
+	_ = fakeClient.Create(ctx, ds)
+
+	_ = fakeClient.Create(ctx, &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-a"}})
+	_ = fakeClient.Create(ctx, &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-b"}, Spec: corev1.NodeSpec{Unschedulable: true}})
+	_ = fakeClient.Create(ctx, &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-c"}})
+
+	trueVal := true
+	var podsToRestart []corev1.Pod
+	for _, node := range []string{"node-a", "node-b", "node-c"} {
+		pod := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "agent-" + node,
+				Namespace: "default",
+				OwnerReferences: []metav1.OwnerReference{
+					{APIVersion: "apps/v1", Kind: "DaemonSet", Name: "agent", UID: ds.UID, Controller: &trueVal},
+				},
+			},
+			Spec:   corev1.PodSpec{NodeName: node},
+			Status: corev1.PodStatus{Phase: corev1.PodRunning},
+		}
+		_ = fakeClient.Create(ctx, pod)
+		podsToRestart = append(podsToRestart, *pod)
+	}
+
+	// Two schedulable nodes fit in one batch of maxUnavailable=2, so no health wait
+	if err := runRestart(ctx, r, r.loadConfig(ctx, nil), cm, "v2", podsToRestart); err != nil {
+		t.Fatalf("Restart failed: %v", err)
+	}
+
+	var remaining corev1.PodList
+	_ = fakeClient.List(ctx, &remaining, client.InNamespace("default"))
+	if names := podNames(remaining.Items); len(names) != 1 || names[0] != "agent-node-b" {
+		t.Errorf("Expected only the pod on the cordoned node to remain, got %v", names)
+	}
+}
+
+func TestNodesHealthy(t *testing.T) {
+	agentPod := func(name, node string, ownerUID types.UID, ready bool) corev1.Pod {
+		status := corev1.ConditionFalse
+		if ready {
+			status = corev1.ConditionTrue
+		}
+		return corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "default",
+				UID:       types.UID(name),
+				OwnerReferences: []metav1.OwnerReference{
+					{APIVersion: "apps/v1", Kind: "DaemonSet", Name: "agent", UID: ownerUID, Controller: ptr.To(true)},
+				},
+			},
+			Spec: corev1.PodSpec{NodeName: node},
+			Status: corev1.PodStatus{
+				Phase:      corev1.PodRunning,
+				Conditions: []corev1.PodCondition{{Type: corev1.PodReady, Status: status}},
+			},
+		}
+	}
+	restarted := []corev1.Pod{agentPod("old-a", "node-a", "agent-uid", true), agentPod("old-b", "node-b", "agent-uid", true)}
+
+	tests := []struct {
+		name     string
+		existing []corev1.Pod
+		expected bool
+	}{
+		{
+			name:     "every node runs a new Ready pod",
+			existing: []corev1.Pod{agentPod("new-a", "node-a", "agent-uid", true), agentPod("new-b", "node-b", "agent-uid", true)},
+			expected: true,
+		},
+		{
+			name:     "new pod on a node isn't Ready",
+			existing: []corev1.Pod{agentPod("new-a", "node-a", "agent-uid", true), agentPod("new-b", "node-b", "agent-uid", false)},
+		},
+		{
+			name:     "node without a new pod",
+			existing: []corev1.Pod{agentPod("new-a", "node-a", "agent-uid", true)},
+		},
+		{
+			name: "only the restarted pod is Ready on a node",
+			// A restarted pod still terminating doesn't count for its node
+			existing: []corev1.Pod{agentPod("new-a", "node-a", "agent-uid", true), restarted[1]},
+		},
+		{
+			name:     "Ready pod of another DaemonSet",
+			existing: []corev1.Pod{agentPod("new-a", "node-a", "agent-uid", true), agentPod("other-b", "node-b", "other-uid", true)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, fakeClient := setupTestReconciler()
+			ctx := context.Background()
+			for _, pod := range tt.existing {
+				_ = fakeClient.Create(ctx, &pod)
+			}
+
+			healthy, err := r.nodesHealthy(ctx, "agent-uid", restarted)
+			if err != nil {
+				t.Fatalf("nodesHealthy() failed: %v", err)
+			}
+			if healthy != tt.expected {
+				t.Errorf("nodesHealthy() = %v, expected %v", healthy, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,34 @@
+package controller
+
+import (
+	"time"
+)
+
+// debounceEntry records when a ConfigMap version was first seen
+type debounceEntry struct {
+	version string
+	since   time.Time
+}
+
+// debounce returns how much longer to wait before handling the change to
+// version, or 0 once it has been stable for the debounce duration. A newer
+// version restarts the wait.
+func (r *ConfigMapReconciler) debounce(key, version string, duration time.Duration) time.Duration {
+	if duration <= 0 {
+		r.debouncing.Delete(key)
+		return 0
+	}
+
+	value, ok := r.debouncing.Load(key)
+	if !ok || value.(debounceEntry).version != version {
+		r.debouncing.Store(key, debounceEntry{version: version, since: time.Now()})
+		return duration
+	}
+
+	if wait := duration - time.Since(value.(debounceEntry).since); wait > 0 {
+		return wait
+	}
+
+	r.debouncing.Delete(key)
+	return 0
+}
@@ -0,0 +1,92 @@
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	autoapplyv1alpha1 "github.com/manos/k8s-autoapply-operator/api/v1alpha1"
+)
+
+func TestReconcile_Debounce(t *testing.T) {
+	r, fakeClient := setupTestReconciler()
+	ctx := context.Background()
+
+	req := ctrl.Request{
+		NamespacedName: types.NamespacedName{Name: "test-config", Namespace: "default"},
+	}
+	r.configMapVersions.Store(req.String(), "old-version")
+
+	_ = fakeClient.Create(ctx, &autoapplyv1alpha1.AutoApplyConfig{
+		ObjectMeta: metav1.ObjectMeta{Name: "debounce"},
+		Spec: autoapplyv1alpha1.AutoApplyConfigSpec{
+			DebounceDuration: &metav1.Duration{Duration: time.Minute},
+		},
+	})
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-config", Namespace: "default"},
+		Data:       map[string]string{"key": "v1"},
+	}
+	_ = fakeClient.Create(ctx, cm)
+	_ = fakeClient.Create(ctx, &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-pod", Namespace: "default"},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{Name: "app", Image: "nginx"}},
+			Volumes: []corev1.Volume{{
+				Name: "config",
+				VolumeSource: corev1.VolumeSource{
+					ConfigMap: &corev1.ConfigMapVolumeSource{
+						LocalObjectReference: corev1.LocalObjectReference{Name: "test-config"},
+					},
+				},
+			}},
+		},
+		Status: corev1.PodStatus{Phase: corev1.PodRunning},
+	})
+
+	countPods := func() int {
+		var pods corev1.PodList
+		_ = fakeClient.List(ctx, &pods, client.InNamespace("default"))
+		return len(pods.Items)
+	}
+
+	// First change starts the debounce
+	result, err := r.Reconcile(ctx, req)
+	if err != nil {
+		t.Fatalf("Reconcile failed: %v", err)
+	}
+	if result.RequeueAfter != time.Minute {
+		t.Errorf("Expected requeue after the debounce duration, got %v", result.RequeueAfter)
+	}
+	if countPods() != 1 {
+		t.Error("Expected pod to be kept while debouncing")
+	}
+
+	// A newer version restarts the wait
+	cm.Data["key"] = "v2"
+	_ = fakeClient.Update(ctx, cm)
+	if result, _ = r.Reconcile(ctx, req); result.RequeueAfter != time.Minute {
+		t.Errorf("Expected a newer version to restart the debounce, got %v", result.RequeueAfter)
+	}
+	if countPods() != 1 {
+		t.Error("Expected pod to be kept while debouncing")
+	}
+
+	// Once the version has been stable long enough, the restart runs
+	r.debouncing.Store(req.String(), debounceEntry{version: configMapVersion(cm), since: time.Now().Add(-2 * time.Minute)})
+	if _, err := r.Reconcile(ctx, req); err != nil {
+		t.Fatalf("Reconcile failed: %v", err)
+	}
+	if countPods() != 0 {
+		t.Error("Expected pod to be restarted after the debounce")
+	}
+	if _, settling := r.debouncing.Load(req.String()); settling {
+		t.Error("Expected debounce state to be cleared")
+	}
+}
@@ -0,0 +1,50 @@
+package controller
+
+import (
+	"context"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// Deployments without an explicit rollingUpdate get the API server default
+var defaultDeploymentMaxUnavailable = intstr.FromString("25%")
+
+// deploymentMaxUnavailable returns how many pods of the pod's Deployment may
+// be restarted at once, following its rollingUpdate strategy. Evictions can't
+// surge, so a Deployment relying on maxSurge alone is restarted one pod at a
+// time. Returns 0 (no limit) for other owners, Recreate Deployments, or if
+// the lookup fails.
+func (r *ConfigMapReconciler) deploymentMaxUnavailable(ctx context.Context, pod *corev1.Pod) int {
+	workload, err := r.resolveWorkload(ctx, pod)
+	if err != nil || workload == nil || workload.Kind != "Deployment" {
+		return 0
+	}
+
+	var deployment appsv1.Deployment
+	if err := r.Get(ctx, types.NamespacedName{Namespace: pod.Namespace, Name: workload.Name}, &deployment); err != nil {
+		return 0
+	}
+	if deployment.Spec.Strategy.Type == appsv1.RecreateDeploymentStrategyType {
+		return 0
+	}
+
+	maxUnavailable := &defaultDeploymentMaxUnavailable
+	if rolling := deployment.Spec.Strategy.RollingUpdate; rolling != nil && rolling.MaxUnavailable != nil {
+		maxUnavailable = rolling.MaxUnavailable
+	}
+
+	replicas := 1
+	if deployment.Spec.Replicas != nil {
+		replicas = int(*deployment.Spec.Replicas)
+	}
+
+	// Rounded down like the Deployment controller does
+	value, err := intstr.GetScaledValueFromIntOrPercent(maxUnavailable, replicas, false)
+	if err != nil || value < 1 {
+		return 1
+	}
+	return value
+}
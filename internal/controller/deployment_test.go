@@ -0,0 +1,100 @@
+package controller
+
+import (
+	"context"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	autoapplyv1alpha1 "github.com/manos/k8s-autoapply-operator/api/v1alpha1"
+)
+
+func TestDeploymentMaxUnavailable(t *testing.T) {
+	tests := []struct {
+		name     string
+		strategy appsv1.DeploymentStrategy
+		expected int
+	}{
+		{"default rolling update", appsv1.DeploymentStrategy{}, 2},
+		{"absolute", appsv1.DeploymentStrategy{RollingUpdate: &appsv1.RollingUpdateDeployment{
+			MaxUnavailable: &intstr.IntOrString{Type: intstr.Int, IntVal: 3}}}, 3},
+		{"percent rounds down", appsv1.DeploymentStrategy{RollingUpdate: &appsv1.RollingUpdateDeployment{
+			MaxUnavailable: &intstr.IntOrString{Type: intstr.String, StrVal: "30%"}}}, 3},
+		{"surge only", appsv1.DeploymentStrategy{RollingUpdate: &appsv1.RollingUpdateDeployment{
+			MaxUnavailable: &intstr.IntOrString{Type: intstr.Int, IntVal: 0}}}, 1},
+		{"recreate", appsv1.DeploymentStrategy{Type: appsv1.RecreateDeploymentStrategyType}, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, fakeClient := setupTestReconciler()
+			ctx := context.Background()
+
+			replicas := int32(10)
+			trueVal := true
+			_ = fakeClient.Create(ctx, &appsv1.Deployment{
+				ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"},
+				Spec:       appsv1.DeploymentSpec{Replicas: &replicas, Strategy: tt.strategy},
+			})
+			_ = fakeClient.Create(ctx, &appsv1.ReplicaSet{
+				ObjectMeta: metav1.ObjectMeta{Name: "web-abc", Namespace: "default", OwnerReferences: []metav1.OwnerReference{
+					{APIVersion: "apps/v1", Kind: "Deployment", Name: "web", Controller: &trueVal},
+				}},
+			})
+			pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "web-abc-1", Namespace: "default", OwnerReferences: []metav1.OwnerReference{
+				{APIVersion: "apps/v1", Kind: "ReplicaSet", Name: "web-abc", Controller: &trueVal},
+			}}}
+
+			if result := r.deploymentMaxUnavailable(ctx, pod); result != tt.expected {
+				t.Errorf("deploymentMaxUnavailable() = %d, expected %d", result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestDeploymentMaxUnavailable_NotADeployment(t *testing.T) {
+	r, _ := setupTestReconciler()
+
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "standalone", Namespace: "default"}}
+	if result := r.deploymentMaxUnavailable(context.Background(), pod); result != 0 {
+		t.Errorf("Expected no limit for a standalone pod, got %d", result)
+	}
+}
+
+func TestOwnerBatches(t *testing.T) {
+	tests := []struct {
+		name     string
+		strategy autoapplyv1alpha1.RestartStrategy
+		pods     int
+		limit    int
+		expected []int
+	}{
+		{"halves without limit", autoapplyv1alpha1.RestartStrategyRolling, 5, 0, []int{3, 2}},
+		{"limit above half", autoapplyv1alpha1.RestartStrategyRolling, 5, 4, []int{3, 2}},
+		{"limit cuts halves", autoapplyv1alpha1.RestartStrategyRolling, 10, 2, []int{2, 2, 1, 2, 2, 1}},
+		{"one at a time", autoapplyv1alpha1.RestartStrategyRolling, 3, 1, []int{1, 1, 1}},
+		{"canary first", autoapplyv1alpha1.RestartStrategyCanary, 6, 2, []int{1, 2, 2, 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			batches := ownerBatches(tt.strategy, nil, make([]corev1.Pod, tt.pods), tt.limit)
+
+			sizes := make([]int, len(batches))
+			for i, batch := range batches {
+				sizes[i] = len(batch)
+			}
+			if len(sizes) != len(tt.expected) {
+				t.Fatalf("Expected batches %v, got %v", tt.expected, sizes)
+			}
+			for i := range sizes {
+				if sizes[i] != tt.expected[i] {
+					t.Fatalf("Expected batches %v, got %v", tt.expected, sizes)
+				}
+			}
+		})
+	}
+}
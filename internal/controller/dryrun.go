@@ -0,0 +1,172 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	autoapplyv1alpha1 "github.com/manos/k8s-autoapply-operator/api/v1alpha1"
+)
+
+// +kubebuilder:rbac:groups=policy,resources=poddisruptionbudgets,verbs=get;list;watch
+
+// restartPlan describes what a restart would do without doing it
+type restartPlan struct {
+	batches [][]corev1.Pod
+	// pdbBlocked maps pod names to a PDB whose disruptions their batch uses up
+	pdbBlocked map[string]string
+	// skipped maps pods left running to the reason
+	skipped map[string]string
+}
+
+// planRestart computes the batches a restart would use and which pods would
+// be blocked by a PodDisruptionBudget. Batches follow the waves and owner
+// plans of a real restart; owners of a wave restart concurrently, so batch i
+// of a wave holds every owner's i-th batch.
+func (r *ConfigMapReconciler) planRestart(ctx context.Context, cfg operatorConfig, namespace string, pods []corev1.Pod) restartPlan {
+	logger := log.FromContext(ctx)
+	plan := restartPlan{pdbBlocked: make(map[string]string), skipped: make(map[string]string)}
+
+	switch {
+	case cfg.yoloMode:
+		// YOLO deletes everything at once and ignores PDBs
+		plan.batches = [][]corev1.Pod{pods}
+		return plan
+	case cfg.strategy == autoapplyv1alpha1.RestartStrategyTrickle:
+		plan.batches = trickleBatches(cfg, pods)
+	default:
+		for _, wave := range r.planWaves(ctx, cfg, pods) {
+			var batches [][]corev1.Pod
+			for _, owner := range wave.owners {
+				for i, batch := range owner.batches {
+					if i == len(batches) {
+						batches = append(batches, nil)
+					}
+					batches[i] = append(batches[i], batch...)
+				}
+				for _, pod := range owner.cordoned {
+					plan.skipped[pod.Name] = "node is cordoned"
+				}
+			}
+			plan.batches = append(plan.batches, batches...)
+		}
+	}
+
+	pdbs, err := r.loadPDBs(ctx, namespace)
+	if err != nil {
+		logger.Error(err, "Failed to load PDBs for restart plan")
+		return plan
+	}
+
+	// Evicted pods come back between batches, so each batch starts with the
+	// PDBs' current budget
+	for _, batch := range plan.batches {
+		budget := newDisruptionBudget(pdbs)
+		for _, pod := range batch {
+			if pdb := budget.take(&pod); pdb != "" {
+				plan.pdbBlocked[pod.Name] = pdb
+			}
+		}
+	}
+
+	return plan
+}
+
+// reportDryRun records the restart plan via logs and events instead of restarting pods
+func (r *ConfigMapReconciler) reportDryRun(ctx context.Context, cfg operatorConfig, configMap *corev1.ConfigMap, pods []corev1.Pod) {
+	logger := log.FromContext(ctx)
+	plan := r.planRestart(ctx, cfg, configMap.Namespace, pods)
+
+	logger.Info("DRY RUN: restart plan",
+		"pods", len(pods)-len(plan.skipped),
+		"batches", len(plan.batches),
+		"pdbBlocked", len(plan.pdbBlocked),
+		"skipped", len(plan.skipped),
+		"yoloMode", cfg.yoloMode)
+
+	r.Recorder.Eventf(configMap, corev1.EventTypeNormal, "DryRunRestartPlan",
+		"Would restart %d pods in %d batches (%d currently blocked by PodDisruptionBudget)",
+		len(pods)-len(plan.skipped), len(plan.batches), len(plan.pdbBlocked))
+
+	for i, batch := range plan.batches {
+		for _, pod := range batch {
+			message := fmt.Sprintf("Would be restarted in batch %d/%d due to change in ConfigMap %s",
+				i+1, len(plan.batches), configMap.Name)
+			if pdb, blocked := plan.pdbBlocked[pod.Name]; blocked {
+				message += fmt.Sprintf(", currently blocked by PodDisruptionBudget %s", pdb)
+			}
+
+			logger.Info("DRY RUN: would restart pod",
+				"pod", pod.Name,
+				"batch", i+1,
+				"pdbBlocker", plan.pdbBlocked[pod.Name])
+			r.Recorder.Event(&pod, corev1.EventTypeNormal, "DryRunRestart", message)
+		}
+	}
+
+	for _, pod := range pods {
+		if reason, skipped := plan.skipped[pod.Name]; skipped {
+			logger.Info("DRY RUN: would skip pod", "pod", pod.Name, "reason", reason)
+			r.Recorder.Eventf(&pod, corev1.EventTypeNormal, "DryRunSkip",
+				"Would be left running despite change in ConfigMap %s, %s", configMap.Name, reason)
+		}
+	}
+}
+
+// pdbSelects checks if a PDB covers the pod
+func pdbSelects(pdb *policyv1.PodDisruptionBudget, pod *corev1.Pod) bool {
+	if pdb.Spec.Selector == nil {
+		return false
+	}
+	selector, err := metav1.LabelSelectorAsSelector(pdb.Spec.Selector)
+	if err != nil {
+		return false
+	}
+	return selector.Matches(labels.Set(pod.Labels))
+}
+
+// disruptionBudget tracks the disruptions one batch takes from each PDB. PDB
+// status doesn't update while a batch is planned, so checking pods one by one
+// would let them together exceed a budget.
+type disruptionBudget struct {
+	pdbs []policyv1.PodDisruptionBudget
+	used map[string]int32
+}
+
+func newDisruptionBudget(pdbs []policyv1.PodDisruptionBudget) *disruptionBudget {
+	return &disruptionBudget{pdbs: pdbs, used: make(map[string]int32)}
+}
+
+// take returns the name of a PDB selecting the pod that has no disruptions
+// left, or else counts the pod's disruption against every PDB selecting it
+func (b *disruptionBudget) take(pod *corev1.Pod) string {
+	var selecting []string
+	for _, pdb := range b.pdbs {
+		if !pdbSelects(&pdb, pod) {
+			continue
+		}
+		if b.used[pdb.Name] >= pdb.Status.DisruptionsAllowed {
+			return pdb.Name
+		}
+		selecting = append(selecting, pdb.Name)
+	}
+	for _, name := range selecting {
+		b.used[name]++
+	}
+	return ""
+}
+
+// loadPDBs loads PodDisruptionBudgets for a namespace
+func (r *ConfigMapReconciler) loadPDBs(ctx context.Context, namespace string) ([]policyv1.PodDisruptionBudget, error) {
+	var pdbList policyv1.PodDisruptionBudgetList
+	if err := r.List(ctx, &pdbList, client.InNamespace(namespace)); err != nil {
+		return nil, err
+	}
+	return pdbList.Items, nil
+}
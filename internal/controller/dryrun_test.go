@@ -0,0 +1,237 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/ptr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	autoapplyv1alpha1 "github.com/manos/k8s-autoapply-operator/api/v1alpha1"
+)
+
+func TestDisruptionBudgetTake(t *testing.T) {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   "test-pod",
+			Labels: map[string]string{"app": "test"},
+		},
+	}
+
+	tests := []struct {
+		name               string
+		disruptionsAllowed int32
+		matchLabels        map[string]string
+		expected           string
+	}{
+		{"disruptions allowed", 1, map[string]string{"app": "test"}, ""},
+		{"no disruptions allowed", 0, map[string]string{"app": "test"}, "test-pdb"},
+		{"pdb selects other pods", 0, map[string]string{"app": "other"}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pdb := policyv1.PodDisruptionBudget{
+				ObjectMeta: metav1.ObjectMeta{Name: "test-pdb"},
+				Spec: policyv1.PodDisruptionBudgetSpec{
+					Selector: &metav1.LabelSelector{MatchLabels: tt.matchLabels},
+				},
+				Status: policyv1.PodDisruptionBudgetStatus{
+					DisruptionsAllowed: tt.disruptionsAllowed,
+				},
+			}
+
+			result := newDisruptionBudget([]policyv1.PodDisruptionBudget{pdb}).take(pod)
+			if result != tt.expected {
+				t.Errorf("take() = %q, expected %q", result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestReconcile_DryRun(t *testing.T) {
+	r, fakeClient := setupTestReconciler()
+	ctx := context.Background()
+
+	req := ctrl.Request{
+		NamespacedName: types.NamespacedName{Name: "test-config", Namespace: "default"},
+	}
+	r.configMapVersions.Store(req.String(), "old-version")
+
+	_ = fakeClient.Create(ctx, &autoapplyv1alpha1.AutoApplyConfig{
+		ObjectMeta: metav1.ObjectMeta{Name: "dry-run"},
+		Spec:       autoapplyv1alpha1.AutoApplyConfigSpec{DryRun: ptr.To(true)},
+	})
+	_ = fakeClient.Create(ctx, &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-config", Namespace: "default"},
+	})
+	_ = fakeClient.Create(ctx, &policyv1.PodDisruptionBudget{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-pdb", Namespace: "default"},
+		Spec: policyv1.PodDisruptionBudgetSpec{
+			Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "test"}},
+		},
+	})
+
+	for _, name := range []string{"test-pod-a", "test-pod-b"} {
+		_ = fakeClient.Create(ctx, &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "default",
+				Labels:    map[string]string{"app": "test"},
+			},
+			Spec: corev1.PodSpec{
+				Containers: []corev1.Container{{Name: "app", Image: "nginx"}},
+				Volumes: []corev1.Volume{{
+					Name: "config",
+					VolumeSource: corev1.VolumeSource{
+						ConfigMap: &corev1.ConfigMapVolumeSource{
+							LocalObjectReference: corev1.LocalObjectReference{Name: "test-config"},
+						},
+					},
+				}},
+			},
+			Status: corev1.PodStatus{Phase: corev1.PodRunning},
+		})
+	}
+
+	if _, err := r.Reconcile(ctx, req); err != nil {
+		t.Fatalf("Reconcile failed: %v", err)
+	}
+
+	// Nothing should be deleted
+	var pods corev1.PodList
+	_ = fakeClient.List(ctx, &pods, client.InNamespace("default"))
+	if len(pods.Items) != 2 {
+		t.Errorf("Dry run should not delete pods, found %d remaining", len(pods.Items))
+	}
+
+	recorder := r.Recorder.(*record.FakeRecorder)
+	close(recorder.Events)
+
+	var events []string
+	for event := range recorder.Events {
+		events = append(events, event)
+	}
+
+	if len(events) != 3 {
+		t.Fatalf("Expected 1 plan event and 2 pod events, got %v", events)
+	}
+	if events[0] != "Normal DryRunRestartPlan Would restart 2 pods in 2 batches (2 currently blocked by PodDisruptionBudget)" {
+		t.Errorf("Unexpected plan event: %s", events[0])
+	}
+	for _, event := range events[1:] {
+		if !strings.Contains(event, "currently blocked by PodDisruptionBudget test-pdb") {
+			t.Errorf("Expected pod event to name the blocking PDB, got %s", event)
+		}
+	}
+}
+
+func TestDisruptionBudget_CapsBatch(t *testing.T) {
+	pdb := policyv1.PodDisruptionBudget{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-pdb"},
+		Spec: policyv1.PodDisruptionBudgetSpec{
+			Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "test"}},
+		},
+		Status: policyv1.PodDisruptionBudgetStatus{DisruptionsAllowed: 2},
+	}
+	budget := newDisruptionBudget([]policyv1.PodDisruptionBudget{pdb})
+
+	var blocked []string
+	for _, name := range []string{"pod-1", "pod-2", "pod-3"} {
+		pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: map[string]string{"app": "test"}}}
+		if budget.take(pod) != "" {
+			blocked = append(blocked, name)
+		}
+	}
+
+	// Each pod alone fits the budget, but only two fit together
+	if len(blocked) != 1 || blocked[0] != "pod-3" {
+		t.Errorf("Expected only pod-3 to be blocked, got %v", blocked)
+	}
+
+	other := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "other", Labels: map[string]string{"app": "other"}}}
+	if pdb := budget.take(other); pdb != "" {
+		t.Errorf("Expected a pod outside the PDB not to be blocked, got %q", pdb)
+	}
+}
+
+// cappedDeployment creates a Deployment allowing one unavailable pod, its
+// ReplicaSet and pods using test-config, reporting every replica rolled out
+func cappedDeployment(t *testing.T, c client.Client, name string, replicas int32, wave string) {
+	t.Helper()
+	ctx := context.Background()
+	maxUnavailable := intstr.FromInt32(1)
+	_ = c.Create(ctx, &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", Annotations: map[string]string{restartWaveAnnotation: wave}},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: &replicas,
+			Strategy: appsv1.DeploymentStrategy{RollingUpdate: &appsv1.RollingUpdateDeployment{MaxUnavailable: &maxUnavailable}},
+		},
+		Status: appsv1.DeploymentStatus{
+			ObservedGeneration: 1, Replicas: replicas, UpdatedReplicas: replicas, AvailableReplicas: replicas,
+		},
+	})
+	_ = c.Create(ctx, &appsv1.ReplicaSet{ObjectMeta: metav1.ObjectMeta{
+		Name: name + "-rs", Namespace: "default", UID: types.UID(name + "-rs"),
+		OwnerReferences: []metav1.OwnerReference{{APIVersion: "apps/v1", Kind: "Deployment", Name: name, Controller: ptr.To(true)}},
+	}})
+	for i := range replicas {
+		pod := podUsingConfigMap(fmt.Sprintf("%s-%d", name, i), "test-config", time.Now())
+		pod.OwnerReferences = []metav1.OwnerReference{
+			{APIVersion: "apps/v1", Kind: "ReplicaSet", Name: name + "-rs", UID: types.UID(name + "-rs"), Controller: ptr.To(true)},
+		}
+		_ = c.Create(ctx, pod)
+	}
+}
+
+func TestPlanRestart_MatchesRealRun(t *testing.T) {
+	r, fakeClient := setupTestReconciler()
+	r.RecordOperations = true
+	ctx := context.Background()
+
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: "test-config", Namespace: "default"}}
+	r.configMapVersions.Store(req.String(), "old-version")
+	cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "test-config", Namespace: "default"}}
+	_ = fakeClient.Create(ctx, cm)
+
+	// The backend goes first, and neither Deployment allows more than one pod down
+	cappedDeployment(t, fakeClient, "frontend", 2, "1")
+	cappedDeployment(t, fakeClient, "backend", 3, "0")
+
+	cfg := r.loadConfig(ctx, cm)
+	plan := r.planRestart(ctx, cfg, "default", r.findPodsUsingConfigMap(ctx, cm, cfg))
+	var planned [][]string
+	for _, batch := range plan.batches {
+		planned = append(planned, podNames(batch))
+	}
+
+	reconcileRestart(t, r, req)
+	ops := listOperations(t, fakeClient)
+	if len(ops) != 1 || ops[0].Status.Phase != autoapplyv1alpha1.RestartOperationSucceeded {
+		t.Fatalf("Expected one succeeded RestartOperation, got %+v", ops)
+	}
+	var restarted [][]string
+	for _, batch := range ops[0].Status.Batches {
+		restarted = append(restarted, batch.Pods)
+	}
+
+	expected := [][]string{{"backend-0"}, {"backend-1"}, {"backend-2"}, {"frontend-0"}, {"frontend-1"}}
+	if !reflect.DeepEqual(planned, expected) {
+		t.Errorf("Expected dry-run batches %v, got %v", expected, planned)
+	}
+	if !reflect.DeepEqual(restarted, planned) {
+		t.Errorf("Expected the restart to use the dry-run batches %v, got %v", planned, restarted)
+	}
+}
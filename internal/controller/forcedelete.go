@@ -0,0 +1,52 @@
+package controller
+
+import (
+	"context"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// forceDeleteIfStuck force-deletes a pod still terminating longer than
+// ForceDeleteTerminatingAfter past its deletion deadline, as pods on an
+// unreachable node do, so restarts don't wait on it forever. It reports
+// whether the pod was force-deleted.
+func (r *ConfigMapReconciler) forceDeleteIfStuck(ctx context.Context, pod *corev1.Pod) bool {
+	after := r.ForceDeleteTerminatingAfter
+	if after <= 0 || pod.DeletionTimestamp == nil || time.Since(pod.DeletionTimestamp.Time) < after {
+		return false
+	}
+
+	logger := log.FromContext(ctx).WithValues("pod", pod.Name)
+	if err := r.Delete(ctx, pod, client.GracePeriodSeconds(0), client.Preconditions{UID: &pod.UID}); client.IgnoreNotFound(err) != nil {
+		logger.Error(err, "Failed to force-delete pod stuck terminating")
+		return false
+	}
+	logger.Info("Force-deleted pod stuck terminating", "since", pod.DeletionTimestamp.Time)
+	r.Recorder.Eventf(pod, corev1.EventTypeWarning, "ForceDeleted",
+		"Force-deleted after terminating for %s past its grace period", time.Since(pod.DeletionTimestamp.Time).Round(time.Second))
+	return true
+}
+
+// forceDeleteStuckPods force-deletes those of the restarted pods that are
+// still stuck terminating. Replacements of the same name are left alone.
+func (r *ConfigMapReconciler) forceDeleteStuckPods(ctx context.Context, pods []corev1.Pod) {
+	if r.ForceDeleteTerminatingAfter <= 0 {
+		return
+	}
+	for _, pod := range pods {
+		var current corev1.Pod
+		if err := r.Get(ctx, client.ObjectKeyFromObject(&pod), &current); err != nil {
+			if !apierrors.IsNotFound(err) {
+				log.FromContext(ctx).V(1).Info("Error getting restarted pod", "pod", pod.Name, "error", err)
+			}
+			continue
+		}
+		if current.UID == pod.UID {
+			r.forceDeleteIfStuck(ctx, &current)
+		}
+	}
+}
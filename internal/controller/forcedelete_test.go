@@ -0,0 +1,100 @@
+package controller
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+)
+
+func TestForceDeleteIfStuck(t *testing.T) {
+	r, fakeClient := setupTestReconciler()
+	ctx := context.Background()
+	recorder := r.Recorder.(*record.FakeRecorder)
+
+	pod := podUsingConfigMap("zombie", "test-config", time.Now().Add(-time.Hour))
+	pod.Finalizers = []string{"example.com/hold"}
+	_ = fakeClient.Create(ctx, pod)
+	_ = fakeClient.Delete(ctx, pod)
+	_ = fakeClient.Get(ctx, client.ObjectKeyFromObject(pod), pod)
+
+	if r.forceDeleteIfStuck(ctx, pod) {
+		t.Error("Expected no force deletion when disabled")
+	}
+
+	r.ForceDeleteTerminatingAfter = time.Minute
+	if r.forceDeleteIfStuck(ctx, pod) {
+		t.Error("Expected no force deletion before the threshold")
+	}
+
+	pod.DeletionTimestamp = &metav1.Time{Time: time.Now().Add(-2 * time.Minute)}
+	if !r.forceDeleteIfStuck(ctx, pod) {
+		t.Fatal("Expected the pod stuck terminating to be force-deleted")
+	}
+	if event := <-recorder.Events; !strings.HasPrefix(event, "Warning ForceDeleted Force-deleted after terminating for 2m") {
+		t.Errorf("Unexpected event %q", event)
+	}
+}
+
+func TestPodsHealthy_ForceDeletesStuckStatefulSetPod(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+
+	statefulSet := &appsv1.StatefulSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "default", UID: "db-uid"},
+		Spec:       appsv1.StatefulSetSpec{Replicas: ptr.To(int32(1))},
+	}
+	// The finalizer stands in for the kubelet of an unreachable node, which
+	// never confirms the pod stopped; force-deleting removes the pod and lets
+	// the StatefulSet roll out
+	fakeClient := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(statefulSet).
+		WithStatusSubresource(&appsv1.StatefulSet{}).
+		WithInterceptorFuncs(interceptor.Funcs{
+			Delete: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.DeleteOption) error {
+				deleteOpts := (&client.DeleteOptions{}).ApplyOptions(opts)
+				if deleteOpts.GracePeriodSeconds == nil || *deleteOpts.GracePeriodSeconds != 0 {
+					return c.Delete(ctx, obj, opts...)
+				}
+				obj.SetFinalizers(nil)
+				if err := c.Update(ctx, obj); err != nil {
+					return err
+				}
+				statefulSet.Status = appsv1.StatefulSetStatus{Replicas: 1, ReadyReplicas: 1, AvailableReplicas: 1}
+				return c.Status().Update(ctx, statefulSet)
+			},
+		}).
+		Build()
+	r := &ConfigMapReconciler{Client: fakeClient, Scheme: scheme, Recorder: record.NewFakeRecorder(10), ForceDeleteTerminatingAfter: time.Millisecond}
+	ctx := context.Background()
+
+	ownerRefs := []metav1.OwnerReference{{APIVersion: "apps/v1", Kind: "StatefulSet", Name: "db", UID: "db-uid", Controller: ptr.To(true)}}
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+		Name: "db-0", Namespace: "default", UID: "old-uid", OwnerReferences: ownerRefs, Finalizers: []string{"example.com/hold"},
+	}}
+	_ = fakeClient.Create(ctx, pod)
+	_ = fakeClient.Delete(ctx, pod)
+	time.Sleep(2 * time.Millisecond)
+
+	// The rollout can't complete while the pod is stuck, so it's only healthy
+	// if the pod is force-deleted before the rollout status is checked
+	if healthy, err := r.podsHealthy(ctx, []corev1.Pod{*pod}, nil); !healthy || err != nil {
+		t.Fatalf("Expected the stuck pod force-deleted and the StatefulSet healthy, got %v %v", healthy, err)
+	}
+	if err := fakeClient.Get(ctx, client.ObjectKeyFromObject(pod), &corev1.Pod{}); !apierrors.IsNotFound(err) {
+		t.Errorf("Expected the stuck pod gone, got %v", err)
+	}
+}
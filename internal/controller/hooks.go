@@ -0,0 +1,178 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// RestartHook lets code embedding the operator run custom logic around
+// restarts. Register hooks via ConfigMapReconciler.Hooks before the manager
+// starts. Hooks for different owners may run concurrently.
+type RestartHook interface {
+	// BeforeBatch runs before a batch of pods is restarted. An error aborts
+	// the batch and the rest of its owner's restart.
+	BeforeBatch(ctx context.Context, configMap *corev1.ConfigMap, pods []corev1.Pod) error
+	// AfterBatch runs with the pods a batch restarted. An error aborts the
+	// rest of the owner's restart.
+	AfterBatch(ctx context.Context, configMap *corev1.ConfigMap, restarted []corev1.Pod) error
+	// OnSkip runs for each pod using the ConfigMap that won't be restarted
+	OnSkip(ctx context.Context, configMap *corev1.ConfigMap, pod *corev1.Pod, reason string)
+	// OnComplete runs once a restart operation has finished
+	OnComplete(ctx context.Context, configMap *corev1.ConfigMap, err error)
+}
+
+// NoopRestartHook implements RestartHook with no-ops, for embedding in hooks
+// that only need some of the methods
+type NoopRestartHook struct{}
+
+func (NoopRestartHook) BeforeBatch(context.Context, *corev1.ConfigMap, []corev1.Pod) error {
+	return nil
+}
+
+func (NoopRestartHook) AfterBatch(context.Context, *corev1.ConfigMap, []corev1.Pod) error {
+	return nil
+}
+
+func (NoopRestartHook) OnSkip(context.Context, *corev1.ConfigMap, *corev1.Pod, string) {}
+
+func (NoopRestartHook) OnComplete(context.Context, *corev1.ConfigMap, error) {}
+
+// WebhookNotifier posts a JSON summary to URL when a restart operation completes
+type WebhookNotifier struct {
+	NoopRestartHook
+	URL    string
+	Client *http.Client
+}
+
+// webhookNotification is the body WebhookNotifier sends
+type webhookNotification struct {
+	Namespace string `json:"namespace"`
+	ConfigMap string `json:"configMap"`
+	Succeeded bool   `json:"succeeded"`
+	Error     string `json:"error,omitempty"`
+}
+
+func (n *WebhookNotifier) OnComplete(ctx context.Context, configMap *corev1.ConfigMap, err error) {
+	logger := log.FromContext(ctx)
+
+	notification := webhookNotification{
+		Namespace: configMap.Namespace,
+		ConfigMap: configMap.Name,
+		Succeeded: err == nil,
+	}
+	if err != nil {
+		notification.Error = err.Error()
+	}
+
+	body, err := json.Marshal(notification)
+	if err != nil {
+		logger.Error(err, "Failed to encode restart notification")
+		return
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.URL, bytes.NewReader(body))
+	if err != nil {
+		logger.Error(err, "Failed to build restart notification")
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := httpClient(n.Client).Do(req)
+	if err != nil {
+		logger.Error(err, "Failed to send restart notification", "url", n.URL)
+		return
+	}
+	_ = resp.Body.Close()
+}
+
+// VerificationProbe GETs URL after every batch and aborts the restart unless
+// it answers with a 2xx status
+type VerificationProbe struct {
+	NoopRestartHook
+	URL    string
+	Client *http.Client
+}
+
+func (p *VerificationProbe) AfterBatch(ctx context.Context, _ *corev1.ConfigMap, restarted []corev1.Pod) error {
+	if len(restarted) == 0 {
+		return nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.URL, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := httpClient(p.Client).Do(req)
+	if err != nil {
+		return fmt.Errorf("verification probe failed: %w", err)
+	}
+	_ = resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("verification probe returned %s", resp.Status)
+	}
+	return nil
+}
+
+// httpClient returns c, or http.DefaultClient if c is nil
+func httpClient(c *http.Client) *http.Client {
+	if c == nil {
+		return http.DefaultClient
+	}
+	return c
+}
+
+// beforeBatch asks the restart policy about a batch, then runs every hook's
+// BeforeBatch with the pods it let through, stopping at the first error
+func (r *ConfigMapReconciler) beforeBatch(ctx context.Context, cfg operatorConfig, configMap *corev1.ConfigMap, pods []corev1.Pod) ([]corev1.Pod, error) {
+	pods, err := r.applyRestartPolicy(ctx, cfg, configMap, pods)
+	if err != nil {
+		return nil, err
+	}
+	for _, hook := range r.Hooks {
+		if err := hook.BeforeBatch(ctx, configMap, pods); err != nil {
+			return nil, fmt.Errorf("before batch hook: %w", err)
+		}
+	}
+	return pods, nil
+}
+
+// afterBatch runs every hook's AfterBatch, stopping at the first error
+func (r *ConfigMapReconciler) afterBatch(ctx context.Context, configMap *corev1.ConfigMap, restarted []corev1.Pod) error {
+	r.recordBatch(ctx, configMap, restarted)
+	for _, hook := range r.Hooks {
+		if err := hook.AfterBatch(ctx, configMap, restarted); err != nil {
+			return fmt.Errorf("after batch hook: %w", err)
+		}
+	}
+	return nil
+}
+
+// skipPod tells every hook that a pod won't be restarted
+func (r *ConfigMapReconciler) skipPod(ctx context.Context, configMap *corev1.ConfigMap, pod *corev1.Pod, reason string) {
+	r.recordSkip(ctx, configMap, pod, reason)
+	for _, hook := range r.Hooks {
+		hook.OnSkip(ctx, configMap, pod, reason)
+	}
+}
+
+// completeRestart tells every hook that a restart operation finished
+func (r *ConfigMapReconciler) completeRestart(ctx context.Context, configMap *corev1.ConfigMap, err error) {
+	// Pods a PDB blocked get the change once a retry restarts them
+	var propagation time.Duration
+	if err == nil && !r.blockedPodsPending(configMap) {
+		propagation = r.configPropagated(configMap)
+	}
+	r.finishOperation(ctx, configMap, err, propagation)
+	for _, hook := range r.Hooks {
+		hook.OnComplete(ctx, configMap, err)
+	}
+}
@@ -0,0 +1,159 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// recordingHook records the hook calls it receives
+type recordingHook struct {
+	mu          sync.Mutex
+	calls       []string
+	beforeError error
+}
+
+func (h *recordingHook) record(call string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.calls = append(h.calls, call)
+}
+
+func (h *recordingHook) BeforeBatch(_ context.Context, _ *corev1.ConfigMap, _ []corev1.Pod) error {
+	h.record("before")
+	return h.beforeError
+}
+
+func (h *recordingHook) AfterBatch(_ context.Context, _ *corev1.ConfigMap, _ []corev1.Pod) error {
+	h.record("after")
+	return nil
+}
+
+func (h *recordingHook) OnSkip(_ context.Context, _ *corev1.ConfigMap, pod *corev1.Pod, _ string) {
+	h.record("skip " + pod.Name)
+}
+
+func (h *recordingHook) OnComplete(_ context.Context, _ *corev1.ConfigMap, err error) {
+	if err != nil {
+		h.record("complete with error")
+		return
+	}
+	h.record("complete")
+}
+
+func TestRestart_Hooks(t *testing.T) {
+	r, fakeClient := setupTestReconciler()
+	ctx := context.Background()
+
+	cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "test-config", Namespace: "default"}}
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "test-pod", Namespace: "default"}}
+	_ = fakeClient.Create(ctx, pod)
+
+	hook := &recordingHook{}
+	r.Hooks = []RestartHook{hook}
+
+	if err := runRestart(ctx, r, r.loadConfig(ctx, nil), cm, "v2", []corev1.Pod{*pod}); err != nil {
+		t.Fatalf("Restart failed: %v", err)
+	}
+	if len(hook.calls) != 2 || hook.calls[0] != "before" || hook.calls[1] != "after" {
+		t.Errorf("Expected before and after calls, got %v", hook.calls)
+	}
+}
+
+func TestRestart_HookAbortsBatch(t *testing.T) {
+	r, fakeClient := setupTestReconciler()
+	ctx := context.Background()
+
+	cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "test-config", Namespace: "default"}}
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "test-pod", Namespace: "default"}}
+	_ = fakeClient.Create(ctx, pod)
+
+	r.Hooks = []RestartHook{&recordingHook{beforeError: errors.New("not now")}}
+
+	if err := runRestart(ctx, r, r.loadConfig(ctx, nil), cm, "v2", []corev1.Pod{*pod}); err == nil {
+		t.Error("Expected the hook error to abort the batch")
+	}
+
+	var pods corev1.PodList
+	_ = fakeClient.List(ctx, &pods, client.InNamespace("default"))
+	if len(pods.Items) != 1 {
+		t.Error("Expected the pod to be kept")
+	}
+}
+
+func TestFindPodsUsingConfigMap_SkipHook(t *testing.T) {
+	r, fakeClient := setupTestReconciler()
+	ctx := context.Background()
+
+	cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "test-config", Namespace: "default"}}
+	for _, pod := range []*corev1.Pod{
+		{ObjectMeta: metav1.ObjectMeta{Name: "opted-out", Namespace: "default", Annotations: map[string]string{excludeAnnotation: "true"}}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "unrelated", Namespace: "default"}},
+	} {
+		if pod.Name == "opted-out" {
+			pod.Spec.Volumes = []corev1.Volume{{
+				Name: "config",
+				VolumeSource: corev1.VolumeSource{
+					ConfigMap: &corev1.ConfigMapVolumeSource{
+						LocalObjectReference: corev1.LocalObjectReference{Name: "test-config"},
+					},
+				},
+			}}
+		}
+		_ = fakeClient.Create(ctx, pod)
+	}
+
+	hook := &recordingHook{}
+	r.Hooks = []RestartHook{hook}
+
+	if pods := r.findPodsUsingConfigMap(ctx, cm, operatorConfig{}); len(pods) != 0 {
+		t.Errorf("Expected no pods to restart, got %d", len(pods))
+	}
+	if len(hook.calls) != 1 || hook.calls[0] != "skip opted-out" {
+		t.Errorf("Expected only the opted-out pod to be reported, got %v", hook.calls)
+	}
+}
+
+func TestWebhookNotifier(t *testing.T) {
+	var received webhookNotification
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		_ = json.NewDecoder(req.Body).Decode(&received)
+	}))
+	defer server.Close()
+
+	cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "test-config", Namespace: "default"}}
+	notifier := &WebhookNotifier{URL: server.URL}
+	notifier.OnComplete(context.Background(), cm, errors.New("boom"))
+
+	if received.ConfigMap != "test-config" || received.Succeeded || received.Error != "boom" {
+		t.Errorf("Unexpected notification: %+v", received)
+	}
+}
+
+func TestVerificationProbe(t *testing.T) {
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	probe := &VerificationProbe{URL: server.URL}
+	restarted := []corev1.Pod{{ObjectMeta: metav1.ObjectMeta{Name: "test-pod"}}}
+
+	if err := probe.AfterBatch(context.Background(), nil, restarted); err != nil {
+		t.Errorf("Expected healthy probe to pass, got %v", err)
+	}
+
+	status = http.StatusServiceUnavailable
+	if err := probe.AfterBatch(context.Background(), nil, restarted); err == nil {
+		t.Error("Expected failing probe to abort")
+	}
+}
@@ -0,0 +1,115 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// ImpactWebhookPath is where the ConfigMap impact webhook is served
+const ImpactWebhookPath = "/warn-configmap-impact"
+
+// ConfigMapImpactWarner is a validating admission webhook for ConfigMap
+// updates. It never rejects an edit, but returns admission warnings, which
+// kubectl prints, when the edit will restart pods or protected workloads.
+type ConfigMapImpactWarner struct {
+	Reconciler *ConfigMapReconciler
+	Decoder    admission.Decoder
+
+	// WarnPods is how many pods an edit must restart to be warned about;
+	// protected workloads are always warned about
+	WarnPods int
+}
+
+func (w *ConfigMapImpactWarner) Handle(ctx context.Context, req admission.Request) admission.Response {
+	if req.Operation != admissionv1.Update {
+		return admission.Allowed("")
+	}
+
+	// The webhook only warns, so anything it can't read is let through
+	var configMap, old corev1.ConfigMap
+	if err := w.Decoder.Decode(req, &configMap); err != nil {
+		return admission.Allowed("")
+	}
+	if err := w.Decoder.DecodeRaw(req.OldObject, &old); err != nil {
+		return admission.Allowed("")
+	}
+
+	return admission.Allowed("").WithWarnings(w.warnings(ctx, &old, &configMap)...)
+}
+
+// warnings describes the restarts an edit of the ConfigMap will trigger
+func (w *ConfigMapImpactWarner) warnings(ctx context.Context, old, configMap *corev1.ConfigMap) []string {
+	r := w.Reconciler
+	cfg := r.loadConfig(ctx, configMap)
+	if cfg.dryRun || cfg.notifyOnly || cfg.isNamespaceExcluded(configMap.Namespace) || cfg.isConfigMapExcluded(configMap.Namespace, configMap.Name) {
+		return nil
+	}
+	detector := r.changeDetector(cfg)
+	if detector.Version(old) == detector.Version(configMap) {
+		return nil
+	}
+
+	pods, protected := r.restartImpact(ctx, configMap, cfg)
+
+	var warnings []string
+	if len(pods) > 0 && len(pods) >= w.WarnPods {
+		how := fmt.Sprintf("with the %s strategy", cfg.strategy)
+		if cfg.yoloMode {
+			how = "all at once"
+		}
+		warnings = append(warnings, fmt.Sprintf("this change to ConfigMap %s will restart %d pods %s", configMap.Name, len(pods), how))
+	}
+	for _, workload := range protected {
+		warnings = append(warnings, fmt.Sprintf("this change to ConfigMap %s will restart pods of protected %s", configMap.Name, workload))
+	}
+	return warnings
+}
+
+// restartImpact returns the pods a change to the ConfigMap would restart and
+// the protected workloads among their owners. Unlike findPodsUsingConfigMap
+// it has no side effects, since the change may still be rejected.
+func (r *ConfigMapReconciler) restartImpact(ctx context.Context, configMap *corev1.ConfigMap, cfg operatorConfig) ([]corev1.Pod, []string) {
+	var pods corev1.PodList
+	if err := r.List(ctx, &pods, client.InNamespace(configMap.Namespace),
+		client.MatchingFields{podConfigMapIndex: configMap.Name}); err != nil {
+		log.FromContext(ctx).Error(err, "Failed to list pods")
+		return nil, nil
+	}
+
+	var restarted []corev1.Pod
+	protected := make(map[string]bool)
+	annotationCache := make(workloadAnnotationCache)
+	rolloutCache := make(workloadRolloutCache)
+	for _, pod := range pods.Items {
+		if reason, ok := r.podSkipReason(ctx, configMap, &pod, cfg, annotationCache); !ok || reason != "" {
+			continue
+		}
+		if cfg.recentRolloutWindow > 0 && r.rolledOutRecently(ctx, &pod, cfg.recentRolloutWindow, rolloutCache) {
+			continue
+		}
+		restarted = append(restarted, pod)
+
+		if r.resolvePodAnnotations(ctx, &pod, annotationCache)[protectedAnnotation] != "true" {
+			continue
+		}
+		name := "Pod " + pod.Name
+		if workload, err := r.resolveWorkload(ctx, &pod); err == nil && workload != nil {
+			name = workload.Kind + " " + workload.Name
+		}
+		protected[name] = true
+	}
+
+	names := make([]string, 0, len(protected))
+	for name := range protected {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return restarted, names
+}
@@ -0,0 +1,87 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+func configMapUpdate(t *testing.T, old, configMap *corev1.ConfigMap) admission.Request {
+	t.Helper()
+	oldRaw, err := json.Marshal(old)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, err := json.Marshal(configMap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+		Operation: admissionv1.Update,
+		Object:    runtime.RawExtension{Raw: raw},
+		OldObject: runtime.RawExtension{Raw: oldRaw},
+	}}
+}
+
+func TestConfigMapImpactWarner(t *testing.T) {
+	r, fakeClient := setupTestReconciler()
+	ctx := context.Background()
+	warner := &ConfigMapImpactWarner{Reconciler: r, Decoder: admission.NewDecoder(r.Scheme), WarnPods: 1}
+
+	_ = fakeClient.Create(ctx, podUsingConfigMap("web", "test-config", time.Now()))
+	protected := podUsingConfigMap("payments", "test-config", time.Now())
+	protected.Annotations = map[string]string{protectedAnnotation: "true"}
+	_ = fakeClient.Create(ctx, protected)
+
+	old := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-config", Namespace: "default"},
+		Data:       map[string]string{"key": "old"},
+	}
+	changed := old.DeepCopy()
+	changed.Data["key"] = "new"
+
+	resp := warner.Handle(ctx, configMapUpdate(t, old, changed))
+	if !resp.Allowed {
+		t.Fatal("Expected the edit to be allowed")
+	}
+	expected := []string{
+		"this change to ConfigMap test-config will restart 2 pods with the Rolling strategy",
+		"this change to ConfigMap test-config will restart pods of protected Pod payments",
+	}
+	if !reflect.DeepEqual(resp.Warnings, expected) {
+		t.Errorf("Expected warnings %v, got %v", expected, resp.Warnings)
+	}
+
+	// Only metadata changed
+	relabeled := old.DeepCopy()
+	relabeled.Labels = map[string]string{"team": "payments"}
+	if resp := warner.Handle(ctx, configMapUpdate(t, old, relabeled)); !resp.Allowed || len(resp.Warnings) != 0 {
+		t.Errorf("Expected no warnings for a metadata change, got %v", resp.Warnings)
+	}
+
+	// Below the threshold only protected workloads are warned about
+	warner.WarnPods = 3
+	resp = warner.Handle(ctx, configMapUpdate(t, old, changed))
+	if !reflect.DeepEqual(resp.Warnings, expected[1:]) {
+		t.Errorf("Expected warnings %v, got %v", expected[1:], resp.Warnings)
+	}
+
+	// Warning has no side effects
+	var pods corev1.PodList
+	_ = fakeClient.List(ctx, &pods)
+	if len(pods.Items) != 2 {
+		t.Errorf("Expected no pods to be restarted, got %d left", len(pods.Items))
+	}
+	if events := r.Recorder.(*record.FakeRecorder).Events; len(events) != 0 {
+		t.Errorf("Expected no events, got %d", len(events))
+	}
+}
@@ -0,0 +1,169 @@
+package controller
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	autoapplyv1alpha1 "github.com/manos/k8s-autoapply-operator/api/v1alpha1"
+)
+
+const (
+	// How many upcoming maintenance window periods a config's status lists
+	statusUpcomingWindows = 3
+	// How many window openings restartWindowOpen tries for cluster and
+	// namespace windows open at the same time
+	maxWindowOverlapSearches = 1000
+)
+
+// maintenanceWindow is a parsed AutoApplyConfig maintenance window
+type maintenanceWindow struct {
+	recurringWindow
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// parseMaintenanceWindow validates and parses a window from the API. A
+// start, end and days window is turned into the equivalent cron schedule.
+func parseMaintenanceWindow(w autoapplyv1alpha1.MaintenanceWindow) (maintenanceWindow, error) {
+	var mw maintenanceWindow
+	var err error
+
+	mw.location = time.UTC
+	if w.TimeZone != "" {
+		if mw.location, err = time.LoadLocation(w.TimeZone); err != nil {
+			return mw, fmt.Errorf("invalid timeZone: %w", err)
+		}
+	}
+	if mw.exceptions, err = parseExceptionDates(w.Exceptions); err != nil {
+		return mw, err
+	}
+
+	if w.Schedule != "" {
+		if w.Start != "" || w.End != "" || len(w.Days) > 0 {
+			return mw, fmt.Errorf("schedule can't be combined with start, end or days")
+		}
+		if mw.opens, err = parseCron(w.Schedule); err != nil {
+			return mw, fmt.Errorf("invalid schedule: %w", err)
+		}
+		if w.Duration == nil || w.Duration.Duration <= 0 {
+			return mw, fmt.Errorf("duration is required with schedule")
+		}
+		mw.length = w.Duration.Duration
+		return mw, nil
+	}
+
+	start, err := parseTimeOfDay(w.Start)
+	if err != nil {
+		return mw, fmt.Errorf("invalid start: %w", err)
+	}
+	end, err := parseTimeOfDay(w.End)
+	if err != nil {
+		return mw, fmt.Errorf("invalid end: %w", err)
+	}
+
+	days := "*"
+	if len(w.Days) > 0 {
+		numbers := make([]string, 0, len(w.Days))
+		for _, day := range w.Days {
+			weekday, ok := weekdays[strings.ToLower(day)]
+			if !ok {
+				return mw, fmt.Errorf("invalid day %q", day)
+			}
+			numbers = append(numbers, fmt.Sprint(int(weekday)))
+		}
+		days = strings.Join(numbers, ",")
+	}
+	if mw.opens, err = parseCron(fmt.Sprintf("%d %d * * %s", start%60, start/60, days)); err != nil {
+		return mw, err
+	}
+
+	// An end at or before the start crosses midnight, an equal one means all day
+	minutes := end - start
+	if minutes <= 0 {
+		minutes += 24 * 60
+	}
+	mw.length = time.Duration(minutes) * time.Minute
+	return mw, nil
+}
+
+// parseTimeOfDay parses HH:MM into minutes after midnight
+func parseTimeOfDay(value string) (int, error) {
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, err
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// maintenanceWindowOpen checks if restarts may run now. With no windows
+// configured restarts are always allowed; otherwise it returns when the
+// earliest window opens next.
+func maintenanceWindowOpen(windows []maintenanceWindow, now time.Time) (bool, time.Time) {
+	if len(windows) == 0 {
+		return true, now
+	}
+
+	var next time.Time
+	for _, w := range windows {
+		if w.isOpen(now) {
+			return true, now
+		}
+		if opens := w.nextOpen(now); !opens.IsZero() && (next.IsZero() || opens.Before(next)) {
+			next = opens
+		}
+	}
+	return false, next
+}
+
+// restartWindowOpen checks if restarts may run now, with a window of the
+// cluster configs and one of the namespace configs open. Otherwise it returns
+// when both are open next, zero if they don't overlap within
+// maxWindowOverlapSearches openings.
+func (c *operatorConfig) restartWindowOpen(now time.Time) (bool, time.Time) {
+	at := now
+	for range maxWindowOverlapSearches {
+		open, next := maintenanceWindowOpen(c.maintenanceWindows, at)
+		if open {
+			open, next = maintenanceWindowOpen(c.namespaceMaintenanceWindows, at)
+		}
+		if open {
+			return at.Equal(now), at
+		}
+		if next.IsZero() {
+			break
+		}
+		at = next
+	}
+	return false, time.Time{}
+}
+
+// upcomingMaintenanceWindows lists the next periods of a spec's valid
+// maintenance windows for its status
+func upcomingMaintenanceWindows(spec *autoapplyv1alpha1.AutoApplyConfigSpec, now time.Time) []autoapplyv1alpha1.WindowPeriod {
+	var windows []recurringWindow
+	for _, window := range spec.MaintenanceWindows {
+		if mw, err := parseMaintenanceWindow(window); err == nil {
+			windows = append(windows, mw.recurringWindow)
+		}
+	}
+
+	var periods []autoapplyv1alpha1.WindowPeriod
+	for _, period := range upcomingWindows(windows, now, statusUpcomingWindows) {
+		periods = append(periods, autoapplyv1alpha1.WindowPeriod{
+			Opens:  metav1.NewTime(period.opens),
+			Closes: metav1.NewTime(period.closes),
+		})
+	}
+	return periods
+}
@@ -0,0 +1,163 @@
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	autoapplyv1alpha1 "github.com/manos/k8s-autoapply-operator/api/v1alpha1"
+)
+
+func mustParseWindow(t *testing.T, w autoapplyv1alpha1.MaintenanceWindow) maintenanceWindow {
+	t.Helper()
+	mw, err := parseMaintenanceWindow(w)
+	if err != nil {
+		t.Fatalf("parseMaintenanceWindow() failed: %v", err)
+	}
+	return mw
+}
+
+func TestParseMaintenanceWindow_Invalid(t *testing.T) {
+	tests := []struct {
+		name   string
+		window autoapplyv1alpha1.MaintenanceWindow
+	}{
+		{"bad start", autoapplyv1alpha1.MaintenanceWindow{Start: "25:00", End: "03:00"}},
+		{"bad end", autoapplyv1alpha1.MaintenanceWindow{Start: "01:00", End: "3am"}},
+		{"bad day", autoapplyv1alpha1.MaintenanceWindow{Start: "01:00", End: "03:00", Days: []string{"Someday"}}},
+		{"bad time zone", autoapplyv1alpha1.MaintenanceWindow{Start: "01:00", End: "03:00", TimeZone: "Mars/Olympus"}},
+		{"schedule without duration", autoapplyv1alpha1.MaintenanceWindow{Schedule: "0 2 * * *"}},
+		{"schedule with start", autoapplyv1alpha1.MaintenanceWindow{Schedule: "0 2 * * *", Start: "02:00", Duration: &metav1.Duration{Duration: time.Hour}}},
+		{"bad schedule", autoapplyv1alpha1.MaintenanceWindow{Schedule: "0 25 * * *", Duration: &metav1.Duration{Duration: time.Hour}}},
+		{"bad exception", autoapplyv1alpha1.MaintenanceWindow{Start: "01:00", End: "03:00", Exceptions: []string{"Christmas"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := parseMaintenanceWindow(tt.window); err == nil {
+				t.Error("Expected an error")
+			}
+		})
+	}
+}
+
+func TestMaintenanceWindowIsOpen(t *testing.T) {
+	// 2024-01-01 is a Monday
+	monday := func(hour, minute int) time.Time {
+		return time.Date(2024, 1, 1, hour, minute, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		name     string
+		window   autoapplyv1alpha1.MaintenanceWindow
+		now      time.Time
+		expected bool
+	}{
+		{"inside window", autoapplyv1alpha1.MaintenanceWindow{Start: "02:00", End: "04:00"}, monday(3, 0), true},
+		{"before window", autoapplyv1alpha1.MaintenanceWindow{Start: "02:00", End: "04:00"}, monday(1, 59), false},
+		{"end is exclusive", autoapplyv1alpha1.MaintenanceWindow{Start: "02:00", End: "04:00"}, monday(4, 0), false},
+		{"crosses midnight, late", autoapplyv1alpha1.MaintenanceWindow{Start: "22:00", End: "02:00"}, monday(23, 0), true},
+		{"crosses midnight, early", autoapplyv1alpha1.MaintenanceWindow{Start: "22:00", End: "02:00"}, monday(1, 0), true},
+		{"allowed day", autoapplyv1alpha1.MaintenanceWindow{Start: "02:00", End: "04:00", Days: []string{"Mon"}}, monday(3, 0), true},
+		{"other day", autoapplyv1alpha1.MaintenanceWindow{Start: "02:00", End: "04:00", Days: []string{"Tue"}}, monday(3, 0), false},
+		{"opened previous day", autoapplyv1alpha1.MaintenanceWindow{Start: "22:00", End: "02:00", Days: []string{"Sun"}}, monday(1, 0), true},
+		{"time zone", autoapplyv1alpha1.MaintenanceWindow{Start: "02:00", End: "04:00", TimeZone: "America/New_York"}, monday(8, 0), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := mustParseWindow(t, tt.window).isOpen(tt.now)
+			if result != tt.expected {
+				t.Errorf("isOpen() = %v, expected %v", result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestMaintenanceWindowOpen(t *testing.T) {
+	// 2024-01-01 is a Monday
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	open, _ := maintenanceWindowOpen(nil, now)
+	if !open {
+		t.Error("Restarts should always be allowed without windows")
+	}
+
+	windows := []maintenanceWindow{
+		mustParseWindow(t, autoapplyv1alpha1.MaintenanceWindow{Start: "02:00", End: "04:00", Days: []string{"Wed"}}),
+		mustParseWindow(t, autoapplyv1alpha1.MaintenanceWindow{Start: "20:00", End: "21:00", Days: []string{"Tue"}}),
+	}
+
+	open, next := maintenanceWindowOpen(windows, now)
+	if open {
+		t.Error("Expected windows to be closed")
+	}
+	expected := time.Date(2024, 1, 2, 20, 0, 0, 0, time.UTC)
+	if !next.Equal(expected) {
+		t.Errorf("Next window = %v, expected %v", next, expected)
+	}
+}
+
+func TestReconcile_OutsideMaintenanceWindow(t *testing.T) {
+	r, fakeClient := setupTestReconciler()
+	ctx := context.Background()
+
+	req := ctrl.Request{
+		NamespacedName: types.NamespacedName{Name: "test-config", Namespace: "default"},
+	}
+	r.configMapVersions.Store(req.String(), "old-version")
+
+	// A window that opens in two hours
+	opens := time.Now().UTC().Add(2 * time.Hour)
+	_ = fakeClient.Create(ctx, &autoapplyv1alpha1.AutoApplyConfig{
+		ObjectMeta: metav1.ObjectMeta{Name: "window"},
+		Spec: autoapplyv1alpha1.AutoApplyConfigSpec{
+			MaintenanceWindows: []autoapplyv1alpha1.MaintenanceWindow{{
+				Start: opens.Format("15:04"),
+				End:   opens.Add(time.Hour).Format("15:04"),
+			}},
+		},
+	})
+	_ = fakeClient.Create(ctx, &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-config", Namespace: "default"},
+	})
+	_ = fakeClient.Create(ctx, &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-pod", Namespace: "default"},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{Name: "app", Image: "nginx"}},
+			Volumes: []corev1.Volume{{
+				Name: "config",
+				VolumeSource: corev1.VolumeSource{
+					ConfigMap: &corev1.ConfigMapVolumeSource{
+						LocalObjectReference: corev1.LocalObjectReference{Name: "test-config"},
+					},
+				},
+			}},
+		},
+		Status: corev1.PodStatus{Phase: corev1.PodRunning},
+	})
+
+	result, err := r.Reconcile(ctx, req)
+	if err != nil {
+		t.Fatalf("Reconcile failed: %v", err)
+	}
+
+	if result.RequeueAfter <= time.Hour || result.RequeueAfter > 2*time.Hour {
+		t.Errorf("Expected requeue when the window opens, got %v", result.RequeueAfter)
+	}
+	if _, pending := r.pendingRestarts.Load(req.String()); !pending {
+		t.Error("Expected the restart to be queued")
+	}
+
+	var pods corev1.PodList
+	_ = fakeClient.List(ctx, &pods, client.InNamespace("default"))
+	if len(pods.Items) != 1 {
+		t.Errorf("Expected pod to be kept outside the window, found %d pods", len(pods.Items))
+	}
+}
@@ -0,0 +1,236 @@
+package controller
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+var (
+	trickleRestartedPods = newConfigMapGauge(prometheus.GaugeOpts{
+		Name: "autoapply_trickle_restarted_pods",
+		Help: "Pods restarted so far by an in-progress trickle restart",
+	})
+
+	trickleRemainingPods = newConfigMapGauge(prometheus.GaugeOpts{
+		Name: "autoapply_trickle_remaining_pods",
+		Help: "Pods still running the previous config in an in-progress trickle restart",
+	})
+
+	hotReloadPods = newConfigMapGauge(prometheus.GaugeOpts{
+		Name: "autoapply_hot_reload_pods",
+		Help: "Pods using a ConfigMap that were left to reload its latest change themselves",
+	})
+
+	staleConfigPods = newConfigMapGauge(prometheus.GaugeOpts{
+		Name: "autoapply_stale_config_pods",
+		Help: "Pods still running config from before the last handled change of a ConfigMap",
+	})
+
+	configMapChanges = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "autoapply_configmap_changes_total",
+		Help: "Changes of a ConfigMap's data seen by the operator",
+	}, configMapMetricLabels)
+
+	configPropagationSeconds = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "autoapply_config_propagation_seconds",
+		Help:    "Time from the operator seeing a ConfigMap change until every selected pod was restarted with it",
+		Buckets: []float64{15, 30, 60, 120, 300, 600, 1200, 1800, 3600, 7200},
+	}, configMapMetricLabels)
+)
+
+func init() {
+	metrics.Registry.MustRegister(trickleRestartedPods, trickleRemainingPods, hotReloadPods, staleConfigPods, configMapChanges,
+		configPropagationSeconds)
+}
+
+// Labels of the per-ConfigMap metrics, in order
+var configMapMetricLabels = []string{"namespace", "configmap"}
+
+// otherLabelValue replaces label values past the cardinality cap
+const otherLabelValue = "other"
+
+// ConfigureMetrics sets the labels per-ConfigMap metrics keep, out of
+// namespace and configmap, and caps each label at maxLabelValues distinct
+// values, zero meaning unlimited. Dropped labels are exported empty, which
+// Prometheus treats as absent; values past the cap are aggregated into
+// "other". It must be called before the manager starts.
+func ConfigureMetrics(labels []string, maxLabelValues int) error {
+	keep := make(map[string]bool)
+	for _, label := range labels {
+		label = strings.TrimSpace(label)
+		valid := false
+		for _, known := range configMapMetricLabels {
+			valid = valid || label == known
+		}
+		if !valid {
+			return fmt.Errorf("unknown metrics label %q, expected one of %s", label, strings.Join(configMapMetricLabels, ", "))
+		}
+		keep[label] = true
+	}
+	metricLabels = newMetricLabeler(keep, maxLabelValues)
+	return nil
+}
+
+// metricLabels decides the label values of every per-ConfigMap series
+var metricLabels = newMetricLabeler(map[string]bool{"namespace": true, "configmap": true}, 0)
+
+// metricLabeler maps ConfigMaps to the label values of their series. Values
+// are admitted up to maxValues per label in the order they're first seen.
+type metricLabeler struct {
+	keep      []bool
+	maxValues int
+
+	mu       sync.Mutex
+	admitted []map[string]bool
+}
+
+func newMetricLabeler(keep map[string]bool, maxValues int) *metricLabeler {
+	l := &metricLabeler{maxValues: maxValues}
+	for _, label := range configMapMetricLabels {
+		l.keep = append(l.keep, keep[label])
+		l.admitted = append(l.admitted, make(map[string]bool))
+	}
+	return l
+}
+
+// values returns the label values of the ConfigMap's series. exclusive is
+// set if no other ConfigMap shares it.
+func (l *metricLabeler) values(namespace, name string) (values []string, exclusive bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	exclusive = true
+	for i, value := range []string{namespace, name} {
+		switch {
+		case !l.keep[i]:
+			value = ""
+			exclusive = false
+		case l.admitted[i][value]:
+		case l.maxValues > 0 && len(l.admitted[i]) >= l.maxValues:
+			value = otherLabelValue
+			exclusive = false
+		default:
+			l.admitted[i][value] = true
+		}
+		values = append(values, value)
+	}
+	return values, exclusive
+}
+
+// configMapGauge is a gauge per ConfigMap. ConfigMaps sharing a series,
+// through a dropped or capped label, add up.
+type configMapGauge struct {
+	*prometheus.GaugeVec
+
+	mu sync.Mutex
+	// values holds each ConfigMap's value and the series it's counted in
+	values map[types.NamespacedName]gaugeValue
+	// series holds the total and number of ConfigMaps of each series
+	series map[string]*gaugeSeries
+}
+
+type gaugeValue struct {
+	value  float64
+	series []string
+}
+
+type gaugeSeries struct {
+	total   float64
+	members int
+}
+
+func newConfigMapGauge(opts prometheus.GaugeOpts) *configMapGauge {
+	return &configMapGauge{
+		GaugeVec: prometheus.NewGaugeVec(opts, configMapMetricLabels),
+		values:   make(map[types.NamespacedName]gaugeValue),
+		series:   make(map[string]*gaugeSeries),
+	}
+}
+
+// set sets the ConfigMap's value
+func (g *configMapGauge) set(namespace, name string, value float64) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	key := types.NamespacedName{Namespace: namespace, Name: name}
+	previous, ok := g.values[key]
+	if !ok {
+		previous.series, _ = metricLabels.values(namespace, name)
+	}
+	seriesKey := strings.Join(previous.series, "/")
+	s := g.series[seriesKey]
+	if s == nil {
+		s = &gaugeSeries{}
+		g.series[seriesKey] = s
+	}
+	if !ok {
+		s.members++
+	}
+	s.total += value - previous.value
+	g.values[key] = gaugeValue{value: value, series: previous.series}
+	g.WithLabelValues(previous.series...).Set(s.total)
+}
+
+// delete drops the ConfigMap's value, and its series once no other
+// ConfigMap counts in it
+func (g *configMapGauge) delete(namespace, name string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	key := types.NamespacedName{Namespace: namespace, Name: name}
+	previous, ok := g.values[key]
+	if !ok {
+		return
+	}
+	delete(g.values, key)
+	seriesKey := strings.Join(previous.series, "/")
+	s := g.series[seriesKey]
+	s.members--
+	s.total -= previous.value
+	if s.members == 0 {
+		delete(g.series, seriesKey)
+		g.DeleteLabelValues(previous.series...)
+		return
+	}
+	g.WithLabelValues(previous.series...).Set(s.total)
+}
+
+// reset drops every ConfigMap's value
+func (g *configMapGauge) reset() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.values = make(map[types.NamespacedName]gaugeValue)
+	g.series = make(map[string]*gaugeSeries)
+	g.Reset()
+}
+
+// configMapChanged counts a change of the ConfigMap
+func configMapChanged(namespace, name string) {
+	values, _ := metricLabels.values(namespace, name)
+	configMapChanges.WithLabelValues(values...).Inc()
+}
+
+// observePropagation records how long the ConfigMap's change took to reach
+// its pods
+func observePropagation(namespace, name string, seconds float64) {
+	values, _ := metricLabels.values(namespace, name)
+	configPropagationSeconds.WithLabelValues(values...).Observe(seconds)
+}
+
+// deleteConfigMapMetrics drops the series of a deleted ConfigMap. Counters
+// and histograms other ConfigMaps add to are kept.
+func deleteConfigMapMetrics(namespace, name string) {
+	for _, gauge := range []*configMapGauge{trickleRestartedPods, trickleRemainingPods, hotReloadPods, staleConfigPods} {
+		gauge.delete(namespace, name)
+	}
+	if values, exclusive := metricLabels.values(namespace, name); exclusive {
+		configMapChanges.DeleteLabelValues(values...)
+		configPropagationSeconds.DeleteLabelValues(values...)
+	}
+}
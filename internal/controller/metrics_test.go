@@ -0,0 +1,64 @@
+package controller
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestConfigureMetrics_UnknownLabel(t *testing.T) {
+	if err := ConfigureMetrics([]string{"namespace", "workload"}, 0); err == nil {
+		t.Error("Expected an error for a label no metric has")
+	}
+}
+
+func TestMetricLabeler(t *testing.T) {
+	l := newMetricLabeler(map[string]bool{"namespace": true, "configmap": true}, 2)
+
+	for _, tt := range []struct {
+		namespace, name string
+		expected        []string
+		exclusive       bool
+	}{
+		{"team-a", "app", []string{"team-a", "app"}, true},
+		{"team-b", "db", []string{"team-b", "db"}, true},
+		{"team-c", "app", []string{otherLabelValue, "app"}, false},
+		{"team-a", "cache", []string{"team-a", otherLabelValue}, false},
+		// Values admitted before stay once the cap is reached
+		{"team-b", "app", []string{"team-b", "app"}, true},
+	} {
+		values, exclusive := l.values(tt.namespace, tt.name)
+		if len(values) != 2 || values[0] != tt.expected[0] || values[1] != tt.expected[1] || exclusive != tt.exclusive {
+			t.Errorf("values(%s, %s) = %v, %v, expected %v, %v", tt.namespace, tt.name, values, exclusive, tt.expected, tt.exclusive)
+		}
+	}
+
+	dropped := newMetricLabeler(map[string]bool{"namespace": true}, 0)
+	if values, exclusive := dropped.values("team-a", "app"); values[0] != "team-a" || values[1] != "" || exclusive {
+		t.Errorf("Expected the configmap label dropped, got %v, %v", values, exclusive)
+	}
+}
+
+func TestConfigMapGauge_SharedSeries(t *testing.T) {
+	defer func(labeler *metricLabeler) { metricLabels = labeler }(metricLabels)
+	metricLabels = newMetricLabeler(map[string]bool{"namespace": true}, 0)
+
+	gauge := newConfigMapGauge(prometheus.GaugeOpts{Name: "test_pods", Help: "Test"})
+	gauge.set("team-a", "app", 2)
+	gauge.set("team-a", "db", 3)
+	gauge.set("team-a", "app", 1)
+
+	if total := testutil.ToFloat64(gauge.WithLabelValues("team-a", "")); total != 4 {
+		t.Errorf("Expected the namespace's ConfigMaps to add up to 4, got %v", total)
+	}
+
+	gauge.delete("team-a", "db")
+	if total := testutil.ToFloat64(gauge.WithLabelValues("team-a", "")); total != 1 {
+		t.Errorf("Expected 1 once a ConfigMap is deleted, got %v", total)
+	}
+	gauge.delete("team-a", "app")
+	if series := testutil.CollectAndCount(gauge); series != 0 {
+		t.Errorf("Expected the series dropped with its last ConfigMap, got %d", series)
+	}
+}
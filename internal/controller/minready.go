@@ -0,0 +1,93 @@
+package controller
+
+import (
+	"context"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// ownerMinReady maps the controller UID of each deleted pod to the
+// minReadySeconds of the workload managing it, for owners that set one
+func (r *ConfigMapReconciler) ownerMinReady(ctx context.Context, deletedPods []corev1.Pod) map[types.UID]time.Duration {
+	minReady := make(map[types.UID]time.Duration)
+	checked := make(map[types.UID]bool)
+	for i := range deletedPods {
+		owner := metav1.GetControllerOf(&deletedPods[i])
+		if owner == nil || checked[owner.UID] {
+			continue
+		}
+		checked[owner.UID] = true
+
+		workload, err := r.resolveWorkload(ctx, &deletedPods[i])
+		if err != nil || workload == nil {
+			continue
+		}
+		seconds, err := r.workloadMinReadySeconds(ctx, deletedPods[i].Namespace, workload)
+		if err != nil {
+			log.FromContext(ctx).V(1).Info("Failed to read minReadySeconds", "workload", workload.Kind+"/"+workload.Name, "error", err)
+			continue
+		}
+		if seconds > 0 {
+			minReady[owner.UID] = time.Duration(seconds) * time.Second
+		}
+	}
+	return minReady
+}
+
+// workloadMinReadySeconds returns spec.minReadySeconds of a Deployment,
+// StatefulSet or DaemonSet, 0 for other kinds
+func (r *ConfigMapReconciler) workloadMinReadySeconds(ctx context.Context, namespace string, workload *workloadRef) (int32, error) {
+	key := client.ObjectKey{Namespace: namespace, Name: workload.Name}
+	switch workload.Kind {
+	case "Deployment":
+		var deployment appsv1.Deployment
+		if err := r.Get(ctx, key, &deployment); err != nil {
+			return 0, err
+		}
+		return deployment.Spec.MinReadySeconds, nil
+	case "StatefulSet":
+		var statefulSet appsv1.StatefulSet
+		if err := r.Get(ctx, key, &statefulSet); err != nil {
+			return 0, err
+		}
+		return statefulSet.Spec.MinReadySeconds, nil
+	case "DaemonSet":
+		var daemonSet appsv1.DaemonSet
+		if err := r.Get(ctx, key, &daemonSet); err != nil {
+			return 0, err
+		}
+		return daemonSet.Spec.MinReadySeconds, nil
+	}
+	return 0, nil
+}
+
+// isPodAvailable checks if the pod has been Ready for at least minReady, the
+// way Deployments count available replicas
+func isPodAvailable(pod *corev1.Pod, minReady time.Duration, now time.Time) bool {
+	if !isPodReady(pod) {
+		return false
+	}
+	if minReady <= 0 {
+		return true
+	}
+	for _, cond := range pod.Status.Conditions {
+		if cond.Type == corev1.PodReady {
+			return !cond.LastTransitionTime.IsZero() && !cond.LastTransitionTime.Add(minReady).After(now)
+		}
+	}
+	return false
+}
+
+// controllerUID returns the UID of the pod's controller, empty if it has none
+func controllerUID(pod *corev1.Pod) types.UID {
+	if owner := metav1.GetControllerOf(pod); owner != nil {
+		return owner.UID
+	}
+	return ""
+}
@@ -0,0 +1,54 @@
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func readySince(since time.Time) *corev1.Pod {
+	return &corev1.Pod{Status: corev1.PodStatus{
+		Phase: corev1.PodRunning,
+		Conditions: []corev1.PodCondition{{
+			Type: corev1.PodReady, Status: corev1.ConditionTrue, LastTransitionTime: metav1.NewTime(since),
+		}},
+	}}
+}
+
+func TestIsPodAvailable(t *testing.T) {
+	now := time.Now()
+
+	if !isPodAvailable(readySince(now), 0, now) {
+		t.Error("Expected a Ready pod to be available without minReadySeconds")
+	}
+	if isPodAvailable(readySince(now.Add(-5*time.Second)), 10*time.Second, now) {
+		t.Error("Expected a pod Ready for 5s not to be available with minReadySeconds 10")
+	}
+	if !isPodAvailable(readySince(now.Add(-10*time.Second)), 10*time.Second, now) {
+		t.Error("Expected a pod Ready for 10s to be available with minReadySeconds 10")
+	}
+	if isPodAvailable(&corev1.Pod{Status: corev1.PodStatus{Phase: corev1.PodPending}}, 0, now) {
+		t.Error("Expected a pending pod not to be available")
+	}
+}
+
+func TestOwnerMinReady(t *testing.T) {
+	r, fakeClient := setupTestReconciler()
+	ctx := context.Background()
+
+	ownerRefs := createDeploymentWithReplicaSet(ctx, fakeClient, &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"},
+		Spec:       appsv1.DeploymentSpec{MinReadySeconds: 30},
+	})
+	pod := corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "web-1", Namespace: "default", OwnerReferences: ownerRefs}}
+	standalone := corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "standalone", Namespace: "default"}}
+
+	minReady := r.ownerMinReady(ctx, []corev1.Pod{pod, standalone})
+	if len(minReady) != 1 || minReady[controllerUID(&pod)] != 30*time.Second {
+		t.Errorf("Expected 30s for the Deployment's pods, got %v", minReady)
+	}
+}
@@ -0,0 +1,200 @@
+package controller
+
+import (
+	"context"
+	"regexp"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	autoapplyv1alpha1 "github.com/manos/k8s-autoapply-operator/api/v1alpha1"
+)
+
+// applyNamespaceConfigs overlays the AutoApplyNamespaceConfigs in the
+// ConfigMap's namespace on the cluster-wide config. A field set by any
+// namespace config replaces the cluster value; several namespace configs are
+// merged with each other using the cluster-wide rules. The fields guarding
+// restarts cluster admins rely on may only be tightened.
+func (r *ConfigMapReconciler) applyNamespaceConfigs(ctx context.Context, cfg *operatorConfig, configMap *corev1.ConfigMap) {
+	logger := log.FromContext(ctx)
+
+	var configList autoapplyv1alpha1.AutoApplyNamespaceConfigList
+	if err := r.List(ctx, &configList, client.InNamespace(configMap.Namespace)); err != nil {
+		logger.Error(err, "Failed to list namespace configs", "namespace", configMap.Namespace)
+		return
+	}
+
+	// Tracks which fields a namespace config already overrode
+	overridden := make(map[string]bool)
+
+	for _, item := range configList.Items {
+		spec := &item.Spec
+		if !configAppliesTo(ctx, item.Name, spec, configMap) {
+			continue
+		}
+
+		// Exclusions only ever add up
+		for _, pattern := range spec.ExcludePods {
+			if re, err := regexp.Compile(pattern); err == nil {
+				cfg.excludePodPatterns = append(cfg.excludePodPatterns, re)
+			}
+		}
+		for _, pattern := range spec.ExcludeConfigMaps {
+			if re, err := regexp.Compile(pattern); err == nil {
+				cfg.excludeConfigMapPatterns = append(cfg.excludeConfigMapPatterns, re)
+			}
+		}
+		if spec.DisableDefaultConfigMapExclusions {
+			cfg.disableDefaultConfigMapExclusions = true
+		}
+		mergeSwitch(&cfg.yoloMode, spec.YoloMode, "yoloMode", overridden)
+		if spec.DisableYoloMode {
+			cfg.yoloDisabled = true
+		}
+		// Tenants may add dry runs and approval, never lift them
+		if ptr.Deref(spec.DryRun, false) {
+			cfg.dryRun = true
+		}
+		if ptr.Deref(spec.RequireApproval, false) {
+			cfg.requireApproval = true
+		}
+		mergeSwitch(&cfg.notifyOnly, spec.NotifyOnly, "notifyOnly", overridden)
+		mergeSwitch(&cfg.recreateBarePods, spec.RecreateBarePods, "recreateBarePods", overridden)
+		cfg.mergeExcludeDaemonSets(spec.ExcludeDaemonSets)
+		// Only turning off critical holds restarts back during node disruptions
+		if spec.Critical != nil && !*spec.Critical {
+			cfg.critical = false
+		}
+		mergeSwitch(&cfg.skipOptionalReferences, spec.SkipOptionalReferences, "skipOptionalReferences", overridden)
+		mergeSwitch(&cfg.restartByPriority, spec.RestartByPriority, "restartByPriority", overridden)
+		if p := spec.MaxRestartPriority; p != nil && (cfg.maxRestartPriority == nil || *p < *cfg.maxRestartPriority) {
+			cfg.maxRestartPriority = p
+		}
+		mergeSwitch(&cfg.skipRefreshableMounts, spec.SkipRefreshableMounts, "skipRefreshableMounts", overridden)
+		// Notifications add up too, reading Secrets only from this namespace
+		for _, notification := range spec.Notifications {
+			cfg.namespaceNotifications = append(cfg.namespaceNotifications, withSecretNamespace(notification, item.Namespace, true))
+		}
+		// The Job would run with the operator's permissions to create Jobs
+		// and any service account, so only cluster configs may set one
+		if spec.PreRestartJob != nil {
+			logger.Info("Ignoring preRestartJob of namespace config, only AutoApplyConfigs may set one", "config", client.ObjectKeyFromObject(&item))
+		}
+		for _, probe := range spec.VerificationProbes {
+			// Probes may only reach Services in the config's own namespace
+			if errs := validation.IsDNS1035Label(probe.Service); len(errs) > 0 {
+				logger.Info("Ignoring verification probe of namespace config, not a Service name",
+					"config", client.ObjectKeyFromObject(&item), "service", probe.Service)
+				continue
+			}
+			cfg.verificationProbes = append(cfg.verificationProbes, probe)
+		}
+
+		if spec.Strategy != "" {
+			if !overridden["strategy"] || strategyPriority[spec.Strategy] > strategyPriority[cfg.strategy] {
+				cfg.strategy = spec.Strategy
+			}
+			overridden["strategy"] = true
+		}
+		if d := spec.CanarySoakDuration; d != nil {
+			if !overridden["canarySoakDuration"] || d.Duration > cfg.canarySoakDuration {
+				cfg.canarySoakDuration = d.Duration
+			}
+			overridden["canarySoakDuration"] = true
+		}
+		if w := spec.VPAEvictionWindow; w != nil {
+			if !overridden["vpaEvictionWindow"] || w.Duration > cfg.vpaEvictionWindow {
+				cfg.vpaEvictionWindow = w.Duration
+			}
+			overridden["vpaEvictionWindow"] = true
+		}
+		if n := spec.TrickleBatchSize; n > 0 {
+			if !overridden["trickleBatchSize"] || int(n) < cfg.trickleBatchSize {
+				cfg.trickleBatchSize = int(n)
+			}
+			overridden["trickleBatchSize"] = true
+		}
+		if d := spec.TrickleInterval; d != nil {
+			if !overridden["trickleInterval"] || d.Duration > cfg.trickleInterval {
+				cfg.trickleInterval = d.Duration
+			}
+			overridden["trickleInterval"] = true
+		}
+		if d := spec.DebounceDuration; d != nil {
+			if !overridden["debounceDuration"] || d.Duration > cfg.debounceDuration {
+				cfg.debounceDuration = d.Duration
+			}
+			overridden["debounceDuration"] = true
+		}
+		if w := spec.RecentRolloutWindow; w != nil {
+			if !overridden["recentRolloutWindow"] || w.Duration > cfg.recentRolloutWindow {
+				cfg.recentRolloutWindow = w.Duration
+			}
+			overridden["recentRolloutWindow"] = true
+		}
+		if steps := spec.BatchSteps; len(steps) > 0 {
+			if !overridden["batchSteps"] || len(steps) > len(cfg.batchSteps) {
+				cfg.batchSteps = steps
+			}
+			overridden["batchSteps"] = true
+		}
+		if w := spec.PDBRetryWindow; w != nil {
+			if !overridden["pdbRetryWindow"] || w.Duration > cfg.pdbRetryWindow {
+				cfg.pdbRetryWindow = w.Duration
+			}
+			overridden["pdbRetryWindow"] = true
+		}
+		if w := spec.CoalesceWindow; w != nil {
+			if !overridden["coalesceWindow"] || w.Duration > cfg.coalesceWindow {
+				cfg.coalesceWindow = w.Duration
+			}
+			overridden["coalesceWindow"] = true
+		}
+		if t := spec.ApprovalTimeout; t != nil && t.Duration > 0 {
+			if !overridden["approvalTimeout"] || cfg.approvalTimeout == 0 || t.Duration < cfg.approvalTimeout {
+				cfg.approvalTimeout = t.Duration
+			}
+			overridden["approvalTimeout"] = true
+		}
+		if backup := spec.PodBackup; backup != nil {
+			cfg.mergePodBackup(backup, !overridden["podBackup"])
+			overridden["podBackup"] = true
+		}
+		if t := spec.RestartTimeout; t != nil && t.Duration > 0 {
+			if !overridden["restartTimeout"] || t.Duration < cfg.restartTimeout {
+				cfg.restartTimeout = t.Duration
+			}
+			overridden["restartTimeout"] = true
+		}
+		if detection := spec.ChangeDetection; detection != nil {
+			if !overridden["changeDetection"] {
+				cfg.changeDetection = ""
+				cfg.changeDetectionKeys = nil
+			}
+			cfg.mergeChangeDetection(detection)
+			overridden["changeDetection"] = true
+		}
+		// Namespace windows narrow the cluster ones rather than replace them
+		for _, window := range spec.MaintenanceWindows {
+			if mw, err := parseMaintenanceWindow(window); err == nil {
+				cfg.namespaceMaintenanceWindows = append(cfg.namespaceMaintenanceWindows, mw)
+			}
+		}
+	}
+}
+
+// mergeSwitch overlays a namespace config's switch on value. Once set, it
+// replaces the cluster value even when false; several namespace configs
+// setting it enable it if any does.
+func mergeSwitch(value *bool, set *bool, field string, overridden map[string]bool) {
+	if set == nil {
+		return
+	}
+	if !overridden[field] || *set {
+		*value = *set
+	}
+	overridden[field] = true
+}
@@ -0,0 +1,250 @@
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+
+	autoapplyv1alpha1 "github.com/manos/k8s-autoapply-operator/api/v1alpha1"
+)
+
+func TestLoadConfig_NamespaceConfigOverridesCluster(t *testing.T) {
+	r, fakeClient := setupTestReconciler()
+	ctx := context.Background()
+
+	_ = fakeClient.Create(ctx, &autoapplyv1alpha1.AutoApplyConfig{
+		ObjectMeta: metav1.ObjectMeta{Name: "cluster"},
+		Spec: autoapplyv1alpha1.AutoApplyConfigSpec{
+			Strategy:       autoapplyv1alpha1.RestartStrategyCanary,
+			RestartTimeout: &metav1.Duration{Duration: 10 * time.Minute},
+		},
+	})
+	_ = fakeClient.Create(ctx, &autoapplyv1alpha1.AutoApplyNamespaceConfig{
+		ObjectMeta: metav1.ObjectMeta{Name: "team", Namespace: "team-a"},
+		Spec: autoapplyv1alpha1.AutoApplyConfigSpec{
+			YoloMode:       ptr.To(true),
+			Strategy:       autoapplyv1alpha1.RestartStrategyRolling,
+			RestartTimeout: &metav1.Duration{Duration: time.Hour},
+		},
+	})
+
+	teamA := r.loadConfig(ctx, &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "team-a"}})
+	if !teamA.yoloMode {
+		t.Error("Expected yoloMode from the namespace config")
+	}
+	if teamA.strategy != autoapplyv1alpha1.RestartStrategyRolling {
+		t.Errorf("Expected namespace strategy to override cluster, got %q", teamA.strategy)
+	}
+	if teamA.restartTimeout != time.Hour {
+		t.Errorf("Expected namespace restartTimeout to override cluster, got %v", teamA.restartTimeout)
+	}
+
+	teamB := r.loadConfig(ctx, &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "team-b"}})
+	if teamB.yoloMode {
+		t.Error("Namespace config should not affect other namespaces")
+	}
+	if teamB.strategy != autoapplyv1alpha1.RestartStrategyCanary {
+		t.Errorf("Expected cluster strategy in other namespaces, got %q", teamB.strategy)
+	}
+}
+
+func TestLoadConfig_NamespaceConfigTurnsOffClusterSwitches(t *testing.T) {
+	tests := []struct {
+		name  string
+		set   func(spec *autoapplyv1alpha1.AutoApplyConfigSpec, on bool)
+		value func(cfg operatorConfig) bool
+	}{
+		{"yoloMode", func(s *autoapplyv1alpha1.AutoApplyConfigSpec, on bool) { s.YoloMode = ptr.To(on) },
+			func(c operatorConfig) bool { return c.yoloMode }},
+		{"notifyOnly", func(s *autoapplyv1alpha1.AutoApplyConfigSpec, on bool) { s.NotifyOnly = ptr.To(on) },
+			func(c operatorConfig) bool { return c.notifyOnly }},
+		{"recreateBarePods", func(s *autoapplyv1alpha1.AutoApplyConfigSpec, on bool) { s.RecreateBarePods = ptr.To(on) },
+			func(c operatorConfig) bool { return c.recreateBarePods }},
+		{"critical", func(s *autoapplyv1alpha1.AutoApplyConfigSpec, on bool) { s.Critical = ptr.To(on) },
+			func(c operatorConfig) bool { return c.critical }},
+		{"skipOptionalReferences", func(s *autoapplyv1alpha1.AutoApplyConfigSpec, on bool) { s.SkipOptionalReferences = ptr.To(on) },
+			func(c operatorConfig) bool { return c.skipOptionalReferences }},
+		{"restartByPriority", func(s *autoapplyv1alpha1.AutoApplyConfigSpec, on bool) { s.RestartByPriority = ptr.To(on) },
+			func(c operatorConfig) bool { return c.restartByPriority }},
+		{"skipRefreshableMounts", func(s *autoapplyv1alpha1.AutoApplyConfigSpec, on bool) { s.SkipRefreshableMounts = ptr.To(on) },
+			func(c operatorConfig) bool { return c.skipRefreshableMounts }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, fakeClient := setupTestReconciler()
+			ctx := context.Background()
+
+			cluster := &autoapplyv1alpha1.AutoApplyConfig{ObjectMeta: metav1.ObjectMeta{Name: "cluster"}}
+			tt.set(&cluster.Spec, true)
+			_ = fakeClient.Create(ctx, cluster)
+			namespaced := &autoapplyv1alpha1.AutoApplyNamespaceConfig{ObjectMeta: metav1.ObjectMeta{Name: "team", Namespace: "team-a"}}
+			tt.set(&namespaced.Spec, false)
+			_ = fakeClient.Create(ctx, namespaced)
+
+			if tt.value(r.loadConfig(ctx, &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "team-a"}})) {
+				t.Errorf("Expected the namespace config to turn off %s", tt.name)
+			}
+			if !tt.value(r.loadConfig(ctx, &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "team-b"}})) {
+				t.Errorf("Expected the cluster %s in other namespaces", tt.name)
+			}
+		})
+	}
+}
+
+func TestLoadConfig_NamespaceConfigOnlyTightens(t *testing.T) {
+	tests := []struct {
+		name string
+		set  func(spec *autoapplyv1alpha1.AutoApplyConfigSpec, tight bool)
+		// tight reports whether the field is at its stricter setting
+		tight func(cfg operatorConfig) bool
+	}{
+		{"dryRun", func(s *autoapplyv1alpha1.AutoApplyConfigSpec, tight bool) { s.DryRun = ptr.To(tight) },
+			func(c operatorConfig) bool { return c.dryRun }},
+		{"requireApproval", func(s *autoapplyv1alpha1.AutoApplyConfigSpec, tight bool) { s.RequireApproval = ptr.To(tight) },
+			func(c operatorConfig) bool { return c.requireApproval }},
+		{"critical", func(s *autoapplyv1alpha1.AutoApplyConfigSpec, tight bool) { s.Critical = ptr.To(!tight) },
+			func(c operatorConfig) bool { return !c.critical }},
+		{"maxRestartPriority", func(s *autoapplyv1alpha1.AutoApplyConfigSpec, tight bool) {
+			s.MaxRestartPriority = ptr.To(int32(1000))
+			if !tight {
+				s.MaxRestartPriority = ptr.To(int32(2000000000))
+			}
+		}, func(c operatorConfig) bool { return c.maxRestartPriority != nil && *c.maxRestartPriority == 1000 }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, fakeClient := setupTestReconciler()
+			ctx := context.Background()
+
+			cluster := &autoapplyv1alpha1.AutoApplyConfig{ObjectMeta: metav1.ObjectMeta{Name: "cluster"}}
+			tt.set(&cluster.Spec, true)
+			_ = fakeClient.Create(ctx, cluster)
+			loosening := &autoapplyv1alpha1.AutoApplyNamespaceConfig{ObjectMeta: metav1.ObjectMeta{Name: "team", Namespace: "team-a"}}
+			tt.set(&loosening.Spec, false)
+			_ = fakeClient.Create(ctx, loosening)
+			tightening := &autoapplyv1alpha1.AutoApplyNamespaceConfig{ObjectMeta: metav1.ObjectMeta{Name: "team", Namespace: "team-b"}}
+			tt.set(&tightening.Spec, true)
+			_ = fakeClient.Create(ctx, tightening)
+
+			if !tt.tight(r.loadConfig(ctx, &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "team-a"}})) {
+				t.Errorf("Expected the namespace config not to loosen the cluster %s", tt.name)
+			}
+
+			// Without the cluster setting, a namespace config can tighten it
+			_ = fakeClient.Delete(ctx, cluster)
+			if !tt.tight(r.loadConfig(ctx, &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "team-b"}})) {
+				t.Errorf("Expected the namespace config to tighten %s", tt.name)
+			}
+		})
+	}
+}
+
+func TestReconcile_NamespaceConfigCannotSkipApproval(t *testing.T) {
+	r, fakeClient, _, req := setupApprovalTest(t, 0)
+	ctx := context.Background()
+
+	// The tenant tries to opt its namespace out of cluster-wide approval
+	_ = fakeClient.Create(ctx, &autoapplyv1alpha1.AutoApplyNamespaceConfig{
+		ObjectMeta: metav1.ObjectMeta{Name: "team", Namespace: "default"},
+		Spec:       autoapplyv1alpha1.AutoApplyConfigSpec{RequireApproval: ptr.To(false)},
+	})
+
+	if _, err := r.Reconcile(ctx, req); err != nil {
+		t.Fatalf("Reconcile failed: %v", err)
+	}
+	if countDefaultPods(t, fakeClient) != 1 {
+		t.Error("Expected no restart before approval")
+	}
+	ops := listOperations(t, fakeClient)
+	if len(ops) != 1 || ops[0].Status.Phase != autoapplyv1alpha1.RestartOperationAwaitingApproval {
+		t.Errorf("Expected the restart to await approval, got %+v", ops)
+	}
+}
+
+func TestLoadConfig_NamespaceMaintenanceWindowsNarrowCluster(t *testing.T) {
+	r, fakeClient := setupTestReconciler()
+	ctx := context.Background()
+
+	_ = fakeClient.Create(ctx, &autoapplyv1alpha1.AutoApplyConfig{
+		ObjectMeta: metav1.ObjectMeta{Name: "cluster"},
+		Spec: autoapplyv1alpha1.AutoApplyConfigSpec{
+			MaintenanceWindows: []autoapplyv1alpha1.MaintenanceWindow{{Start: "02:00", End: "04:00"}},
+		},
+	})
+	// A tenant window all afternoon must not open restarts outside the
+	// cluster's, and only its overlap with them counts
+	_ = fakeClient.Create(ctx, &autoapplyv1alpha1.AutoApplyNamespaceConfig{
+		ObjectMeta: metav1.ObjectMeta{Name: "team", Namespace: "team-a"},
+		Spec: autoapplyv1alpha1.AutoApplyConfigSpec{
+			MaintenanceWindows: []autoapplyv1alpha1.MaintenanceWindow{
+				{Start: "12:00", End: "18:00"},
+				{Start: "03:00", End: "05:00", Days: []string{"Wed"}},
+			},
+		},
+	})
+	cfg := r.loadConfig(ctx, &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "team-a"}})
+
+	// 2024-01-01 is a Monday
+	tests := []struct {
+		name     string
+		now      time.Time
+		wantOpen bool
+		wantNext time.Time
+	}{
+		{"only the namespace window open", time.Date(2024, 1, 1, 12, 30, 0, 0, time.UTC), false, time.Date(2024, 1, 3, 3, 0, 0, 0, time.UTC)},
+		{"only the cluster window open", time.Date(2024, 1, 1, 2, 30, 0, 0, time.UTC), false, time.Date(2024, 1, 3, 3, 0, 0, 0, time.UTC)},
+		{"both open", time.Date(2024, 1, 3, 3, 30, 0, 0, time.UTC), true, time.Date(2024, 1, 3, 3, 30, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			open, next := cfg.restartWindowOpen(tt.now)
+			if open != tt.wantOpen || !next.Equal(tt.wantNext) {
+				t.Errorf("restartWindowOpen() = %v, %v, expected %v, %v", open, next, tt.wantOpen, tt.wantNext)
+			}
+		})
+	}
+}
+
+func TestLoadConfig_NamespaceConfigCannotRunPreRestartJob(t *testing.T) {
+	r, fakeClient := setupTestReconciler()
+	ctx := context.Background()
+
+	_ = fakeClient.Create(ctx, &autoapplyv1alpha1.AutoApplyNamespaceConfig{
+		ObjectMeta: metav1.ObjectMeta{Name: "team", Namespace: "team-a"},
+		Spec: autoapplyv1alpha1.AutoApplyConfigSpec{
+			PreRestartJob: &autoapplyv1alpha1.PreRestartJob{Template: preRestartJobTemplate()},
+		},
+	})
+
+	cfg := r.loadConfig(ctx, &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "team-a"}})
+	if len(cfg.preRestartJobs) != 0 {
+		t.Errorf("Expected the namespace config's pre-restart Job to be ignored, got %d", len(cfg.preRestartJobs))
+	}
+}
+
+func TestLoadConfig_NamespaceConfigProbesStayInNamespace(t *testing.T) {
+	r, fakeClient := setupTestReconciler()
+	ctx := context.Background()
+
+	_ = fakeClient.Create(ctx, &autoapplyv1alpha1.AutoApplyNamespaceConfig{
+		ObjectMeta: metav1.ObjectMeta{Name: "team", Namespace: "team-a"},
+		Spec: autoapplyv1alpha1.AutoApplyConfigSpec{
+			VerificationProbes: []autoapplyv1alpha1.ServiceProbe{
+				{Service: "web", Port: 80},
+				{Service: "kubernetes.default", Port: 443},
+				{Service: "169.254.169.254", Port: 80},
+			},
+		},
+	})
+
+	cfg := r.loadConfig(ctx, &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "team-a"}})
+	if len(cfg.verificationProbes) != 1 || cfg.verificationProbes[0].Service != "web" {
+		t.Errorf("Expected only the probe of Service web, got %+v", cfg.verificationProbes)
+	}
+}
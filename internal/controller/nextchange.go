@@ -0,0 +1,66 @@
+package controller
+
+import (
+	"context"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	autoapplyv1alpha1 "github.com/manos/k8s-autoapply-operator/api/v1alpha1"
+)
+
+// nextChangeYolo is the next-change-strategy value that forces YOLO mode
+const nextChangeYolo = "yolo"
+
+// consumeNextChangeStrategy applies the ConfigMap's one-shot strategy override
+// to cfg and removes the annotation, so later changes use the standing policy
+// again. In dry-run mode the annotation is kept for the real change.
+func (r *ConfigMapReconciler) consumeNextChangeStrategy(ctx context.Context, configMap *corev1.ConfigMap, cfg operatorConfig) operatorConfig {
+	logger := log.FromContext(ctx)
+
+	value, ok := configMap.Annotations[nextChangeStrategyAnnotation]
+	if !ok {
+		return cfg
+	}
+
+	if !cfg.dryRun {
+		patch := client.MergeFrom(configMap.DeepCopy())
+		delete(configMap.Annotations, nextChangeStrategyAnnotation)
+		if err := r.Patch(ctx, configMap, patch); err != nil {
+			logger.Error(err, "Failed to remove next change strategy annotation")
+		}
+	}
+
+	if strings.EqualFold(value, nextChangeYolo) {
+		if cfg.yoloDisabled {
+			r.Recorder.Eventf(configMap, corev1.EventTypeWarning, "YoloModeDisabled",
+				"Ignoring %s %q, yolo mode is disabled for this ConfigMap", nextChangeStrategyAnnotation, value)
+			return cfg
+		}
+		cfg.yoloMode = true
+	} else if strategy, ok := parseRestartStrategy(value); ok {
+		cfg.strategy = strategy
+		cfg.yoloMode = false
+	} else {
+		r.Recorder.Eventf(configMap, corev1.EventTypeWarning, "InvalidNextChangeStrategy",
+			"Ignoring unknown %s %q", nextChangeStrategyAnnotation, value)
+		return cfg
+	}
+
+	logger.Info("Using one-shot strategy for this change", "strategy", value)
+	r.Recorder.Eventf(configMap, corev1.EventTypeNormal, "NextChangeStrategyApplied",
+		"Using %s for this change as requested by %s", value, nextChangeStrategyAnnotation)
+	return cfg
+}
+
+// parseRestartStrategy matches a strategy name case-insensitively
+func parseRestartStrategy(value string) (autoapplyv1alpha1.RestartStrategy, bool) {
+	for strategy := range strategyPriority {
+		if strings.EqualFold(value, string(strategy)) {
+			return strategy, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,55 @@
+package controller
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	autoapplyv1alpha1 "github.com/manos/k8s-autoapply-operator/api/v1alpha1"
+)
+
+func TestConsumeNextChangeStrategy(t *testing.T) {
+	tests := []struct {
+		name         string
+		value        string
+		dryRun       bool
+		expectYolo   bool
+		expectStrat  autoapplyv1alpha1.RestartStrategy
+		expectRemove bool
+	}{
+		{"yolo", "yolo", false, true, autoapplyv1alpha1.RestartStrategyRolling, true},
+		{"strategy", "canary", false, false, autoapplyv1alpha1.RestartStrategyCanary, true},
+		{"unknown", "fast", false, false, autoapplyv1alpha1.RestartStrategyRolling, true},
+		{"kept in dry run", "yolo", true, true, autoapplyv1alpha1.RestartStrategyRolling, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, fakeClient := setupTestReconciler()
+			ctx := context.Background()
+
+			cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{
+				Name:        "test-config",
+				Namespace:   "default",
+				Annotations: map[string]string{nextChangeStrategyAnnotation: tt.value},
+			}}
+			_ = fakeClient.Create(ctx, cm)
+
+			cfg := r.loadConfig(ctx, cm)
+			cfg.dryRun = tt.dryRun
+			cfg = r.consumeNextChangeStrategy(ctx, cm, cfg)
+
+			if cfg.yoloMode != tt.expectYolo || cfg.strategy != tt.expectStrat {
+				t.Errorf("Got yoloMode=%v strategy=%q", cfg.yoloMode, cfg.strategy)
+			}
+
+			_ = fakeClient.Get(ctx, client.ObjectKeyFromObject(cm), cm)
+			if _, kept := cm.Annotations[nextChangeStrategyAnnotation]; kept == tt.expectRemove {
+				t.Errorf("Annotation kept = %v, expected removal %v", kept, tt.expectRemove)
+			}
+		})
+	}
+}
@@ -0,0 +1,91 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// Delay before a restart paused for a node disruption checks the nodes again
+const nodeDisruptionRetryInterval = time.Minute
+
+// nodeDisruption returns why the cluster's nodes are too disrupted to
+// restart pods, or "" if they aren't
+func (r *ConfigMapReconciler) nodeDisruption(ctx context.Context) (string, error) {
+	if r.MaxNotReadyNodes <= 0 && r.MaxCordonedNodes <= 0 {
+		return "", nil
+	}
+
+	var nodes corev1.NodeList
+	if err := r.List(ctx, &nodes); err != nil {
+		return "", err
+	}
+
+	var notReady, cordoned int
+	for _, node := range nodes.Items {
+		if !nodeReady(&node) {
+			notReady++
+		}
+		if node.Spec.Unschedulable {
+			cordoned++
+		}
+	}
+
+	var reasons []string
+	if r.MaxNotReadyNodes > 0 && notReady > r.MaxNotReadyNodes {
+		reasons = append(reasons, fmt.Sprintf("%d nodes are NotReady (max %d)", notReady, r.MaxNotReadyNodes))
+	}
+	if r.MaxCordonedNodes > 0 && cordoned > r.MaxCordonedNodes {
+		reasons = append(reasons, fmt.Sprintf("%d nodes are cordoned (max %d)", cordoned, r.MaxCordonedNodes))
+	}
+	return strings.Join(reasons, " and "), nil
+}
+
+// nodeReady checks the node's Ready condition
+func nodeReady(node *corev1.Node) bool {
+	for _, condition := range node.Status.Conditions {
+		if condition.Type == corev1.NodeReady {
+			return condition.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// pauseForNodeDisruption holds the ConfigMap's restart while the nodes are
+// disrupted or the cluster is upgraded, retrying until they stabilize. Events
+// on the ConfigMap report when it's paused and resumed. The resumed restart
+// catches up on the pods still running the previous config.
+func (r *ConfigMapReconciler) pauseForNodeDisruption(ctx context.Context, configMap *corev1.ConfigMap, key string) (bool, ctrl.Result) {
+	logger := log.FromContext(ctx)
+
+	reason, err := r.nodeDisruption(ctx)
+	if err == nil && reason == "" {
+		reason, err = r.clusterUpgrade(ctx)
+	}
+	if err != nil {
+		// Not knowing the nodes' state shouldn't hold every restart
+		logger.Error(err, "Failed to check for node disruptions and upgrades")
+		return false, ctrl.Result{}
+	}
+
+	if reason == "" {
+		if _, paused := r.disruptionPaused.LoadAndDelete(key); paused {
+			logger.Info("Nodes stabilized, resuming restart")
+			r.Recorder.Event(configMap, corev1.EventTypeNormal, "RestartResumed",
+				"Nodes stabilized, resuming the paused restart for pods still running the previous config")
+		}
+		return false, ctrl.Result{}
+	}
+
+	logger.Info("Nodes disrupted, pausing restart", "reason", reason)
+	if previous, paused := r.disruptionPaused.Swap(key, reason); !paused || previous != reason {
+		r.Recorder.Eventf(configMap, corev1.EventTypeWarning, "RestartPaused",
+			"Restart paused while %s; it resumes once the nodes stabilize", reason)
+	}
+	return true, ctrl.Result{RequeueAfter: nodeDisruptionRetryInterval}
+}
@@ -0,0 +1,101 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/ptr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	autoapplyv1alpha1 "github.com/manos/k8s-autoapply-operator/api/v1alpha1"
+)
+
+func TestNodeDisruption(t *testing.T) {
+	r, fakeClient := setupTestReconciler()
+	ctx := context.Background()
+	r.MaxNotReadyNodes = 1
+	r.MaxCordonedNodes = 2
+
+	for i, node := range []struct {
+		ready    corev1.ConditionStatus
+		cordoned bool
+	}{
+		{corev1.ConditionTrue, false},
+		{corev1.ConditionFalse, true},
+		{corev1.ConditionUnknown, true},
+		{corev1.ConditionTrue, true},
+	} {
+		_ = fakeClient.Create(ctx, &corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf("node-%d", i)},
+			Spec:       corev1.NodeSpec{Unschedulable: node.cordoned},
+			Status: corev1.NodeStatus{Conditions: []corev1.NodeCondition{
+				{Type: corev1.NodeReady, Status: node.ready},
+			}},
+		})
+	}
+
+	reason, err := r.nodeDisruption(ctx)
+	if err != nil {
+		t.Fatalf("nodeDisruption() failed: %v", err)
+	}
+	expected := "2 nodes are NotReady (max 1) and 3 nodes are cordoned (max 2)"
+	if reason != expected {
+		t.Errorf("Expected %q, got %q", expected, reason)
+	}
+
+	r.MaxNotReadyNodes, r.MaxCordonedNodes = 2, 0
+	if reason, _ := r.nodeDisruption(ctx); reason != "" {
+		t.Errorf("Expected no disruption within the limits, got %q", reason)
+	}
+}
+
+func TestReconcile_PausedForNodeDisruption(t *testing.T) {
+	r, fakeClient := setupTestReconciler()
+	ctx := context.Background()
+	r.MaxNotReadyNodes = 1
+	recorder := r.Recorder.(*record.FakeRecorder)
+
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: "test-config", Namespace: "default"}}
+	r.configMapVersions.Store(req.String(), "old-version")
+	_ = fakeClient.Create(ctx, &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "test-config", Namespace: "default"}})
+	_ = fakeClient.Create(ctx, podUsingConfigMap("test-pod", "test-config", metav1.Now().Time))
+	for _, name := range []string{"node-a", "node-b"} {
+		_ = fakeClient.Create(ctx, &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: name}})
+	}
+
+	result, err := r.Reconcile(ctx, req)
+	if err != nil {
+		t.Fatalf("Reconcile failed: %v", err)
+	}
+	if result.RequeueAfter != nodeDisruptionRetryInterval {
+		t.Errorf("Expected a retry once the nodes may have stabilized, got %v", result.RequeueAfter)
+	}
+	if event := <-recorder.Events; !strings.HasPrefix(event, "Warning RestartPaused Restart paused while 2 nodes are NotReady") {
+		t.Errorf("Unexpected event %q", event)
+	}
+	var pods corev1.PodList
+	_ = fakeClient.List(ctx, &pods, client.InNamespace("default"))
+	if len(pods.Items) != 1 {
+		t.Errorf("Expected the pod to be kept while nodes are disrupted, found %d pods", len(pods.Items))
+	}
+
+	// A critical config restarts anyway
+	_ = fakeClient.Create(ctx, &autoapplyv1alpha1.AutoApplyConfig{
+		ObjectMeta: metav1.ObjectMeta{Name: "critical"},
+		Spec:       autoapplyv1alpha1.AutoApplyConfigSpec{Critical: ptr.To(true), YoloMode: ptr.To(true)},
+	})
+	if _, err := r.Reconcile(ctx, req); err != nil {
+		t.Fatalf("Reconcile failed: %v", err)
+	}
+	_ = fakeClient.List(ctx, &pods, client.InNamespace("default"))
+	if len(pods.Items) != 0 {
+		t.Errorf("Expected the critical config's pod to be restarted, found %d pods", len(pods.Items))
+	}
+}
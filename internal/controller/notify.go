@@ -0,0 +1,307 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	autoapplyv1alpha1 "github.com/manos/k8s-autoapply-operator/api/v1alpha1"
+)
+
+// +kubebuilder:rbac:groups="",resources=secrets,verbs=get
+
+const (
+	// Deadline for posting one notification
+	notificationTimeout = 10 * time.Second
+
+	// Restart events reported by notifications
+	notificationStarted   = "Started"
+	notificationCompleted = "Completed"
+	notificationFailed    = "Failed"
+)
+
+// restartNotification is the body posted to Webhook notification URLs, and
+// the data notification templates render
+type restartNotification struct {
+	Event     string `json:"event"`
+	Namespace string `json:"namespace"`
+	ConfigMap string `json:"configMap"`
+	Pods      int    `json:"pods"`
+	// Workloads restarted, as Kind/name
+	Workloads []string `json:"workloads,omitempty"`
+	// Duration of a finished restart, e.g. "3m12s"
+	Duration string `json:"duration,omitempty"`
+	// Operation is the RestartOperation recording the restart
+	Operation string `json:"operation,omitempty"`
+	Error     string `json:"error,omitempty"`
+	// Summary is the human-readable message text
+	Summary string `json:"summary,omitempty"`
+}
+
+// restartSummary is what notifications report about a restart
+type restartSummary struct {
+	pods      int
+	workloads []string
+	started   time.Time
+	operation string
+}
+
+// slackMessage is the body posted to Slack incoming webhooks
+type slackMessage struct {
+	Text string `json:"text"`
+}
+
+// text renders the notification as a short digest
+func (n restartNotification) text() string {
+	var b strings.Builder
+	switch n.Event {
+	case notificationStarted:
+		fmt.Fprintf(&b, "Restarting %d pods using ConfigMap %s/%s", n.Pods, n.Namespace, n.ConfigMap)
+	case notificationFailed:
+		fmt.Fprintf(&b, "Restart of %d pods using ConfigMap %s/%s failed", n.Pods, n.Namespace, n.ConfigMap)
+	default:
+		fmt.Fprintf(&b, "Restarted %d pods using ConfigMap %s/%s", n.Pods, n.Namespace, n.ConfigMap)
+	}
+	if n.Duration != "" && n.Event == notificationFailed {
+		fmt.Fprintf(&b, " after %s", n.Duration)
+	} else if n.Duration != "" {
+		fmt.Fprintf(&b, " in %s", n.Duration)
+	}
+	if n.Error != "" {
+		fmt.Fprintf(&b, ": %s", n.Error)
+	}
+	if len(n.Workloads) > 0 {
+		fmt.Fprintf(&b, "\nWorkloads: %s", strings.Join(n.Workloads, ", "))
+	}
+	if n.Operation != "" {
+		fmt.Fprintf(&b, "\nDetails: kubectl get restartoperation -n %s %s -o yaml", n.Namespace, n.Operation)
+	}
+	return b.String()
+}
+
+// render returns the message text for a target, from its template if set.
+// A broken template falls back to the built-in summary.
+func (n restartNotification) render(target autoapplyv1alpha1.Notification) (string, error) {
+	if target.Template == "" {
+		return n.text(), nil
+	}
+	tmpl, err := parseNotificationTemplate(target.Template)
+	if err != nil {
+		return n.text(), err
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, n); err != nil {
+		return n.text(), fmt.Errorf("rendering notification template: %w", err)
+	}
+	return b.String(), nil
+}
+
+// parseNotificationTemplate parses a notification's Template, which may use
+// join like strings.Join
+func parseNotificationTemplate(text string) (*template.Template, error) {
+	return template.New("notification").Funcs(template.FuncMap{"join": strings.Join}).Parse(text)
+}
+
+// summarizeRestart describes a restart of pods that began at started
+func (r *ConfigMapReconciler) summarizeRestart(ctx context.Context, configMap *corev1.ConfigMap, pods []corev1.Pod, started time.Time) restartSummary {
+	summary := restartSummary{pods: len(pods), started: started}
+
+	seen := make(map[string]bool)
+	for _, pod := range pods {
+		name := "Pod/" + pod.Name
+		if workload, err := r.resolveWorkload(ctx, &pod); err == nil && workload != nil {
+			name = workload.Kind + "/" + workload.Name
+		}
+		if !seen[name] {
+			seen[name] = true
+			summary.workloads = append(summary.workloads, name)
+		}
+	}
+	sort.Strings(summary.workloads)
+
+	if value, ok := r.operations.Load(operationKey(configMap)); ok {
+		summary.operation = value.(*operationRecord).op.Name
+	}
+	return summary
+}
+
+// withSecretNamespace resolves the namespace of a notification's Secret
+// references. Namespace configs may only read Secrets from their own
+// namespace, so force replaces any namespace they set.
+func withSecretNamespace(notification autoapplyv1alpha1.Notification, namespace string, force bool) autoapplyv1alpha1.Notification {
+	resolve := func(ref *autoapplyv1alpha1.SecretKeyRef) *autoapplyv1alpha1.SecretKeyRef {
+		if ref == nil {
+			return nil
+		}
+		resolved := *ref
+		if force || resolved.Namespace == "" {
+			resolved.Namespace = namespace
+		}
+		return &resolved
+	}
+	notification.URLSecretRef = resolve(notification.URLSecretRef)
+	notification.AuthSecretRef = resolve(notification.AuthSecretRef)
+	return notification
+}
+
+// notifyStarted reports a restart that is about to begin
+func (r *ConfigMapReconciler) notifyStarted(ctx context.Context, cfg operatorConfig, configMap *corev1.ConfigMap, summary restartSummary) {
+	r.notify(ctx, cfg, restartNotification{
+		Event:     notificationStarted,
+		Namespace: configMap.Namespace,
+		ConfigMap: configMap.Name,
+		Pods:      summary.pods,
+		Workloads: summary.workloads,
+		Operation: summary.operation,
+	})
+}
+
+// notifyFinished reports a restart that completed, or failed with restartErr
+func (r *ConfigMapReconciler) notifyFinished(ctx context.Context, cfg operatorConfig, configMap *corev1.ConfigMap, summary restartSummary, restartErr error) {
+	notification := restartNotification{
+		Event:     notificationCompleted,
+		Namespace: configMap.Namespace,
+		ConfigMap: configMap.Name,
+		Pods:      summary.pods,
+		Workloads: summary.workloads,
+		Duration:  time.Since(summary.started).Round(time.Second).String(),
+		Operation: summary.operation,
+	}
+	if restartErr != nil {
+		notification.Event = notificationFailed
+		notification.Error = restartErr.Error()
+	}
+	r.notify(ctx, cfg, notification)
+}
+
+// notify posts a notification to every configured notification URL.
+// Failures are logged and never affect the restart.
+func (r *ConfigMapReconciler) notify(ctx context.Context, cfg operatorConfig, notification restartNotification) {
+	logger := log.FromContext(ctx)
+
+	for _, target := range cfg.notifications {
+		if err := r.sendNotification(ctx, target, notification, false); err != nil {
+			logger.Error(err, "Failed to send restart notification", "event", notification.Event)
+		}
+	}
+	for _, target := range cfg.namespaceNotifications {
+		if err := r.sendNotification(ctx, target, notification, true); err != nil {
+			logger.Error(err, "Failed to send restart notification", "event", notification.Event)
+		}
+	}
+}
+
+// sendNotification posts a notification to one target. Targets from
+// namespace configs must be on a NamespaceNotificationHosts host, and
+// redirects away from it aren't followed.
+func (r *ConfigMapReconciler) sendNotification(ctx context.Context, target autoapplyv1alpha1.Notification, notification restartNotification, namespaced bool) error {
+	url := target.URL
+	if target.URLSecretRef != nil {
+		value, err := r.secretValue(ctx, target.URLSecretRef)
+		if err != nil {
+			return err
+		}
+		url = value
+	}
+	if url == "" {
+		return fmt.Errorf("notification has no URL")
+	}
+	httpClient := http.DefaultClient
+	if namespaced {
+		if err := r.allowNamespaceNotification(url); err != nil {
+			return err
+		}
+		httpClient = noRedirectClient
+	}
+
+	text, err := notification.render(target)
+	if err != nil {
+		log.FromContext(ctx).Error(err, "Invalid notification template, using the default summary")
+	}
+	notification.Summary = text
+
+	var payload any = notification
+	if target.Format == autoapplyv1alpha1.NotificationFormatSlack {
+		payload = slackMessage{Text: text}
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, notificationTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if target.AuthSecretRef != nil {
+		token, err := r.secretValue(ctx, target.AuthSecretRef)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	_ = resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("notification returned %s", resp.Status)
+	}
+	return nil
+}
+
+// noRedirectClient returns redirects as responses instead of following them
+var noRedirectClient = &http.Client{
+	CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+}
+
+// allowNamespaceNotification checks that a namespace config's notification
+// URL is an http(s) URL on one of NamespaceNotificationHosts
+func (r *ConfigMapReconciler) allowNamespaceNotification(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("namespace config notification URL must be http or https")
+	}
+	for _, host := range r.NamespaceNotificationHosts {
+		if strings.EqualFold(u.Hostname(), host) {
+			return nil
+		}
+	}
+	return fmt.Errorf("namespace config notifications to host %q are not allowed", u.Hostname())
+}
+
+// secretValue reads one key of a Secret
+func (r *ConfigMapReconciler) secretValue(ctx context.Context, ref *autoapplyv1alpha1.SecretKeyRef) (string, error) {
+	var secret corev1.Secret
+	if err := r.Get(ctx, client.ObjectKey{Namespace: ref.Namespace, Name: ref.Name}, &secret); err != nil {
+		return "", fmt.Errorf("reading secret %s/%s: %w", ref.Namespace, ref.Name, err)
+	}
+	value, ok := secret.Data[ref.Key]
+	if !ok {
+		return "", fmt.Errorf("secret %s/%s has no key %s", ref.Namespace, ref.Name, ref.Key)
+	}
+	return string(value), nil
+}
@@ -0,0 +1,245 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	autoapplyv1alpha1 "github.com/manos/k8s-autoapply-operator/api/v1alpha1"
+)
+
+func TestNotify_Webhook(t *testing.T) {
+	r, fakeClient := setupTestReconciler()
+	ctx := context.Background()
+
+	_ = fakeClient.Create(ctx, &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "notify", Namespace: "default"},
+		Data:       map[string][]byte{"token": []byte("s3cret")},
+	})
+
+	var received restartNotification
+	var auth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		auth = req.Header.Get("Authorization")
+		_ = json.NewDecoder(req.Body).Decode(&received)
+	}))
+	defer server.Close()
+
+	cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "test-config", Namespace: "default"}}
+	cfg := operatorConfig{notifications: []autoapplyv1alpha1.Notification{{
+		URL:           server.URL,
+		AuthSecretRef: &autoapplyv1alpha1.SecretKeyRef{Namespace: "default", Name: "notify", Key: "token"},
+	}}}
+
+	r.notifyFinished(ctx, cfg, cm, restartSummary{pods: 3, started: time.Now()}, errors.New("boom"))
+
+	expected := restartNotification{
+		Event: notificationFailed, Namespace: "default", ConfigMap: "test-config", Pods: 3, Duration: "0s", Error: "boom",
+		Summary: "Restart of 3 pods using ConfigMap default/test-config failed after 0s: boom",
+	}
+	if !reflect.DeepEqual(received, expected) {
+		t.Errorf("Unexpected notification: %+v", received)
+	}
+	if auth != "Bearer s3cret" {
+		t.Errorf("Expected bearer token from the Secret, got %q", auth)
+	}
+}
+
+func TestNotify_Slack(t *testing.T) {
+	r, fakeClient := setupTestReconciler()
+	ctx := context.Background()
+
+	var received slackMessage
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		_ = json.NewDecoder(req.Body).Decode(&received)
+	}))
+	defer server.Close()
+
+	_ = fakeClient.Create(ctx, &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "slack", Namespace: "default"},
+		Data:       map[string][]byte{"url": []byte(server.URL)},
+	})
+
+	cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "test-config", Namespace: "default"}}
+	cfg := operatorConfig{notifications: []autoapplyv1alpha1.Notification{{
+		URLSecretRef: &autoapplyv1alpha1.SecretKeyRef{Namespace: "default", Name: "slack", Key: "url"},
+		Format:       autoapplyv1alpha1.NotificationFormatSlack,
+	}}}
+
+	r.notifyStarted(ctx, cfg, cm, restartSummary{pods: 4})
+
+	if received.Text != "Restarting 4 pods using ConfigMap default/test-config" {
+		t.Errorf("Unexpected Slack message: %q", received.Text)
+	}
+}
+
+func TestLoadConfig_NotificationSecretNamespace(t *testing.T) {
+	r, fakeClient := setupTestReconciler()
+	ctx := context.Background()
+
+	ref := func(namespace string) *autoapplyv1alpha1.SecretKeyRef {
+		return &autoapplyv1alpha1.SecretKeyRef{Namespace: namespace, Name: "notify", Key: "url"}
+	}
+	_ = fakeClient.Create(ctx, &autoapplyv1alpha1.AutoApplyConfig{
+		ObjectMeta: metav1.ObjectMeta{Name: "cluster"},
+		Spec: autoapplyv1alpha1.AutoApplyConfigSpec{Notifications: []autoapplyv1alpha1.Notification{
+			{URLSecretRef: ref("")},
+			{URLSecretRef: ref("ops")},
+		}},
+	})
+	_ = fakeClient.Create(ctx, &autoapplyv1alpha1.AutoApplyNamespaceConfig{
+		ObjectMeta: metav1.ObjectMeta{Name: "team", Namespace: "my-app"},
+		Spec: autoapplyv1alpha1.AutoApplyConfigSpec{Notifications: []autoapplyv1alpha1.Notification{
+			{URLSecretRef: ref("ops")},
+		}},
+	})
+
+	cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "test-config", Namespace: "my-app"}}
+	cfg := r.loadConfig(ctx, cm)
+
+	var namespaces []string
+	for _, notification := range append(cfg.notifications, cfg.namespaceNotifications...) {
+		namespaces = append(namespaces, notification.URLSecretRef.Namespace)
+	}
+	// Cluster configs default to the ConfigMap's namespace, namespace configs are pinned to their own
+	expected := []string{"my-app", "ops", "my-app"}
+	if len(namespaces) != len(expected) {
+		t.Fatalf("Expected Secret namespaces %v, got %v", expected, namespaces)
+	}
+	for i := range expected {
+		if namespaces[i] != expected[i] {
+			t.Errorf("Expected Secret namespaces %v, got %v", expected, namespaces)
+		}
+	}
+}
+
+func TestNotify_NamespaceConfigHosts(t *testing.T) {
+	r, _ := setupTestReconciler()
+	ctx := context.Background()
+
+	received := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		received++
+	}))
+	defer server.Close()
+
+	cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "test-config", Namespace: "default"}}
+	cfg := operatorConfig{namespaceNotifications: []autoapplyv1alpha1.Notification{{URL: server.URL}}}
+
+	r.notifyStarted(ctx, cfg, cm, restartSummary{pods: 1})
+	if received != 0 {
+		t.Fatalf("Expected no notification to a host that isn't allowed, got %d", received)
+	}
+
+	r.NamespaceNotificationHosts = []string{"127.0.0.1"}
+	r.notifyStarted(ctx, cfg, cm, restartSummary{pods: 1})
+	if received != 1 {
+		t.Errorf("Expected a notification to an allowed host, got %d", received)
+	}
+}
+
+func TestNotify_NamespaceConfigRedirectNotFollowed(t *testing.T) {
+	r, _ := setupTestReconciler()
+	ctx := context.Background()
+
+	internal := 0
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		internal++
+	}))
+	defer target.Close()
+	allowed := httptest.NewServer(http.RedirectHandler(target.URL, http.StatusTemporaryRedirect))
+	defer allowed.Close()
+
+	r.NamespaceNotificationHosts = []string{"127.0.0.1"}
+	cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "test-config", Namespace: "default"}}
+	cfg := operatorConfig{namespaceNotifications: []autoapplyv1alpha1.Notification{{URL: allowed.URL}}}
+
+	r.notifyStarted(ctx, cfg, cm, restartSummary{pods: 1})
+	if internal != 0 {
+		t.Errorf("Expected the redirect of a namespace config notification not to be followed")
+	}
+}
+
+func TestSummarizeRestart(t *testing.T) {
+	r, fakeClient := setupTestReconciler()
+	ctx := context.Background()
+
+	trueVal := true
+	_ = fakeClient.Create(ctx, &appsv1.ReplicaSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "web-abc", Namespace: "default", OwnerReferences: []metav1.OwnerReference{
+			{APIVersion: "apps/v1", Kind: "Deployment", Name: "web", Controller: &trueVal},
+		}},
+	})
+	owned := func(name, kind, owner string) corev1.Pod {
+		return corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", OwnerReferences: []metav1.OwnerReference{
+			{APIVersion: "apps/v1", Kind: kind, Name: owner, Controller: &trueVal},
+		}}}
+	}
+	pods := []corev1.Pod{
+		owned("web-abc-1", "ReplicaSet", "web-abc"),
+		owned("web-abc-2", "ReplicaSet", "web-abc"),
+		owned("db-0", "StatefulSet", "db"),
+		{ObjectMeta: metav1.ObjectMeta{Name: "debug", Namespace: "default"}},
+	}
+
+	cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "test-config", Namespace: "default"}}
+	r.operations.Store(operationKey(cm), &operationRecord{op: &autoapplyv1alpha1.RestartOperation{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-config-x7k2p"},
+	}})
+
+	summary := r.summarizeRestart(ctx, cm, pods, time.Now())
+
+	if summary.pods != 4 || summary.operation != "test-config-x7k2p" {
+		t.Errorf("Unexpected summary: %+v", summary)
+	}
+	expected := []string{"Deployment/web", "Pod/debug", "StatefulSet/db"}
+	if !reflect.DeepEqual(summary.workloads, expected) {
+		t.Errorf("Expected workloads %v, got %v", expected, summary.workloads)
+	}
+}
+
+func TestRestartNotificationRender(t *testing.T) {
+	n := restartNotification{
+		Event:     notificationCompleted,
+		Namespace: "default",
+		ConfigMap: "test-config",
+		Pods:      3,
+		Workloads: []string{"Deployment/web", "StatefulSet/db"},
+		Duration:  "2m5s",
+		Operation: "test-config-x7k2p",
+	}
+
+	text, err := n.render(autoapplyv1alpha1.Notification{})
+	if err != nil {
+		t.Fatalf("render failed: %v", err)
+	}
+	expected := "Restarted 3 pods using ConfigMap default/test-config in 2m5s\n" +
+		"Workloads: Deployment/web, StatefulSet/db\n" +
+		"Details: kubectl get restartoperation -n default test-config-x7k2p -o yaml"
+	if text != expected {
+		t.Errorf("Unexpected default summary:\n%s", text)
+	}
+
+	text, err = n.render(autoapplyv1alpha1.Notification{Template: "{{.ConfigMap}} rolled out to {{join .Workloads \" and \"}}"})
+	if err != nil {
+		t.Fatalf("render failed: %v", err)
+	}
+	if text != "test-config rolled out to Deployment/web and StatefulSet/db" {
+		t.Errorf("Unexpected templated summary: %q", text)
+	}
+
+	// A broken template still sends the default summary
+	text, err = n.render(autoapplyv1alpha1.Notification{Template: "{{.Nope"})
+	if err == nil || text != expected {
+		t.Errorf("Expected an error and the default summary, got %v and %q", err, text)
+	}
+}
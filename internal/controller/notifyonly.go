@@ -0,0 +1,199 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// +kubebuilder:rbac:groups=apps,resources=deployments/status;statefulsets/status;daemonsets/status,verbs=patch
+
+// conditionRestartRecommended on a workload means its pods run config from
+// before a ConfigMap change that notify-only mode didn't restart them for
+const conditionRestartRecommended = "RestartRecommended"
+
+// Reasons of the RestartRecommended condition
+const (
+	reasonConfigMapChanged = "ConfigMapChanged"
+	reasonConfigApplied    = "ConfigApplied"
+)
+
+// namespacedWorkload identifies a workload across namespaces
+type namespacedWorkload struct {
+	namespace string
+	workloadRef
+}
+
+// notifyOnly handles a change in notify-only mode: the pods a restart would
+// select are reported and left running. Restarts in progress for an earlier
+// change stop where they are.
+func (r *ConfigMapReconciler) notifyOnly(ctx context.Context, cfg operatorConfig, configMap *corev1.ConfigMap, key, version string) (ctrl.Result, error) {
+	r.pendingRestarts.Delete(key)
+	r.trickles.Delete(key)
+	r.pdbRetries.Delete(key)
+	defer r.persistVersion(ctx, cfg, configMap, version, time.Now())
+
+	pods := r.findPodsUsingConfigMap(ctx, configMap, cfg)
+	if len(pods) == 0 {
+		log.FromContext(ctx).Info("No pods to restart")
+		return ctrl.Result{}, nil
+	}
+	r.recommendRestart(ctx, configMap, pods)
+	return ctrl.Result{}, nil
+}
+
+// recommendRestart reports the pods a change would restart instead of
+// restarting them: as an Event on the ConfigMap, in the stale config metric,
+// and as a RestartRecommended condition on their workloads
+func (r *ConfigMapReconciler) recommendRestart(ctx context.Context, configMap *corev1.ConfigMap, pods []corev1.Pod) {
+	logger := log.FromContext(ctx)
+
+	logger.Info("Notify-only, recommending a restart instead", "pods", len(pods))
+	r.Recorder.Eventf(configMap, corev1.EventTypeNormal, "RestartRecommended",
+		"%d pods run the previous config, restart them to apply it: %s", len(pods), listPodNames(pods))
+	staleConfigPods.set(configMap.Namespace, configMap.Name, float64(len(pods)))
+
+	counts := make(map[workloadRef]int)
+	for i := range pods {
+		workload, err := r.resolveWorkload(ctx, &pods[i])
+		if err != nil || workload == nil {
+			continue
+		}
+		counts[*workload]++
+	}
+	for workload, count := range counts {
+		message := fmt.Sprintf("ConfigMap %s changed, %d pods run the previous config", configMap.Name, count)
+		found, err := r.setRestartRecommended(ctx, configMap.Namespace, workload, corev1.ConditionTrue, reasonConfigMapChanged, message)
+		if err != nil {
+			logger.Error(err, "Failed to set RestartRecommended condition", "workload", workload.Kind+"/"+workload.Name)
+			continue
+		}
+		if found {
+			r.restartRecommended.Store(namespacedWorkload{configMap.Namespace, workload}, struct{}{})
+		}
+	}
+}
+
+// clearRestartRecommendations sets RestartRecommended to False on workloads
+// none of whose pods run stale config anymore
+func (r *ConfigMapReconciler) clearRestartRecommendations(ctx context.Context, stale []corev1.Pod) {
+	tracked := false
+	r.restartRecommended.Range(func(_, _ any) bool {
+		tracked = true
+		return false
+	})
+	if !tracked {
+		return
+	}
+
+	staleWorkloads := make(map[namespacedWorkload]bool)
+	for i := range stale {
+		if workload, err := r.resolveWorkload(ctx, &stale[i]); err == nil && workload != nil {
+			staleWorkloads[namespacedWorkload{stale[i].Namespace, *workload}] = true
+		}
+	}
+
+	r.restartRecommended.Range(func(key, _ any) bool {
+		workload := key.(namespacedWorkload)
+		if staleWorkloads[workload] {
+			return true
+		}
+		if _, err := r.setRestartRecommended(ctx, workload.namespace, workload.workloadRef, corev1.ConditionFalse,
+			reasonConfigApplied, "All pods run the latest config"); err != nil {
+			log.FromContext(ctx).Error(err, "Failed to clear RestartRecommended condition", "workload", workload.Kind+"/"+workload.Name)
+			return true
+		}
+		r.restartRecommended.Delete(key)
+		return true
+	})
+}
+
+// setRestartRecommended sets the RestartRecommended condition in a
+// workload's status. found is false for kinds without status conditions
+// and workloads that no longer exist.
+func (r *ConfigMapReconciler) setRestartRecommended(ctx context.Context, namespace string, workload workloadRef, status corev1.ConditionStatus, reason, message string) (found bool, err error) {
+	key := types.NamespacedName{Namespace: namespace, Name: workload.Name}
+	now := metav1.Now()
+
+	var obj client.Object
+	var patch client.Patch
+	switch workload.Kind {
+	case "Deployment":
+		var deployment appsv1.Deployment
+		if err := r.Get(ctx, key, &deployment); err != nil {
+			return false, client.IgnoreNotFound(err)
+		}
+		patch = client.MergeFromWithOptions(deployment.DeepCopy(), client.MergeFromWithOptimisticLock{})
+		condition := appsv1.DeploymentCondition{Type: conditionRestartRecommended, Status: status,
+			LastUpdateTime: now, LastTransitionTime: now, Reason: reason, Message: message}
+		i := conditionIndex(len(deployment.Status.Conditions), func(i int) string { return string(deployment.Status.Conditions[i].Type) })
+		if i < 0 {
+			deployment.Status.Conditions = append(deployment.Status.Conditions, condition)
+		} else {
+			if deployment.Status.Conditions[i].Status == status {
+				condition.LastTransitionTime = deployment.Status.Conditions[i].LastTransitionTime
+			}
+			deployment.Status.Conditions[i] = condition
+		}
+		obj = &deployment
+	case "StatefulSet":
+		var statefulSet appsv1.StatefulSet
+		if err := r.Get(ctx, key, &statefulSet); err != nil {
+			return false, client.IgnoreNotFound(err)
+		}
+		patch = client.MergeFromWithOptions(statefulSet.DeepCopy(), client.MergeFromWithOptimisticLock{})
+		condition := appsv1.StatefulSetCondition{Type: conditionRestartRecommended, Status: status,
+			LastTransitionTime: now, Reason: reason, Message: message}
+		i := conditionIndex(len(statefulSet.Status.Conditions), func(i int) string { return string(statefulSet.Status.Conditions[i].Type) })
+		if i < 0 {
+			statefulSet.Status.Conditions = append(statefulSet.Status.Conditions, condition)
+		} else {
+			if statefulSet.Status.Conditions[i].Status == status {
+				condition.LastTransitionTime = statefulSet.Status.Conditions[i].LastTransitionTime
+			}
+			statefulSet.Status.Conditions[i] = condition
+		}
+		obj = &statefulSet
+	case "DaemonSet":
+		var daemonSet appsv1.DaemonSet
+		if err := r.Get(ctx, key, &daemonSet); err != nil {
+			return false, client.IgnoreNotFound(err)
+		}
+		patch = client.MergeFromWithOptions(daemonSet.DeepCopy(), client.MergeFromWithOptimisticLock{})
+		condition := appsv1.DaemonSetCondition{Type: conditionRestartRecommended, Status: status,
+			LastTransitionTime: now, Reason: reason, Message: message}
+		i := conditionIndex(len(daemonSet.Status.Conditions), func(i int) string { return string(daemonSet.Status.Conditions[i].Type) })
+		if i < 0 {
+			daemonSet.Status.Conditions = append(daemonSet.Status.Conditions, condition)
+		} else {
+			if daemonSet.Status.Conditions[i].Status == status {
+				condition.LastTransitionTime = daemonSet.Status.Conditions[i].LastTransitionTime
+			}
+			daemonSet.Status.Conditions[i] = condition
+		}
+		obj = &daemonSet
+	default:
+		return false, nil
+	}
+
+	return true, r.Status().Patch(ctx, obj, patch)
+}
+
+// conditionIndex returns the index of the RestartRecommended condition among
+// n conditions, or -1
+func conditionIndex(n int, typeAt func(int) string) int {
+	for i := range n {
+		if typeAt(i) == conditionRestartRecommended {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,104 @@
+package controller
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/ptr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	autoapplyv1alpha1 "github.com/manos/k8s-autoapply-operator/api/v1alpha1"
+)
+
+func restartRecommendedCondition(t *testing.T, c client.Client) *appsv1.DeploymentCondition {
+	t.Helper()
+	var deploy appsv1.Deployment
+	if err := c.Get(context.Background(), types.NamespacedName{Name: "web", Namespace: "default"}, &deploy); err != nil {
+		t.Fatalf("Failed to get Deployment: %v", err)
+	}
+	for i := range deploy.Status.Conditions {
+		if deploy.Status.Conditions[i].Type == conditionRestartRecommended {
+			return &deploy.Status.Conditions[i]
+		}
+	}
+	return nil
+}
+
+func TestReconcile_NotifyOnly(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = autoapplyv1alpha1.AddToScheme(scheme)
+	fakeClient := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithIndex(&corev1.Pod{}, podConfigMapIndex, indexPodConfigMaps).
+		WithStatusSubresource(&appsv1.Deployment{}).
+		Build()
+	recorder := record.NewFakeRecorder(10)
+	r := &ConfigMapReconciler{Client: fakeClient, Scheme: scheme, Recorder: recorder}
+	ctx := context.Background()
+
+	_ = fakeClient.Create(ctx, &autoapplyv1alpha1.AutoApplyConfig{
+		ObjectMeta: metav1.ObjectMeta{Name: "notify"},
+		Spec:       autoapplyv1alpha1.AutoApplyConfigSpec{NotifyOnly: ptr.To(true)},
+	})
+	_ = fakeClient.Create(ctx, &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-config", Namespace: "default"},
+		Data:       map[string]string{"key": "new"},
+	})
+	ownerRefs := createDeploymentWithReplicaSet(ctx, fakeClient, &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"},
+	})
+	pod := podUsingConfigMap("web-old", "test-config", time.Now().Add(-time.Hour))
+	pod.OwnerReferences = ownerRefs
+	_ = fakeClient.Create(ctx, pod)
+
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: "test-config", Namespace: "default"}}
+	r.configMapVersions.Store(req.String(), "old-version")
+	if _, err := r.Reconcile(ctx, req); err != nil {
+		t.Fatalf("Reconcile failed: %v", err)
+	}
+
+	if countDefaultPods(t, fakeClient) != 1 {
+		t.Fatal("Expected no pod to be deleted in notify-only mode")
+	}
+	found := false
+	for len(recorder.Events) > 0 {
+		if event := <-recorder.Events; strings.Contains(event, "RestartRecommended") && strings.Contains(event, "web-old") {
+			found = true
+		}
+	}
+	if !found {
+		t.Error("Expected a RestartRecommended event listing web-old")
+	}
+	condition := restartRecommendedCondition(t, fakeClient)
+	if condition == nil || condition.Status != corev1.ConditionTrue || condition.Reason != reasonConfigMapChanged {
+		t.Fatalf("Expected RestartRecommended=True on the Deployment, got %+v", condition)
+	}
+
+	// The still running pod keeps the recommendation
+	r.scanStaleConfig(ctx)
+	if condition := restartRecommendedCondition(t, fakeClient); condition.Status != corev1.ConditionTrue {
+		t.Fatalf("Expected RestartRecommended to stay True, got %+v", condition)
+	}
+
+	// Once the pod is replaced the workload runs the latest config
+	_ = fakeClient.Delete(ctx, pod)
+	replacement := podUsingConfigMap("web-new", "test-config", time.Now().Add(time.Minute))
+	replacement.OwnerReferences = ownerRefs
+	_ = fakeClient.Create(ctx, replacement)
+	r.scanStaleConfig(ctx)
+	if condition := restartRecommendedCondition(t, fakeClient); condition.Status != corev1.ConditionFalse || condition.Reason != reasonConfigApplied {
+		t.Errorf("Expected RestartRecommended=False once replaced, got %+v", condition)
+	}
+}
@@ -0,0 +1,24 @@
+package controller
+
+import (
+	"sync"
+	"time"
+)
+
+// Delay before a change waiting for another restart in its namespace is retried
+const namespaceLockRetryInterval = 10 * time.Second
+
+// tryLockNamespace takes the restart lock of a namespace, returning the
+// unlock func, or nil if another restart in the namespace holds it
+func (r *ConfigMapReconciler) tryLockNamespace(namespace string) func() {
+	if !r.SerializeNamespaces {
+		return func() {}
+	}
+
+	value, _ := r.namespaceLocks.LoadOrStore(namespace, &sync.Mutex{})
+	lock := value.(*sync.Mutex)
+	if !lock.TryLock() {
+		return nil
+	}
+	return lock.Unlock
+}
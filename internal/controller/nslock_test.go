@@ -0,0 +1,137 @@
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestTryLockNamespace(t *testing.T) {
+	r := &ConfigMapReconciler{SerializeNamespaces: true}
+
+	unlock := r.tryLockNamespace("default")
+	if unlock == nil {
+		t.Fatal("Expected to lock a free namespace")
+	}
+	if r.tryLockNamespace("default") != nil {
+		t.Error("Expected the namespace to stay locked")
+	}
+	other := r.tryLockNamespace("other")
+	if other == nil {
+		t.Fatal("Expected other namespaces to be independent")
+	}
+	other()
+
+	unlock()
+	if unlock = r.tryLockNamespace("default"); unlock == nil {
+		t.Error("Expected the namespace to be free after unlocking")
+	}
+	unlock()
+
+	r.SerializeNamespaces = false
+	if r.tryLockNamespace("default") == nil || r.tryLockNamespace("default") == nil {
+		t.Error("Expected no locking when serialization is off")
+	}
+}
+
+func TestReconcile_WaitsForNamespaceLock(t *testing.T) {
+	r, fakeClient := setupTestReconciler()
+	r.SerializeNamespaces = true
+	ctx := context.Background()
+
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: "test-config", Namespace: "default"}}
+	r.configMapVersions.Store(req.String(), "old-version")
+
+	_ = fakeClient.Create(ctx, &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-config", Namespace: "default"},
+		Data:       map[string]string{"key": "value"},
+	})
+	_ = fakeClient.Create(ctx, &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-pod", Namespace: "default"},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{Name: "app", Image: "nginx"}},
+			Volumes: []corev1.Volume{{
+				Name: "config",
+				VolumeSource: corev1.VolumeSource{
+					ConfigMap: &corev1.ConfigMapVolumeSource{
+						LocalObjectReference: corev1.LocalObjectReference{Name: "test-config"},
+					},
+				},
+			}},
+		},
+		Status: corev1.PodStatus{Phase: corev1.PodRunning},
+	})
+
+	// Another ConfigMap's restart holds the namespace
+	unlock := r.tryLockNamespace("default")
+
+	result, err := r.Reconcile(ctx, req)
+	if err != nil {
+		t.Fatalf("Reconcile failed: %v", err)
+	}
+	if result.RequeueAfter != namespaceLockRetryInterval {
+		t.Errorf("Expected requeue after %v, got %v", namespaceLockRetryInterval, result.RequeueAfter)
+	}
+	var pods corev1.PodList
+	_ = fakeClient.List(ctx, &pods, client.InNamespace("default"))
+	if len(pods.Items) != 1 {
+		t.Fatalf("Expected pod to wait for the lock, found %d pods", len(pods.Items))
+	}
+
+	unlock()
+	if _, err := r.Reconcile(ctx, req); err != nil {
+		t.Fatalf("Reconcile failed: %v", err)
+	}
+	_ = fakeClient.List(ctx, &pods, client.InNamespace("default"))
+	if len(pods.Items) != 0 {
+		t.Errorf("Expected pod to be restarted once the lock was free, found %d pods", len(pods.Items))
+	}
+}
+
+func TestReconcile_HoldsNamespaceLockUntilRestartFinishes(t *testing.T) {
+	r, fakeClient := setupTestReconciler()
+	r.SerializeNamespaces = true
+	ctx := context.Background()
+
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: "test-config", Namespace: "default"}}
+	r.configMapVersions.Store(req.String(), "old-version")
+	_ = fakeClient.Create(ctx, &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-config", Namespace: "default"},
+		Data:       map[string]string{"key": "value"},
+	})
+	pods := createOwnedPods(ctx, fakeClient, "deploy-a", 2)
+	for i := range pods {
+		pods[i].Spec.Volumes = []corev1.Volume{{Name: "config", VolumeSource: corev1.VolumeSource{
+			ConfigMap: &corev1.ConfigMapVolumeSource{LocalObjectReference: corev1.LocalObjectReference{Name: "test-config"}},
+		}}}
+		_ = fakeClient.Update(ctx, &pods[i])
+	}
+
+	result, err := r.Reconcile(ctx, req)
+	if err != nil || result.RequeueAfter == 0 {
+		t.Fatalf("Expected the restart to continue on requeue, got %v, %v", result, err)
+	}
+	if unlock := r.tryLockNamespace("default"); unlock != nil {
+		unlock()
+		t.Fatal("Expected the running restart to hold the namespace")
+	}
+
+	for i := 0; result.RequeueAfter > 0; i++ {
+		if i == 20 {
+			t.Fatal("Expected the restart to finish")
+		}
+		time.Sleep(result.RequeueAfter)
+		if result, err = r.Reconcile(ctx, req); err != nil {
+			t.Fatalf("Reconcile failed: %v", err)
+		}
+	}
+	if unlock := r.tryLockNamespace("default"); unlock == nil {
+		t.Error("Expected the namespace to be free once the restart finished")
+	}
+}
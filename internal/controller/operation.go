@@ -0,0 +1,240 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	autoapplyv1alpha1 "github.com/manos/k8s-autoapply-operator/api/v1alpha1"
+)
+
+// +kubebuilder:rbac:groups=autoapply.io,resources=restartoperations,verbs=get;list;watch;create;delete
+// +kubebuilder:rbac:groups=autoapply.io,resources=restartoperations/status,verbs=get;update;patch
+
+const (
+	// Label linking a RestartOperation to the ConfigMap that triggered it
+	operationConfigMapLabel = "autoapply.io/configmap"
+	// RestartOperations kept per ConfigMap, older ones are deleted
+	maxRestartOperations = 10
+)
+
+// operationRecord is the RestartOperation of an in-progress restart. Owners
+// restart concurrently, so updates are serialized by mu.
+type operationRecord struct {
+	mu sync.Mutex
+	op *autoapplyv1alpha1.RestartOperation
+}
+
+// startOperation creates the RestartOperation recording a restart of pods
+// after the given keys changed
+func (r *ConfigMapReconciler) startOperation(ctx context.Context, cfg operatorConfig, configMap *corev1.ConfigMap, version string, changes keyChanges, pods []corev1.Pod) {
+	if !r.RecordOperations {
+		return
+	}
+	logger := log.FromContext(ctx)
+
+	// An approved operation already records this change
+	if value, ok := r.operations.Load(operationKey(configMap)); ok && value.(*operationRecord).op.Spec.ConfigMapVersion == version {
+		return
+	}
+
+	op := &autoapplyv1alpha1.RestartOperation{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: configMap.Name + "-",
+			Namespace:    configMap.Namespace,
+			Labels:       map[string]string{operationConfigMapLabel: configMap.Name},
+		},
+		Spec: autoapplyv1alpha1.RestartOperationSpec{
+			ConfigMapName:    configMap.Name,
+			ConfigMapVersion: version,
+			Strategy:         cfg.strategy,
+			YoloMode:         cfg.yoloMode,
+			Pods:             podNames(pods),
+			ChangedKeys:      changes.apiChangedKeys(),
+		},
+	}
+	// Owned by the ConfigMap so records go away with it
+	if err := controllerutil.SetOwnerReference(configMap, op, r.Scheme); err != nil {
+		logger.Error(err, "Failed to set RestartOperation owner")
+	}
+	if err := r.Create(ctx, op); err != nil {
+		logger.Error(err, "Failed to create RestartOperation")
+		return
+	}
+
+	now := metav1.Now()
+	op.Status = autoapplyv1alpha1.RestartOperationStatus{
+		Phase:     autoapplyv1alpha1.RestartOperationRunning,
+		StartTime: &now,
+	}
+	record := &operationRecord{op: op}
+	r.operations.Store(operationKey(configMap), record)
+	r.patchOperationStatus(ctx, op)
+	r.pruneOperations(ctx, configMap)
+}
+
+// operationName returns the name of the ConfigMap's in-progress RestartOperation, if any
+func (r *ConfigMapReconciler) operationName(configMap *corev1.ConfigMap) string {
+	value, ok := r.operations.Load(operationKey(configMap))
+	if !ok {
+		return ""
+	}
+	return value.(*operationRecord).op.Name
+}
+
+// resumeOperation picks up recording a restart a previous operator instance
+// was in the middle of, if its RestartOperation is still running
+func (r *ConfigMapReconciler) resumeOperation(ctx context.Context, configMap *corev1.ConfigMap, name string) {
+	op := &autoapplyv1alpha1.RestartOperation{}
+	if err := r.Get(ctx, client.ObjectKey{Namespace: configMap.Namespace, Name: name}, op); err != nil {
+		log.FromContext(ctx).Error(err, "Failed to get RestartOperation to resume", "operation", name)
+		return
+	}
+	if op.Status.Phase != autoapplyv1alpha1.RestartOperationRunning {
+		return
+	}
+	r.operations.Store(operationKey(configMap), &operationRecord{op: op})
+}
+
+// recordBatch adds a restarted batch to the in-progress RestartOperation
+func (r *ConfigMapReconciler) recordBatch(ctx context.Context, configMap *corev1.ConfigMap, restarted []corev1.Pod) {
+	if len(restarted) == 0 {
+		return
+	}
+	r.updateOperation(ctx, configMap, func(status *autoapplyv1alpha1.RestartOperationStatus) {
+		status.Batches = append(status.Batches, autoapplyv1alpha1.RestartBatch{
+			Time: metav1.Now(),
+			Pods: podNames(restarted),
+		})
+	})
+}
+
+// recordSkip adds a skipped pod to the in-progress RestartOperation
+func (r *ConfigMapReconciler) recordSkip(ctx context.Context, configMap *corev1.ConfigMap, pod *corev1.Pod, reason string) {
+	r.updateOperation(ctx, configMap, func(status *autoapplyv1alpha1.RestartOperationStatus) {
+		for _, skipped := range status.SkippedPods {
+			if skipped.Name == pod.Name {
+				return
+			}
+		}
+		status.SkippedPods = append(status.SkippedPods, autoapplyv1alpha1.SkippedPod{Name: pod.Name, Reason: reason})
+	})
+}
+
+// finishOperation sets the final phase and, if the change reached every
+// selected pod, its propagation time on the in-progress RestartOperation
+func (r *ConfigMapReconciler) finishOperation(ctx context.Context, configMap *corev1.ConfigMap, err error, propagation time.Duration) {
+	if _, ok := r.operations.Load(operationKey(configMap)); !ok {
+		return
+	}
+
+	r.updateOperation(ctx, configMap, func(status *autoapplyv1alpha1.RestartOperationStatus) {
+		now := metav1.Now()
+		status.CompletionTime = &now
+		if propagation > 0 {
+			status.PropagationTime = &metav1.Duration{Duration: propagation.Round(time.Second)}
+		}
+		switch {
+		case err == nil:
+			status.Phase = autoapplyv1alpha1.RestartOperationSucceeded
+		case len(status.Batches) > 0:
+			status.Phase = autoapplyv1alpha1.RestartOperationPartiallyCompleted
+			status.Message = err.Error()
+		default:
+			status.Phase = autoapplyv1alpha1.RestartOperationFailed
+			status.Message = err.Error()
+		}
+		if failing := replacementFailures(err); len(failing) > 0 {
+			status.FailingContainers = failing
+			meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+				Type:    autoapplyv1alpha1.ConditionDegraded,
+				Status:  metav1.ConditionTrue,
+				Reason:  failing[0].Reason,
+				Message: "Replacement pods fail to start: " + describeFailingContainers(failing),
+			})
+		}
+	})
+	r.operations.Delete(operationKey(configMap))
+}
+
+// updateOperation applies change to the in-progress RestartOperation status, if any
+func (r *ConfigMapReconciler) updateOperation(ctx context.Context, configMap *corev1.ConfigMap, change func(*autoapplyv1alpha1.RestartOperationStatus)) {
+	value, ok := r.operations.Load(operationKey(configMap))
+	if !ok {
+		return
+	}
+	record := value.(*operationRecord)
+
+	record.mu.Lock()
+	defer record.mu.Unlock()
+	change(&record.op.Status)
+	r.patchOperationStatus(ctx, record.op)
+}
+
+// patchOperationStatus writes the whole status, so a failed write is made up
+// for by the next one. Failures are logged and returned.
+func (r *ConfigMapReconciler) patchOperationStatus(ctx context.Context, op *autoapplyv1alpha1.RestartOperation) error {
+	patch, err := json.Marshal(map[string]any{"status": op.Status})
+	if err == nil {
+		err = r.Status().Patch(ctx, op, client.RawPatch(types.MergePatchType, patch))
+	}
+	if err != nil {
+		log.FromContext(ctx).Error(err, "Failed to update RestartOperation", "operation", op.Name)
+	}
+	return err
+}
+
+// pruneOperations deletes the oldest RestartOperations of a ConfigMap beyond
+// maxRestartOperations. It runs whenever one is created. Operations still
+// running or awaiting approval are kept.
+func (r *ConfigMapReconciler) pruneOperations(ctx context.Context, configMap *corev1.ConfigMap) {
+	logger := log.FromContext(ctx)
+
+	var ops autoapplyv1alpha1.RestartOperationList
+	if err := r.List(ctx, &ops, client.InNamespace(configMap.Namespace),
+		client.MatchingLabels{operationConfigMapLabel: configMap.Name}); err != nil {
+		logger.Error(err, "Failed to list RestartOperations")
+		return
+	}
+	if len(ops.Items) <= maxRestartOperations {
+		return
+	}
+
+	sort.Slice(ops.Items, func(i, j int) bool {
+		return startTime(&ops.Items[i]).Before(startTime(&ops.Items[j]))
+	})
+	excess := len(ops.Items) - maxRestartOperations
+	for i := 0; i < len(ops.Items) && excess > 0; i++ {
+		switch ops.Items[i].Status.Phase {
+		case autoapplyv1alpha1.RestartOperationRunning, autoapplyv1alpha1.RestartOperationAwaitingApproval:
+			continue
+		}
+		if err := r.Delete(ctx, &ops.Items[i]); client.IgnoreNotFound(err) != nil {
+			logger.Error(err, "Failed to delete RestartOperation", "operation", ops.Items[i].Name)
+		}
+		excess--
+	}
+}
+
+// startTime returns when a RestartOperation started, falling back to its creation
+func startTime(op *autoapplyv1alpha1.RestartOperation) *metav1.Time {
+	if op.Status.StartTime != nil {
+		return op.Status.StartTime
+	}
+	return &op.CreationTimestamp
+}
+
+// operationKey is the key of a ConfigMap's in-progress RestartOperation
+func operationKey(configMap *corev1.ConfigMap) string {
+	return client.ObjectKeyFromObject(configMap).String()
+}
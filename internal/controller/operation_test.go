@@ -0,0 +1,184 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	autoapplyv1alpha1 "github.com/manos/k8s-autoapply-operator/api/v1alpha1"
+)
+
+func listOperations(t *testing.T, c client.Client) []autoapplyv1alpha1.RestartOperation {
+	t.Helper()
+	var ops autoapplyv1alpha1.RestartOperationList
+	if err := c.List(context.Background(), &ops, client.InNamespace("default")); err != nil {
+		t.Fatalf("Failed to list RestartOperations: %v", err)
+	}
+	return ops.Items
+}
+
+func TestRestartOperation_RecordsRestart(t *testing.T) {
+	r, fakeClient := setupTestReconciler()
+	r.RecordOperations = true
+	ctx := context.Background()
+
+	cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "test-config", Namespace: "default", UID: "cm-uid"}}
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "test-pod", Namespace: "default"}}
+	_ = fakeClient.Create(ctx, pod)
+
+	if err := runRestart(ctx, r, r.loadConfig(ctx, nil), cm, "v2", []corev1.Pod{*pod}); err != nil {
+		t.Fatalf("Restart failed: %v", err)
+	}
+	r.skipPod(ctx, cm, &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "blocked"}}, "blocked by PodDisruptionBudget")
+
+	ops := listOperations(t, fakeClient)
+	if len(ops) != 1 {
+		t.Fatalf("Expected one RestartOperation, got %d", len(ops))
+	}
+	op := ops[0]
+	if op.Spec.ConfigMapName != "test-config" || op.Spec.ConfigMapVersion != "v2" || len(op.Spec.Pods) != 1 {
+		t.Errorf("Unexpected spec: %+v", op.Spec)
+	}
+	if op.Labels[operationConfigMapLabel] != "test-config" || len(op.OwnerReferences) != 1 {
+		t.Errorf("Expected the operation to be labelled and owned by the ConfigMap, got %v %v", op.Labels, op.OwnerReferences)
+	}
+	if op.Status.Phase != autoapplyv1alpha1.RestartOperationRunning || op.Status.StartTime == nil {
+		t.Errorf("Expected a running operation, got %+v", op.Status)
+	}
+	if len(op.Status.Batches) != 1 || len(op.Status.SkippedPods) != 1 {
+		t.Errorf("Expected one batch and one skipped pod, got %+v", op.Status)
+	}
+
+	r.completeRestart(ctx, cm, nil)
+
+	op = listOperations(t, fakeClient)[0]
+	if op.Status.Phase != autoapplyv1alpha1.RestartOperationSucceeded || op.Status.CompletionTime == nil {
+		t.Errorf("Expected a succeeded operation, got %+v", op.Status)
+	}
+}
+
+func TestRestartOperation_FinalPhase(t *testing.T) {
+	tests := []struct {
+		name     string
+		restart  bool
+		err      error
+		expected autoapplyv1alpha1.RestartOperationPhase
+	}{
+		{"succeeded", true, nil, autoapplyv1alpha1.RestartOperationSucceeded},
+		{"partially completed", true, errors.New("unhealthy"), autoapplyv1alpha1.RestartOperationPartiallyCompleted},
+		{"failed", false, errors.New("unhealthy"), autoapplyv1alpha1.RestartOperationFailed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, fakeClient := setupTestReconciler()
+			r.RecordOperations = true
+			ctx := context.Background()
+
+			cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "test-config", Namespace: "default", UID: "cm-uid"}}
+			pod := corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "test-pod", Namespace: "default"}}
+
+			r.startOperation(ctx, operatorConfig{}, cm, "v2", keyChanges{}, []corev1.Pod{pod})
+			if tt.restart {
+				r.recordBatch(ctx, cm, []corev1.Pod{pod})
+			}
+			r.completeRestart(ctx, cm, tt.err)
+
+			op := listOperations(t, fakeClient)[0]
+			if op.Status.Phase != tt.expected {
+				t.Errorf("Expected phase %s, got %s", tt.expected, op.Status.Phase)
+			}
+		})
+	}
+}
+
+func TestRestartOperation_Prune(t *testing.T) {
+	r, fakeClient := setupTestReconciler()
+	r.RecordOperations = true
+	ctx := context.Background()
+
+	cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "test-config", Namespace: "default", UID: "cm-uid"}}
+	for range maxRestartOperations + 2 {
+		r.startOperation(ctx, operatorConfig{}, cm, "v2", keyChanges{}, nil)
+		r.completeRestart(ctx, cm, nil)
+	}
+
+	if ops := listOperations(t, fakeClient); len(ops) != maxRestartOperations {
+		t.Errorf("Expected %d RestartOperations to be kept, got %d", maxRestartOperations, len(ops))
+	}
+}
+
+func TestRestartOperation_PrunedOnCreation(t *testing.T) {
+	r, fakeClient := setupTestReconciler()
+	r.RecordOperations = true
+	ctx := context.Background()
+
+	// Expired approval requests are never finished like restarts are
+	cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "test-config", Namespace: "default", UID: "cm-uid"}}
+	for range maxRestartOperations + 2 {
+		op := r.requestApproval(ctx, operatorConfig{}, cm, "v2", nil)
+		if op == nil {
+			t.Fatal("Expected an approval request")
+		}
+		r.expireApproval(ctx, op, "superseded")
+	}
+	if ops := listOperations(t, fakeClient); len(ops) != maxRestartOperations {
+		t.Errorf("Expected %d RestartOperations to be kept, got %d", maxRestartOperations, len(ops))
+	}
+
+	// The operations in progress are kept even while older ones are pruned
+	pending := r.requestApproval(ctx, operatorConfig{}, cm, "v3", nil)
+	r.startOperation(ctx, operatorConfig{}, cm, "v4", keyChanges{}, nil)
+	ops := listOperations(t, fakeClient)
+	if len(ops) != maxRestartOperations {
+		t.Errorf("Expected %d RestartOperations to be kept, got %d", maxRestartOperations, len(ops))
+	}
+	phases := make(map[string]autoapplyv1alpha1.RestartOperationPhase)
+	for _, op := range ops {
+		phases[op.Name] = op.Status.Phase
+	}
+	if phases[pending.Name] != autoapplyv1alpha1.RestartOperationAwaitingApproval || phases[r.operationName(cm)] != autoapplyv1alpha1.RestartOperationRunning {
+		t.Errorf("Expected the pending and running operations to be kept, got %v", phases)
+	}
+}
+
+func TestRestartOperation_ResumedAfterOperatorRestart(t *testing.T) {
+	r, fakeClient := setupTestReconciler()
+	r.RecordOperations = true
+	ctx := context.Background()
+
+	cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "test-config", Namespace: "default", UID: "cm-uid"}}
+	r.startOperation(ctx, operatorConfig{}, cm, "v2", keyChanges{}, nil)
+	name := r.operationName(cm)
+	if name == "" {
+		t.Fatal("Expected the in-progress RestartOperation to be tracked")
+	}
+
+	// A new operator finishes the operation the progress names
+	resumed, _ := setupTestReconciler()
+	resumed.Client = fakeClient
+	resumed.resumeOperation(ctx, cm, name)
+	resumed.completeRestart(ctx, cm, nil)
+
+	op := listOperations(t, fakeClient)[0]
+	if op.Status.Phase != autoapplyv1alpha1.RestartOperationSucceeded {
+		t.Errorf("Expected the resumed operation to succeed, got %s", op.Status.Phase)
+	}
+}
+
+func TestRestartOperation_Disabled(t *testing.T) {
+	r, fakeClient := setupTestReconciler()
+	ctx := context.Background()
+
+	cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "test-config", Namespace: "default", UID: "cm-uid"}}
+	r.startOperation(ctx, operatorConfig{}, cm, "v2", keyChanges{}, nil)
+	r.completeRestart(ctx, cm, nil)
+
+	if ops := listOperations(t, fakeClient); len(ops) != 0 {
+		t.Errorf("Expected no RestartOperations, got %d", len(ops))
+	}
+}
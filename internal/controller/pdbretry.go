@@ -0,0 +1,194 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+const (
+	// Default time pods blocked by a PDB are retried after a restart
+	defaultPDBRetryWindow = 1 * time.Hour
+	// First delay before retrying pods blocked by a PDB, doubled per attempt
+	pdbRetryInitialBackoff = 30 * time.Second
+	// Max delay between retries of pods blocked by a PDB
+	pdbRetryMaxBackoff = 5 * time.Minute
+)
+
+// pdbRetry tracks the pods a PodDisruptionBudget kept from being restarted
+type pdbRetry struct {
+	pods []corev1.Pod
+	// version is the ConfigMap version being retried, set once the restart
+	// that skipped the pods finished
+	version  string
+	deadline time.Time
+	attempts int
+	// recreating are restarted pods without a controller to create again
+	// once they're gone, restarted at recreateSince
+	recreating    []corev1.Pod
+	recreateSince time.Time
+}
+
+// trackBlockedPod remembers a pod skipped because a PDB blocked its eviction
+func (r *ConfigMapReconciler) trackBlockedPod(configMap *corev1.ConfigMap, pod corev1.Pod) {
+	value, _ := r.pdbRetries.LoadOrStore(client.ObjectKeyFromObject(configMap).String(), &pdbRetry{})
+	retry := value.(*pdbRetry)
+	retry.pods = append(retry.pods, pod)
+}
+
+// retryingBlockedPods checks if blocked pods of the ConfigMap version are
+// waiting to be retried
+func (r *ConfigMapReconciler) retryingBlockedPods(key, version string) bool {
+	value, ok := r.pdbRetries.Load(key)
+	return ok && value.(*pdbRetry).version == version
+}
+
+// scheduleBlockedPodRetry schedules a retry of the pods a PDB blocked during
+// a finished restart of the ConfigMap version
+func (r *ConfigMapReconciler) scheduleBlockedPodRetry(ctx context.Context, cfg operatorConfig, key, version string) ctrl.Result {
+	value, ok := r.pdbRetries.Load(key)
+	if !ok {
+		return ctrl.Result{}
+	}
+	if cfg.pdbRetryWindow <= 0 {
+		r.pdbRetries.Delete(key)
+		return ctrl.Result{}
+	}
+
+	retry := value.(*pdbRetry)
+	retry.version = version
+	retry.deadline = time.Now().Add(cfg.pdbRetryWindow)
+	retry.attempts = 0
+
+	log.FromContext(ctx).Info("Retrying pods blocked by PodDisruptionBudget later",
+		"count", len(retry.pods),
+		"window", cfg.pdbRetryWindow)
+	return ctrl.Result{RequeueAfter: pdbRetryInitialBackoff}
+}
+
+// retryBlockedPods tries again to evict the pods a PDB blocked, requeueing
+// with backoff until they're all restarted or the retry window has passed
+func (r *ConfigMapReconciler) retryBlockedPods(ctx context.Context, cfg operatorConfig, configMap *corev1.ConfigMap, key string) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+
+	value, _ := r.pdbRetries.Load(key)
+	retry := value.(*pdbRetry)
+
+	// Bare pods the last attempt restarted are created again first
+	if len(retry.recreating) > 0 {
+		terminating, err := r.recreateBarePods(ctx, configMap, retry.recreating, retry.recreateSince)
+		if err != nil {
+			logger.Error(err, "Failed to re-create pods previously blocked by PodDisruptionBudget")
+		}
+		if retry.recreating = terminating; len(terminating) > 0 {
+			return ctrl.Result{RequeueAfter: pollInterval}, nil
+		}
+	}
+
+	var blocked, restarted, held []corev1.Pod
+	var wait time.Duration
+	for i, pod := range retry.pods {
+		var current corev1.Pod
+		if err := r.Get(ctx, client.ObjectKeyFromObject(&pod), &current); err != nil {
+			if !apierrors.IsNotFound(err) {
+				logger.Error(err, "Failed to get pod", "pod", pod.Name)
+				blocked = append(blocked, pod)
+			}
+			continue
+		}
+		// Replaced or on its way out since it was blocked
+		if current.UID != pod.UID || current.DeletionTimestamp != nil {
+			continue
+		}
+
+		if wait = r.restartBudgetDelay(1); wait > 0 {
+			held = retry.pods[i:]
+			break
+		}
+		if err := r.evictPod(ctx, &current); err != nil {
+			if apierrors.IsNotFound(err) {
+				continue
+			}
+			if !apierrors.IsTooManyRequests(err) {
+				logger.Error(err, "Failed to evict pod", "pod", pod.Name)
+			}
+			blocked = append(blocked, current)
+			continue
+		}
+		r.recordPodRestarted(&current, configMap)
+		restarted = append(restarted, current)
+	}
+
+	if len(restarted) > 0 {
+		logger.Info("Restarted pods previously blocked by PodDisruptionBudget", "count", len(restarted))
+		r.Recorder.Eventf(configMap, corev1.EventTypeNormal, "BlockedPodsRestarted",
+			"Restarted %d pods previously blocked by PodDisruptionBudget: %s", len(restarted), listPodNames(restarted))
+		if cfg.recreateBarePods {
+			retry.recreating, retry.recreateSince = restarted, time.Now()
+		}
+	}
+
+	// The rest are tried once the restart budget has room, not counting as
+	// an attempt
+	if len(held) > 0 {
+		retry.pods = append(blocked, held...)
+		logger.V(1).Info("Waiting for the cluster-wide restart budget", "count", len(held), "delay", wait)
+		return ctrl.Result{RequeueAfter: wait}, nil
+	}
+
+	if len(blocked) > 0 && !time.Now().Before(retry.deadline) {
+		logger.Info("Giving up on pods blocked by PodDisruptionBudget", "count", len(blocked))
+		r.Recorder.Eventf(configMap, corev1.EventTypeWarning, "BlockedPodsNotRestarted",
+			"%d pods blocked by PodDisruptionBudget were not restarted within %s: %s",
+			len(blocked), cfg.pdbRetryWindow, listPodNames(blocked))
+		blocked = nil
+	}
+
+	if len(blocked) == 0 {
+		// Done once the restarted bare pods were created again
+		if len(retry.recreating) > 0 {
+			retry.pods = nil
+			return ctrl.Result{RequeueAfter: pollInterval}, nil
+		}
+		r.pdbRetries.Delete(key)
+		// Pods given up on never got the change
+		if time.Now().Before(retry.deadline) {
+			r.configPropagated(configMap)
+		}
+		return ctrl.Result{}, nil
+	}
+
+	retry.pods = blocked
+	retry.attempts++
+	delay := min(pdbRetryBackoff(retry.attempts), time.Until(retry.deadline))
+	logger.V(1).Info("Pods still blocked by PodDisruptionBudget", "count", len(blocked), "retryIn", delay)
+	return ctrl.Result{RequeueAfter: delay}, nil
+}
+
+// pdbRetryBackoff returns the delay before the given retry attempt
+func pdbRetryBackoff(attempts int) time.Duration {
+	delay := pdbRetryInitialBackoff
+	for range attempts {
+		delay *= 2
+		if delay >= pdbRetryMaxBackoff {
+			return pdbRetryMaxBackoff
+		}
+	}
+	return delay
+}
+
+// listPodNames joins pod names for an Event, listing at most maxListedPods
+func listPodNames(pods []corev1.Pod) string {
+	names := podNames(pods)
+	if len(names) > maxListedPods {
+		names = append(names[:maxListedPods], fmt.Sprintf("and %d more", len(pods)-maxListedPods))
+	}
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,212 @@
+package controller
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+
+	autoapplyv1alpha1 "github.com/manos/k8s-autoapply-operator/api/v1alpha1"
+)
+
+// setupPDBReconciler returns a reconciler whose evictions are rejected as a
+// PDB would do while *blocking is set
+func setupPDBReconciler(blocking *bool) (*ConfigMapReconciler, client.Client) {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = autoapplyv1alpha1.AddToScheme(scheme)
+
+	fakeClient := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithIndex(&corev1.Pod{}, podConfigMapIndex, indexPodConfigMaps).
+		WithInterceptorFuncs(interceptor.Funcs{
+			SubResourceCreate: func(ctx context.Context, c client.Client, subResourceName string, obj client.Object, subResource client.Object, opts ...client.SubResourceCreateOption) error {
+				if subResourceName == "eviction" && *blocking {
+					return apierrors.NewTooManyRequests("Cannot evict pod as it would violate the pod's disruption budget.", 0)
+				}
+				return c.SubResource(subResourceName).Create(ctx, obj, subResource, opts...)
+			},
+		}).
+		Build()
+	return &ConfigMapReconciler{Client: fakeClient, Scheme: scheme, Recorder: record.NewFakeRecorder(10)}, fakeClient
+}
+
+func TestPDBRetryBackoff(t *testing.T) {
+	expected := []time.Duration{30 * time.Second, time.Minute, 2 * time.Minute, 4 * time.Minute, 5 * time.Minute, 5 * time.Minute}
+	for attempts, delay := range expected {
+		if got := pdbRetryBackoff(attempts); got != delay {
+			t.Errorf("pdbRetryBackoff(%d) = %s, expected %s", attempts, got, delay)
+		}
+	}
+}
+
+func TestRetryBlockedPods(t *testing.T) {
+	blocking := true
+	r, fakeClient := setupPDBReconciler(&blocking)
+	ctx := context.Background()
+
+	cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "test-config", Namespace: "default"}}
+	key := client.ObjectKeyFromObject(cm).String()
+	cfg := operatorConfig{pdbRetryWindow: time.Hour}
+	for _, name := range []string{"pod-a", "pod-b"} {
+		pod := podUsingConfigMap(name, "test-config", time.Now())
+		_ = fakeClient.Create(ctx, pod)
+		r.trackBlockedPod(cm, *pod)
+	}
+
+	if result := r.scheduleBlockedPodRetry(ctx, cfg, key, "v2"); result.RequeueAfter != pdbRetryInitialBackoff {
+		t.Errorf("Expected a retry after %s, got %+v", pdbRetryInitialBackoff, result)
+	}
+	if !r.retryingBlockedPods(key, "v2") || r.retryingBlockedPods(key, "v3") {
+		t.Error("Expected only v2 to be retried")
+	}
+
+	// Still blocked, backs off
+	result, err := r.retryBlockedPods(ctx, cfg, cm, key)
+	if err != nil {
+		t.Fatalf("retryBlockedPods failed: %v", err)
+	}
+	if result.RequeueAfter != pdbRetryBackoff(1) {
+		t.Errorf("Expected a retry after %s, got %+v", pdbRetryBackoff(1), result)
+	}
+
+	// Disruptions allowed again
+	blocking = false
+	if result, _ := r.retryBlockedPods(ctx, cfg, cm, key); result.RequeueAfter != 0 {
+		t.Errorf("Expected no more retries, got %+v", result)
+	}
+	if _, ok := r.pdbRetries.Load(key); ok {
+		t.Error("Expected the retry to be done")
+	}
+
+	var pods corev1.PodList
+	_ = fakeClient.List(ctx, &pods)
+	if len(pods.Items) != 0 {
+		t.Errorf("Expected the blocked pods to be evicted, found %d", len(pods.Items))
+	}
+
+	recorder := r.Recorder.(*record.FakeRecorder)
+	var restarted bool
+	for len(recorder.Events) > 0 {
+		if strings.HasPrefix(<-recorder.Events, "Normal BlockedPodsRestarted Restarted 2 pods previously blocked") {
+			restarted = true
+		}
+	}
+	if !restarted {
+		t.Error("Expected a BlockedPodsRestarted event")
+	}
+}
+
+func TestRetryBlockedPods_GivesUpAfterWindow(t *testing.T) {
+	blocking := true
+	r, fakeClient := setupPDBReconciler(&blocking)
+	ctx := context.Background()
+
+	cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "test-config", Namespace: "default"}}
+	key := client.ObjectKeyFromObject(cm).String()
+	cfg := operatorConfig{pdbRetryWindow: time.Hour}
+	pod := podUsingConfigMap("pod-a", "test-config", time.Now())
+	_ = fakeClient.Create(ctx, pod)
+	r.trackBlockedPod(cm, *pod)
+	r.scheduleBlockedPodRetry(ctx, cfg, key, "v2")
+
+	value, _ := r.pdbRetries.Load(key)
+	value.(*pdbRetry).deadline = time.Now().Add(-time.Second)
+
+	if result, _ := r.retryBlockedPods(ctx, cfg, cm, key); result.RequeueAfter != 0 {
+		t.Errorf("Expected no more retries, got %+v", result)
+	}
+	if _, ok := r.pdbRetries.Load(key); ok {
+		t.Error("Expected the retry to be given up")
+	}
+
+	expected := "Warning BlockedPodsNotRestarted 1 pods blocked by PodDisruptionBudget were not restarted within 1h0m0s: pod-a"
+	if event := <-r.Recorder.(*record.FakeRecorder).Events; event != expected {
+		t.Errorf("Expected event %q, got %q", expected, event)
+	}
+}
+
+func TestScheduleBlockedPodRetry_Disabled(t *testing.T) {
+	r, _ := setupTestReconciler()
+	ctx := context.Background()
+
+	cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "test-config", Namespace: "default"}}
+	key := client.ObjectKeyFromObject(cm).String()
+	r.trackBlockedPod(cm, *podUsingConfigMap("pod-a", "test-config", time.Now()))
+
+	if result := r.scheduleBlockedPodRetry(ctx, operatorConfig{}, key, "v2"); result.RequeueAfter != 0 {
+		t.Errorf("Expected no retry, got %+v", result)
+	}
+	if _, ok := r.pdbRetries.Load(key); ok {
+		t.Error("Expected blocked pods to be forgotten")
+	}
+}
+
+func TestReconcile_RetriesOnlyBlockedPods(t *testing.T) {
+	blocking := false
+	r, fakeClient := setupPDBReconciler(&blocking)
+	ctx := context.Background()
+
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: "test-config", Namespace: "default"}}
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-config", Namespace: "default"},
+		Data:       map[string]string{"key": "value"},
+	}
+	_ = fakeClient.Create(ctx, cm)
+	version := r.changeDetector(operatorConfig{}).Version(cm)
+	r.configMapVersions.Store(req.String(), version)
+
+	blocked := podUsingConfigMap("blocked-pod", "test-config", time.Now())
+	_ = fakeClient.Create(ctx, blocked)
+	_ = fakeClient.Create(ctx, podUsingConfigMap("restarted-pod", "test-config", time.Now()))
+	r.trackBlockedPod(cm, *blocked)
+	r.scheduleBlockedPodRetry(ctx, operatorConfig{pdbRetryWindow: time.Hour}, req.String(), version)
+
+	if _, err := r.Reconcile(ctx, req); err != nil {
+		t.Fatalf("Reconcile failed: %v", err)
+	}
+
+	var pods corev1.PodList
+	_ = fakeClient.List(ctx, &pods)
+	if len(pods.Items) != 1 || pods.Items[0].Name != "restarted-pod" {
+		t.Errorf("Expected only the blocked pod to be evicted, got %v", podNames(pods.Items))
+	}
+}
+
+func TestLoadConfig_PDBRetryWindow(t *testing.T) {
+	r, fakeClient := setupTestReconciler()
+	ctx := context.Background()
+
+	if cfg := r.loadConfig(ctx, nil); cfg.pdbRetryWindow != defaultPDBRetryWindow {
+		t.Errorf("Expected the default window, got %s", cfg.pdbRetryWindow)
+	}
+
+	// A configured window replaces the default, even a shorter one
+	_ = fakeClient.Create(ctx, &autoapplyv1alpha1.AutoApplyConfig{
+		ObjectMeta: metav1.ObjectMeta{Name: "disabled"},
+		Spec:       autoapplyv1alpha1.AutoApplyConfigSpec{PDBRetryWindow: &metav1.Duration{}},
+	})
+	if cfg := r.loadConfig(ctx, nil); cfg.pdbRetryWindow != 0 {
+		t.Errorf("Expected retries to be disabled, got %s", cfg.pdbRetryWindow)
+	}
+
+	_ = fakeClient.Create(ctx, &autoapplyv1alpha1.AutoApplyConfig{
+		ObjectMeta: metav1.ObjectMeta{Name: "long"},
+		Spec:       autoapplyv1alpha1.AutoApplyConfigSpec{PDBRetryWindow: &metav1.Duration{Duration: 2 * time.Hour}},
+	})
+	if cfg := r.loadConfig(ctx, nil); cfg.pdbRetryWindow != 2*time.Hour {
+		t.Errorf("Expected the longest window, got %s", cfg.pdbRetryWindow)
+	}
+}
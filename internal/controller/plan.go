@@ -0,0 +1,101 @@
+package controller
+
+import (
+	"context"
+	"sort"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	autoapplyv1alpha1 "github.com/manos/k8s-autoapply-operator/api/v1alpha1"
+)
+
+// ownerPlan is how one owner's pods are restarted
+type ownerPlan struct {
+	uid  types.UID
+	mode ownerRestartMode
+	// pods are all of the owner's pods selected for restart
+	pods    []corev1.Pod
+	batches [][]corev1.Pod
+	// cordoned are DaemonSet pods left running because their node is cordoned
+	cordoned []corev1.Pod
+
+	// deployment and workload are set for the Surge and Rollout modes
+	deployment *appsv1.Deployment
+	workload   *workloadRef
+}
+
+// wavePlan is the owners of one restart wave and how each is restarted
+type wavePlan struct {
+	wave   restartWave
+	owners []ownerPlan
+}
+
+// planWaves groups the pods' owners into restart waves and plans each
+// owner's batches
+func (r *ConfigMapReconciler) planWaves(ctx context.Context, cfg operatorConfig, pods []corev1.Pod) []wavePlan {
+	waves := r.restartWaves(ctx, pods)
+	if cfg.restartByPriority {
+		waves = splitWavesByPriority(waves)
+	}
+
+	plans := make([]wavePlan, 0, len(waves))
+	for _, wave := range waves {
+		uids := make([]types.UID, 0, len(wave.owners))
+		for uid := range wave.owners {
+			uids = append(uids, uid)
+		}
+		sort.Slice(uids, func(i, j int) bool { return uids[i] < uids[j] })
+
+		plan := wavePlan{wave: wave}
+		for _, uid := range uids {
+			plan.owners = append(plan.owners, r.planOwner(ctx, cfg, uid, wave.owners[uid]))
+		}
+		plans = append(plans, plan)
+	}
+	return plans
+}
+
+// planOwner decides how one owner's pods are restarted. Workloads are rolled
+// out as a unit with the Rollout strategy, DaemonSets restart node by node,
+// Deployments surge with the Surge strategy, and other owners restart in halves, a canary and the rest, or the
+// configured batch steps. A Deployment's batches are cut further so none
+// takes down more pods than its rollingUpdate allows.
+func (r *ConfigMapReconciler) planOwner(ctx context.Context, cfg operatorConfig, ownerUID types.UID, pods []corev1.Pod) ownerPlan {
+	plan := ownerPlan{uid: ownerUID, mode: ownerRestartBatches, pods: pods}
+
+	if cfg.strategy == autoapplyv1alpha1.RestartStrategyRollout && ownerUID != "" {
+		workload, err := r.resolveWorkload(ctx, &pods[0])
+		if err == nil && workload != nil && newRolloutObject(workload.Kind) != nil {
+			plan.mode, plan.workload = ownerRestartRollout, workload
+			plan.batches = [][]corev1.Pod{pods}
+			return plan
+		}
+	}
+
+	// DaemonSet pods are pinned to nodes, so they restart node by node instead
+	if owner := metav1.GetControllerOf(&pods[0]); owner != nil && owner.Kind == "DaemonSet" {
+		plan.mode = ownerRestartNodes
+		plan.batches, plan.cordoned = r.daemonSetBatches(ctx, cfg, owner, pods)
+		return plan
+	}
+
+	// Batches are cut from the front, so spread zones and nodes over them
+	pods = r.spreadByTopology(ctx, pods)
+
+	// Surging never reduces capacity, so it isn't bound by maxUnavailable
+	if cfg.strategy == autoapplyv1alpha1.RestartStrategySurge {
+		if deployment := r.surgeDeployment(ctx, &pods[0]); deployment != nil {
+			plan.mode, plan.deployment = ownerRestartSurge, deployment
+			plan.batches = ownerBatches(cfg.strategy, cfg.batchSteps, pods, 0)
+			return plan
+		}
+		log.FromContext(ctx).V(1).Info("Owner is not a Deployment, restarting in batches", "owner", ownerName(ownerUID))
+	}
+
+	plan.batches = ownerBatches(cfg.strategy, cfg.batchSteps, pods, r.deploymentMaxUnavailable(ctx, &pods[0]))
+	return plan
+}
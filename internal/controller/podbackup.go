@@ -0,0 +1,158 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/yaml"
+
+	autoapplyv1alpha1 "github.com/manos/k8s-autoapply-operator/api/v1alpha1"
+)
+
+// +kubebuilder:rbac:groups="",resources=configmaps,verbs=create;delete
+
+const (
+	// Label linking a pod backup ConfigMap to the ConfigMap whose change deleted the pods
+	podBackupOfLabel = "autoapply.io/pod-backup-of"
+	// Annotation with when a backup was taken, shared by its ConfigMaps
+	podBackupTakenAtAnnotation = "autoapply.io/backed-up-at"
+	// Backups kept per ConfigMap unless configured otherwise
+	defaultPodBackupKeep = 5
+	// Most pod manifest bytes per backup ConfigMap, below the API server's 1MiB object limit
+	maxPodBackupBytes = 900 * 1024
+)
+
+// mergePodBackup merges a config's backup settings into c, keeping the most
+// backups for the longest, or replacing c's settings when override is set
+func (c *operatorConfig) mergePodBackup(backup *autoapplyv1alpha1.PodBackup, override bool) {
+	keep := defaultPodBackupKeep
+	if backup.Keep > 0 {
+		keep = int(backup.Keep)
+	}
+	var maxAge time.Duration
+	if backup.MaxAge != nil {
+		maxAge = backup.MaxAge.Duration
+	}
+
+	if override || c.podBackupKeep == 0 {
+		c.podBackupKeep, c.podBackupMaxAge = keep, maxAge
+		return
+	}
+	c.podBackupKeep = max(c.podBackupKeep, keep)
+	// No age limit is the longest
+	if c.podBackupMaxAge != 0 && (maxAge == 0 || maxAge > c.podBackupMaxAge) {
+		c.podBackupMaxAge = maxAge
+	}
+}
+
+// backupPods snapshots pods into ConfigMaps before yolo mode deletes them,
+// one key per pod, then prunes backups beyond the retention limits. Large
+// backups are spread over several ConfigMaps taken at the same time.
+func (r *ConfigMapReconciler) backupPods(ctx context.Context, cfg operatorConfig, configMap *corev1.ConfigMap, pods []corev1.Pod) error {
+	logger := log.FromContext(ctx)
+	takenAt := time.Now().UTC().Format(time.RFC3339)
+
+	var backups []*corev1.ConfigMap
+	size := 0
+	for i := range pods {
+		data, err := yaml.Marshal(restorablePod(&pods[i]))
+		if err != nil {
+			return fmt.Errorf("serializing pod %s: %w", pods[i].Name, err)
+		}
+		if len(backups) == 0 || size+len(data) > maxPodBackupBytes {
+			backups = append(backups, &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{
+					GenerateName: configMap.Name + "-pod-backup-",
+					Namespace:    configMap.Namespace,
+					Labels:       map[string]string{podBackupOfLabel: configMap.Name},
+					Annotations:  map[string]string{podBackupTakenAtAnnotation: takenAt},
+				},
+				Data: make(map[string]string),
+			})
+			size = 0
+		}
+		backups[len(backups)-1].Data[pods[i].Name+".yaml"] = string(data)
+		size += len(data)
+	}
+
+	for _, backup := range backups {
+		if err := controllerutil.SetOwnerReference(configMap, backup, r.Scheme); err != nil {
+			logger.Error(err, "Failed to set pod backup owner")
+		}
+		if err := r.Create(ctx, backup); err != nil {
+			return fmt.Errorf("creating pod backup: %w", err)
+		}
+		logger.Info("Backed up pods", "backup", backup.Name, "pods", len(backup.Data))
+	}
+
+	r.prunePodBackups(ctx, cfg, configMap)
+	return nil
+}
+
+// restorablePod returns the pod without the fields the API server sets, so
+// its manifest can be created again as is. Owner references are dropped too:
+// pods of a controller are re-created by it, not from the backup.
+func restorablePod(pod *corev1.Pod) *corev1.Pod {
+	restorable := &corev1.Pod{
+		TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "Pod"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        pod.Name,
+			Namespace:   pod.Namespace,
+			Labels:      pod.Labels,
+			Annotations: pod.Annotations,
+		},
+		Spec: *pod.Spec.DeepCopy(),
+	}
+	// Let the scheduler place it again
+	restorable.Spec.NodeName = ""
+	// Can't be set on create, only added to a running pod
+	restorable.Spec.EphemeralContainers = nil
+	return restorable
+}
+
+// prunePodBackups deletes a ConfigMap's pod backups beyond the configured
+// count or older than the configured age
+func (r *ConfigMapReconciler) prunePodBackups(ctx context.Context, cfg operatorConfig, configMap *corev1.ConfigMap) {
+	logger := log.FromContext(ctx)
+
+	var backups corev1.ConfigMapList
+	if err := r.List(ctx, &backups, client.InNamespace(configMap.Namespace),
+		client.MatchingLabels{podBackupOfLabel: configMap.Name}); err != nil {
+		logger.Error(err, "Failed to list pod backups")
+		return
+	}
+
+	// Newest backup first
+	var taken []string
+	byTime := make(map[string][]corev1.ConfigMap)
+	for _, backup := range backups.Items {
+		at := backup.Annotations[podBackupTakenAtAnnotation]
+		if _, ok := byTime[at]; !ok {
+			taken = append(taken, at)
+		}
+		byTime[at] = append(byTime[at], backup)
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(taken)))
+
+	for i, at := range taken {
+		expired := false
+		if t, err := time.Parse(time.RFC3339, at); err == nil && cfg.podBackupMaxAge > 0 {
+			expired = time.Since(t) > cfg.podBackupMaxAge
+		}
+		if i < cfg.podBackupKeep && !expired {
+			continue
+		}
+		for j := range byTime[at] {
+			if err := r.Delete(ctx, &byTime[at][j]); client.IgnoreNotFound(err) != nil {
+				logger.Error(err, "Failed to delete pod backup", "backup", byTime[at][j].Name)
+			}
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package controller
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/ptr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	autoapplyv1alpha1 "github.com/manos/k8s-autoapply-operator/api/v1alpha1"
+)
+
+func listPodBackups(t *testing.T, c client.Client) []corev1.ConfigMap {
+	t.Helper()
+	var backups corev1.ConfigMapList
+	if err := c.List(context.Background(), &backups, client.HasLabels{podBackupOfLabel}); err != nil {
+		t.Fatalf("Failed to list pod backups: %v", err)
+	}
+	return backups.Items
+}
+
+func TestReconcile_BacksUpPodsBeforeYolo(t *testing.T) {
+	r, fakeClient := setupTestReconciler()
+	ctx := context.Background()
+
+	_ = fakeClient.Create(ctx, &autoapplyv1alpha1.AutoApplyConfig{
+		ObjectMeta: metav1.ObjectMeta{Name: "yolo"},
+		Spec: autoapplyv1alpha1.AutoApplyConfigSpec{
+			YoloMode:  ptr.To(true),
+			PodBackup: &autoapplyv1alpha1.PodBackup{},
+		},
+	})
+	_ = fakeClient.Create(ctx, &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-config", Namespace: "default", UID: "cm-uid"},
+		Data:       map[string]string{"key": "new"},
+	})
+	pod := podUsingConfigMap("standalone", "test-config", time.Now().Add(-time.Hour))
+	pod.Spec.NodeName = "node-1"
+	_ = fakeClient.Create(ctx, pod)
+
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: "test-config", Namespace: "default"}}
+	r.configMapVersions.Store(req.String(), "old-version")
+	if _, err := r.Reconcile(ctx, req); err != nil {
+		t.Fatalf("Reconcile failed: %v", err)
+	}
+
+	if countDefaultPods(t, fakeClient) != 0 {
+		t.Fatal("Expected the pod to be restarted")
+	}
+	backups := listPodBackups(t, fakeClient)
+	if len(backups) != 1 {
+		t.Fatalf("Expected one pod backup, got %d", len(backups))
+	}
+	manifest := backups[0].Data["standalone.yaml"]
+	if !strings.Contains(manifest, "kind: Pod") || !strings.Contains(manifest, "name: standalone") {
+		t.Errorf("Expected the pod manifest in the backup, got %q", manifest)
+	}
+	if strings.Contains(manifest, "node-1") || strings.Contains(manifest, "phase:") {
+		t.Errorf("Expected node and status to be left out, got %q", manifest)
+	}
+
+	// The backup itself isn't handled as a config change
+	cfg := r.loadConfig(ctx, &backups[0])
+	if !cfg.isConfigMapExcluded("default", backups[0].Name) {
+		t.Errorf("Expected pod backups to be excluded, got %s", backups[0].Name)
+	}
+}
+
+func TestPrunePodBackups(t *testing.T) {
+	r, fakeClient := setupTestReconciler()
+	ctx := context.Background()
+
+	configMap := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "test-config", Namespace: "default"}}
+	now := time.Now().UTC()
+	for i, age := range []time.Duration{time.Minute, time.Hour, 2 * time.Hour, 48 * time.Hour} {
+		_ = fakeClient.Create(ctx, &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{
+			Name:        "test-config-pod-backup-" + string(rune('a'+i)),
+			Namespace:   "default",
+			Labels:      map[string]string{podBackupOfLabel: "test-config"},
+			Annotations: map[string]string{podBackupTakenAtAnnotation: now.Add(-age).Format(time.RFC3339)},
+		}})
+	}
+
+	r.prunePodBackups(ctx, operatorConfig{podBackupKeep: 3}, configMap)
+	if backups := listPodBackups(t, fakeClient); len(backups) != 3 {
+		t.Fatalf("Expected 3 backups kept, got %d", len(backups))
+	}
+
+	r.prunePodBackups(ctx, operatorConfig{podBackupKeep: 3, podBackupMaxAge: 90 * time.Minute}, configMap)
+	backups := listPodBackups(t, fakeClient)
+	if len(backups) != 2 {
+		t.Fatalf("Expected backups older than the max age to be deleted, got %d", len(backups))
+	}
+	for _, backup := range backups {
+		if backup.Name == "test-config-pod-backup-c" {
+			t.Errorf("Expected the 2h old backup to be deleted")
+		}
+	}
+}
+
+func TestMergePodBackup(t *testing.T) {
+	var cfg operatorConfig
+	cfg.mergePodBackup(&autoapplyv1alpha1.PodBackup{MaxAge: &metav1.Duration{Duration: time.Hour}}, false)
+	if cfg.podBackupKeep != defaultPodBackupKeep || cfg.podBackupMaxAge != time.Hour {
+		t.Fatalf("Expected defaults with a 1h max age, got %d %v", cfg.podBackupKeep, cfg.podBackupMaxAge)
+	}
+	cfg.mergePodBackup(&autoapplyv1alpha1.PodBackup{Keep: 10}, false)
+	if cfg.podBackupKeep != 10 || cfg.podBackupMaxAge != 0 {
+		t.Errorf("Expected the most retentive settings to win, got %d %v", cfg.podBackupKeep, cfg.podBackupMaxAge)
+	}
+	cfg.mergePodBackup(&autoapplyv1alpha1.PodBackup{Keep: 2}, true)
+	if cfg.podBackupKeep != 2 {
+		t.Errorf("Expected a namespace config to override, got %d", cfg.podBackupKeep)
+	}
+}
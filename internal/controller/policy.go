@@ -0,0 +1,156 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	autoapplyv1alpha1 "github.com/manos/k8s-autoapply-operator/api/v1alpha1"
+)
+
+// PolicySchemaVersion identifies the layout of restart policy requests. Like
+// ActivitySchemaVersion it only changes when fields are removed or change
+// meaning.
+const PolicySchemaVersion = "autoapply.io/restart-policy/v1"
+
+const (
+	// How long the policy endpoint has to decide on a batch
+	policyTimeout = 10 * time.Second
+	// Skip reason of pods the policy dropped from a batch
+	skipReasonPolicy = "dropped by restart policy"
+)
+
+// RestartPolicy asks an external endpoint before every batch whether its pods
+// may be restarted. The endpoint allows the batch, denies it, or drops some
+// of its pods.
+type RestartPolicy struct {
+	URL    string
+	Client *http.Client
+	// FailOpen restarts batches the endpoint couldn't decide on, e.g. because
+	// it's down; by default they're denied
+	FailOpen bool
+}
+
+// PolicyRequest is the batch plan POSTed to the policy endpoint
+type PolicyRequest struct {
+	SchemaVersion string                         `json:"schemaVersion"`
+	Namespace     string                         `json:"namespace"`
+	ConfigMap     string                         `json:"configMap"`
+	ChangedKeys   *autoapplyv1alpha1.ChangedKeys `json:"changedKeys,omitempty"`
+	Pods          []PolicyPod                    `json:"pods"`
+}
+
+// PolicyPod is a pod in a batch with the workload managing it, if any
+type PolicyPod struct {
+	Name         string `json:"name"`
+	WorkloadKind string `json:"workloadKind,omitempty"`
+	WorkloadName string `json:"workloadName,omitempty"`
+}
+
+// PolicyDecision is the policy endpoint's answer
+type PolicyDecision struct {
+	Allowed bool   `json:"allowed"`
+	Reason  string `json:"reason,omitempty"`
+	// DropPods are left out of an allowed batch
+	DropPods []string `json:"dropPods,omitempty"`
+}
+
+// decide POSTs the plan and returns the endpoint's decision
+func (p *RestartPolicy) decide(ctx context.Context, plan PolicyRequest) (*PolicyDecision, error) {
+	body, err := json.Marshal(plan)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, policyTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.URL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := httpClient(p.Client).Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("policy endpoint returned %s", resp.Status)
+	}
+
+	var decision PolicyDecision
+	if err := json.NewDecoder(resp.Body).Decode(&decision); err != nil {
+		return nil, fmt.Errorf("decoding policy decision: %w", err)
+	}
+	return &decision, nil
+}
+
+// applyRestartPolicy returns the pods of a batch the restart policy lets
+// through, or an error if it denies the batch
+func (r *ConfigMapReconciler) applyRestartPolicy(ctx context.Context, cfg operatorConfig, configMap *corev1.ConfigMap, pods []corev1.Pod) ([]corev1.Pod, error) {
+	if r.Policy == nil || len(pods) == 0 {
+		return pods, nil
+	}
+	logger := log.FromContext(ctx)
+
+	plan := PolicyRequest{
+		SchemaVersion: PolicySchemaVersion,
+		Namespace:     configMap.Namespace,
+		ConfigMap:     configMap.Name,
+		ChangedKeys:   r.changedKeys(cfg, configMap).apiChangedKeys(),
+	}
+	for i := range pods {
+		pod := PolicyPod{Name: pods[i].Name}
+		if workload, err := r.resolveWorkload(ctx, &pods[i]); err == nil && workload != nil {
+			pod.WorkloadKind, pod.WorkloadName = workload.Kind, workload.Name
+		}
+		plan.Pods = append(plan.Pods, pod)
+	}
+
+	decision, err := r.Policy.decide(ctx, plan)
+	if err != nil {
+		if r.Policy.FailOpen {
+			logger.Error(err, "Restart policy undecided, restarting anyway")
+			return pods, nil
+		}
+		r.Recorder.Eventf(configMap, corev1.EventTypeWarning, "RestartPolicyFailed",
+			"Not restarting %d pods, the restart policy couldn't decide: %v", len(pods), err)
+		return nil, fmt.Errorf("restart policy: %w", err)
+	}
+
+	if !decision.Allowed {
+		logger.Info("Restart policy denied batch", "pods", len(pods), "reason", decision.Reason)
+		r.Recorder.Eventf(configMap, corev1.EventTypeWarning, "RestartDenied",
+			"Restart policy denied restarting %d pods: %s", len(pods), decision.Reason)
+		return nil, fmt.Errorf("restart denied by policy: %s", decision.Reason)
+	}
+
+	reason := skipReasonPolicy
+	if decision.Reason != "" {
+		reason += ": " + decision.Reason
+	}
+	var allowed, dropped []corev1.Pod
+	for _, pod := range pods {
+		if slices.Contains(decision.DropPods, pod.Name) {
+			r.skipPod(ctx, configMap, &pod, reason)
+			dropped = append(dropped, pod)
+			continue
+		}
+		allowed = append(allowed, pod)
+	}
+	if len(dropped) > 0 {
+		logger.Info("Restart policy dropped pods from batch", "pods", podNames(dropped), "reason", decision.Reason)
+		r.Recorder.Eventf(configMap, corev1.EventTypeNormal, "PodsDroppedByPolicy",
+			"Restart policy left %d pods running: %s", len(dropped), strings.Join(podNames(dropped), ", "))
+	}
+	return allowed, nil
+}
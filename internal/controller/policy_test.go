@@ -0,0 +1,78 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestApplyRestartPolicy(t *testing.T) {
+	r, fakeClient := setupTestReconciler()
+	ctx := context.Background()
+
+	var received PolicyRequest
+	decision := PolicyDecision{Allowed: true, Reason: "frozen", DropPods: []string{"web-2"}}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		_ = json.NewDecoder(req.Body).Decode(&received)
+		_ = json.NewEncoder(w).Encode(decision)
+	}))
+	defer server.Close()
+	r.Policy = &RestartPolicy{URL: server.URL}
+
+	ownerRefs := createDeploymentWithReplicaSet(ctx, fakeClient, &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"},
+	})
+	cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "test-config", Namespace: "default"}}
+	pods := []corev1.Pod{
+		{ObjectMeta: metav1.ObjectMeta{Name: "web-1", Namespace: "default", OwnerReferences: ownerRefs}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "web-2", Namespace: "default", OwnerReferences: ownerRefs}},
+	}
+
+	allowed, err := r.applyRestartPolicy(ctx, operatorConfig{}, cm, pods)
+	if err != nil {
+		t.Fatalf("Expected the batch to be allowed, got %v", err)
+	}
+	if len(allowed) != 1 || allowed[0].Name != "web-1" {
+		t.Errorf("Expected web-2 to be dropped, got %v", podNames(allowed))
+	}
+	if received.SchemaVersion != PolicySchemaVersion || received.ConfigMap != "test-config" || len(received.Pods) != 2 {
+		t.Fatalf("Unexpected policy request: %+v", received)
+	}
+	if pod := received.Pods[0]; pod.WorkloadKind != "Deployment" || pod.WorkloadName != "web" {
+		t.Errorf("Expected the pod's Deployment in the request, got %+v", pod)
+	}
+
+	decision = PolicyDecision{Allowed: false, Reason: "change freeze"}
+	if _, err := r.applyRestartPolicy(ctx, operatorConfig{}, cm, pods); err == nil {
+		t.Error("Expected a denied batch to fail")
+	}
+}
+
+func TestApplyRestartPolicy_Unreachable(t *testing.T) {
+	r, _ := setupTestReconciler()
+	ctx := context.Background()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "test-config", Namespace: "default"}}
+	pods := []corev1.Pod{{ObjectMeta: metav1.ObjectMeta{Name: "web-1", Namespace: "default"}}}
+
+	r.Policy = &RestartPolicy{URL: server.URL}
+	if _, err := r.applyRestartPolicy(ctx, operatorConfig{}, cm, pods); err == nil {
+		t.Error("Expected undecided batches to be denied by default")
+	}
+
+	r.Policy.FailOpen = true
+	if allowed, err := r.applyRestartPolicy(ctx, operatorConfig{}, cm, pods); err != nil || len(allowed) != 1 {
+		t.Errorf("Expected fail-open to restart the batch, got %v %v", podNames(allowed), err)
+	}
+}
@@ -0,0 +1,111 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+
+	autoapplyv1alpha1 "github.com/manos/k8s-autoapply-operator/api/v1alpha1"
+)
+
+// +kubebuilder:rbac:groups=authorization.k8s.io,resources=selfsubjectaccessreviews,verbs=create
+
+// Delay before a change that failed the permission preflight is retried
+const preflightRetryInterval = 1 * time.Minute
+
+// permission is an API access a restart needs in the ConfigMap's namespace
+type permission struct {
+	verb        string
+	group       string
+	resource    string
+	subresource string
+}
+
+func (p permission) String() string {
+	resource := p.resource
+	if p.subresource != "" {
+		resource += "/" + p.subresource
+	}
+	if p.group != "" {
+		resource += "." + p.group
+	}
+	return p.verb + " " + resource
+}
+
+// requiredPermissions lists what restarting pods with cfg needs
+func requiredPermissions(cfg operatorConfig) []permission {
+	perms := []permission{
+		{verb: "patch", resource: "configmaps"},
+		{verb: "list", resource: "pods"},
+	}
+	if cfg.yoloMode {
+		return append(perms, permission{verb: "delete", resource: "pods"})
+	}
+	perms = append(perms, permission{verb: "create", resource: "pods", subresource: "eviction"})
+	if cfg.strategy == autoapplyv1alpha1.RestartStrategyRollout {
+		for _, resource := range []string{"deployments", "statefulsets", "daemonsets"} {
+			perms = append(perms, permission{verb: "patch", group: "apps", resource: resource})
+		}
+	}
+	if cfg.strategy == autoapplyv1alpha1.RestartStrategySurge {
+		perms = append(perms,
+			permission{verb: "patch", group: "apps", resource: "deployments"},
+			permission{verb: "patch", resource: "pods"})
+	}
+	return perms
+}
+
+// missingPermissions checks every permission a restart needs with
+// SelfSubjectAccessReviews, so all gaps are reported at once instead of
+// failing pod by pod
+func (r *ConfigMapReconciler) missingPermissions(ctx context.Context, cfg operatorConfig, namespace string) ([]permission, error) {
+	var missing []permission
+	for _, perm := range requiredPermissions(cfg) {
+		review := &authorizationv1.SelfSubjectAccessReview{
+			Spec: authorizationv1.SelfSubjectAccessReviewSpec{
+				ResourceAttributes: &authorizationv1.ResourceAttributes{
+					Namespace:   namespace,
+					Verb:        perm.verb,
+					Group:       perm.group,
+					Resource:    perm.resource,
+					Subresource: perm.subresource,
+				},
+			},
+		}
+		if err := r.Create(ctx, review); err != nil {
+			return nil, fmt.Errorf("checking %s: %w", perm, err)
+		}
+		if !review.Status.Allowed {
+			missing = append(missing, perm)
+		}
+	}
+	return missing, nil
+}
+
+// preflight reports whether the operator may restart pods for the
+// ConfigMap, emitting a PreflightFailed Event listing missing permissions
+func (r *ConfigMapReconciler) preflight(ctx context.Context, cfg operatorConfig, configMap *corev1.ConfigMap) (bool, error) {
+	if !r.PreflightChecks {
+		return true, nil
+	}
+
+	missing, err := r.missingPermissions(ctx, cfg, configMap.Namespace)
+	if err != nil {
+		return false, err
+	}
+	if len(missing) == 0 {
+		return true, nil
+	}
+
+	names := make([]string, 0, len(missing))
+	for _, perm := range missing {
+		names = append(names, perm.String())
+	}
+	r.Recorder.Eventf(configMap, corev1.EventTypeWarning, "PreflightFailed",
+		"Missing permissions in namespace %s: %s", configMap.Namespace, strings.Join(names, ", "))
+	return false, nil
+}
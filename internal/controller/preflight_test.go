@@ -0,0 +1,135 @@
+package controller
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+
+	autoapplyv1alpha1 "github.com/manos/k8s-autoapply-operator/api/v1alpha1"
+)
+
+// setupPreflightReconciler answers SelfSubjectAccessReviews, denying the given permissions
+func setupPreflightReconciler(denied ...string) (*ConfigMapReconciler, client.Client, *record.FakeRecorder) {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = autoapplyv1alpha1.AddToScheme(scheme)
+
+	fakeClient := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithIndex(&corev1.Pod{}, podConfigMapIndex, indexPodConfigMaps).
+		WithInterceptorFuncs(interceptor.Funcs{
+			Create: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.CreateOption) error {
+				review, ok := obj.(*authorizationv1.SelfSubjectAccessReview)
+				if !ok {
+					return c.Create(ctx, obj, opts...)
+				}
+				attrs := review.Spec.ResourceAttributes
+				perm := permission{verb: attrs.Verb, group: attrs.Group, resource: attrs.Resource, subresource: attrs.Subresource}
+				review.Status.Allowed = true
+				for _, d := range denied {
+					if perm.String() == d {
+						review.Status.Allowed = false
+					}
+				}
+				return nil
+			},
+		}).
+		Build()
+
+	recorder := record.NewFakeRecorder(10)
+	r := &ConfigMapReconciler{Client: fakeClient, Scheme: scheme, Recorder: recorder, PreflightChecks: true}
+	return r, fakeClient, recorder
+}
+
+func TestRequiredPermissions(t *testing.T) {
+	tests := []struct {
+		name     string
+		cfg      operatorConfig
+		expected string
+	}{
+		{"rolling", operatorConfig{strategy: autoapplyv1alpha1.RestartStrategyRolling}, "create pods/eviction"},
+		{"yolo", operatorConfig{yoloMode: true}, "delete pods"},
+		{"rollout", operatorConfig{strategy: autoapplyv1alpha1.RestartStrategyRollout}, "patch deployments.apps"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			found := false
+			for _, perm := range requiredPermissions(tt.cfg) {
+				if perm.String() == tt.expected {
+					found = true
+				}
+			}
+			if !found {
+				t.Errorf("Expected %q among %v", tt.expected, requiredPermissions(tt.cfg))
+			}
+		})
+	}
+}
+
+func TestReconcile_PreflightFailed(t *testing.T) {
+	r, fakeClient, recorder := setupPreflightReconciler("create pods/eviction", "patch configmaps")
+	ctx := context.Background()
+
+	req := ctrl.Request{
+		NamespacedName: types.NamespacedName{Name: "test-config", Namespace: "default"},
+	}
+	r.configMapVersions.Store(req.String(), "old-version")
+
+	_ = fakeClient.Create(ctx, &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-config", Namespace: "default"},
+	})
+	_ = fakeClient.Create(ctx, configVolumePod("test-pod", ""))
+
+	result, err := r.Reconcile(ctx, req)
+	if err != nil {
+		t.Fatalf("Reconcile failed: %v", err)
+	}
+	if result.RequeueAfter != preflightRetryInterval {
+		t.Errorf("Expected a retry after %v, got %v", preflightRetryInterval, result.RequeueAfter)
+	}
+	if _, pending := r.pendingRestarts.Load(req.String()); !pending {
+		t.Error("Expected the restart to be queued")
+	}
+
+	select {
+	case event := <-recorder.Events:
+		if !strings.Contains(event, "PreflightFailed") ||
+			!strings.Contains(event, "patch configmaps") || !strings.Contains(event, "create pods/eviction") {
+			t.Errorf("Expected one event listing both missing permissions, got %q", event)
+		}
+	default:
+		t.Error("Expected a PreflightFailed event")
+	}
+
+	var pods corev1.PodList
+	_ = fakeClient.List(ctx, &pods, client.InNamespace("default"))
+	if len(pods.Items) != 1 {
+		t.Errorf("Expected the pod to be kept, found %d pods", len(pods.Items))
+	}
+}
+
+func TestPreflight_Allowed(t *testing.T) {
+	r, _, recorder := setupPreflightReconciler()
+	cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "test-config", Namespace: "default"}}
+
+	ok, err := r.preflight(context.Background(), operatorConfig{strategy: autoapplyv1alpha1.RestartStrategyRollout}, cm)
+	if err != nil || !ok {
+		t.Errorf("Expected preflight to pass, got %v %v", ok, err)
+	}
+	if len(recorder.Events) != 0 {
+		t.Errorf("Expected no events, got %d", len(recorder.Events))
+	}
+}
@@ -0,0 +1,149 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	autoapplyv1alpha1 "github.com/manos/k8s-autoapply-operator/api/v1alpha1"
+)
+
+// +kubebuilder:rbac:groups=batch,resources=jobs,verbs=get;list;watch;create
+// +kubebuilder:rbac:groups=batch,resources=cronjobs,verbs=get;list;watch
+
+const (
+	// ConfigMap annotation naming a CronJob in its namespace whose job
+	// template runs before pods are restarted
+	preRestartJobAnnotation = "autoapply.io/pre-restart-job"
+	// Default time a pre-restart Job has to succeed
+	defaultPreRestartJobTimeout = 10 * time.Minute
+)
+
+// preRestartJobs returns the configured pre-restart Jobs plus the one the
+// ConfigMap's annotation points to
+func (r *ConfigMapReconciler) preRestartJobs(ctx context.Context, cfg operatorConfig, configMap *corev1.ConfigMap) ([]autoapplyv1alpha1.PreRestartJob, error) {
+	jobs := cfg.preRestartJobs
+
+	name := configMap.Annotations[preRestartJobAnnotation]
+	if name == "" {
+		return jobs, nil
+	}
+	var cronJob batchv1.CronJob
+	if err := r.Get(ctx, client.ObjectKey{Namespace: configMap.Namespace, Name: name}, &cronJob); err != nil {
+		return nil, fmt.Errorf("reading pre-restart CronJob %s: %w", name, err)
+	}
+	return append(jobs, autoapplyv1alpha1.PreRestartJob{Template: cronJob.Spec.JobTemplate}), nil
+}
+
+// preRestartJobProgress is how far the pre-restart Jobs of a restart got
+type preRestartJobProgress struct {
+	// index is the Job running, name the one created for it and created when
+	index   int
+	name    string
+	created time.Time
+}
+
+// stepPreRestartJobs runs the pre-restart Jobs one after another: it creates
+// the next Job or checks on the running one. done is set once all of them
+// succeeded; an error aborts the restart.
+func (r *ConfigMapReconciler) stepPreRestartJobs(ctx context.Context, cfg operatorConfig, configMap *corev1.ConfigMap, progress *preRestartJobProgress) (done bool, err error) {
+	jobs, err := r.preRestartJobs(ctx, cfg, configMap)
+	if err != nil {
+		return false, err
+	}
+	for progress.index < len(jobs) {
+		spec := jobs[progress.index]
+		if progress.name == "" {
+			name, err := r.createPreRestartJob(ctx, configMap, spec)
+			if err != nil {
+				return false, err
+			}
+			progress.name, progress.created = name, time.Now()
+		}
+
+		succeeded, err := r.preRestartJobSucceeded(ctx, configMap, progress.name)
+		if err != nil {
+			return false, err
+		}
+		if !succeeded {
+			if timeout := preRestartJobTimeout(spec); time.Since(progress.created) >= timeout {
+				return false, fmt.Errorf("pre-restart Job %s did not succeed within %s", progress.name, timeout)
+			}
+			return false, nil
+		}
+		progress.index++
+		progress.name = ""
+	}
+	return true, nil
+}
+
+// reportPreRestartJobFailed reports a restart aborted by a pre-restart Job
+func (r *ConfigMapReconciler) reportPreRestartJobFailed(ctx context.Context, configMap *corev1.ConfigMap, err error) {
+	log.FromContext(ctx).Error(err, "Pre-restart Job failed, aborting restart")
+	r.Recorder.Eventf(configMap, corev1.EventTypeWarning, "PreRestartJobFailed",
+		"Restart aborted: %v", err)
+}
+
+// preRestartJobTimeout returns the time a pre-restart Job has to succeed
+func preRestartJobTimeout(spec autoapplyv1alpha1.PreRestartJob) time.Duration {
+	if spec.Timeout != nil && spec.Timeout.Duration > 0 {
+		return spec.Timeout.Duration
+	}
+	return defaultPreRestartJobTimeout
+}
+
+// createPreRestartJob creates a Job from the template and returns its name
+func (r *ConfigMapReconciler) createPreRestartJob(ctx context.Context, configMap *corev1.ConfigMap, spec autoapplyv1alpha1.PreRestartJob) (string, error) {
+	logger := log.FromContext(ctx)
+
+	job := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: configMap.Name + "-pre-restart-",
+			Namespace:    configMap.Namespace,
+			Labels:       map[string]string{operationConfigMapLabel: configMap.Name},
+			Annotations:  spec.Template.Annotations,
+		},
+		Spec: *spec.Template.Spec.DeepCopy(),
+	}
+	for k, v := range spec.Template.Labels {
+		job.Labels[k] = v
+	}
+	// Owned by the ConfigMap so finished Jobs go away with it
+	if err := controllerutil.SetOwnerReference(configMap, job, r.Scheme); err != nil {
+		logger.Error(err, "Failed to set pre-restart Job owner")
+	}
+	if err := r.Create(ctx, job); err != nil {
+		return "", fmt.Errorf("creating pre-restart Job: %w", err)
+	}
+	logger.Info("Waiting for pre-restart Job", "job", job.Name, "timeout", preRestartJobTimeout(spec))
+	return job.Name, nil
+}
+
+// preRestartJobSucceeded checks if the named pre-restart Job succeeded,
+// failing if the Job did
+func (r *ConfigMapReconciler) preRestartJobSucceeded(ctx context.Context, configMap *corev1.ConfigMap, name string) (bool, error) {
+	var job batchv1.Job
+	if err := r.Get(ctx, client.ObjectKey{Namespace: configMap.Namespace, Name: name}, &job); err != nil {
+		return false, fmt.Errorf("reading pre-restart Job %s: %w", name, err)
+	}
+	for _, cond := range job.Status.Conditions {
+		if cond.Status != corev1.ConditionTrue {
+			continue
+		}
+		switch cond.Type {
+		case batchv1.JobComplete:
+			log.FromContext(ctx).Info("Pre-restart Job succeeded", "job", name)
+			return true, nil
+		case batchv1.JobFailed:
+			return false, fmt.Errorf("pre-restart Job %s failed: %s", name, cond.Message)
+		}
+	}
+	return false, nil
+}
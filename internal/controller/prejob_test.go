@@ -0,0 +1,117 @@
+package controller
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+
+	autoapplyv1alpha1 "github.com/manos/k8s-autoapply-operator/api/v1alpha1"
+)
+
+// setupJobReconciler finishes every created Job immediately with the given condition
+func setupJobReconciler(result batchv1.JobConditionType, objs ...client.Object) (*ConfigMapReconciler, client.Client) {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = autoapplyv1alpha1.AddToScheme(scheme)
+
+	fakeClient := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(objs...).
+		WithInterceptorFuncs(interceptor.Funcs{
+			Create: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.CreateOption) error {
+				if job, ok := obj.(*batchv1.Job); ok {
+					job.Status.Conditions = []batchv1.JobCondition{{
+						Type: result, Status: corev1.ConditionTrue, Message: "BackoffLimitExceeded",
+					}}
+				}
+				return c.Create(ctx, obj, opts...)
+			},
+		}).
+		Build()
+
+	return &ConfigMapReconciler{Client: fakeClient, Scheme: scheme, Recorder: record.NewFakeRecorder(10)}, fakeClient
+}
+
+func preRestartJobTemplate() batchv1.JobTemplateSpec {
+	return batchv1.JobTemplateSpec{
+		ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"app": "validate"}},
+		Spec: batchv1.JobSpec{Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{
+			RestartPolicy: corev1.RestartPolicyNever,
+			Containers:    []corev1.Container{{Name: "validate", Image: "busybox"}},
+		}}},
+	}
+}
+
+func TestStepPreRestartJobs(t *testing.T) {
+	tests := []struct {
+		name      string
+		result    batchv1.JobConditionType
+		expectErr bool
+	}{
+		{"succeeded", batchv1.JobComplete, false},
+		{"failed", batchv1.JobFailed, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, fakeClient := setupJobReconciler(tt.result)
+			ctx := context.Background()
+
+			cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "test-config", Namespace: "default", UID: "cm-uid"}}
+			cfg := operatorConfig{preRestartJobs: []autoapplyv1alpha1.PreRestartJob{{Template: preRestartJobTemplate()}}}
+
+			done, err := r.stepPreRestartJobs(ctx, cfg, cm, &preRestartJobProgress{})
+			if (err != nil) != tt.expectErr || done == tt.expectErr {
+				t.Errorf("stepPreRestartJobs() = %v, %v, expectErr %v", done, err, tt.expectErr)
+			}
+
+			var jobs batchv1.JobList
+			_ = fakeClient.List(ctx, &jobs, client.InNamespace("default"))
+			if len(jobs.Items) != 1 {
+				t.Fatalf("Expected one Job, got %d", len(jobs.Items))
+			}
+			job := jobs.Items[0]
+			if !strings.HasPrefix(job.Name, "test-config-pre-restart-") || job.Labels["app"] != "validate" || len(job.OwnerReferences) != 1 {
+				t.Errorf("Unexpected Job: %s %v %v", job.Name, job.Labels, job.OwnerReferences)
+			}
+		})
+	}
+}
+
+func TestStepPreRestartJobs_CronJobAnnotation(t *testing.T) {
+	cronJob := &batchv1.CronJob{
+		ObjectMeta: metav1.ObjectMeta{Name: "drain-queue", Namespace: "default"},
+		Spec:       batchv1.CronJobSpec{Schedule: "@yearly", JobTemplate: preRestartJobTemplate()},
+	}
+	r, fakeClient := setupJobReconciler(batchv1.JobComplete, cronJob)
+	ctx := context.Background()
+
+	cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{
+		Name: "test-config", Namespace: "default", UID: "cm-uid",
+		Annotations: map[string]string{preRestartJobAnnotation: "drain-queue"},
+	}}
+	if done, err := r.stepPreRestartJobs(ctx, operatorConfig{}, cm, &preRestartJobProgress{}); !done || err != nil {
+		t.Fatalf("stepPreRestartJobs() = %v, %v", done, err)
+	}
+
+	var jobs batchv1.JobList
+	_ = fakeClient.List(ctx, &jobs, client.InNamespace("default"))
+	if len(jobs.Items) != 1 {
+		t.Errorf("Expected a Job from the CronJob template, got %d", len(jobs.Items))
+	}
+
+	cm.Annotations[preRestartJobAnnotation] = "missing"
+	if _, err := r.stepPreRestartJobs(ctx, operatorConfig{}, cm, &preRestartJobProgress{}); err == nil {
+		t.Error("Expected an error for a missing CronJob")
+	}
+}
@@ -0,0 +1,99 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+
+	autoapplyv1alpha1 "github.com/manos/k8s-autoapply-operator/api/v1alpha1"
+)
+
+const (
+	// Default timeout of one verification probe attempt
+	defaultProbeTimeout = 5 * time.Second
+	// Attempts before a verification probe counts as failed, pollInterval
+	// apart so a single dropped connection doesn't abort a restart
+	probeAttempts = 3
+)
+
+// serviceAddress is the in-cluster address of a Service port
+var serviceAddress = func(namespace, service string, port int32) string {
+	return net.JoinHostPort(service+"."+namespace+".svc", strconv.Itoa(int(port)))
+}
+
+// probeProgress is how far the verification probes of a restarted batch got
+type probeProgress struct {
+	// Probe is the probe running and Failures its failed attempts so far
+	Probe    int `json:"probe"`
+	Failures int `json:"failures,omitempty"`
+}
+
+// stepVerificationProbes checks that the configured Services answer once a
+// batch restarted pods, one attempt per call. done is set once every probe
+// passed. A probe failing probeAttempts times in a row fails the batch,
+// emitting a VerificationFailed Event.
+func (r *ConfigMapReconciler) stepVerificationProbes(ctx context.Context, cfg operatorConfig, configMap *corev1.ConfigMap, progress *probeProgress) (done bool, err error) {
+	for progress.Probe < len(cfg.verificationProbes) {
+		probe := cfg.verificationProbes[progress.Probe]
+		if err := probeService(ctx, configMap.Namespace, probe); err != nil {
+			progress.Failures++
+			if progress.Failures < probeAttempts {
+				return false, nil
+			}
+			r.Recorder.Eventf(configMap, corev1.EventTypeWarning, "VerificationFailed",
+				"Probe of Service %s failed, remaining batches aborted: %v", probe.Service, err)
+			return false, fmt.Errorf("verification probe of service %s: %w", probe.Service, err)
+		}
+		progress.Probe++
+		progress.Failures = 0
+	}
+	return true, nil
+}
+
+// probeService makes one attempt at reaching a Service
+func probeService(ctx context.Context, namespace string, probe autoapplyv1alpha1.ServiceProbe) error {
+	timeout := defaultProbeTimeout
+	if probe.Timeout != nil && probe.Timeout.Duration > 0 {
+		timeout = probe.Timeout.Duration
+	}
+	return probeOnce(ctx, serviceAddress(namespace, probe.Service, probe.Port), probe.Path, timeout)
+}
+
+// probeOnce opens a TCP connection to address, or GETs path on it if set
+func probeOnce(ctx context.Context, address, path string, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	if path == "" {
+		var dialer net.Dialer
+		conn, err := dialer.DialContext(ctx, "tcp", address)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	}
+
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://"+address+path, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	_ = resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("returned %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package controller
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	autoapplyv1alpha1 "github.com/manos/k8s-autoapply-operator/api/v1alpha1"
+)
+
+func TestStepVerificationProbes(t *testing.T) {
+	status := http.StatusOK
+	var path string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		path = req.URL.Path
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	original := serviceAddress
+	serviceAddress = func(namespace, service string, port int32) string {
+		return strings.TrimPrefix(server.URL, "http://")
+	}
+	defer func() { serviceAddress = original }()
+
+	r, _ := setupTestReconciler()
+	ctx := context.Background()
+
+	cfg := operatorConfig{verificationProbes: []autoapplyv1alpha1.ServiceProbe{{Service: "web", Port: 80, Path: "healthz"}}}
+	cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "test-config", Namespace: "default"}}
+
+	if done, err := r.stepVerificationProbes(ctx, cfg, cm, &probeProgress{}); !done || err != nil {
+		t.Errorf("Expected healthy Service to pass, got %v, %v", done, err)
+	}
+	if path != "/healthz" {
+		t.Errorf("Expected /healthz to be probed, got %q", path)
+	}
+
+	// A failing probe is retried on later steps before it aborts
+	status = http.StatusServiceUnavailable
+	progress := &probeProgress{}
+	for attempt := 1; attempt < probeAttempts; attempt++ {
+		if done, err := r.stepVerificationProbes(ctx, cfg, cm, progress); done || err != nil {
+			t.Fatalf("Expected attempt %d to be retried, got %v, %v", attempt, done, err)
+		}
+	}
+	if _, err := r.stepVerificationProbes(ctx, cfg, cm, progress); err == nil {
+		t.Error("Expected failing probe to abort")
+	}
+	select {
+	case event := <-r.Recorder.(*record.FakeRecorder).Events:
+		if !strings.Contains(event, "VerificationFailed") {
+			t.Errorf("Expected a VerificationFailed event, got %q", event)
+		}
+	default:
+		t.Error("Expected a VerificationFailed event")
+	}
+}
+
+func TestProbeOnce_TCP(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	address := strings.TrimPrefix(server.URL, "http://")
+
+	if err := probeOnce(context.Background(), address, "", defaultProbeTimeout); err != nil {
+		t.Errorf("Expected TCP probe to connect, got %v", err)
+	}
+
+	server.Close()
+	if err := probeOnce(context.Background(), address, "", defaultProbeTimeout); err == nil {
+		t.Error("Expected TCP probe of a closed port to fail")
+	}
+}
+
+func TestRestart_VerificationProbeAbortsOwner(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	original := serviceAddress
+	serviceAddress = func(namespace, service string, port int32) string {
+		return strings.TrimPrefix(server.URL, "http://")
+	}
+	defer func() { serviceAddress = original }()
+
+	r, fakeClient := setupTestReconciler()
+	ctx := context.Background()
+
+	cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "test-config", Namespace: "default"}}
+	pods := createOwnedPods(ctx, fakeClient, "deploy-a", 2)
+	cfg := r.loadConfig(ctx, nil)
+	cfg.verificationProbes = []autoapplyv1alpha1.ServiceProbe{{Service: "web", Port: 80, Path: "/healthz"}}
+
+	if err := runRestart(ctx, r, cfg, cm, "v2", pods); err == nil {
+		t.Fatal("Expected the failing probe to fail the restart")
+	}
+
+	var remaining corev1.PodList
+	_ = fakeClient.List(ctx, &remaining, client.InNamespace("default"))
+	if len(remaining.Items) != 2 {
+		t.Errorf("Expected the second batch to be left running, found %v", podNames(remaining.Items))
+	}
+}
@@ -0,0 +1,46 @@
+package controller
+
+import (
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// seenChange is when the operator first saw a ConfigMap version
+type seenChange struct {
+	version string
+	at      time.Time
+}
+
+// noteChangeSeen records when a ConfigMap version was first seen, keeping the
+// earlier time while the same version is debounced or waits for a window
+func (r *ConfigMapReconciler) noteChangeSeen(key, version string) {
+	if value, ok := r.changesSeen.Load(key); ok && value.(seenChange).version == version {
+		return
+	}
+	r.changesSeen.Store(key, seenChange{version: version, at: time.Now()})
+}
+
+// configPropagated observes the time from seeing the ConfigMap's latest
+// change until every selected pod was restarted with it. It returns 0 if the
+// change wasn't seen by this operator instance.
+func (r *ConfigMapReconciler) configPropagated(configMap *corev1.ConfigMap) time.Duration {
+	value, ok := r.changesSeen.LoadAndDelete(client.ObjectKeyFromObject(configMap).String())
+	if !ok {
+		return 0
+	}
+	elapsed := time.Since(value.(seenChange).at)
+	observePropagation(configMap.Namespace, configMap.Name, elapsed.Seconds())
+	return elapsed
+}
+
+// blockedPodsPending checks if pods a PDB blocked during the restart of the
+// ConfigMap still wait to be retried
+func (r *ConfigMapReconciler) blockedPodsPending(configMap *corev1.ConfigMap) bool {
+	value, ok := r.pdbRetries.Load(client.ObjectKeyFromObject(configMap).String())
+	if !ok {
+		return false
+	}
+	return len(value.(*pdbRetry).pods) > 0
+}
@@ -0,0 +1,84 @@
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestNoteChangeSeen(t *testing.T) {
+	r := &ConfigMapReconciler{}
+	r.noteChangeSeen("default/test-config", "v2")
+	first, _ := r.changesSeen.Load("default/test-config")
+
+	// Seeing the same version again, e.g. after debouncing, keeps the first time
+	time.Sleep(time.Millisecond)
+	r.noteChangeSeen("default/test-config", "v2")
+	if again, _ := r.changesSeen.Load("default/test-config"); again != first {
+		t.Error("Expected the first time the version was seen to be kept")
+	}
+
+	r.noteChangeSeen("default/test-config", "v3")
+	if latest, _ := r.changesSeen.Load("default/test-config"); latest.(seenChange).version != "v3" {
+		t.Error("Expected a newer version to replace the seen change")
+	}
+}
+
+func TestConfigPropagation_RecordedOnOperation(t *testing.T) {
+	r, fakeClient := setupTestReconciler()
+	r.RecordOperations = true
+	ctx := context.Background()
+
+	cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "propagated", Namespace: "default", UID: "cm-uid"}}
+	pod := podUsingConfigMap("test-pod", "propagated", time.Now())
+	_ = fakeClient.Create(ctx, pod)
+	r.changesSeen.Store("default/propagated", seenChange{version: "v2", at: time.Now().Add(-90 * time.Second)})
+
+	r.startOperation(ctx, operatorConfig{}, cm, "v2", keyChanges{}, []corev1.Pod{*pod})
+	r.completeRestart(ctx, cm, nil)
+
+	op := listOperations(t, fakeClient)[0]
+	if op.Status.PropagationTime == nil || op.Status.PropagationTime.Duration != 90*time.Second {
+		t.Errorf("Expected a propagation time of 90s, got %v", op.Status.PropagationTime)
+	}
+	if !configPropagationSeconds.DeleteLabelValues("default", "propagated") {
+		t.Error("Expected the propagation time to be observed")
+	}
+	if _, ok := r.changesSeen.Load("default/propagated"); ok {
+		t.Error("Expected the seen change to be done")
+	}
+}
+
+func TestConfigPropagation_WaitsForBlockedPods(t *testing.T) {
+	blocking := true
+	r, fakeClient := setupPDBReconciler(&blocking)
+	ctx := context.Background()
+
+	cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "blocked", Namespace: "default", UID: "cm-uid"}}
+	key := client.ObjectKeyFromObject(cm).String()
+	cfg := operatorConfig{pdbRetryWindow: time.Hour}
+	pod := podUsingConfigMap("test-pod", "blocked", time.Now())
+	_ = fakeClient.Create(ctx, pod)
+	r.noteChangeSeen(key, "v2")
+
+	r.trackBlockedPod(cm, *pod)
+	r.completeRestart(ctx, cm, nil)
+	r.scheduleBlockedPodRetry(ctx, cfg, key, "v2")
+
+	if _, ok := r.changesSeen.Load(key); !ok {
+		t.Fatal("Expected the change to still be propagating")
+	}
+
+	blocking = false
+	if _, err := r.retryBlockedPods(ctx, cfg, cm, key); err != nil {
+		t.Fatalf("retryBlockedPods failed: %v", err)
+	}
+	if _, ok := r.changesSeen.Load(key); ok {
+		t.Error("Expected the change to be propagated once the blocked pod restarted")
+	}
+	deleteConfigMapMetrics("default", "blocked")
+}
@@ -0,0 +1,104 @@
+package controller
+
+import (
+	"context"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// +kubebuilder:rbac:groups=apps,resources=controllerrevisions,verbs=get;list;watch
+
+// workloadRolloutCache caches when workloads last rolled out for one reconcile
+type workloadRolloutCache map[workloadRef]time.Time
+
+// rolledOutRecently reports whether the pod's workload rolled out within the
+// window. Lookup failures count as not recent, so the pod is restarted.
+func (r *ConfigMapReconciler) rolledOutRecently(ctx context.Context, pod *corev1.Pod, window time.Duration, cache workloadRolloutCache) bool {
+	workload, err := r.resolveWorkload(ctx, pod)
+	if err != nil || workload == nil {
+		return false
+	}
+
+	rolledOutAt, ok := cache[*workload]
+	if !ok {
+		rolledOutAt, err = r.workloadRolledOutAt(ctx, pod.Namespace, workload)
+		if err != nil {
+			log.FromContext(ctx).V(1).Info("Failed to look up last rollout", "kind", workload.Kind, "name", workload.Name, "error", err.Error())
+		}
+		cache[*workload] = rolledOutAt
+	}
+
+	return !rolledOutAt.IsZero() && time.Since(rolledOutAt) < window
+}
+
+// workloadRolledOutAt returns when the workload last rolled out: the last
+// progress of a Deployment, or when the newest revision of a StatefulSet or
+// DaemonSet was created. Zero for other kinds.
+func (r *ConfigMapReconciler) workloadRolledOutAt(ctx context.Context, namespace string, workload *workloadRef) (time.Time, error) {
+	key := types.NamespacedName{Namespace: namespace, Name: workload.Name}
+
+	switch workload.Kind {
+	case "Deployment":
+		var deployment appsv1.Deployment
+		if err := r.Get(ctx, key, &deployment); err != nil {
+			return time.Time{}, err
+		}
+		for _, condition := range deployment.Status.Conditions {
+			if condition.Type == appsv1.DeploymentProgressing {
+				return condition.LastUpdateTime.Time, nil
+			}
+		}
+		return time.Time{}, nil
+	case "StatefulSet", "DaemonSet":
+		obj := newRolloutObject(workload.Kind)
+		if err := r.Get(ctx, key, obj); err != nil {
+			return time.Time{}, err
+		}
+		return r.newestRevisionCreated(ctx, obj)
+	}
+
+	return time.Time{}, nil
+}
+
+// newestRevisionCreated returns when the highest ControllerRevision of a
+// StatefulSet or DaemonSet was created
+func (r *ConfigMapReconciler) newestRevisionCreated(ctx context.Context, obj client.Object) (time.Time, error) {
+	var selector *metav1.LabelSelector
+	switch workload := obj.(type) {
+	case *appsv1.StatefulSet:
+		selector = workload.Spec.Selector
+	case *appsv1.DaemonSet:
+		selector = workload.Spec.Selector
+	}
+	labelSelector, err := metav1.LabelSelectorAsSelector(selector)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	var revisions appsv1.ControllerRevisionList
+	if err := r.List(ctx, &revisions, client.InNamespace(obj.GetNamespace()),
+		client.MatchingLabelsSelector{Selector: labelSelector}); err != nil {
+		return time.Time{}, err
+	}
+
+	var newest *appsv1.ControllerRevision
+	for i := range revisions.Items {
+		revision := &revisions.Items[i]
+		if !metav1.IsControlledBy(revision, obj) {
+			continue
+		}
+		if newest == nil || revision.Revision > newest.Revision {
+			newest = revision
+		}
+	}
+	if newest == nil {
+		return time.Time{}, nil
+	}
+	return newest.CreationTimestamp.Time, nil
+}
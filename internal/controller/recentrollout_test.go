@@ -0,0 +1,115 @@
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestWorkloadRolledOutAt(t *testing.T) {
+	r, fakeClient := setupTestReconciler()
+	ctx := context.Background()
+
+	progressed := metav1.NewTime(time.Now().Add(-5 * time.Minute).Truncate(time.Second))
+	_ = fakeClient.Create(ctx, &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"},
+		Status: appsv1.DeploymentStatus{Conditions: []appsv1.DeploymentCondition{
+			{Type: appsv1.DeploymentAvailable, LastUpdateTime: metav1.Now()},
+			{Type: appsv1.DeploymentProgressing, LastUpdateTime: progressed},
+		}},
+	})
+
+	selector := &metav1.LabelSelector{MatchLabels: map[string]string{"app": "db"}}
+	statefulSet := &appsv1.StatefulSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "default", UID: "db-uid"},
+		Spec:       appsv1.StatefulSetSpec{Selector: selector},
+	}
+	_ = fakeClient.Create(ctx, statefulSet)
+	trueVal := true
+	revision := func(name string, owner string, number int64, created time.Time) *appsv1.ControllerRevision {
+		return &appsv1.ControllerRevision{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: name, Namespace: "default", Labels: map[string]string{"app": "db"},
+				CreationTimestamp: metav1.NewTime(created.Truncate(time.Second)),
+				OwnerReferences: []metav1.OwnerReference{
+					{APIVersion: "apps/v1", Kind: "StatefulSet", Name: owner, UID: "db-uid", Controller: &trueVal},
+				},
+			},
+			Revision: number,
+		}
+	}
+	latest := time.Now().Add(-time.Hour)
+	_ = fakeClient.Create(ctx, revision("db-1", "db", 1, time.Now().Add(-24*time.Hour)))
+	_ = fakeClient.Create(ctx, revision("db-2", "db", 2, latest))
+	// Same labels, other owner
+	other := revision("other-9", "other", 9, time.Now())
+	other.OwnerReferences[0].UID = "other-uid"
+	_ = fakeClient.Create(ctx, other)
+
+	tests := []struct {
+		workload workloadRef
+		expected time.Time
+	}{
+		{workloadRef{Kind: "Deployment", Name: "web"}, progressed.Time},
+		{workloadRef{Kind: "StatefulSet", Name: "db"}, latest.Truncate(time.Second)},
+		{workloadRef{Kind: "Job", Name: "migrate"}, time.Time{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.workload.Kind, func(t *testing.T) {
+			result, err := r.workloadRolledOutAt(ctx, "default", &tt.workload)
+			if err != nil {
+				t.Fatalf("workloadRolledOutAt() failed: %v", err)
+			}
+			if !result.Equal(tt.expected) {
+				t.Errorf("workloadRolledOutAt() = %v, expected %v", result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestFindPodsUsingConfigMap_RecentRollout(t *testing.T) {
+	tests := []struct {
+		name      string
+		window    time.Duration
+		restarted int
+	}{
+		{"disabled", 0, 1},
+		{"rolled out within window", 15 * time.Minute, 0},
+		{"rolled out before window", time.Minute, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, fakeClient := setupTestReconciler()
+			ctx := context.Background()
+
+			trueVal := true
+			_ = fakeClient.Create(ctx, &appsv1.Deployment{
+				ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"},
+				Status: appsv1.DeploymentStatus{Conditions: []appsv1.DeploymentCondition{
+					{Type: appsv1.DeploymentProgressing, LastUpdateTime: metav1.NewTime(time.Now().Add(-5 * time.Minute))},
+				}},
+			})
+			_ = fakeClient.Create(ctx, &appsv1.ReplicaSet{
+				ObjectMeta: metav1.ObjectMeta{Name: "web-abc", Namespace: "default", OwnerReferences: []metav1.OwnerReference{
+					{APIVersion: "apps/v1", Kind: "Deployment", Name: "web", Controller: &trueVal},
+				}},
+			})
+			pod := podUsingConfigMap("web-abc-1", "app-config", time.Now().Add(-5*time.Minute))
+			pod.OwnerReferences = []metav1.OwnerReference{
+				{APIVersion: "apps/v1", Kind: "ReplicaSet", Name: "web-abc", Controller: &trueVal},
+			}
+			_ = fakeClient.Create(ctx, pod)
+
+			cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "app-config", Namespace: "default"}}
+			cfg := operatorConfig{recentRolloutWindow: tt.window}
+			if pods := r.findPodsUsingConfigMap(ctx, cm, cfg); len(pods) != tt.restarted {
+				t.Errorf("Expected %d pods to restart, got %d", tt.restarted, len(pods))
+			}
+		})
+	}
+}
@@ -0,0 +1,1204 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/ptr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	autoapplyv1alpha1 "github.com/manos/k8s-autoapply-operator/api/v1alpha1"
+)
+
+// restartProgressAnnotation on a ConfigMap records where its running restart
+// is, so a restarted operator resumes it
+const restartProgressAnnotation = "autoapply.io/restart-progress"
+
+// restartStep is where a running restart is
+type restartStep string
+
+const (
+	// restartStepRestarting means the owners of the current wave are restarting
+	restartStepRestarting restartStep = "Restarting"
+	// restartStepVerifyingWave means the current wave must become healthy
+	// before the next one starts
+	restartStepVerifyingWave restartStep = "VerifyingWave"
+	// restartStepDeleting means YOLO mode deletes the pods as fast as the
+	// restart budget allows
+	restartStepDeleting restartStep = "Deleting"
+	// restartStepRecreating means the pods without a controller YOLO mode
+	// deleted are created again once they're gone
+	restartStepRecreating restartStep = "Recreating"
+	// restartStepProbing means the verification probes run after YOLO mode
+	// restarted every pod
+	restartStepProbing restartStep = "Probing"
+	// restartStepWaitingForVPA means pods a VerticalPodAutoscaler is about to
+	// evict are left to it until its eviction window ends
+	restartStepWaitingForVPA restartStep = "WaitingForVPA"
+	// restartStepFinished means no pods are left to restart
+	restartStepFinished restartStep = "Finished"
+)
+
+// ownerRestartMode is how an owner's pods restart
+type ownerRestartMode string
+
+const (
+	// ownerRestartBatches evicts the pods in halves, or a canary and the rest
+	ownerRestartBatches ownerRestartMode = "Batches"
+	// ownerRestartNodes evicts DaemonSet pods node by node
+	ownerRestartNodes ownerRestartMode = "Nodes"
+	// ownerRestartRollout triggers a rollout restart of the workload
+	ownerRestartRollout ownerRestartMode = "Rollout"
+	// ownerRestartSurge scales the Deployment up by each batch before its
+	// pods are removed
+	ownerRestartSurge ownerRestartMode = "Surge"
+)
+
+// ownerRestartStep is how far an owner's current batch got
+type ownerRestartStep string
+
+const (
+	// ownerRestartPending means the batch is about to start
+	ownerRestartPending ownerRestartStep = "Pending"
+	// ownerRestartEvicting means the batch's pods are being evicted, those a
+	// PodDisruptionBudget blocks retried
+	ownerRestartEvicting ownerRestartStep = "Evicting"
+	// ownerRestartRecreating means the batch's evicted pods without a
+	// controller are created again once they're gone
+	ownerRestartRecreating ownerRestartStep = "Recreating"
+	// ownerRestartProbing means the verification probes must pass for the
+	// restarted batch
+	ownerRestartProbing ownerRestartStep = "Probing"
+	// ownerRestartVerifying means the batch's replacements must become
+	// healthy before the next batch
+	ownerRestartVerifying ownerRestartStep = "Verifying"
+	// ownerRestartSoaking means the canary's replacement must stay Ready for
+	// the soak duration
+	ownerRestartSoaking ownerRestartStep = "Soaking"
+	// ownerRestartSurging means the Deployment is scaled up by the batch, once
+	// the restart budget has room, and the extra pods must become Ready
+	ownerRestartSurging ownerRestartStep = "Surging"
+	// ownerRestartDraining means the Deployment was scaled back and the
+	// batch's pods must go away
+	ownerRestartDraining ownerRestartStep = "Draining"
+	// ownerRestartDone means every batch restarted
+	ownerRestartDone ownerRestartStep = "Done"
+	// ownerRestartFailed means the owner's restart stopped with an error
+	ownerRestartFailed ownerRestartStep = "Failed"
+)
+
+// podRef identifies a pod selected for restart
+type podRef struct {
+	Name string    `json:"name"`
+	UID  types.UID `json:"uid"`
+	// NodeName is where the pod ran, which a DaemonSet's replacement must too
+	NodeName string `json:"nodeName,omitempty"`
+}
+
+// ownerProgress records how far the restart of one owner's pods got
+type ownerProgress struct {
+	// Kind, Name and UID identify the owner, all empty for standalone pods
+	Kind string    `json:"kind,omitempty"`
+	Name string    `json:"name,omitempty"`
+	UID  types.UID `json:"uid,omitempty"`
+	// Wave is the index of the restart wave the owner belongs to
+	Wave int `json:"wave"`
+
+	Mode ownerRestartMode `json:"mode"`
+	// WorkloadKind and WorkloadName are the workload a Rollout owner rolls
+	// out, or the Deployment a Surge owner scales
+	WorkloadKind string `json:"workloadKind,omitempty"`
+	WorkloadName string `json:"workloadName,omitempty"`
+
+	// Batches are the planned batches and Batch the current one
+	Batches [][]podRef `json:"batches,omitempty"`
+	Batch   int        `json:"batch"`
+	// Step is how far the current batch got, unset until the owner starts
+	Step     ownerRestartStep `json:"step,omitempty"`
+	StepTime *metav1.Time     `json:"stepTime,omitempty"`
+
+	// Pending are the batch's pods not evicted, or re-created, yet, Blocked
+	// those a PodDisruptionBudget blocked and Restarted those evicted
+	Pending   []string `json:"pending,omitempty"`
+	Blocked   []string `json:"blocked,omitempty"`
+	Restarted []string `json:"restarted,omitempty"`
+	// Probes are how far the verification probes of the batch got
+	Probes *probeProgress `json:"probes,omitempty"`
+	// Replicas is the Deployment's replica count before a Surge batch
+	// scaled it up
+	Replicas *int32 `json:"replicas,omitempty"`
+
+	// Message is why the owner's restart failed
+	Message string `json:"message,omitempty"`
+}
+
+// restartProgress records where a running restart is
+type restartProgress struct {
+	// Version is the ConfigMap version being rolled out
+	Version string `json:"version"`
+	// Started is when the change was picked up and Launched when pods began
+	// restarting, which the restart timeout counts from
+	Started  metav1.Time `json:"started"`
+	Launched metav1.Time `json:"launched"`
+	// Pods is how many pods were selected for restart
+	Pods int `json:"pods"`
+
+	Step     restartStep  `json:"step"`
+	StepTime *metav1.Time `json:"stepTime,omitempty"`
+
+	// Waves name the restart waves in order, and Wave is the current one
+	Waves []string `json:"waves,omitempty"`
+	Wave  int      `json:"wave"`
+	// Owners are the owners whose pods are restarted, in wave order
+	Owners []ownerProgress `json:"owners,omitempty"`
+	// VPADeferred are pods left to a VerticalPodAutoscaler about to evict them
+	VPADeferred []podRef `json:"vpaDeferred,omitempty"`
+	// YOLO is how far YOLO mode got deleting the pods
+	YOLO *yoloProgress `json:"yolo,omitempty"`
+	// Probes are how far the verification probes after a YOLO mode restart got
+	Probes *probeProgress `json:"probes,omitempty"`
+
+	// Operation is the RestartOperation recording the restart, if any
+	Operation string `json:"operation,omitempty"`
+}
+
+// yoloProgress records how far YOLO mode got deleting the pods
+type yoloProgress struct {
+	// Pods are the pods to delete, in order, and Next the first one the
+	// restart budget held back
+	Pods []podRef `json:"pods"`
+	Next int      `json:"next"`
+	// Restarted counts the pods deleted
+	Restarted int `json:"restarted"`
+	// Recreating are the deleted pods without a controller still to be
+	// created again
+	Recreating []podRef `json:"recreating,omitempty"`
+}
+
+// restartState is a restart spanning reconciles. Each reconcile takes the
+// steps that are due and requeues for the next one; progress is recorded on
+// the ConfigMap so a restarted operator resumes where it was.
+type restartState struct {
+	// selected are the pods to restart until the restart is launched
+	selected []corev1.Pod
+	// jobs are the pre-restart Jobs, nil once all of them succeeded
+	jobs *preRestartJobProgress
+	// changes are the keys changed since the last handled version, unknown
+	// for a resumed restart
+	changes keyChanges
+	// summary describes the restart for notifications once launched
+	summary restartSummary
+
+	progress restartProgress
+	// pods are the planned pods by name, as last seen
+	pods map[string]corev1.Pod
+	// failures are the errors of failed owners by index in progress.Owners
+	failures map[int]error
+	// errs are failures not attributed to an owner
+	errs     []error
+	jobErr   error
+	timedOut bool
+
+	// next is when the next step is due and resourceVersion the ConfigMap's
+	// after progress was last recorded. Reconciles before next for an
+	// unchanged ConfigMap, as recording progress causes, don't take a step.
+	next            time.Time
+	resourceVersion string
+
+	// release frees the namespace lock and restart slot the restart holds
+	release func()
+}
+
+// newRestart returns the state of a restart of pods about to begin
+func newRestart(version string, pods []corev1.Pod) *restartState {
+	return &restartState{
+		selected: pods,
+		jobs:     &preRestartJobProgress{},
+		progress: restartProgress{Version: version, Started: metav1.Now()},
+		pods:     make(map[string]corev1.Pod),
+		failures: make(map[int]error),
+	}
+}
+
+// restartInProgress returns the ConfigMap's running restart, if any. The
+// first time the ConfigMap is seen, a restart a previous operator instance
+// was in the middle of is resumed from the progress it recorded.
+func (r *ConfigMapReconciler) restartInProgress(ctx context.Context, configMap *corev1.ConfigMap, key string) *restartState {
+	if value, ok := r.restarts.Load(key); ok {
+		return value.(*restartState)
+	}
+	if _, seen := r.configMapVersions.Load(key); seen {
+		return nil
+	}
+	value, ok := configMap.Annotations[restartProgressAnnotation]
+	if !ok {
+		return nil
+	}
+
+	var progress restartProgress
+	if err := json.Unmarshal([]byte(value), &progress); err != nil {
+		log.FromContext(ctx).Error(err, "Ignoring unreadable restart progress")
+		return nil
+	}
+	state := r.resumeRestart(ctx, configMap, progress)
+	r.configMapVersions.Store(key, progress.Version)
+	r.restarts.Store(key, state)
+	return state
+}
+
+// resumeRestart rebuilds the state of a restart from its recorded progress
+func (r *ConfigMapReconciler) resumeRestart(ctx context.Context, configMap *corev1.ConfigMap, progress restartProgress) *restartState {
+	state := &restartState{
+		progress: progress,
+		pods:     make(map[string]corev1.Pod),
+		failures: make(map[int]error),
+	}
+	for i := range progress.Owners {
+		owner := &progress.Owners[i]
+		for _, batch := range owner.Batches {
+			for _, ref := range batch {
+				state.pods[ref.Name] = r.resumedPod(ctx, configMap.Namespace, owner, ref)
+			}
+		}
+	}
+	if progress.YOLO != nil {
+		for _, ref := range progress.YOLO.Pods {
+			state.pods[ref.Name] = r.resumedPod(ctx, configMap.Namespace, nil, ref)
+		}
+	}
+	for _, ref := range progress.VPADeferred {
+		state.pods[ref.Name] = r.resumedPod(ctx, configMap.Namespace, nil, ref)
+	}
+	if progress.Operation != "" {
+		r.resumeOperation(ctx, configMap, progress.Operation)
+	}
+	state.summary = r.summarizeRestart(ctx, configMap, state.plannedPods(), progress.Launched.Time)
+	state.summary.pods = progress.Pods
+
+	log.FromContext(ctx).Info("Resuming restart", "step", progress.Step, "started", progress.Started)
+	return state
+}
+
+// resumedPod returns the pod a reference recorded as it is now, or rebuilt
+// from the reference and its owner if it's gone or was replaced
+func (r *ConfigMapReconciler) resumedPod(ctx context.Context, namespace string, owner *ownerProgress, ref podRef) corev1.Pod {
+	var pod corev1.Pod
+	if err := r.Get(ctx, client.ObjectKey{Namespace: namespace, Name: ref.Name}, &pod); err == nil && pod.UID == ref.UID {
+		return pod
+	}
+
+	pod = corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: ref.Name, Namespace: namespace, UID: ref.UID},
+		Spec:       corev1.PodSpec{NodeName: ref.NodeName},
+	}
+	if owner != nil && owner.UID != "" {
+		pod.OwnerReferences = []metav1.OwnerReference{{
+			Kind:       owner.Kind,
+			Name:       owner.Name,
+			UID:        owner.UID,
+			Controller: ptr.To(true),
+		}}
+	}
+	return pod
+}
+
+// abandonRestart drops the restart of a deleted ConfigMap. Deployments a
+// batch surged are scaled back.
+func (r *ConfigMapReconciler) abandonRestart(ctx context.Context, namespace, key string) {
+	value, ok := r.restarts.LoadAndDelete(key)
+	if !ok {
+		return
+	}
+	state := value.(*restartState)
+	r.restoreSurges(ctx, namespace, state)
+	if state.release != nil {
+		state.release()
+	}
+}
+
+// stepRestart takes the restart's due steps and requeues for the next one,
+// or finishes the restart
+func (r *ConfigMapReconciler) stepRestart(ctx context.Context, cfg operatorConfig, configMap *corev1.ConfigMap, key string, state *restartState) ctrl.Result {
+	// A resumed restart needs its namespace lock and restart slot back first
+	if state.release == nil {
+		var wait time.Duration
+		if state.release, wait = r.lockRestart(ctx, configMap.Namespace); state.release == nil {
+			return ctrl.Result{RequeueAfter: wait}
+		}
+	}
+
+	if wait := time.Until(state.next); wait > 0 && configMap.ResourceVersion == state.resourceVersion {
+		return ctrl.Result{RequeueAfter: wait}
+	}
+
+	wait, done := r.advanceRestart(ctx, cfg, configMap, state)
+	if done {
+		return r.finishRestart(ctx, cfg, configMap, key, state)
+	}
+	r.recordProgress(ctx, configMap, state)
+	state.next, state.resourceVersion = time.Now().Add(wait), configMap.ResourceVersion
+	return ctrl.Result{RequeueAfter: wait}
+}
+
+// finishRestart reports a finished restart and persists its version. A
+// newer change that came in meanwhile is handled on requeue.
+func (r *ConfigMapReconciler) finishRestart(ctx context.Context, cfg operatorConfig, configMap *corev1.ConfigMap, key string, state *restartState) ctrl.Result {
+	logger := log.FromContext(ctx)
+
+	r.restarts.Delete(key)
+	state.release()
+
+	var result ctrl.Result
+	if state.jobErr == nil {
+		restartErr := state.err()
+		if state.timedOut {
+			r.reportRestartTimedOut(ctx, cfg, configMap, state.plannedPods())
+		}
+		if restartErr != nil {
+			logger.Error(restartErr, "Restart encountered errors")
+			r.reportRestartFailures(configMap, restartErr)
+		}
+		r.completeRestart(ctx, configMap, restartErr)
+		r.notifyFinished(ctx, cfg, configMap, state.summary, restartErr)
+		result = r.scheduleBlockedPodRetry(ctx, cfg, key, state.progress.Version)
+	}
+
+	r.clearProgress(ctx, configMap)
+	r.persistVersion(ctx, cfg, configMap, state.progress.Version, state.progress.Started.Time)
+
+	if r.changeDetector(cfg).Version(configMap) != state.progress.Version && result.RequeueAfter == 0 {
+		result.RequeueAfter = pollInterval
+	}
+	return result
+}
+
+// recordProgress writes the restart's progress to the ConfigMap if it
+// changed. Nothing is recorded before the restart is launched, so an
+// operator restarted while pre-restart Jobs run handles the change again.
+func (r *ConfigMapReconciler) recordProgress(ctx context.Context, configMap *corev1.ConfigMap, state *restartState) {
+	logger := log.FromContext(ctx)
+
+	if state.progress.Step == "" {
+		return
+	}
+
+	value, err := json.Marshal(state.progress)
+	if err != nil {
+		logger.Error(err, "Failed to encode restart progress")
+		return
+	}
+	if configMap.Annotations[restartProgressAnnotation] == string(value) {
+		return
+	}
+
+	patch := client.MergeFrom(configMap.DeepCopy())
+	if configMap.Annotations == nil {
+		configMap.Annotations = make(map[string]string)
+	}
+	configMap.Annotations[restartProgressAnnotation] = string(value)
+	if err := r.Patch(ctx, configMap, patch); err != nil {
+		logger.Error(err, "Failed to record restart progress", "configmap", client.ObjectKeyFromObject(configMap))
+	}
+}
+
+// clearProgress removes the progress of a finished restart from the ConfigMap
+func (r *ConfigMapReconciler) clearProgress(ctx context.Context, configMap *corev1.ConfigMap) {
+	if _, ok := configMap.Annotations[restartProgressAnnotation]; !ok {
+		return
+	}
+
+	patch := client.MergeFrom(configMap.DeepCopy())
+	delete(configMap.Annotations, restartProgressAnnotation)
+	if err := r.Patch(ctx, configMap, patch); err != nil {
+		log.FromContext(ctx).Error(err, "Failed to clear restart progress", "configmap", client.ObjectKeyFromObject(configMap))
+	}
+}
+
+// advanceRestart takes the restart's due steps. It returns how long until
+// the next one is due, or done once the restart finished.
+func (r *ConfigMapReconciler) advanceRestart(ctx context.Context, cfg operatorConfig, configMap *corev1.ConfigMap, state *restartState) (wait time.Duration, done bool) {
+	logger := log.FromContext(ctx)
+
+	// Pre-restart Jobs must succeed before any pod is restarted
+	if state.jobs != nil {
+		finished, err := r.stepPreRestartJobs(ctx, cfg, configMap, state.jobs)
+		if err != nil {
+			r.reportPreRestartJobFailed(ctx, configMap, err)
+			r.finishOperation(ctx, configMap, err, 0)
+			state.jobErr = err
+			return 0, true
+		}
+		if !finished {
+			return pollInterval, false
+		}
+		state.jobs = nil
+	}
+
+	if state.progress.Step == "" {
+		r.launchRestart(ctx, cfg, configMap, state)
+	}
+
+	progress := &state.progress
+	if progress.Step != restartStepFinished && time.Since(progress.Launched.Time) >= cfg.restartTimeout {
+		r.abortRestart(ctx, configMap, state)
+	}
+
+	for {
+		switch progress.Step {
+		case restartStepRestarting:
+			if wait := r.advanceWave(ctx, cfg, configMap, state); wait > 0 {
+				return wait, false
+			}
+			wave := progress.Wave
+			switch {
+			case state.waveFailed(wave):
+				state.notRestarted(ctx, wave+1, fmt.Errorf("restart wave %s failed", progress.Waves[wave]))
+				state.wavesFinished()
+			case wave < len(progress.Waves)-1:
+				// Later waves depend on this one, so it must be healthy first
+				logger.Info("Waiting for restart wave to become healthy", "wave", progress.Waves[wave])
+				state.setStep(restartStepVerifyingWave)
+			default:
+				state.wavesFinished()
+			}
+
+		case restartStepVerifyingWave:
+			wave := progress.Wave
+			wait, err := r.awaitHealthy(ctx, state.wavePods(wave), progress.StepTime.Time)
+			if err != nil {
+				logger.Error(err, "Restart wave not healthy, stopping", "wave", progress.Waves[wave])
+				state.notRestarted(ctx, wave+1, fmt.Errorf("restart wave %s unhealthy: %w", progress.Waves[wave], err))
+				state.wavesFinished()
+				continue
+			}
+			if wait > 0 {
+				return wait, false
+			}
+			progress.Wave++
+			logger.Info("Restarting wave", "wave", progress.Waves[progress.Wave], "owners", len(state.waveOwners(progress.Wave)))
+			state.setStep(restartStepRestarting)
+
+		case restartStepDeleting:
+			wait, err := r.advanceYOLO(ctx, cfg, configMap, state)
+			if err != nil {
+				state.errs = append(state.errs, err)
+				state.wavesFinished()
+				continue
+			}
+			if wait > 0 {
+				return wait, false
+			}
+			if len(progress.YOLO.Recreating) > 0 {
+				state.setStep(restartStepRecreating)
+				continue
+			}
+			state.yoloDeleted(cfg)
+
+		case restartStepRecreating:
+			yolo := progress.YOLO
+			terminating, err := r.recreateBarePods(ctx, configMap, state.refPods(yolo.Recreating), progress.StepTime.Time)
+			yolo.Recreating = nil
+			for _, pod := range terminating {
+				yolo.Recreating = append(yolo.Recreating, newPodRef(&pod))
+			}
+			if err != nil {
+				state.errs = append(state.errs, err)
+				state.wavesFinished()
+				continue
+			}
+			if len(terminating) > 0 {
+				return pollInterval, false
+			}
+			state.yoloDeleted(cfg)
+
+		case restartStepProbing:
+			finished, err := r.stepVerificationProbes(ctx, cfg, configMap, progress.Probes)
+			if err == nil && !finished {
+				return pollInterval, false
+			}
+			if err != nil {
+				state.errs = append(state.errs, err)
+			}
+			progress.Probes = nil
+			state.wavesFinished()
+
+		case restartStepWaitingForVPA:
+			remaining := r.podsStillRunning(ctx, state.refPods(progress.VPADeferred))
+			if len(remaining) == 0 {
+				state.setStep(restartStepFinished)
+				continue
+			}
+			if wait := time.Until(progress.StepTime.Add(cfg.vpaEvictionWindow)); wait > 0 {
+				return min(wait, pollInterval), false
+			}
+			logger.Info("VPA did not evict pods within window, restarting them", "count", len(remaining))
+			r.restartPods(ctx, cfg, configMap, state, remaining)
+			if progress.Step == restartStepWaitingForVPA {
+				state.setStep(restartStepFinished)
+			}
+
+		default:
+			return 0, true
+		}
+	}
+}
+
+// launchRestart starts restarting the selected pods. Pods a VPA is about to
+// evict are left to it and restarted later if it doesn't.
+func (r *ConfigMapReconciler) launchRestart(ctx context.Context, cfg operatorConfig, configMap *corev1.ConfigMap, state *restartState) {
+	logger := log.FromContext(ctx)
+
+	pods := state.selected
+	state.selected = nil
+	r.startOperation(ctx, cfg, configMap, state.progress.Version, state.changes, pods)
+	state.summary = r.summarizeRestart(ctx, configMap, pods, time.Now())
+	r.notifyStarted(ctx, cfg, configMap, state.summary)
+	state.progress.Operation = r.operationName(configMap)
+	state.progress.Pods = len(pods)
+	state.progress.Launched = metav1.Now()
+	if cfg.vpaEvictionWindow > 0 {
+		var deferred []corev1.Pod
+		deferred, pods = r.splitPodsPendingVPAEviction(ctx, configMap.Namespace, pods)
+		if len(deferred) > 0 {
+			logger.Info("Deferring pods pending VPA eviction",
+				"count", len(deferred),
+				"window", cfg.vpaEvictionWindow)
+		}
+		for _, pod := range deferred {
+			state.pods[pod.Name] = pod
+			state.progress.VPADeferred = append(state.progress.VPADeferred, newPodRef(&pod))
+		}
+	}
+
+	r.restartPods(ctx, cfg, configMap, state, pods)
+	if state.progress.Step == "" {
+		state.wavesFinished()
+	}
+}
+
+// restartPods restarts pods using the configured restart mode. YOLO mode
+// restarts them right away, otherwise each owner's batches are planned for
+// the restart to step through.
+func (r *ConfigMapReconciler) restartPods(ctx context.Context, cfg operatorConfig, configMap *corev1.ConfigMap, state *restartState, pods []corev1.Pod) {
+	logger := log.FromContext(ctx)
+
+	if len(pods) == 0 {
+		return
+	}
+
+	r.Recorder.Event(configMap, corev1.EventTypeNormal, "TriggeredRestart",
+		describeChange(fmt.Sprintf("Restarting %d pods due to ConfigMap change", len(pods)), state.changes))
+
+	if cfg.yoloMode {
+		// YOLO MODE: restart everything at once, no batching, no health checks
+		logger.Info("YOLO MODE: restarting all pods at once")
+		// Nothing is deleted without the backup it was configured to have
+		if cfg.podBackupKeep > 0 {
+			if err := r.backupPods(ctx, cfg, configMap, pods); err != nil {
+				r.Recorder.Eventf(configMap, corev1.EventTypeWarning, "PodBackupFailed",
+					"Not restarting pods, backing them up failed: %v", err)
+				state.errs = append(state.errs, err)
+				return
+			}
+		}
+		pods, err := r.beforeBatch(ctx, cfg, configMap, pods)
+		if err != nil {
+			state.errs = append(state.errs, err)
+			return
+		}
+		yolo := &yoloProgress{}
+		for _, pod := range pods {
+			state.pods[pod.Name] = pod
+			yolo.Pods = append(yolo.Pods, newPodRef(&pod))
+		}
+		state.progress.YOLO = yolo
+		state.setStep(restartStepDeleting)
+		return
+	}
+
+	// Safe mode: 50% (or one canary) per owner -> wait -> check health -> remaining
+	waves := r.planWaves(ctx, cfg, pods)
+	logger.Info("Starting rolling restart",
+		"total", len(pods),
+		"waves", len(waves))
+
+	// Pods restarted after the VPA window get waves of their own after the
+	// planned ones
+	progress := &state.progress
+	first := len(progress.Waves)
+	for _, wave := range waves {
+		index := len(progress.Waves)
+		progress.Waves = append(progress.Waves, wave.wave.String())
+		for _, plan := range wave.owners {
+			for _, pod := range plan.pods {
+				state.pods[pod.Name] = pod
+			}
+			for _, pod := range plan.cordoned {
+				logger.Info("Skipping pod on cordoned node", "pod", pod.Name, "node", pod.Spec.NodeName)
+				r.skipPod(ctx, configMap, &pod, "node is cordoned")
+			}
+			progress.Owners = append(progress.Owners, newOwnerProgress(index, plan))
+		}
+	}
+	progress.Wave = first
+	state.setStep(restartStepRestarting)
+}
+
+// newOwnerProgress returns the progress of an owner planned for a wave,
+// before it started
+func newOwnerProgress(wave int, plan ownerPlan) ownerProgress {
+	owner := ownerProgress{Wave: wave, Mode: plan.mode}
+	if ref := metav1.GetControllerOf(&plan.pods[0]); ref != nil {
+		owner.Kind, owner.Name, owner.UID = ref.Kind, ref.Name, ref.UID
+	}
+	switch {
+	case plan.workload != nil:
+		owner.WorkloadKind, owner.WorkloadName = plan.workload.Kind, plan.workload.Name
+	case plan.deployment != nil:
+		owner.WorkloadKind, owner.WorkloadName = "Deployment", plan.deployment.Name
+	}
+	for _, batch := range plan.batches {
+		refs := make([]podRef, 0, len(batch))
+		for i := range batch {
+			refs = append(refs, newPodRef(&batch[i]))
+		}
+		owner.Batches = append(owner.Batches, refs)
+	}
+	return owner
+}
+
+// newPodRef returns the reference recorded for a pod selected for restart
+func newPodRef(pod *corev1.Pod) podRef {
+	return podRef{Name: pod.Name, UID: pod.UID, NodeName: pod.Spec.NodeName}
+}
+
+// abortRestart stops a restart past its deadline. Surged Deployments are
+// scaled back and owners not done yet fail.
+func (r *ConfigMapReconciler) abortRestart(ctx context.Context, configMap *corev1.ConfigMap, state *restartState) {
+	r.restoreSurges(ctx, configMap.Namespace, state)
+	for i := range state.progress.Owners {
+		owner := &state.progress.Owners[i]
+		switch owner.Step {
+		case ownerRestartDone, ownerRestartFailed:
+		case "":
+			state.failOwner(ctx, i, fmt.Errorf("not restarted: %w", context.DeadlineExceeded))
+		default:
+			state.failOwner(ctx, i, fmt.Errorf("batch %d not finished: %w", owner.Batch+1, context.DeadlineExceeded))
+		}
+	}
+	state.timedOut = true
+	state.setStep(restartStepFinished)
+}
+
+// advanceWave takes the due steps of the current wave's owners, starting
+// waiting owners as others finish. It returns how long until the next step
+// is due, 0 once every owner of the wave finished.
+func (r *ConfigMapReconciler) advanceWave(ctx context.Context, cfg operatorConfig, configMap *corev1.ConfigMap, state *restartState) time.Duration {
+	// Workloads rolled out as a unit need no batches
+	r.rolloutWorkloads(ctx, configMap, state)
+
+	owners := state.waveOwners(state.progress.Wave)
+	for {
+		// Owners are independent, so up to maxConcurrentOwners restart at once
+		running, waiting := 0, 0
+		for _, i := range owners {
+			switch state.progress.Owners[i].Step {
+			case "":
+				waiting++
+			case ownerRestartDone, ownerRestartFailed:
+			default:
+				running++
+			}
+		}
+		for _, i := range owners {
+			owner := &state.progress.Owners[i]
+			if owner.Step != "" || running >= maxConcurrentOwners {
+				continue
+			}
+			waiting--
+			if len(owner.Batches) == 0 {
+				setOwnerStep(owner, ownerRestartDone)
+				continue
+			}
+			log.FromContext(ctx).V(1).Info("Restarting owner pods",
+				"owner", ownerName(owner.UID),
+				"batches", len(owner.Batches),
+				"firstBatch", len(owner.Batches[0]))
+			setOwnerStep(owner, ownerRestartPending)
+			running++
+		}
+
+		var wait time.Duration
+		finished := false
+		for _, i := range owners {
+			switch state.progress.Owners[i].Step {
+			case "", ownerRestartDone, ownerRestartFailed:
+				continue
+			}
+			ownerWait := r.advanceOwner(ctx, cfg, configMap, state, i)
+			if ownerWait == 0 {
+				finished = true
+			} else if wait == 0 || ownerWait < wait {
+				wait = ownerWait
+			}
+		}
+		if !finished || waiting == 0 {
+			return wait
+		}
+	}
+}
+
+// advanceOwner takes the due steps of one owner's restart. It returns how
+// long until the next one is due, 0 once the owner is done or failed.
+func (r *ConfigMapReconciler) advanceOwner(ctx context.Context, cfg operatorConfig, configMap *corev1.ConfigMap, state *restartState, i int) time.Duration {
+	owner := &state.progress.Owners[i]
+	for {
+		switch owner.Step {
+		case ownerRestartDone, ownerRestartFailed:
+			return 0
+		}
+		wait, err := r.stepOwner(ctx, cfg, configMap, state, owner)
+		if err != nil {
+			state.failOwner(ctx, i, err)
+			return 0
+		}
+		if wait > 0 {
+			return wait
+		}
+	}
+}
+
+// stepOwner takes the owner's current step once. It returns how long until
+// the step should be taken again, 0 if the owner moved on to another step.
+func (r *ConfigMapReconciler) stepOwner(ctx context.Context, cfg operatorConfig, configMap *corev1.ConfigMap, state *restartState, owner *ownerProgress) (time.Duration, error) {
+	switch owner.Step {
+	case ownerRestartPending:
+		return 0, r.startBatch(ctx, cfg, configMap, state, owner)
+	case ownerRestartEvicting:
+		return r.evictBatch(ctx, cfg, configMap, state, owner)
+	case ownerRestartRecreating:
+		return r.recreateBatch(ctx, cfg, configMap, state, owner)
+	case ownerRestartProbing:
+		return r.probeOwnerBatch(ctx, cfg, configMap, state, owner)
+	case ownerRestartVerifying:
+		return r.verifyOwnerBatch(ctx, cfg, state, owner)
+	case ownerRestartSoaking:
+		return r.soakCanary(ctx, cfg, configMap, state, owner)
+	case ownerRestartSurging:
+		return r.awaitSurge(ctx, configMap, state, owner)
+	case ownerRestartDraining:
+		return r.awaitSurgeDrained(ctx, cfg, state, owner)
+	}
+	return 0, fmt.Errorf("unknown restart step %q", owner.Step)
+}
+
+// startBatch asks the restart policy and hooks about the owner's current
+// batch and starts evicting or surging the pods they let through
+func (r *ConfigMapReconciler) startBatch(ctx context.Context, cfg operatorConfig, configMap *corev1.ConfigMap, state *restartState, owner *ownerProgress) error {
+	pods, err := r.beforeBatch(ctx, cfg, configMap, state.batchPods(owner, nil))
+	if err != nil {
+		return batchError(owner, err)
+	}
+
+	owner.Pending, owner.Blocked, owner.Restarted = podNames(pods), nil, nil
+	if owner.Mode == ownerRestartSurge {
+		setOwnerStep(owner, ownerRestartSurging)
+		return nil
+	}
+	setOwnerStep(owner, ownerRestartEvicting)
+	return nil
+}
+
+// evictBatch evicts the owner's pending pods as the restart budget allows,
+// one at a time while evicted pods drain. Evictions a PDB rejects are retried
+// until pdbWaitTimeout, then skipped and retried later by retryBlockedPods.
+func (r *ConfigMapReconciler) evictBatch(ctx context.Context, cfg operatorConfig, configMap *corev1.ConfigMap, state *restartState, owner *ownerProgress) (time.Duration, error) {
+	logger := log.FromContext(ctx)
+
+	retrying := len(owner.Pending) == 0
+	names := owner.Pending
+	if retrying {
+		names = owner.Blocked
+	}
+	if len(names) > 0 {
+		pass := r.evictPods(ctx, configMap, state.batchPods(owner, names), retrying)
+		for _, pod := range append(pass.restarted, pass.blocked...) {
+			state.pods[pod.Name] = pod
+		}
+		owner.Restarted = append(owner.Restarted, podNames(pass.restarted)...)
+		if retrying {
+			owner.Blocked = append(podNames(pass.blocked), podNames(pass.held)...)
+		} else {
+			owner.Pending = podNames(pass.held)
+			owner.Blocked = append(owner.Blocked, podNames(pass.blocked)...)
+		}
+		if pass.wait > 0 {
+			return pass.wait, nil
+		}
+	}
+
+	if len(owner.Blocked) > 0 {
+		if time.Since(owner.StepTime.Time) < pdbWaitTimeout {
+			logger.V(1).Info("Requeueing pods blocked by PodDisruptionBudget", "count", len(owner.Blocked))
+			return pollInterval, nil
+		}
+		for _, pod := range state.batchPods(owner, owner.Blocked) {
+			logger.Error(fmt.Errorf("timeout waiting for PDB to allow eviction of pod %s", pod.Name),
+				"Skipping pod", "pod", pod.Name)
+			r.skipPod(ctx, configMap, &pod, "blocked by PodDisruptionBudget")
+			r.trackBlockedPod(configMap, pod)
+		}
+		owner.Blocked = nil
+	}
+
+	if err := r.afterBatch(ctx, configMap, state.batchPods(owner, owner.Restarted)); err != nil {
+		return 0, batchError(owner, err)
+	}
+	if owner.UID == "" && cfg.recreateBarePods && len(owner.Restarted) > 0 {
+		owner.Pending = owner.Restarted
+		setOwnerStep(owner, ownerRestartRecreating)
+		return 0, nil
+	}
+	state.batchEvicted(ctx, cfg, owner)
+	return 0, nil
+}
+
+// recreateBatch creates the batch's evicted bare pods again once the old
+// ones are gone
+func (r *ConfigMapReconciler) recreateBatch(ctx context.Context, cfg operatorConfig, configMap *corev1.ConfigMap, state *restartState, owner *ownerProgress) (time.Duration, error) {
+	terminating, err := r.recreateBarePods(ctx, configMap, state.batchPods(owner, owner.Pending), owner.StepTime.Time)
+	owner.Pending = podNames(terminating)
+	if err != nil {
+		return 0, batchError(owner, err)
+	}
+	if len(terminating) > 0 {
+		return pollInterval, nil
+	}
+	state.batchEvicted(ctx, cfg, owner)
+	return 0, nil
+}
+
+// advanceYOLO deletes the pods YOLO mode has yet to, as far as the restart
+// budget allows. It returns how long until the budget has room for the
+// rest, 0 once every pod was tried.
+func (r *ConfigMapReconciler) advanceYOLO(ctx context.Context, cfg operatorConfig, configMap *corev1.ConfigMap, state *restartState) (time.Duration, error) {
+	yolo := state.progress.YOLO
+	pass, err := r.yoloRestart(ctx, configMap, state.refPods(yolo.Pods[yolo.Next:]))
+	yolo.Next = len(yolo.Pods) - len(pass.held)
+	yolo.Restarted += len(pass.restarted)
+	if cfg.recreateBarePods {
+		for _, pod := range pass.restarted {
+			if metav1.GetControllerOf(&pod) == nil {
+				yolo.Recreating = append(yolo.Recreating, newPodRef(&pod))
+			}
+		}
+	}
+	return pass.wait, err
+}
+
+// probeOwnerBatch runs the verification probes for the owner's restarted
+// batch, retrying a failed one after pollInterval
+func (r *ConfigMapReconciler) probeOwnerBatch(ctx context.Context, cfg operatorConfig, configMap *corev1.ConfigMap, state *restartState, owner *ownerProgress) (time.Duration, error) {
+	finished, err := r.stepVerificationProbes(ctx, cfg, configMap, owner.Probes)
+	if err != nil {
+		return 0, batchError(owner, err)
+	}
+	if !finished {
+		return pollInterval, nil
+	}
+	owner.Probes = nil
+	// Surge batches need no health check, the surged pods were Ready
+	if owner.Mode == ownerRestartSurge {
+		nextBatch(owner)
+		return 0, nil
+	}
+	state.batchRestarted(ctx, owner)
+	return 0, nil
+}
+
+// verifyOwnerBatch waits for the replacements of the owner's restarted
+// batch to be healthy before the next batch
+func (r *ConfigMapReconciler) verifyOwnerBatch(ctx context.Context, cfg operatorConfig, state *restartState, owner *ownerProgress) (time.Duration, error) {
+	logger := log.FromContext(ctx).WithValues("owner", ownerName(owner.UID))
+	restarted := state.batchPods(owner, owner.Restarted)
+
+	if owner.Mode == ownerRestartNodes {
+		healthy, err := r.nodesHealthy(ctx, owner.UID, restarted)
+		if err == nil && !healthy && time.Since(owner.StepTime.Time) >= podReadyTimeout {
+			err = fmt.Errorf("timeout waiting for DaemonSet pods to become healthy")
+		}
+		if err != nil {
+			logger.Error(err, "Replacement pods not healthy, aborting remaining nodes")
+			return 0, fmt.Errorf("nodes unhealthy: %w", err)
+		}
+		if !healthy {
+			return pollInterval, nil
+		}
+	} else {
+		since := owner.StepTime.Add(batchWaitDuration)
+		if wait := time.Until(since); wait > 0 {
+			return wait, nil
+		}
+		wait, err := r.awaitHealthy(ctx, restarted, since)
+		if err != nil {
+			logger.Error(err, "Previous batch pods not healthy, aborting remaining batches")
+			return 0, fmt.Errorf("batch %d unhealthy: %w", owner.Batch+1, err)
+		}
+		if wait > 0 {
+			return wait, nil
+		}
+	}
+
+	if owner.Batch == 0 && cfg.strategy == autoapplyv1alpha1.RestartStrategyCanary && owner.UID != "" {
+		logger.Info("Canary healthy, soaking", "duration", cfg.canarySoakDuration)
+		setOwnerStep(owner, ownerRestartSoaking)
+		return 0, nil
+	}
+	logger.Info("Previous batch healthy, restarting next batch", "batch", owner.Batch+2)
+	nextBatch(owner)
+	return 0, nil
+}
+
+// failOwner stops an owner's restart with err
+func (s *restartState) failOwner(ctx context.Context, i int, err error) {
+	owner := &s.progress.Owners[i]
+	log.FromContext(ctx).Error(err, "Owner restart failed", "owner", s.ownerError(owner, err).owner())
+	setOwnerStep(owner, ownerRestartFailed)
+	owner.Message = err.Error()
+	s.failures[i] = err
+}
+
+// notRestarted fails every owner of the waves from the given one on, which
+// were never reached
+func (s *restartState) notRestarted(ctx context.Context, from int, cause error) {
+	for i := range s.progress.Owners {
+		if s.progress.Owners[i].Wave >= from {
+			s.failOwner(ctx, i, fmt.Errorf("not restarted: %w", cause))
+		}
+	}
+}
+
+// waveFailed checks if any owner of the wave failed
+func (s *restartState) waveFailed(wave int) bool {
+	for _, i := range s.waveOwners(wave) {
+		if s.progress.Owners[i].Step == ownerRestartFailed {
+			return true
+		}
+	}
+	return false
+}
+
+// wavesFinished moves on once the planned waves ran, to waiting for VPA if
+// it was left pods that weren't restarted since
+func (s *restartState) wavesFinished() {
+	if len(s.progress.VPADeferred) > 0 && !s.vpaDeferredPlanned() {
+		s.setStep(restartStepWaitingForVPA)
+		return
+	}
+	s.setStep(restartStepFinished)
+}
+
+// yoloDeleted moves on once YOLO mode deleted, and re-created, the pods: to
+// the verification probes if there are any, else as wavesFinished
+func (s *restartState) yoloDeleted(cfg operatorConfig) {
+	if s.progress.YOLO.Restarted > 0 && len(cfg.verificationProbes) > 0 {
+		s.progress.Probes = &probeProgress{}
+		s.setStep(restartStepProbing)
+		return
+	}
+	s.wavesFinished()
+}
+
+// vpaDeferredPlanned checks if pods left to VPA were planned into waves, or
+// deleted by YOLO mode, after its eviction window passed
+func (s *restartState) vpaDeferredPlanned() bool {
+	deferred := make(map[string]bool, len(s.progress.VPADeferred))
+	for _, ref := range s.progress.VPADeferred {
+		deferred[ref.Name] = true
+	}
+	for _, owner := range s.progress.Owners {
+		for _, batch := range owner.Batches {
+			for _, ref := range batch {
+				if deferred[ref.Name] {
+					return true
+				}
+			}
+		}
+	}
+	if s.progress.YOLO != nil {
+		for _, ref := range s.progress.YOLO.Pods {
+			if deferred[ref.Name] {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// setStep moves the restart to step
+func (s *restartState) setStep(step restartStep) {
+	s.progress.Step = step
+	s.progress.StepTime = ptr.To(metav1.Now())
+}
+
+// setOwnerStep moves an owner's current batch to step
+func setOwnerStep(owner *ownerProgress, step ownerRestartStep) {
+	owner.Step = step
+	owner.StepTime = ptr.To(metav1.Now())
+}
+
+// batchEvicted moves on once the owner's current batch was evicted: to the
+// verification probes if there are any, else as batchRestarted
+func (s *restartState) batchEvicted(ctx context.Context, cfg operatorConfig, owner *ownerProgress) {
+	if len(owner.Restarted) > 0 && len(cfg.verificationProbes) > 0 {
+		owner.Probes = &probeProgress{}
+		setOwnerStep(owner, ownerRestartProbing)
+		return
+	}
+	s.batchRestarted(ctx, owner)
+}
+
+// batchRestarted moves on once the owner's current batch restarted: to
+// verifying it before the next batch, or done after the last one
+func (s *restartState) batchRestarted(ctx context.Context, owner *ownerProgress) {
+	if owner.Mode == ownerRestartBatches && owner.Batch == 0 && len(owner.Restarted) == 0 {
+		log.FromContext(ctx).Info("No pods were restarted in first batch", "owner", ownerName(owner.UID))
+		setOwnerStep(owner, ownerRestartDone)
+		return
+	}
+	if owner.Batch == len(owner.Batches)-1 {
+		setOwnerStep(owner, ownerRestartDone)
+		return
+	}
+	setOwnerStep(owner, ownerRestartVerifying)
+}
+
+// nextBatch moves the owner on to its next batch, or done after the last one
+func nextBatch(owner *ownerProgress) {
+	if owner.Batch == len(owner.Batches)-1 {
+		setOwnerStep(owner, ownerRestartDone)
+		return
+	}
+	owner.Batch++
+	owner.Pending, owner.Blocked, owner.Restarted = nil, nil, nil
+	setOwnerStep(owner, ownerRestartPending)
+}
+
+// batchError attributes err to the owner's current batch
+func batchError(owner *ownerProgress, err error) error {
+	if owner.Mode != ownerRestartNodes {
+		return fmt.Errorf("batch %d failed: %w", owner.Batch+1, err)
+	}
+	start := 1
+	for _, batch := range owner.Batches[:owner.Batch] {
+		start += len(batch)
+	}
+	return fmt.Errorf("nodes %d-%d failed: %w", start, start+len(owner.Batches[owner.Batch])-1, err)
+}
+
+// waveOwners returns the indexes of the wave's owners in progress.Owners
+func (s *restartState) waveOwners(wave int) []int {
+	var owners []int
+	for i, owner := range s.progress.Owners {
+		if owner.Wave == wave {
+			owners = append(owners, i)
+		}
+	}
+	return owners
+}
+
+// wavePods returns every planned pod of the wave
+func (s *restartState) wavePods(wave int) []corev1.Pod {
+	var pods []corev1.Pod
+	for _, i := range s.waveOwners(wave) {
+		pods = append(pods, s.ownerPods(&s.progress.Owners[i])...)
+	}
+	return pods
+}
+
+// plannedPods returns every pod the restart was planned for, including
+// those left to VPA
+func (s *restartState) plannedPods() []corev1.Pod {
+	var pods []corev1.Pod
+	for wave := range s.progress.Waves {
+		pods = append(pods, s.wavePods(wave)...)
+	}
+	if s.progress.YOLO != nil {
+		pods = append(pods, s.refPods(s.progress.YOLO.Pods)...)
+	}
+	return append(pods, s.refPods(s.progress.VPADeferred)...)
+}
+
+// refPods returns the referenced pods as last seen
+func (s *restartState) refPods(refs []podRef) []corev1.Pod {
+	pods := make([]corev1.Pod, 0, len(refs))
+	for _, ref := range refs {
+		pods = append(pods, s.pods[ref.Name])
+	}
+	return pods
+}
+
+// batchPods returns the pods of the owner's current batch with the given
+// names, or all of them if names is nil
+func (s *restartState) batchPods(owner *ownerProgress, names []string) []corev1.Pod {
+	batch := owner.Batches[owner.Batch]
+	if names == nil {
+		return s.refPods(batch)
+	}
+	var pods []corev1.Pod
+	for _, ref := range batch {
+		if slices.Contains(names, ref.Name) {
+			pods = append(pods, s.pods[ref.Name])
+		}
+	}
+	return pods
+}
+
+// ownerPods returns every planned pod of the owner
+func (s *restartState) ownerPods(owner *ownerProgress) []corev1.Pod {
+	var pods []corev1.Pod
+	for _, batch := range owner.Batches {
+		pods = append(pods, s.refPods(batch)...)
+	}
+	return pods
+}
+
+// ownerError attributes err to the owner, or to the workload it rolls out
+func (s *restartState) ownerError(owner *ownerProgress, err error) *OwnerRestartError {
+	restartErr := &OwnerRestartError{Kind: owner.Kind, Name: owner.Name, Err: err}
+	if owner.Mode == ownerRestartRollout {
+		restartErr.Kind, restartErr.Name = owner.WorkloadKind, owner.WorkloadName
+	}
+	restartErr.Pods = podNames(s.ownerPods(owner))
+	return restartErr
+}
+
+// err joins the failures of the restart, one OwnerRestartError per failed owner
+func (s *restartState) err() error {
+	errs := slices.Clone(s.errs)
+	for i := range s.progress.Owners {
+		owner := &s.progress.Owners[i]
+		if owner.Step != ownerRestartFailed {
+			continue
+		}
+		err, ok := s.failures[i]
+		if !ok {
+			// Failed before the operator restarted
+			err = errors.New(owner.Message)
+		}
+		errs = append(errs, s.ownerError(owner, err))
+	}
+	if s.timedOut {
+		errs = append(errs, context.DeadlineExceeded)
+	}
+	return errors.Join(errs...)
+}
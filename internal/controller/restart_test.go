@@ -0,0 +1,889 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/ptr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+
+	autoapplyv1alpha1 "github.com/manos/k8s-autoapply-operator/api/v1alpha1"
+)
+
+// runRestart takes a restart of pods through all its steps, waiting between
+// them as the requeues would, and returns the error it finished with
+func runRestart(ctx context.Context, r *ConfigMapReconciler, cfg operatorConfig, configMap *corev1.ConfigMap, version string, pods []corev1.Pod) error {
+	return finishSteps(ctx, r, cfg, configMap, newRestart(version, pods))
+}
+
+// finishSteps takes a restart through its remaining steps, waiting between
+// them as the requeues would, and returns the error it finished with
+func finishSteps(ctx context.Context, r *ConfigMapReconciler, cfg operatorConfig, configMap *corev1.ConfigMap, state *restartState) error {
+	for {
+		wait, done := r.advanceRestart(ctx, cfg, configMap, state)
+		if done {
+			break
+		}
+		time.Sleep(wait)
+	}
+	if state.jobErr != nil {
+		return state.jobErr
+	}
+	return state.err()
+}
+
+func TestRestart_StepsWithoutBlocking(t *testing.T) {
+	r, fakeClient := setupTestReconciler()
+	ctx := context.Background()
+
+	cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "test-config", Namespace: "default"}}
+	pods := createOwnedPods(ctx, fakeClient, "deploy-a", 2)
+
+	cfg := r.loadConfig(ctx, nil)
+	state := newRestart("v2", pods)
+	wait, done := r.advanceRestart(ctx, cfg, cm, state)
+	if done || wait <= 0 {
+		t.Fatalf("Expected the restart to requeue while the first batch settles, got wait %v done %v", wait, done)
+	}
+
+	owner := state.progress.Owners[0]
+	if state.progress.Step != restartStepRestarting || owner.Step != ownerRestartVerifying || owner.Batch != 0 {
+		t.Errorf("Expected the first batch to be verified, got %+v", state.progress)
+	}
+	var remaining corev1.PodList
+	_ = fakeClient.List(ctx, &remaining, client.InNamespace("default"))
+	if len(remaining.Items) != 2 {
+		t.Errorf("Expected only the first batch to be evicted, found %v", podNames(remaining.Items))
+	}
+}
+
+func TestRestart_ResumesAfterOperatorRestart(t *testing.T) {
+	r, fakeClient := setupTestReconciler()
+	ctx := context.Background()
+
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-config", Namespace: "default"},
+		Data:       map[string]string{"key": "v2"},
+	}
+	_ = fakeClient.Create(ctx, cm)
+	pods := createOwnedPods(ctx, fakeClient, "deploy-a", 2)
+	cfg := r.loadConfig(ctx, cm)
+	version := configMapVersion(cm)
+
+	// The first operator evicts the first batch, then goes away
+	state := newRestart(version, pods)
+	if _, done := r.advanceRestart(ctx, cfg, cm, state); done {
+		t.Fatal("Expected the restart to be in progress")
+	}
+	r.recordProgress(ctx, cm, state)
+
+	var recorded corev1.ConfigMap
+	_ = fakeClient.Get(ctx, client.ObjectKeyFromObject(cm), &recorded)
+	if _, ok := recorded.Annotations[restartProgressAnnotation]; !ok {
+		t.Fatal("Expected the progress to be recorded on the ConfigMap")
+	}
+
+	// A new operator resumes it from the ConfigMap
+	resumed := &ConfigMapReconciler{Client: fakeClient, Scheme: r.Scheme, Recorder: record.NewFakeRecorder(100)}
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: "test-config", Namespace: "default"}}
+	for i := 0; ; i++ {
+		if i == 20 {
+			t.Fatal("Expected the resumed restart to finish")
+		}
+		result, err := resumed.Reconcile(ctx, req)
+		if err != nil {
+			t.Fatalf("Reconcile failed: %v", err)
+		}
+		if result.RequeueAfter == 0 {
+			break
+		}
+		time.Sleep(result.RequeueAfter)
+	}
+
+	var remaining corev1.PodList
+	_ = fakeClient.List(ctx, &remaining, client.InNamespace("default"))
+	if len(remaining.Items) != 1 {
+		t.Errorf("Expected only the ready pod to remain, found %v", podNames(remaining.Items))
+	}
+	var finished corev1.ConfigMap
+	_ = fakeClient.Get(ctx, client.ObjectKeyFromObject(cm), &finished)
+	if _, ok := finished.Annotations[restartProgressAnnotation]; ok {
+		t.Error("Expected the progress to be cleared once the restart finished")
+	}
+	if persisted, _ := persistedVersion(&finished); persisted != version {
+		t.Errorf("Expected the restart's version to be persisted, got %q", persisted)
+	}
+}
+
+// createOwnedPods creates n pods of a ReplicaSet to restart, and a ready pod
+// of it that lets the health check pass between batches
+func createOwnedPods(ctx context.Context, c client.Client, owner string, n int) []corev1.Pod {
+	ownerRefs := []metav1.OwnerReference{
+		{APIVersion: "apps/v1", Kind: "ReplicaSet", Name: owner, UID: types.UID(owner), Controller: ptr.To(true)},
+	}
+	_ = c.Create(ctx, &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: owner + "-ready", Namespace: "default", UID: types.UID(owner + "-ready"), OwnerReferences: ownerRefs},
+		Status: corev1.PodStatus{
+			Phase:      corev1.PodRunning,
+			Conditions: []corev1.PodCondition{{Type: corev1.PodReady, Status: corev1.ConditionTrue}},
+		},
+	})
+
+	var pods []corev1.Pod
+	for i := 1; i <= n; i++ {
+		name := fmt.Sprintf("%s-%d", owner, i)
+		pod := corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", UID: types.UID(name), OwnerReferences: ownerRefs},
+			Status:     corev1.PodStatus{Phase: corev1.PodRunning},
+		}
+		_ = c.Create(ctx, &pod)
+		pods = append(pods, pod)
+	}
+	return pods
+}
+
+// ownerRestart returns a restart of one owner's planned batches, with its
+// wave restarting and the owner's current batch at step
+func ownerRestart(plan ownerPlan, step ownerRestartStep) *restartState {
+	state := newRestart("v2", nil)
+	state.jobs = nil
+	for _, pod := range plan.pods {
+		state.pods[pod.Name] = pod
+	}
+	state.progress.Launched = metav1.Now()
+	state.progress.Waves = []string{"0"}
+	state.progress.Owners = []ownerProgress{newOwnerProgress(0, plan)}
+	state.setStep(restartStepRestarting)
+	setOwnerStep(&state.progress.Owners[0], step)
+	return state
+}
+
+// halves plans an owner's two pods as two batches of one
+func halves(ownerUID types.UID, mode ownerRestartMode, pods []corev1.Pod) ownerPlan {
+	return ownerPlan{uid: ownerUID, mode: mode, pods: pods, batches: [][]corev1.Pod{{pods[0]}, {pods[1]}}}
+}
+
+// backdate moves the owner's current step back by d
+func backdate(owner *ownerProgress, d time.Duration) {
+	owner.StepTime = ptr.To(metav1.NewTime(owner.StepTime.Add(-d)))
+}
+
+// createBarePods creates n pods without a controller, which only the
+// operator creates again
+func createBarePods(ctx context.Context, c client.Client, n int) []corev1.Pod {
+	var pods []corev1.Pod
+	for i := 1; i <= n; i++ {
+		name := fmt.Sprintf("bare-%d", i)
+		pod := corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", UID: types.UID(name)},
+			Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "app", Image: "nginx"}}},
+		}
+		_ = c.Create(ctx, &pod)
+		pods = append(pods, pod)
+	}
+	return pods
+}
+
+// deletePods deletes the named pods
+func deletePods(ctx context.Context, c client.Client, names ...string) {
+	for _, name := range names {
+		_ = c.Delete(ctx, &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"}})
+	}
+}
+
+// remainingPods returns the names of the pods left in the namespace, sorted
+func remainingPods(ctx context.Context, c client.Client) []string {
+	var pods corev1.PodList
+	_ = c.List(ctx, &pods, client.InNamespace("default"))
+	names := podNames(pods.Items)
+	slices.Sort(names)
+	return names
+}
+
+// ownerFixture sets up an owner's restart for a test: a ReplicaSet's pods,
+// bare pods, or a Deployment's pods restarted by surging
+type ownerFixture func(t *testing.T, ctx context.Context) (*ConfigMapReconciler, client.Client, ownerPlan)
+
+func replicaSetFixture(t *testing.T, ctx context.Context) (*ConfigMapReconciler, client.Client, ownerPlan) {
+	r, fakeClient := setupTestReconciler()
+	return r, fakeClient, halves("deploy-a", ownerRestartBatches, createOwnedPods(ctx, fakeClient, "deploy-a", 2))
+}
+
+func barePodFixture(t *testing.T, ctx context.Context) (*ConfigMapReconciler, client.Client, ownerPlan) {
+	r, fakeClient := setupTestReconciler()
+	return r, fakeClient, halves("", ownerRestartBatches, createBarePods(ctx, fakeClient, 2))
+}
+
+func surgeOwnerFixture(t *testing.T, ctx context.Context) (*ConfigMapReconciler, client.Client, ownerPlan) {
+	deployment, pods := surgeFixture(1)
+	r, fakeClient, _ := setupSurgeTest(t, deployment, pods...)
+	_ = fakeClient.Create(ctx, surgeReplicaSet())
+	plan := halves("web-abc-uid", ownerRestartSurge, surgePods(pods))
+	plan.deployment = deployment
+	return r, fakeClient, plan
+}
+
+func TestStepOwner_Transitions(t *testing.T) {
+	tests := []struct {
+		name     string
+		fixture  ownerFixture
+		step     ownerRestartStep
+		strategy autoapplyv1alpha1.RestartStrategy
+		// prepare sets up the owner's batch for the step
+		prepare   func(ctx context.Context, c client.Client, owner *ownerProgress)
+		wantStep  ownerRestartStep
+		wantBatch int
+		wantWait  bool
+		wantErr   string
+		// check makes further assertions once the step was taken
+		check func(t *testing.T, ctx context.Context, c client.Client, owner *ownerProgress)
+	}{
+		{
+			name:     "Pending starts evicting the batch",
+			fixture:  replicaSetFixture,
+			step:     ownerRestartPending,
+			wantStep: ownerRestartEvicting,
+			check: func(t *testing.T, ctx context.Context, c client.Client, owner *ownerProgress) {
+				if !slices.Equal(owner.Pending, []string{"deploy-a-1"}) {
+					t.Errorf("Expected the batch's pod pending, got %v", owner.Pending)
+				}
+			},
+		},
+		{
+			name:    "Evicting verifies the evicted batch",
+			fixture: replicaSetFixture,
+			step:    ownerRestartEvicting,
+			prepare: func(ctx context.Context, c client.Client, owner *ownerProgress) {
+				owner.Pending = []string{"deploy-a-1"}
+			},
+			wantStep: ownerRestartVerifying,
+			check: func(t *testing.T, ctx context.Context, c client.Client, owner *ownerProgress) {
+				if !slices.Equal(owner.Restarted, []string{"deploy-a-1"}) {
+					t.Errorf("Expected the batch's pod restarted, got %v", owner.Restarted)
+				}
+				if remaining := remainingPods(ctx, c); slices.Contains(remaining, "deploy-a-1") {
+					t.Errorf("Expected the batch's pod evicted, found %v", remaining)
+				}
+			},
+		},
+		{
+			name:    "Evicting the last batch finishes the owner",
+			fixture: replicaSetFixture,
+			step:    ownerRestartEvicting,
+			prepare: func(ctx context.Context, c client.Client, owner *ownerProgress) {
+				owner.Batch, owner.Pending = 1, []string{"deploy-a-2"}
+			},
+			wantStep:  ownerRestartDone,
+			wantBatch: 1,
+		},
+		{
+			name:    "Probing without probes verifies the batch",
+			fixture: replicaSetFixture,
+			step:    ownerRestartProbing,
+			prepare: func(ctx context.Context, c client.Client, owner *ownerProgress) {
+				deletePods(ctx, c, "deploy-a-1")
+				owner.Restarted, owner.Probes = []string{"deploy-a-1"}, &probeProgress{}
+			},
+			wantStep: ownerRestartVerifying,
+		},
+		{
+			name:    "Recreating waits for the old pod to go",
+			fixture: barePodFixture,
+			step:    ownerRestartRecreating,
+			prepare: func(ctx context.Context, c client.Client, owner *ownerProgress) {
+				owner.Pending, owner.Restarted = []string{"bare-1"}, []string{"bare-1"}
+			},
+			wantStep: ownerRestartRecreating,
+			wantWait: true,
+		},
+		{
+			name:    "Recreating re-creates the gone pod and verifies it",
+			fixture: barePodFixture,
+			step:    ownerRestartRecreating,
+			prepare: func(ctx context.Context, c client.Client, owner *ownerProgress) {
+				deletePods(ctx, c, "bare-1")
+				owner.Pending, owner.Restarted = []string{"bare-1"}, []string{"bare-1"}
+			},
+			wantStep: ownerRestartVerifying,
+			check: func(t *testing.T, ctx context.Context, c client.Client, owner *ownerProgress) {
+				if remaining := remainingPods(ctx, c); !slices.Contains(remaining, "bare-1") {
+					t.Errorf("Expected the bare pod re-created, found %v", remaining)
+				}
+			},
+		},
+		{
+			name:    "Recreating fails once the old pod took too long to go",
+			fixture: barePodFixture,
+			step:    ownerRestartRecreating,
+			prepare: func(ctx context.Context, c client.Client, owner *ownerProgress) {
+				owner.Pending, owner.Restarted = []string{"bare-1"}, []string{"bare-1"}
+				backdate(owner, (&ConfigMapReconciler{}).podGoneTimeout(&corev1.Pod{})+time.Second)
+			},
+			wantErr: "timeout waiting for pod bare-1 to terminate",
+		},
+		{
+			name:    "Verifying waits between batches",
+			fixture: replicaSetFixture,
+			step:    ownerRestartVerifying,
+			prepare: func(ctx context.Context, c client.Client, owner *ownerProgress) {
+				deletePods(ctx, c, "deploy-a-1")
+				owner.Restarted = []string{"deploy-a-1"}
+			},
+			wantStep: ownerRestartVerifying,
+			wantWait: true,
+		},
+		{
+			name:    "Verifying starts the next batch once healthy",
+			fixture: replicaSetFixture,
+			step:    ownerRestartVerifying,
+			prepare: func(ctx context.Context, c client.Client, owner *ownerProgress) {
+				deletePods(ctx, c, "deploy-a-1")
+				owner.Restarted = []string{"deploy-a-1"}
+				backdate(owner, batchWaitDuration)
+			},
+			wantStep:  ownerRestartPending,
+			wantBatch: 1,
+		},
+		{
+			name:     "Verifying a healthy canary soaks it",
+			fixture:  replicaSetFixture,
+			step:     ownerRestartVerifying,
+			strategy: autoapplyv1alpha1.RestartStrategyCanary,
+			prepare: func(ctx context.Context, c client.Client, owner *ownerProgress) {
+				deletePods(ctx, c, "deploy-a-1")
+				owner.Restarted = []string{"deploy-a-1"}
+				backdate(owner, batchWaitDuration)
+			},
+			wantStep: ownerRestartSoaking,
+		},
+		{
+			name:    "Verifying fails once the batch took too long to become healthy",
+			fixture: replicaSetFixture,
+			step:    ownerRestartVerifying,
+			prepare: func(ctx context.Context, c client.Client, owner *ownerProgress) {
+				deletePods(ctx, c, "deploy-a-1", "deploy-a-ready")
+				owner.Restarted = []string{"deploy-a-1"}
+				backdate(owner, batchWaitDuration+podReadyTimeout+time.Second)
+			},
+			wantErr: "batch 1 unhealthy: timeout waiting for pods to become healthy",
+		},
+		{
+			name:     "Soaking waits out the soak",
+			fixture:  replicaSetFixture,
+			step:     ownerRestartSoaking,
+			strategy: autoapplyv1alpha1.RestartStrategyCanary,
+			prepare: func(ctx context.Context, c client.Client, owner *ownerProgress) {
+				deletePods(ctx, c, "deploy-a-1")
+				owner.Restarted = []string{"deploy-a-1"}
+			},
+			wantStep: ownerRestartSoaking,
+			wantWait: true,
+		},
+		{
+			name:     "Soaking starts the next batch after the soak",
+			fixture:  replicaSetFixture,
+			step:     ownerRestartSoaking,
+			strategy: autoapplyv1alpha1.RestartStrategyCanary,
+			prepare: func(ctx context.Context, c client.Client, owner *ownerProgress) {
+				deletePods(ctx, c, "deploy-a-1")
+				owner.Restarted = []string{"deploy-a-1"}
+				backdate(owner, defaultCanarySoakDuration)
+			},
+			wantStep:  ownerRestartPending,
+			wantBatch: 1,
+		},
+		{
+			name:    "Surging scales the Deployment up by the batch",
+			fixture: surgeOwnerFixture,
+			step:    ownerRestartSurging,
+			prepare: func(ctx context.Context, c client.Client, owner *ownerProgress) {
+				owner.Pending = []string{"web-abc-1"}
+			},
+			wantStep: ownerRestartSurging,
+			check: func(t *testing.T, ctx context.Context, c client.Client, owner *ownerProgress) {
+				var deployment appsv1.Deployment
+				_ = c.Get(ctx, types.NamespacedName{Name: "web", Namespace: "default"}, &deployment)
+				if owner.Replicas == nil || *owner.Replicas != 2 || *deployment.Spec.Replicas != 3 {
+					t.Errorf("Expected the Deployment surged from 2 to 3 replicas, got %v and %d", owner.Replicas, *deployment.Spec.Replicas)
+				}
+			},
+		},
+		{
+			name:    "Surging scales back once the surge is Ready",
+			fixture: surgeOwnerFixture,
+			step:    ownerRestartSurging,
+			prepare: func(ctx context.Context, c client.Client, owner *ownerProgress) {
+				owner.Pending, owner.Replicas = []string{"web-abc-1"}, ptr.To(int32(2))
+			},
+			wantStep: ownerRestartDraining,
+			check: func(t *testing.T, ctx context.Context, c client.Client, owner *ownerProgress) {
+				if !slices.Equal(owner.Restarted, []string{"web-abc-1"}) || owner.Replicas != nil {
+					t.Errorf("Expected the batch's pod marked for removal, got %+v", owner)
+				}
+			},
+		},
+		{
+			name:    "Draining waits for the batch's pods to go",
+			fixture: surgeOwnerFixture,
+			step:    ownerRestartDraining,
+			prepare: func(ctx context.Context, c client.Client, owner *ownerProgress) {
+				owner.Restarted = []string{"web-abc-1"}
+			},
+			wantStep: ownerRestartDraining,
+			wantWait: true,
+		},
+		{
+			name:    "Draining starts the next batch once the pods are gone",
+			fixture: surgeOwnerFixture,
+			step:    ownerRestartDraining,
+			prepare: func(ctx context.Context, c client.Client, owner *ownerProgress) {
+				deletePods(ctx, c, "web-abc-1")
+				owner.Restarted = []string{"web-abc-1"}
+			},
+			wantStep:  ownerRestartPending,
+			wantBatch: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			r, fakeClient, plan := tt.fixture(t, ctx)
+			cfg := r.loadConfig(ctx, nil)
+			if tt.strategy != "" {
+				cfg.strategy = tt.strategy
+			}
+			cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "test-config", Namespace: "default"}}
+
+			state := ownerRestart(plan, tt.step)
+			owner := &state.progress.Owners[0]
+			if tt.prepare != nil {
+				tt.prepare(ctx, fakeClient, owner)
+			}
+
+			wait, err := r.stepOwner(ctx, cfg, cm, state, owner)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Expected error %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Step failed: %v", err)
+			}
+			if owner.Step != tt.wantStep || owner.Batch != tt.wantBatch {
+				t.Errorf("Expected step %s of batch %d, got %s of batch %d", tt.wantStep, tt.wantBatch, owner.Step, owner.Batch)
+			}
+			if (wait > 0) != tt.wantWait {
+				t.Errorf("Expected a wait %v, got %v", tt.wantWait, wait)
+			}
+			if tt.check != nil {
+				tt.check(t, ctx, fakeClient, owner)
+			}
+		})
+	}
+}
+
+func TestAdvanceOwner_FailureEndsOwner(t *testing.T) {
+	ctx := context.Background()
+	r, fakeClient, plan := replicaSetFixture(t, ctx)
+	cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "test-config", Namespace: "default"}}
+
+	// The first batch's replacement never becomes healthy
+	state := ownerRestart(plan, ownerRestartVerifying)
+	owner := &state.progress.Owners[0]
+	deletePods(ctx, fakeClient, "deploy-a-1", "deploy-a-ready")
+	owner.Restarted = []string{"deploy-a-1"}
+	backdate(owner, batchWaitDuration+podReadyTimeout+time.Second)
+
+	if wait := r.advanceOwner(ctx, r.loadConfig(ctx, nil), cm, state, 0); wait != 0 {
+		t.Fatalf("Expected the owner to stop, got a wait of %v", wait)
+	}
+	if owner.Step != ownerRestartFailed || !strings.Contains(owner.Message, "unhealthy") {
+		t.Errorf("Expected the owner failed as unhealthy, got %s: %s", owner.Step, owner.Message)
+	}
+	var restartErr *OwnerRestartError
+	if err := state.err(); !errors.As(err, &restartErr) || restartErr.Name != "deploy-a" {
+		t.Errorf("Expected the failure attributed to deploy-a, got %v", err)
+	}
+	if remaining := remainingPods(ctx, fakeClient); !slices.Contains(remaining, "deploy-a-2") {
+		t.Errorf("Expected the second batch left running, found %v", remaining)
+	}
+}
+
+func TestRestart_PDBBlocksOneOwnerOfAWave(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = autoapplyv1alpha1.AddToScheme(scheme)
+
+	// Every eviction of deploy-a's pods is rejected as its PDB would
+	fakeClient := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithInterceptorFuncs(interceptor.Funcs{
+			SubResourceCreate: func(ctx context.Context, c client.Client, subResourceName string, obj client.Object, subResource client.Object, opts ...client.SubResourceCreateOption) error {
+				if subResourceName == "eviction" && strings.HasPrefix(obj.GetName(), "deploy-a-") {
+					return apierrors.NewTooManyRequests("Cannot evict pod as it would violate the pod's disruption budget.", 0)
+				}
+				return c.SubResource(subResourceName).Create(ctx, obj, subResource, opts...)
+			},
+		}).
+		Build()
+	r := &ConfigMapReconciler{Client: fakeClient, Scheme: scheme, Recorder: record.NewFakeRecorder(100)}
+	ctx := context.Background()
+
+	pods := append(createOwnedPods(ctx, fakeClient, "deploy-a", 2), createOwnedPods(ctx, fakeClient, "deploy-b", 2)...)
+	cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "test-config", Namespace: "default"}}
+	cfg := r.loadConfig(ctx, nil)
+	state := newRestart("v2", pods)
+	if _, done := r.advanceRestart(ctx, cfg, cm, state); done {
+		t.Fatal("Expected the restart to be in progress")
+	}
+
+	owners := make(map[string]*ownerProgress)
+	for i := range state.progress.Owners {
+		owners[state.progress.Owners[i].Name] = &state.progress.Owners[i]
+	}
+	blocked, other := owners["deploy-a"], owners["deploy-b"]
+	if blocked.Step != ownerRestartEvicting || len(blocked.Blocked) != 1 {
+		t.Errorf("Expected deploy-a's batch to retry its blocked pod, got %+v", blocked)
+	}
+	if other.Step != ownerRestartVerifying {
+		t.Errorf("Expected deploy-b to carry on, got %s", other.Step)
+	}
+
+	// Past pdbWaitTimeout the pod is left for the blocked pod retry
+	backdate(blocked, pdbWaitTimeout)
+	if _, done := r.advanceRestart(ctx, cfg, cm, state); done {
+		t.Fatal("Expected the restart to be in progress")
+	}
+	if blocked.Step != ownerRestartDone || len(blocked.Blocked) != 0 {
+		t.Errorf("Expected deploy-a to give up on its blocked pod, got %+v", blocked)
+	}
+	if _, ok := r.pdbRetries.Load("default/test-config"); !ok {
+		t.Error("Expected the blocked pod to be tracked for a later retry")
+	}
+}
+
+func TestReconcile_ChangeDuringRestartFollowsIt(t *testing.T) {
+	r, fakeClient := setupTestReconciler()
+	ctx := context.Background()
+
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: "test-config", Namespace: "default"}}
+	r.configMapVersions.Store(req.String(), "old-version")
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-config", Namespace: "default"},
+		Data:       map[string]string{"key": "v1"},
+	}
+	_ = fakeClient.Create(ctx, cm)
+	first := configMapVersion(cm)
+	for _, pod := range createOwnedPods(ctx, fakeClient, "deploy-a", 2) {
+		usingConfigMap(ctx, fakeClient, pod)
+	}
+
+	if result, err := r.Reconcile(ctx, req); err != nil || result.RequeueAfter == 0 {
+		t.Fatalf("Expected the restart to continue on requeue, got %v, %v", result, err)
+	}
+
+	// A newer change while the first one restarts pods
+	_ = fakeClient.Get(ctx, req.NamespacedName, cm)
+	cm.Data["key"] = "v2"
+	_ = fakeClient.Update(ctx, cm)
+	second := configMapVersion(cm)
+
+	reconcileRestart(t, r, req)
+	_ = fakeClient.Get(ctx, req.NamespacedName, cm)
+	if persisted, _ := persistedVersion(cm); persisted != first {
+		t.Errorf("Expected the restart to finish rolling out the first change, got %q", persisted)
+	}
+	if remaining := remainingPods(ctx, fakeClient); !slices.Equal(remaining, []string{"deploy-a-ready"}) {
+		t.Errorf("Expected both pods restarted, found %v", remaining)
+	}
+
+	// The newer change is handled once the first restart is done
+	replacement := createOwnedPods(ctx, fakeClient, "deploy-b", 1)[0]
+	usingConfigMap(ctx, fakeClient, replacement)
+	reconcileRestart(t, r, req)
+	_ = fakeClient.Get(ctx, req.NamespacedName, cm)
+	if persisted, _ := persistedVersion(cm); persisted != second {
+		t.Errorf("Expected the newer change rolled out next, got %q", persisted)
+	}
+	if remaining := remainingPods(ctx, fakeClient); slices.Contains(remaining, "deploy-b-1") {
+		t.Errorf("Expected the replacement restarted for the newer change, found %v", remaining)
+	}
+}
+
+// usingConfigMap mounts test-config into the pod
+func usingConfigMap(ctx context.Context, c client.Client, pod corev1.Pod) {
+	pod.Spec.Volumes = []corev1.Volume{{Name: "config", VolumeSource: corev1.VolumeSource{
+		ConfigMap: &corev1.ConfigMapVolumeSource{LocalObjectReference: corev1.LocalObjectReference{Name: "test-config"}},
+	}}}
+	_ = c.Update(ctx, &pod)
+}
+
+func TestRestart_ResumesFromEachStep(t *testing.T) {
+	tests := []struct {
+		name    string
+		fixture ownerFixture
+		// prepare moves the restart of the fixture's owner to the recorded step
+		prepare       func(ctx context.Context, c client.Client, state *restartState)
+		wantRemaining []string
+		wantErr       string
+	}{
+		{
+			name:    "owner Pending",
+			fixture: replicaSetFixture,
+			prepare: func(ctx context.Context, c client.Client, state *restartState) {
+				setOwnerStep(&state.progress.Owners[0], ownerRestartPending)
+			},
+			wantRemaining: []string{"deploy-a-ready"},
+		},
+		{
+			name:    "owner Evicting",
+			fixture: replicaSetFixture,
+			prepare: func(ctx context.Context, c client.Client, state *restartState) {
+				owner := &state.progress.Owners[0]
+				setOwnerStep(owner, ownerRestartEvicting)
+				owner.Pending = []string{"deploy-a-1"}
+			},
+			wantRemaining: []string{"deploy-a-ready"},
+		},
+		{
+			name:    "owner Recreating",
+			fixture: barePodFixture,
+			prepare: func(ctx context.Context, c client.Client, state *restartState) {
+				// The spec of a pod already gone isn't recorded
+				deletePods(ctx, c, "bare-1")
+				owner := &state.progress.Owners[0]
+				setOwnerStep(owner, ownerRestartRecreating)
+				owner.Pending, owner.Restarted = []string{"bare-1"}, []string{"bare-1"}
+			},
+			wantRemaining: []string{"bare-2"},
+			wantErr:       "spec of pod bare-1 unknown after the operator restarted",
+		},
+		{
+			name:    "owner Probing",
+			fixture: replicaSetFixture,
+			prepare: func(ctx context.Context, c client.Client, state *restartState) {
+				deletePods(ctx, c, "deploy-a-1")
+				owner := &state.progress.Owners[0]
+				setOwnerStep(owner, ownerRestartProbing)
+				owner.Restarted, owner.Probes = []string{"deploy-a-1"}, &probeProgress{}
+			},
+			wantRemaining: []string{"deploy-a-ready"},
+		},
+		{
+			name:    "owner Verifying",
+			fixture: replicaSetFixture,
+			prepare: func(ctx context.Context, c client.Client, state *restartState) {
+				deletePods(ctx, c, "deploy-a-1")
+				owner := &state.progress.Owners[0]
+				setOwnerStep(owner, ownerRestartVerifying)
+				owner.Restarted = []string{"deploy-a-1"}
+			},
+			wantRemaining: []string{"deploy-a-ready"},
+		},
+		{
+			name:    "owner Soaking",
+			fixture: replicaSetFixture,
+			prepare: func(ctx context.Context, c client.Client, state *restartState) {
+				deletePods(ctx, c, "deploy-a-1")
+				owner := &state.progress.Owners[0]
+				setOwnerStep(owner, ownerRestartSoaking)
+				owner.Restarted = []string{"deploy-a-1"}
+				backdate(owner, defaultCanarySoakDuration)
+			},
+			wantRemaining: []string{"deploy-a-ready"},
+		},
+		{
+			name:    "owner Surging",
+			fixture: surgeOwnerFixture,
+			prepare: func(ctx context.Context, c client.Client, state *restartState) {
+				owner := &state.progress.Owners[0]
+				setOwnerStep(owner, ownerRestartSurging)
+				owner.Pending = []string{"web-abc-1"}
+			},
+		},
+		{
+			name:    "owner Draining",
+			fixture: surgeOwnerFixture,
+			prepare: func(ctx context.Context, c client.Client, state *restartState) {
+				deletePods(ctx, c, "web-abc-1")
+				owner := &state.progress.Owners[0]
+				setOwnerStep(owner, ownerRestartDraining)
+				owner.Restarted = []string{"web-abc-1"}
+			},
+		},
+		{
+			name:    "VerifyingWave",
+			fixture: replicaSetFixture,
+			prepare: func(ctx context.Context, c client.Client, state *restartState) {
+				// The first wave restarted, the second one is next
+				deletePods(ctx, c, "deploy-a-1", "deploy-a-2")
+				setOwnerStep(&state.progress.Owners[0], ownerRestartDone)
+				later := createOwnedPods(ctx, c, "deploy-b", 2)
+				for _, pod := range later {
+					state.pods[pod.Name] = pod
+				}
+				state.progress.Waves = append(state.progress.Waves, "1")
+				state.progress.Owners = append(state.progress.Owners, newOwnerProgress(1, halves("deploy-b", ownerRestartBatches, later)))
+				state.setStep(restartStepVerifyingWave)
+			},
+			wantRemaining: []string{"deploy-a-ready", "deploy-b-ready"},
+		},
+		{
+			name:    "Deleting",
+			fixture: replicaSetFixture,
+			prepare: func(ctx context.Context, c client.Client, state *restartState) {
+				deletePods(ctx, c, "deploy-a-1")
+				yolo := &yoloProgress{Next: 1, Restarted: 1}
+				for _, name := range []string{"deploy-a-1", "deploy-a-2"} {
+					yolo.Pods = append(yolo.Pods, newPodRef(ptr.To(state.pods[name])))
+				}
+				state.progress.Owners, state.progress.Waves, state.progress.YOLO = nil, nil, yolo
+				state.setStep(restartStepDeleting)
+			},
+			wantRemaining: []string{"deploy-a-ready"},
+		},
+		{
+			name:    "Recreating",
+			fixture: barePodFixture,
+			prepare: func(ctx context.Context, c client.Client, state *restartState) {
+				deletePods(ctx, c, "bare-1")
+				ref := newPodRef(ptr.To(state.pods["bare-1"]))
+				yolo := &yoloProgress{Pods: []podRef{ref}, Next: 1, Restarted: 1, Recreating: []podRef{ref}}
+				state.progress.Owners, state.progress.Waves, state.progress.YOLO = nil, nil, yolo
+				state.setStep(restartStepRecreating)
+			},
+			wantRemaining: []string{"bare-2"},
+			wantErr:       "spec of pod bare-1 unknown after the operator restarted",
+		},
+		{
+			name:    "Probing",
+			fixture: replicaSetFixture,
+			prepare: func(ctx context.Context, c client.Client, state *restartState) {
+				deletePods(ctx, c, "deploy-a-1")
+				ref := newPodRef(ptr.To(state.pods["deploy-a-1"]))
+				state.progress.Owners, state.progress.Waves = nil, nil
+				state.progress.YOLO = &yoloProgress{Pods: []podRef{ref}, Next: 1, Restarted: 1}
+				state.progress.Probes = &probeProgress{}
+				state.setStep(restartStepProbing)
+			},
+			wantRemaining: []string{"deploy-a-2", "deploy-a-ready"},
+		},
+		{
+			name:    "WaitingForVPA",
+			fixture: replicaSetFixture,
+			prepare: func(ctx context.Context, c client.Client, state *restartState) {
+				// VPA's window passed without it evicting deploy-a-2
+				deletePods(ctx, c, "deploy-a-1")
+				setOwnerStep(&state.progress.Owners[0], ownerRestartDone)
+				state.progress.Owners[0].Batches = state.progress.Owners[0].Batches[:1]
+				state.progress.VPADeferred = []podRef{newPodRef(ptr.To(state.pods["deploy-a-2"]))}
+				state.setStep(restartStepWaitingForVPA)
+			},
+			wantRemaining: []string{"deploy-a-ready"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			ctx := context.Background()
+			r, fakeClient, plan := tt.fixture(t, ctx)
+
+			cm := &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: "test-config", Namespace: "default"},
+				Data:       map[string]string{"key": "v2"},
+			}
+			_ = fakeClient.Create(ctx, cm)
+			state := ownerRestart(plan, ownerRestartPending)
+			state.progress.Version = configMapVersion(cm)
+			tt.prepare(ctx, fakeClient, state)
+			r.recordProgress(ctx, cm, state)
+
+			// A new operator picks the restart up from the ConfigMap
+			resumed := &ConfigMapReconciler{Client: fakeClient, Scheme: r.Scheme, Recorder: record.NewFakeRecorder(100)}
+			_ = fakeClient.Get(ctx, client.ObjectKeyFromObject(cm), cm)
+			resumedState := resumed.restartInProgress(ctx, cm, "default/test-config")
+			if resumedState == nil {
+				t.Fatal("Expected the restart to be resumed")
+			}
+			if resumedState.progress.Step != state.progress.Step {
+				t.Errorf("Expected the restart resumed at %s, got %s", state.progress.Step, resumedState.progress.Step)
+			}
+
+			err := finishSteps(ctx, resumed, resumed.loadConfig(ctx, cm), cm, resumedState)
+			if tt.wantErr == "" && err != nil {
+				t.Errorf("Resumed restart failed: %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("Expected error %q, got %v", tt.wantErr, err)
+			}
+			if remaining := remainingPods(ctx, fakeClient); !slices.Equal(remaining, tt.wantRemaining) {
+				t.Errorf("Expected %v left running, found %v", tt.wantRemaining, remaining)
+			}
+		})
+	}
+}
+
+func TestVPADeferredPlanned(t *testing.T) {
+	deferred := podRef{Name: "web-2", UID: "web-2"}
+	other := podRef{Name: "web-1", UID: "web-1"}
+
+	tests := []struct {
+		name     string
+		progress restartProgress
+		want     bool
+	}{
+		{
+			name:     "only left to VPA",
+			progress: restartProgress{Owners: []ownerProgress{{Batches: [][]podRef{{other}}}}},
+		},
+		{
+			name:     "planned into a wave after the window",
+			progress: restartProgress{Owners: []ownerProgress{{Batches: [][]podRef{{other}}}, {Wave: 1, Batches: [][]podRef{{deferred}}}}},
+			want:     true,
+		},
+		{
+			name:     "deleted by YOLO mode after the window",
+			progress: restartProgress{YOLO: &yoloProgress{Pods: []podRef{other, deferred}}},
+			want:     true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			state := newRestart("v2", nil)
+			state.progress = tt.progress
+			state.progress.VPADeferred = []podRef{deferred}
+			if got := state.vpaDeferredPlanned(); got != tt.want {
+				t.Errorf("Expected %v, got %v", tt.want, got)
+			}
+
+			// Until they are, the restart waits for VPA once the waves ran
+			want := restartStepWaitingForVPA
+			if tt.want {
+				want = restartStepFinished
+			}
+			if state.wavesFinished(); state.progress.Step != want {
+				t.Errorf("Expected step %s, got %s", want, state.progress.Step)
+			}
+		})
+	}
+}
@@ -0,0 +1,91 @@
+package controller
+
+import (
+	"context"
+	"time"
+
+	"golang.org/x/time/rate"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// Delay before a change waiting for a cluster-wide restart slot is retried
+const restartSlotRetryInterval = 10 * time.Second
+
+// restartBudget holds the limiters shared by all restarts in the cluster
+type restartBudget struct {
+	// pods limits pod restarts per minute, nil when unlimited
+	pods *rate.Limiter
+	// slots limits concurrent restart operations, nil when unlimited
+	slots chan struct{}
+}
+
+// budget returns the cluster-wide restart budget, created on first use
+func (r *ConfigMapReconciler) budget() *restartBudget {
+	r.budgetOnce.Do(func() {
+		r.restartBudget = &restartBudget{}
+		if n := r.MaxPodRestartsPerMinute; n > 0 {
+			r.restartBudget.pods = rate.NewLimiter(rate.Every(time.Minute/time.Duration(n)), n)
+		}
+		if n := r.MaxConcurrentRestarts; n > 0 {
+			r.restartBudget.slots = make(chan struct{}, n)
+		}
+	})
+	return r.restartBudget
+}
+
+// restartBudgetDelay reserves the cluster-wide budget for restarting n more
+// pods. If the budget has no room for them yet, nothing is reserved and it
+// returns how long until it has.
+func (r *ConfigMapReconciler) restartBudgetDelay(n int) time.Duration {
+	limiter := r.budget().pods
+	if limiter == nil {
+		return 0
+	}
+	// More than the burst never fits, so a large batch takes the whole of it
+	reservation := limiter.ReserveN(time.Now(), min(n, limiter.Burst()))
+	if delay := reservation.Delay(); delay > 0 {
+		reservation.Cancel()
+		return delay
+	}
+	return 0
+}
+
+// tryAcquireRestartSlot takes one of the cluster-wide restart slots,
+// returning the release func, or nil if all are taken
+func (r *ConfigMapReconciler) tryAcquireRestartSlot() func() {
+	slots := r.budget().slots
+	if slots == nil {
+		return func() {}
+	}
+	select {
+	case slots <- struct{}{}:
+		return func() { <-slots }
+	default:
+		return nil
+	}
+}
+
+// lockRestart takes the namespace lock and a cluster-wide restart slot, which
+// a restart holds until it finishes. It returns the release func, or nil and
+// how long until trying again if either is taken.
+func (r *ConfigMapReconciler) lockRestart(ctx context.Context, namespace string) (func(), time.Duration) {
+	logger := log.FromContext(ctx)
+
+	// Only one restart per namespace at a time
+	unlock := r.tryLockNamespace(namespace)
+	if unlock == nil {
+		logger.Info("Another restart is running in the namespace, waiting", "namespace", namespace)
+		return nil, namespaceLockRetryInterval
+	}
+	// And only as many restarts as the cluster-wide budget allows
+	release := r.tryAcquireRestartSlot()
+	if release == nil {
+		unlock()
+		logger.Info("Too many restarts running in the cluster, waiting", "limit", r.MaxConcurrentRestarts)
+		return nil, restartSlotRetryInterval
+	}
+	return func() {
+		release()
+		unlock()
+	}, 0
+}
@@ -0,0 +1,99 @@
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestTryAcquireRestartSlot(t *testing.T) {
+	r := &ConfigMapReconciler{MaxConcurrentRestarts: 2}
+
+	first, second := r.tryAcquireRestartSlot(), r.tryAcquireRestartSlot()
+	if first == nil || second == nil {
+		t.Fatal("Expected two free slots")
+	}
+	if r.tryAcquireRestartSlot() != nil {
+		t.Error("Expected all slots to be taken")
+	}
+
+	first()
+	release := r.tryAcquireRestartSlot()
+	if release == nil {
+		t.Error("Expected a slot to be free after releasing one")
+	}
+
+	unlimited := &ConfigMapReconciler{}
+	for range 10 {
+		if unlimited.tryAcquireRestartSlot() == nil {
+			t.Fatal("Expected no limit by default")
+		}
+	}
+}
+
+func TestRestartBudgetDelay(t *testing.T) {
+	r := &ConfigMapReconciler{MaxPodRestartsPerMinute: 60}
+
+	// A minute's budget is available at once
+	if wait := r.restartBudgetDelay(60); wait != 0 {
+		t.Fatalf("Expected the first 60 restarts to be allowed, got a wait of %v", wait)
+	}
+
+	// The next one has to wait about a second, without using up the budget
+	for range 2 {
+		if wait := r.restartBudgetDelay(1); wait <= 0 || wait > time.Second {
+			t.Errorf("Expected a wait of up to a second, got %v", wait)
+		}
+	}
+
+	unlimited := &ConfigMapReconciler{}
+	if wait := unlimited.restartBudgetDelay(1000); wait != 0 {
+		t.Errorf("Expected no limit by default, got a wait of %v", wait)
+	}
+}
+
+func TestReconcile_WaitsForRestartSlot(t *testing.T) {
+	r, fakeClient := setupTestReconciler()
+	r.MaxConcurrentRestarts = 1
+	ctx := context.Background()
+
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: "test-config", Namespace: "default"}}
+	r.configMapVersions.Store(req.String(), "old-version")
+
+	_ = fakeClient.Create(ctx, &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-config", Namespace: "default"},
+		Data:       map[string]string{"key": "value"},
+	})
+	_ = fakeClient.Create(ctx, podUsingConfigMap("test-pod", "test-config", time.Now()))
+
+	// A restart in another namespace holds the only slot
+	release := r.tryAcquireRestartSlot()
+
+	result, err := r.Reconcile(ctx, req)
+	if err != nil {
+		t.Fatalf("Reconcile failed: %v", err)
+	}
+	if result.RequeueAfter != restartSlotRetryInterval {
+		t.Errorf("Expected requeue after %v, got %v", restartSlotRetryInterval, result.RequeueAfter)
+	}
+	var pods corev1.PodList
+	_ = fakeClient.List(ctx, &pods, client.InNamespace("default"))
+	if len(pods.Items) != 1 {
+		t.Error("Expected no pods to be restarted while the slot is taken")
+	}
+
+	release()
+	if _, err := r.Reconcile(ctx, req); err != nil {
+		t.Fatalf("Reconcile failed: %v", err)
+	}
+	_ = fakeClient.List(ctx, &pods, client.InNamespace("default"))
+	if len(pods.Items) != 0 {
+		t.Error("Expected the pod to be restarted once the slot is free")
+	}
+}
@@ -0,0 +1,83 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// OwnerRestartError attributes a restart failure to the owner whose pods were
+// being restarted. Restart errors passed to RestartHook.OnComplete join one of
+// these per failed owner.
+type OwnerRestartError struct {
+	// Kind and Name identify the owner, both empty for standalone pods
+	Kind string
+	Name string
+	// Pods are the names of the owner's pods being restarted
+	Pods []string
+	Err  error
+}
+
+func (e *OwnerRestartError) Error() string {
+	return fmt.Sprintf("%s: %v", e.owner(), e.Err)
+}
+
+func (e *OwnerRestartError) Unwrap() error {
+	return e.Err
+}
+
+// owner describes the owner for messages
+func (e *OwnerRestartError) owner() string {
+	if e.Kind == "" {
+		return "standalone pods"
+	}
+	return e.Kind + "/" + e.Name
+}
+
+// newOwnerRestartError attributes err to the controller of the given pods
+func newOwnerRestartError(pods []corev1.Pod, err error) *OwnerRestartError {
+	restartErr := &OwnerRestartError{Pods: podNames(pods), Err: err}
+	if len(pods) > 0 {
+		if owner := metav1.GetControllerOf(&pods[0]); owner != nil {
+			restartErr.Kind, restartErr.Name = owner.Kind, owner.Name
+		}
+	}
+	return restartErr
+}
+
+// ownerRestartErrors returns every OwnerRestartError within err, looking
+// through joined errors
+func ownerRestartErrors(err error) []*OwnerRestartError {
+	if err == nil {
+		return nil
+	}
+
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		var result []*OwnerRestartError
+		for _, e := range joined.Unwrap() {
+			result = append(result, ownerRestartErrors(e)...)
+		}
+		return result
+	}
+
+	var ownerErr *OwnerRestartError
+	if errors.As(err, &ownerErr) {
+		return []*OwnerRestartError{ownerErr}
+	}
+	return nil
+}
+
+// reportRestartFailures emits one Warning Event on the ConfigMap per failed
+// owner, and one listing replacement pods that failed to start
+func (r *ConfigMapReconciler) reportRestartFailures(configMap *corev1.ConfigMap, err error) {
+	for _, ownerErr := range ownerRestartErrors(err) {
+		r.Recorder.Eventf(configMap, corev1.EventTypeWarning, "RestartFailed",
+			"Restart of %s failed: %v", ownerErr.owner(), ownerErr.Err)
+	}
+	if failing := replacementFailures(err); len(failing) > 0 {
+		r.Recorder.Eventf(configMap, corev1.EventTypeWarning, "ReplacementPodsFailing",
+			"Restart aborted, pods with the new config fail to start: %s", describeFailingContainers(failing))
+	}
+}
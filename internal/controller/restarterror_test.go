@@ -0,0 +1,57 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+)
+
+func TestRestart_ReportsFailuresPerOwner(t *testing.T) {
+	r, fakeClient := setupTestReconciler()
+	ctx := context.Background()
+
+	cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "test-config", Namespace: "default"}}
+	trueVal := true
+	var podsToRestart []corev1.Pod
+	for _, owner := range []string{"web", "worker"} {
+		pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+			Name:      owner + "-pod",
+			Namespace: "default",
+			OwnerReferences: []metav1.OwnerReference{
+				{Kind: "ReplicaSet", Name: owner, UID: types.UID(owner + "-uid"), Controller: &trueVal},
+			},
+		}}
+		_ = fakeClient.Create(ctx, pod)
+		podsToRestart = append(podsToRestart, *pod)
+	}
+
+	r.Hooks = []RestartHook{&recordingHook{beforeError: errors.New("not now")}}
+	err := runRestart(ctx, r, r.loadConfig(ctx, nil), cm, "v2", podsToRestart)
+
+	ownerErrs := ownerRestartErrors(err)
+	if len(ownerErrs) != 2 {
+		t.Fatalf("Expected one error per owner, got %v", err)
+	}
+	for _, ownerErr := range ownerErrs {
+		if ownerErr.Kind != "ReplicaSet" || len(ownerErr.Pods) != 1 || ownerErr.Pods[0] != ownerErr.Name+"-pod" {
+			t.Errorf("Unexpected attribution: %+v", ownerErr)
+		}
+	}
+
+	events := r.Recorder.(*record.FakeRecorder).Events
+	for len(events) > 0 {
+		<-events
+	}
+	r.reportRestartFailures(cm, err)
+	for range ownerErrs {
+		if event := <-events; !strings.HasPrefix(event, "Warning RestartFailed Restart of ReplicaSet/") {
+			t.Errorf("Unexpected event: %s", event)
+		}
+	}
+}
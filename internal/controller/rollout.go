@@ -0,0 +1,100 @@
+package controller
+
+import (
+	"context"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// restartedAtAnnotation is the pod template annotation `kubectl rollout restart` sets
+const restartedAtAnnotation = "kubectl.kubernetes.io/restartedAt"
+
+// +kubebuilder:rbac:groups=apps,resources=deployments;statefulsets;daemonsets,verbs=patch
+
+// rolloutWorkloads triggers one rollout restart per Deployment, StatefulSet or
+// DaemonSet of the owners planned to roll out
+func (r *ConfigMapReconciler) rolloutWorkloads(ctx context.Context, configMap *corev1.ConfigMap, state *restartState) {
+	logger := log.FromContext(ctx)
+
+	// One timestamp for all owners, so ReplicaSets of the same Deployment
+	// don't trigger a second rollout
+	restartedAt := time.Now().Format(time.RFC3339)
+	rolledOut := make(map[workloadRef]error)
+
+	for i := range state.progress.Owners {
+		owner := &state.progress.Owners[i]
+		if owner.Mode != ownerRestartRollout || owner.Step != "" {
+			continue
+		}
+		workload := workloadRef{Kind: owner.WorkloadKind, Name: owner.WorkloadName}
+		err, done := rolledOut[workload]
+		if !done {
+			err = r.rolloutRestart(ctx, configMap, configMap.Namespace, &workload, restartedAt)
+			rolledOut[workload] = err
+			if err == nil {
+				logger.Info("Triggered rollout restart", "kind", workload.Kind, "name", workload.Name, "pods", len(state.ownerPods(owner)))
+			}
+		}
+		if err != nil {
+			state.failOwner(ctx, i, err)
+			continue
+		}
+		setOwnerStep(owner, ownerRestartDone)
+	}
+}
+
+// rolloutRestart sets the restartedAt annotation on the workload's pod template
+func (r *ConfigMapReconciler) rolloutRestart(ctx context.Context, configMap *corev1.ConfigMap, namespace string, workload *workloadRef, restartedAt string) error {
+	obj := newRolloutObject(workload.Kind)
+	if err := r.Get(ctx, types.NamespacedName{Namespace: namespace, Name: workload.Name}, obj); err != nil {
+		return err
+	}
+
+	patch := client.MergeFrom(obj.DeepCopyObject().(client.Object))
+	template := podTemplate(obj)
+	if template.Annotations == nil {
+		template.Annotations = make(map[string]string)
+	}
+	template.Annotations[restartedAtAnnotation] = restartedAt
+
+	if err := r.Patch(ctx, obj, patch); err != nil {
+		return err
+	}
+
+	r.Recorder.Eventf(obj, corev1.EventTypeNormal, "RolloutRestarted",
+		"Rollout restart triggered by change in ConfigMap %s", configMap.Name)
+	return nil
+}
+
+// newRolloutObject returns an empty object for kinds that support rollout restarts, or nil
+func newRolloutObject(kind string) client.Object {
+	switch kind {
+	case "Deployment":
+		return &appsv1.Deployment{}
+	case "StatefulSet":
+		return &appsv1.StatefulSet{}
+	case "DaemonSet":
+		return &appsv1.DaemonSet{}
+	default:
+		return nil
+	}
+}
+
+// podTemplate returns the pod template of an object from newRolloutObject
+func podTemplate(obj client.Object) *corev1.PodTemplateSpec {
+	switch o := obj.(type) {
+	case *appsv1.Deployment:
+		return &o.Spec.Template
+	case *appsv1.StatefulSet:
+		return &o.Spec.Template
+	case *appsv1.DaemonSet:
+		return &o.Spec.Template
+	default:
+		return nil
+	}
+}
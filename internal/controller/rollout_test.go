@@ -0,0 +1,106 @@
+package controller
+
+import (
+	"context"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	autoapplyv1alpha1 "github.com/manos/k8s-autoapply-operator/api/v1alpha1"
+)
+
+func TestRollingRestart_RolloutStrategy(t *testing.T) {
+	r, fakeClient := setupTestReconciler()
+	ctx := context.Background()
+
+	cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "test-config", Namespace: "default"}}
+
+	deploy := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default", UID: "web-uid"}}
+	_ = fakeClient.Create(ctx, deploy)
+
+	// Two ReplicaSets of the same Deployment, as seen mid-rollout
+	trueVal := true
+	var ownerRefs [][]metav1.OwnerReference
+	for _, name := range []string{"web-new", "web-old"} {
+		rs := &appsv1.ReplicaSet{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "default",
+				UID:       types.UID(name + "-uid"),
+				OwnerReferences: []metav1.OwnerReference{
+					{APIVersion: "apps/v1", Kind: "Deployment", Name: "web", UID: deploy.UID, Controller: &trueVal},
+				},
+			},
+		}
+		_ = fakeClient.Create(ctx, rs)
+		ownerRefs = append(ownerRefs, []metav1.OwnerReference{
+			{APIVersion: "apps/v1", Kind: "ReplicaSet", Name: rs.Name, UID: rs.UID, Controller: &trueVal},
+		})
+	}
+
+	pods := []*corev1.Pod{
+		{ObjectMeta: metav1.ObjectMeta{Name: "web-1", Namespace: "default", OwnerReferences: ownerRefs[0]}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "web-2", Namespace: "default", OwnerReferences: ownerRefs[1]}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "standalone", Namespace: "default"}},
+	}
+	var podsToRestart []corev1.Pod
+	for _, pod := range pods {
+		pod.Status.Phase = corev1.PodRunning
+		_ = fakeClient.Create(ctx, pod)
+		podsToRestart = append(podsToRestart, *pod)
+	}
+
+	cfg := r.loadConfig(ctx, nil)
+	cfg.strategy = autoapplyv1alpha1.RestartStrategyRollout
+	if err := runRestart(ctx, r, cfg, cm, "v2", podsToRestart); err != nil {
+		t.Fatalf("Restart failed: %v", err)
+	}
+
+	_ = fakeClient.Get(ctx, types.NamespacedName{Name: "web", Namespace: "default"}, deploy)
+	if deploy.Spec.Template.Annotations[restartedAtAnnotation] == "" {
+		t.Error("Expected the Deployment's pod template to be annotated")
+	}
+
+	var remaining corev1.PodList
+	_ = fakeClient.List(ctx, &remaining, client.InNamespace("default"))
+	names := podNames(remaining.Items)
+	if len(names) != 2 || names[0] != "web-1" || names[1] != "web-2" {
+		t.Errorf("Expected only Deployment pods to be left for the rollout, got %v", names)
+	}
+
+	rollouts := 0
+	for len(r.Recorder.(*record.FakeRecorder).Events) > 0 {
+		if event := <-r.Recorder.(*record.FakeRecorder).Events; event == "Normal RolloutRestarted Rollout restart triggered by change in ConfigMap test-config" {
+			rollouts++
+		}
+	}
+	if rollouts != 1 {
+		t.Errorf("Expected one rollout for the Deployment, got %d", rollouts)
+	}
+}
+
+func TestLoadConfig_StrategyPriority(t *testing.T) {
+	r, fakeClient := setupTestReconciler()
+	ctx := context.Background()
+
+	_ = fakeClient.Create(ctx, &autoapplyv1alpha1.AutoApplyConfig{
+		ObjectMeta: metav1.ObjectMeta{Name: "rollout"},
+		Spec:       autoapplyv1alpha1.AutoApplyConfigSpec{Strategy: autoapplyv1alpha1.RestartStrategyRollout},
+	})
+	if cfg := r.loadConfig(ctx, nil); cfg.strategy != autoapplyv1alpha1.RestartStrategyRollout {
+		t.Errorf("Expected Rollout to win over the default, got %q", cfg.strategy)
+	}
+
+	_ = fakeClient.Create(ctx, &autoapplyv1alpha1.AutoApplyConfig{
+		ObjectMeta: metav1.ObjectMeta{Name: "canary"},
+		Spec:       autoapplyv1alpha1.AutoApplyConfigSpec{Strategy: autoapplyv1alpha1.RestartStrategyCanary},
+	})
+	if cfg := r.loadConfig(ctx, nil); cfg.strategy != autoapplyv1alpha1.RestartStrategyCanary {
+		t.Errorf("Expected Canary to win over Rollout, got %q", cfg.strategy)
+	}
+}
@@ -0,0 +1,142 @@
+package controller
+
+import (
+	"context"
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	autoapplyv1alpha1 "github.com/manos/k8s-autoapply-operator/api/v1alpha1"
+)
+
+// +kubebuilder:rbac:groups=autoapply.io,resources=autoapplynamespaceconfigs/status,verbs=get;update;patch
+
+const (
+	// Most stale workloads named in a namespace config's rollup
+	rollupStaleWorkloads = 10
+	// Most finished restarts listed in a namespace config's rollup
+	rollupRecentRestarts = 5
+)
+
+// configConsumers are the pods a ConfigMap's changes restart, split by
+// whether they started before its last handled change rolled out
+type configConsumers struct {
+	current []corev1.Pod
+	stale   []corev1.Pod
+}
+
+// updateNamespaceRollups sets every namespace config's rollout rollup from
+// the consumers a stale config scan found
+func (r *ConfigMapReconciler) updateNamespaceRollups(ctx context.Context, configMaps []corev1.ConfigMap, consumers map[types.NamespacedName]configConsumers) {
+	logger := log.FromContext(ctx).WithName("stale-config")
+
+	var configs autoapplyv1alpha1.AutoApplyNamespaceConfigList
+	if err := r.List(ctx, &configs); err != nil {
+		logger.Error(err, "Failed to list namespace configs")
+		return
+	}
+	if len(configs.Items) == 0 {
+		return
+	}
+
+	var ops autoapplyv1alpha1.RestartOperationList
+	if err := r.List(ctx, &ops); err != nil {
+		logger.Error(err, "Failed to list restart operations")
+	}
+
+	workloads := make(map[types.UID]string)
+	for i := range configs.Items {
+		config := &configs.Items[i]
+		rollup := r.namespaceRollup(ctx, config, configMaps, consumers, ops.Items, workloads)
+		if equality.Semantic.DeepEqual(rollup, config.Status.Rollout) {
+			continue
+		}
+		config.Status.Rollout = rollup
+		config.Status.LastUpdated = metav1.Now()
+		if err := r.Status().Update(ctx, config); err != nil {
+			logger.Error(err, "Failed to update namespace config rollup", "config", client.ObjectKeyFromObject(config))
+		}
+	}
+}
+
+// namespaceRollup summarizes the workloads using the ConfigMaps a namespace
+// config applies to, and their latest finished restarts. workloads caches
+// the workload name of each pod controller.
+func (r *ConfigMapReconciler) namespaceRollup(ctx context.Context, config *autoapplyv1alpha1.AutoApplyNamespaceConfig, configMaps []corev1.ConfigMap, consumers map[types.NamespacedName]configConsumers, ops []autoapplyv1alpha1.RestartOperation, workloads map[types.UID]string) *autoapplyv1alpha1.NamespaceRollout {
+	applies := make(map[string]bool)
+	fresh := make(map[string]bool)
+	stale := make(map[string]bool)
+	for i := range configMaps {
+		configMap := &configMaps[i]
+		if configMap.Namespace != config.Namespace || !configAppliesTo(ctx, config.Name, &config.Spec, configMap) {
+			continue
+		}
+		applies[configMap.Name] = true
+
+		found := consumers[client.ObjectKeyFromObject(configMap)]
+		for j := range found.current {
+			fresh[r.workloadName(ctx, &found.current[j], workloads)] = true
+		}
+		for j := range found.stale {
+			stale[r.workloadName(ctx, &found.stale[j], workloads)] = true
+		}
+	}
+
+	rollup := &autoapplyv1alpha1.NamespaceRollout{StaleWorkloads: int32(len(stale))}
+	for name := range fresh {
+		if !stale[name] {
+			rollup.FreshWorkloads++
+		}
+	}
+	for name := range stale {
+		rollup.Stale = append(rollup.Stale, name)
+	}
+	sort.Strings(rollup.Stale)
+	if len(rollup.Stale) > rollupStaleWorkloads {
+		rollup.Stale = rollup.Stale[:rollupStaleWorkloads]
+	}
+
+	for _, op := range ops {
+		if op.Namespace != config.Namespace || !applies[op.Spec.ConfigMapName] || op.Status.CompletionTime == nil {
+			continue
+		}
+		rollup.RecentRestarts = append(rollup.RecentRestarts, autoapplyv1alpha1.RestartOutcome{
+			Operation:      op.Name,
+			ConfigMapName:  op.Spec.ConfigMapName,
+			Phase:          op.Status.Phase,
+			CompletionTime: op.Status.CompletionTime,
+		})
+	}
+	sort.Slice(rollup.RecentRestarts, func(i, j int) bool {
+		return rollup.RecentRestarts[j].CompletionTime.Before(rollup.RecentRestarts[i].CompletionTime)
+	})
+	if len(rollup.RecentRestarts) > rollupRecentRestarts {
+		rollup.RecentRestarts = rollup.RecentRestarts[:rollupRecentRestarts]
+	}
+
+	return rollup
+}
+
+// workloadName returns Kind/Name of the workload managing the pod, or the
+// pod itself if it has no controller
+func (r *ConfigMapReconciler) workloadName(ctx context.Context, pod *corev1.Pod, cache map[types.UID]string) string {
+	owner := metav1.GetControllerOf(pod)
+	if owner == nil {
+		return "Pod/" + pod.Name
+	}
+	if name, ok := cache[owner.UID]; ok {
+		return name
+	}
+
+	name := owner.Kind + "/" + owner.Name
+	if workload, err := r.resolveWorkload(ctx, pod); err == nil && workload != nil {
+		name = workload.Kind + "/" + workload.Name
+	}
+	cache[owner.UID] = name
+	return name
+}
@@ -0,0 +1,74 @@
+package controller
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	autoapplyv1alpha1 "github.com/manos/k8s-autoapply-operator/api/v1alpha1"
+)
+
+func TestScanStaleConfig_NamespaceRollup(t *testing.T) {
+	r, fakeClient := setupTestReconciler()
+	ctx := context.Background()
+
+	appliedAt := time.Now().Add(-time.Hour).UTC().Truncate(time.Second)
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-config", Namespace: "default"},
+		Data:       map[string]string{"key": "value"},
+	}
+	cm.Annotations = map[string]string{
+		lastSeenVersionAnnotation: configMapVersion(cm),
+		appliedAtAnnotation:       appliedAt.Format(time.RFC3339),
+	}
+	_ = fakeClient.Create(ctx, cm)
+
+	webRefs := createDeploymentWithReplicaSet(ctx, fakeClient, &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"},
+	})
+	fresh := podUsingConfigMap("web-1", "test-config", appliedAt.Add(time.Minute))
+	fresh.OwnerReferences = webRefs
+	_ = fakeClient.Create(ctx, fresh)
+	_ = fakeClient.Create(ctx, podUsingConfigMap("batch-runner", "test-config", appliedAt.Add(-time.Minute)))
+
+	config := &autoapplyv1alpha1.AutoApplyNamespaceConfig{ObjectMeta: metav1.ObjectMeta{Name: "team", Namespace: "default"}}
+	_ = fakeClient.Create(ctx, config)
+
+	older, newer := metav1.NewTime(appliedAt.Add(-time.Hour)), metav1.NewTime(appliedAt)
+	for _, op := range []struct {
+		name  string
+		phase autoapplyv1alpha1.RestartOperationPhase
+		done  *metav1.Time
+	}{
+		{"test-config-a", autoapplyv1alpha1.RestartOperationFailed, &older},
+		{"test-config-b", autoapplyv1alpha1.RestartOperationSucceeded, &newer},
+		{"test-config-c", autoapplyv1alpha1.RestartOperationRunning, nil},
+	} {
+		_ = fakeClient.Create(ctx, &autoapplyv1alpha1.RestartOperation{
+			ObjectMeta: metav1.ObjectMeta{Name: op.name, Namespace: "default"},
+			Spec:       autoapplyv1alpha1.RestartOperationSpec{ConfigMapName: "test-config"},
+			Status:     autoapplyv1alpha1.RestartOperationStatus{Phase: op.phase, CompletionTime: op.done},
+		})
+	}
+
+	r.scanStaleConfig(ctx)
+
+	_ = fakeClient.Get(ctx, client.ObjectKeyFromObject(config), config)
+	rollup := config.Status.Rollout
+	if rollup == nil {
+		t.Fatal("Expected a rollup on the namespace config")
+	}
+	if rollup.FreshWorkloads != 1 || rollup.StaleWorkloads != 1 || !reflect.DeepEqual(rollup.Stale, []string{"Pod/batch-runner"}) {
+		t.Errorf("Expected web fresh and batch-runner stale, got %+v", rollup)
+	}
+	if len(rollup.RecentRestarts) != 2 || rollup.RecentRestarts[0].Operation != "test-config-b" ||
+		rollup.RecentRestarts[1].Phase != autoapplyv1alpha1.RestartOperationFailed {
+		t.Errorf("Expected the finished restarts newest first, got %+v", rollup.RecentRestarts)
+	}
+}
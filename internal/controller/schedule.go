@@ -0,0 +1,255 @@
+package controller
+
+import (
+	"fmt"
+	"math/bits"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cronSchedule is a parsed five-field cron expression: minute, hour, day of
+// month, month and day of week. Each field is a bitset of the values it
+// matches.
+type cronSchedule struct {
+	minute, hour, dom, month, dow uint64
+	// With both day fields restricted a day matching either one matches,
+	// as in cron
+	domRestricted, dowRestricted bool
+}
+
+// cronField describes the values one field of a cron expression takes
+type cronField struct {
+	name     string
+	min, max int
+	names    map[string]int
+}
+
+var (
+	cronMinute = cronField{name: "minute", min: 0, max: 59}
+	cronHour   = cronField{name: "hour", min: 0, max: 23}
+	cronDom    = cronField{name: "day of month", min: 1, max: 31}
+	cronMonth  = cronField{name: "month", min: 1, max: 12, names: map[string]int{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}}
+	// 7 is Sunday too
+	cronDow = cronField{name: "day of week", min: 0, max: 7, names: map[string]int{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}}
+)
+
+// How far ahead a schedule is searched for its next time
+const cronSearchYears = 5
+
+// parseCron parses a five-field cron expression. Fields take *, values,
+// ranges (a-b), steps (*/n, a-b/n) and comma-separated lists of these;
+// months and weekdays also take three-letter names.
+func parseCron(expr string) (cronSchedule, error) {
+	var c cronSchedule
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return c, fmt.Errorf("expected 5 fields, got %d", len(fields))
+	}
+
+	var err error
+	for i, target := range []struct {
+		field cronField
+		bits  *uint64
+	}{{cronMinute, &c.minute}, {cronHour, &c.hour}, {cronDom, &c.dom}, {cronMonth, &c.month}, {cronDow, &c.dow}} {
+		if *target.bits, err = parseCronField(fields[i], target.field); err != nil {
+			return c, err
+		}
+	}
+	if c.dow&(1<<7) != 0 {
+		c.dow |= 1
+	}
+	c.domRestricted = fields[2] != "*"
+	c.dowRestricted = fields[4] != "*"
+	return c, nil
+}
+
+// parseCronField parses one field into the bitset of values it matches
+func parseCronField(value string, field cronField) (uint64, error) {
+	var set uint64
+	for _, part := range strings.Split(value, ",") {
+		rangePart, stepPart, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			n, err := strconv.Atoi(stepPart)
+			if err != nil || n < 1 {
+				return 0, fmt.Errorf("invalid %s step %q", field.name, stepPart)
+			}
+			step = n
+		}
+
+		low, high := field.min, field.max
+		if rangePart != "*" {
+			from, to, isRange := strings.Cut(rangePart, "-")
+			var err error
+			if low, err = field.value(from); err != nil {
+				return 0, err
+			}
+			high = low
+			if isRange {
+				if high, err = field.value(to); err != nil {
+					return 0, err
+				}
+			} else if hasStep {
+				high = field.max
+			}
+			if high < low {
+				return 0, fmt.Errorf("invalid %s range %q", field.name, rangePart)
+			}
+		}
+
+		for v := low; v <= high; v += step {
+			set |= 1 << v
+		}
+	}
+	return set, nil
+}
+
+// value parses a single number or name of the field
+func (f cronField) value(s string) (int, error) {
+	if v, ok := f.names[strings.ToLower(s)]; ok {
+		return v, nil
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil || v < f.min || v > f.max {
+		return 0, fmt.Errorf("invalid %s %q", f.name, s)
+	}
+	return v, nil
+}
+
+// matchesDay checks the day of month and day of week fields
+func (c cronSchedule) matchesDay(t time.Time) bool {
+	dom := c.dom&(1<<t.Day()) != 0
+	dow := c.dow&(1<<int(t.Weekday())) != 0
+	if c.domRestricted && c.dowRestricted {
+		return dom || dow
+	}
+	return dom && dow
+}
+
+// next returns the first minute strictly after the given time the schedule
+// matches, in that time's location, or zero if there is none within
+// cronSearchYears
+func (c cronSchedule) next(after time.Time) time.Time {
+	loc := after.Location()
+	t := after.Truncate(time.Minute).Add(time.Minute)
+	limit := after.AddDate(cronSearchYears, 0, 0)
+
+	for t.Before(limit) {
+		switch {
+		case c.month&(1<<int(t.Month())) == 0:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+		case !c.matchesDay(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+		case c.hour&(1<<t.Hour()) == 0:
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc)
+		case c.minute&(1<<t.Minute()) == 0:
+			// Jump straight to the next matching minute of the hour
+			if later := c.minute >> (t.Minute() + 1); later != 0 {
+				t = t.Add(time.Duration(bits.TrailingZeros64(later)+1) * time.Minute)
+			} else {
+				t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc)
+			}
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// recurringWindow opens on a cron schedule in a time zone and stays open for
+// a fixed length. It doesn't open on its exception dates, such as holidays.
+type recurringWindow struct {
+	opens    cronSchedule
+	length   time.Duration
+	location *time.Location
+	// exceptions are YYYY-MM-DD dates in location
+	exceptions map[string]bool
+}
+
+// parseExceptionDates validates YYYY-MM-DD dates
+func parseExceptionDates(dates []string) (map[string]bool, error) {
+	if len(dates) == 0 {
+		return nil, nil
+	}
+	exceptions := make(map[string]bool, len(dates))
+	for _, date := range dates {
+		if _, err := time.Parse(time.DateOnly, date); err != nil {
+			return nil, fmt.Errorf("invalid exception date %q", date)
+		}
+		exceptions[date] = true
+	}
+	return exceptions, nil
+}
+
+// skipped checks if an opening falls on an exception date
+func (w recurringWindow) skipped(opening time.Time) bool {
+	return w.exceptions[opening.Format(time.DateOnly)]
+}
+
+// openedAt returns when the window currently open at now opened
+func (w recurringWindow) openedAt(now time.Time) (time.Time, bool) {
+	now = now.In(w.location)
+	for opening := w.opens.next(now.Add(-w.length)); !opening.IsZero() && !opening.After(now); opening = w.opens.next(opening) {
+		if !w.skipped(opening) {
+			return opening, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// isOpen checks if now falls inside the window
+func (w recurringWindow) isOpen(now time.Time) bool {
+	_, open := w.openedAt(now)
+	return open
+}
+
+// nextOpen returns the next time after now that the window opens, zero if
+// it won't open within cronSearchYears
+func (w recurringWindow) nextOpen(now time.Time) time.Time {
+	now = now.In(w.location)
+	limit := now.AddDate(cronSearchYears, 0, 0)
+	for opening := w.opens.next(now); !opening.IsZero() && opening.Before(limit); opening = w.opens.next(opening) {
+		if !w.skipped(opening) {
+			return opening
+		}
+	}
+	return time.Time{}
+}
+
+// windowPeriod is one opening of a window
+type windowPeriod struct {
+	opens, closes time.Time
+}
+
+// upcomingWindows returns the next count periods of the given windows,
+// starting with any open at now, earliest first
+func upcomingWindows(windows []recurringWindow, now time.Time, count int) []windowPeriod {
+	var periods []windowPeriod
+	for _, w := range windows {
+		from := now
+		if opened, open := w.openedAt(now); open {
+			periods = append(periods, windowPeriod{opened, opened.Add(w.length)})
+		}
+		for i := 0; i < count; i++ {
+			opens := w.nextOpen(from)
+			if opens.IsZero() {
+				break
+			}
+			periods = append(periods, windowPeriod{opens, opens.Add(w.length)})
+			from = opens
+		}
+	}
+
+	sort.Slice(periods, func(i, j int) bool { return periods[i].opens.Before(periods[j].opens) })
+	if len(periods) > count {
+		periods = periods[:count]
+	}
+	return periods
+}
@@ -0,0 +1,110 @@
+package controller
+
+import (
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	autoapplyv1alpha1 "github.com/manos/k8s-autoapply-operator/api/v1alpha1"
+)
+
+func TestCronScheduleNext(t *testing.T) {
+	// 2024-01-01 is a Monday
+	from := time.Date(2024, 1, 1, 10, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		expr     string
+		expected time.Time
+	}{
+		{"every minute", "* * * * *", time.Date(2024, 1, 1, 10, 31, 0, 0, time.UTC)},
+		{"later today", "0 12 * * *", time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)},
+		{"tomorrow", "15 9 * * *", time.Date(2024, 1, 2, 9, 15, 0, 0, time.UTC)},
+		{"step", "*/20 * * * *", time.Date(2024, 1, 1, 10, 40, 0, 0, time.UTC)},
+		{"weekday name", "0 2 * * Sat", time.Date(2024, 1, 6, 2, 0, 0, 0, time.UTC)},
+		{"seven is Sunday", "0 2 * * 7", time.Date(2024, 1, 7, 2, 0, 0, 0, time.UTC)},
+		{"month range", "0 0 1 mar-may *", time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)},
+		{"either day field", "0 0 15 * Wed", time.Date(2024, 1, 3, 0, 0, 0, 0, time.UTC)},
+		{"leap day", "0 0 29 2 *", time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := parseCron(tt.expr)
+			if err != nil {
+				t.Fatalf("parseCron() failed: %v", err)
+			}
+			if got := c.next(from); !got.Equal(tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestParseCron_Invalid(t *testing.T) {
+	for _, expr := range []string{"* * * *", "60 * * * *", "* * 0 * *", "*/0 * * * *", "5-1 * * * *", "* * * * Funday"} {
+		if _, err := parseCron(expr); err == nil {
+			t.Errorf("Expected an error for %q", expr)
+		}
+	}
+}
+
+func TestRecurringWindow_Exceptions(t *testing.T) {
+	w := mustParseWindow(t, autoapplyv1alpha1.MaintenanceWindow{
+		Schedule:   "0 2 * * *",
+		Duration:   &metav1.Duration{Duration: 2 * time.Hour},
+		Exceptions: []string{"2024-01-02"},
+	})
+
+	if !w.isOpen(time.Date(2024, 1, 1, 3, 0, 0, 0, time.UTC)) {
+		t.Error("Expected the window to be open on a normal day")
+	}
+	if w.isOpen(time.Date(2024, 1, 2, 3, 0, 0, 0, time.UTC)) {
+		t.Error("Expected the window to stay shut on an exception date")
+	}
+	if next := w.nextOpen(time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)); !next.Equal(time.Date(2024, 1, 3, 2, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected the next opening to skip the exception, got %v", next)
+	}
+}
+
+func TestRecurringWindow_DaylightSaving(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skipf("No time zone data: %v", err)
+	}
+	w := mustParseWindow(t, autoapplyv1alpha1.MaintenanceWindow{Start: "09:00", End: "10:00", TimeZone: "Europe/Berlin"})
+
+	// Clocks go forward on 2024-03-31, the window keeps opening at 09:00 local
+	before := w.nextOpen(time.Date(2024, 3, 30, 0, 0, 0, 0, berlin))
+	after := w.nextOpen(before)
+	if before.UTC().Hour() != 8 || after.UTC().Hour() != 7 {
+		t.Errorf("Expected 09:00 Berlin on both sides of the change, got %v and %v", before.UTC(), after.UTC())
+	}
+}
+
+func TestUpcomingWindows(t *testing.T) {
+	spec := &autoapplyv1alpha1.AutoApplyConfigSpec{MaintenanceWindows: []autoapplyv1alpha1.MaintenanceWindow{
+		{Start: "02:00", End: "04:00", Days: []string{"Mon"}},
+		{Schedule: "0 12 * * Tue,Wed", Duration: &metav1.Duration{Duration: time.Hour}},
+	}}
+
+	// Inside Monday's window
+	periods := upcomingMaintenanceWindows(spec, time.Date(2024, 1, 1, 3, 0, 0, 0, time.UTC))
+	expected := []time.Time{
+		time.Date(2024, 1, 1, 2, 0, 0, 0, time.UTC),
+		time.Date(2024, 1, 2, 12, 0, 0, 0, time.UTC),
+		time.Date(2024, 1, 3, 12, 0, 0, 0, time.UTC),
+	}
+	if len(periods) != len(expected) {
+		t.Fatalf("Expected %d periods, got %+v", len(expected), periods)
+	}
+	for i, opens := range expected {
+		if !periods[i].Opens.Time.Equal(opens) {
+			t.Errorf("Expected period %d to open at %v, got %v", i, opens, periods[i].Opens.Time)
+		}
+	}
+	if !periods[0].Closes.Time.Equal(time.Date(2024, 1, 1, 4, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected the open window to close at 04:00, got %v", periods[0].Closes.Time)
+	}
+}
@@ -0,0 +1,118 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// Most pod names listed in one StaleConfig Event
+const staleConfigEventPods = 5
+
+// runStaleConfigScanner looks for pods running stale config every
+// StaleConfigScanInterval until ctx is done
+func (r *ConfigMapReconciler) runStaleConfigScanner(ctx context.Context) error {
+	ticker := time.NewTicker(r.StaleConfigScanInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			r.scanStaleConfig(ctx)
+		}
+	}
+}
+
+// scanStaleConfig reports, per ConfigMap, the pods still running a version
+// from before its last handled change: pods skipped by a failed batch,
+// blocked by a PDB, or missed while the operator was down
+func (r *ConfigMapReconciler) scanStaleConfig(ctx context.Context) {
+	logger := log.FromContext(ctx).WithName("stale-config")
+
+	var configMaps corev1.ConfigMapList
+	if err := r.List(ctx, &configMaps); err != nil {
+		logger.Error(err, "Failed to list ConfigMaps")
+		return
+	}
+
+	staleConfigPods.reset()
+	var allStale []corev1.Pod
+	consumers := make(map[types.NamespacedName]configConsumers)
+	for i := range configMaps.Items {
+		configMap := &configMaps.Items[i]
+		found := r.configConsumers(ctx, configMap)
+		consumers[client.ObjectKeyFromObject(configMap)] = found
+		stale := found.stale
+		if len(stale) == 0 {
+			continue
+		}
+		allStale = append(allStale, stale...)
+
+		staleConfigPods.set(configMap.Namespace, configMap.Name, float64(len(stale)))
+		logger.Info("Pods running stale config", "configmap", client.ObjectKeyFromObject(configMap), "pods", len(stale))
+
+		if r.StaleConfigEvents {
+			names := podNames(stale)
+			if len(names) > staleConfigEventPods {
+				names = append(names[:staleConfigEventPods], fmt.Sprintf("and %d more", len(stale)-staleConfigEventPods))
+			}
+			r.Recorder.Eventf(configMap, corev1.EventTypeWarning, "StaleConfig",
+				"%d pods still run config from before %s: %s",
+				len(stale), configMap.Annotations[appliedAtAnnotation], strings.Join(names, ", "))
+		}
+	}
+
+	// Workloads recommended a restart in notify-only mode are current again
+	// once none of their pods run stale config
+	r.clearRestartRecommendations(ctx, allStale)
+
+	// Each team's namespace configs get a rollup of their ConfigMaps
+	r.updateNamespaceRollups(ctx, configMaps.Items, consumers)
+}
+
+// configConsumers returns the pods that get the ConfigMap's changes. Stale
+// ones should have been restarted for its last handled change but were
+// created before it rolled out. ConfigMaps whose current change is still
+// being handled have none.
+func (r *ConfigMapReconciler) configConsumers(ctx context.Context, configMap *corev1.ConfigMap) configConsumers {
+	var consumers configConsumers
+	appliedAt, err := time.Parse(time.RFC3339, configMap.Annotations[appliedAtAnnotation])
+	if err != nil {
+		return consumers
+	}
+	cfg := r.loadConfig(ctx, configMap)
+	if persisted, _ := persistedVersion(configMap); persisted != r.changeDetector(cfg).Version(configMap) {
+		return consumers
+	}
+	if cfg.isNamespaceExcluded(configMap.Namespace) || cfg.isConfigMapExcluded(configMap.Namespace, configMap.Name) {
+		return consumers
+	}
+
+	var pods corev1.PodList
+	if err := r.List(ctx, &pods, client.InNamespace(configMap.Namespace),
+		client.MatchingFields{podConfigMapIndex: configMap.Name}); err != nil {
+		log.FromContext(ctx).Error(err, "Failed to list pods")
+		return consumers
+	}
+
+	annotationCache := make(workloadAnnotationCache)
+	for _, pod := range pods.Items {
+		if reason, ok := r.podSkipReason(ctx, configMap, &pod, cfg, annotationCache); !ok || reason != "" {
+			continue
+		}
+		if pod.CreationTimestamp.Time.Before(appliedAt) {
+			consumers.stale = append(consumers.stale, pod)
+		} else {
+			consumers.current = append(consumers.current, pod)
+		}
+	}
+	return consumers
+}
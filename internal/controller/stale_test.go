@@ -0,0 +1,130 @@
+package controller
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// podUsingConfigMap returns a running pod mounting the ConfigMap, created at created
+func podUsingConfigMap(name, configMap string, created time.Time) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", CreationTimestamp: metav1.NewTime(created)},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{Name: "app", Image: "nginx"}},
+			Volumes: []corev1.Volume{{
+				Name: "config",
+				VolumeSource: corev1.VolumeSource{
+					ConfigMap: &corev1.ConfigMapVolumeSource{
+						LocalObjectReference: corev1.LocalObjectReference{Name: configMap},
+					},
+				},
+			}},
+		},
+		Status: corev1.PodStatus{Phase: corev1.PodRunning},
+	}
+}
+
+func TestReconcile_RecordsAppliedAt(t *testing.T) {
+	r, fakeClient := setupTestReconciler()
+	ctx := context.Background()
+
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: "test-config", Namespace: "default"}}
+	_ = fakeClient.Create(ctx, &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-config", Namespace: "default"},
+		Data:       map[string]string{"key": "value"},
+	})
+
+	// First sight only tracks the ConfigMap, nothing was rolled out
+	if _, err := r.Reconcile(ctx, req); err != nil {
+		t.Fatalf("Reconcile failed: %v", err)
+	}
+	var cm corev1.ConfigMap
+	_ = fakeClient.Get(ctx, req.NamespacedName, &cm)
+	if _, ok := cm.Annotations[appliedAtAnnotation]; ok {
+		t.Fatal("Expected no applied-at time for a newly tracked ConfigMap")
+	}
+
+	cm.Data["key"] = "changed"
+	_ = fakeClient.Update(ctx, &cm)
+	if _, err := r.Reconcile(ctx, req); err != nil {
+		t.Fatalf("Reconcile failed: %v", err)
+	}
+	_ = fakeClient.Get(ctx, req.NamespacedName, &cm)
+	appliedAt, err := time.Parse(time.RFC3339, cm.Annotations[appliedAtAnnotation])
+	if err != nil {
+		t.Fatalf("Expected an applied-at time, got %q", cm.Annotations[appliedAtAnnotation])
+	}
+	if time.Since(appliedAt) > time.Minute {
+		t.Errorf("Expected applied-at to be now, got %v", appliedAt)
+	}
+}
+
+func TestScanStaleConfig(t *testing.T) {
+	r, fakeClient := setupTestReconciler()
+	recorder := record.NewFakeRecorder(10)
+	r.Recorder = recorder
+	r.StaleConfigEvents = true
+	ctx := context.Background()
+
+	appliedAt := time.Now().Add(-time.Hour).UTC().Truncate(time.Second)
+	handled := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "handled", Namespace: "default"},
+		Data:       map[string]string{"key": "value"},
+	}
+	handled.Annotations = map[string]string{
+		lastSeenVersionAnnotation: configMapVersion(handled),
+		appliedAtAnnotation:       appliedAt.Format(time.RFC3339),
+	}
+	// Changed since it was last handled, so a restart is still on its way
+	inFlight := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "in-flight", Namespace: "default", Annotations: map[string]string{
+			lastSeenVersionAnnotation: "old-version",
+			appliedAtAnnotation:       appliedAt.Format(time.RFC3339),
+		}},
+		Data: map[string]string{"key": "value"},
+	}
+	for _, obj := range []client.Object{
+		handled,
+		inFlight,
+		podUsingConfigMap("stale", "handled", appliedAt.Add(-time.Minute)),
+		podUsingConfigMap("fresh", "handled", appliedAt.Add(time.Minute)),
+		podUsingConfigMap("waiting", "in-flight", appliedAt.Add(-time.Minute)),
+	} {
+		if err := fakeClient.Create(ctx, obj); err != nil {
+			t.Fatalf("Failed to create %s: %v", obj.GetName(), err)
+		}
+	}
+
+	r.scanStaleConfig(ctx)
+
+	if stale := testutil.ToFloat64(staleConfigPods.WithLabelValues("default", "handled")); stale != 1 {
+		t.Errorf("Expected 1 stale pod, got %v", stale)
+	}
+	if stale := testutil.ToFloat64(staleConfigPods.WithLabelValues("default", "in-flight")); stale != 0 {
+		t.Errorf("Expected pods of an in-flight change not to count, got %v", stale)
+	}
+
+	select {
+	case event := <-recorder.Events:
+		if !strings.Contains(event, "StaleConfig") || !strings.Contains(event, "stale") {
+			t.Errorf("Unexpected event: %s", event)
+		}
+	default:
+		t.Fatal("Expected a StaleConfig event")
+	}
+	select {
+	case event := <-recorder.Events:
+		t.Errorf("Expected a single event, got %s", event)
+	default:
+	}
+}
@@ -0,0 +1,221 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// +kubebuilder:rbac:groups="",resources=pods,verbs=patch
+
+// podDeletionCostAnnotation ranks pods for removal when a ReplicaSet scales
+// down; the lowest cost goes first
+const podDeletionCostAnnotation = "controller.kubernetes.io/pod-deletion-cost"
+
+// surgeDeployment returns the Deployment a Surge restart scales for the
+// pod's owner, or nil if the owner isn't a Deployment's ReplicaSet
+func (r *ConfigMapReconciler) surgeDeployment(ctx context.Context, pod *corev1.Pod) *appsv1.Deployment {
+	workload, err := r.resolveWorkload(ctx, pod)
+	if err != nil || workload == nil || workload.Kind != "Deployment" {
+		return nil
+	}
+
+	var deployment appsv1.Deployment
+	if err := r.Get(ctx, types.NamespacedName{Namespace: pod.Namespace, Name: workload.Name}, &deployment); err != nil {
+		return nil
+	}
+	return &deployment
+}
+
+// surgeBatch starts restarting a Deployment's batch without ever running
+// fewer Ready pods than before: once the restart budget has room for the
+// batch, it scales the Deployment up by the batch size. Once the extra pods
+// are Ready, awaitSurge marks the batch's pods as cheapest to delete and
+// scales back down, so the ReplicaSet removes exactly those.
+func (r *ConfigMapReconciler) surgeBatch(ctx context.Context, configMap *corev1.ConfigMap, owner *ownerProgress) (time.Duration, error) {
+	logger := log.FromContext(ctx).WithValues("deployment", owner.WorkloadName)
+
+	if len(owner.Pending) == 0 {
+		nextBatch(owner)
+		return 0, nil
+	}
+	if wait := r.restartBudgetDelay(len(owner.Pending)); wait > 0 {
+		logger.V(1).Info("Waiting for the cluster-wide restart budget", "count", len(owner.Pending), "delay", wait)
+		return wait, nil
+	}
+
+	var current appsv1.Deployment
+	if err := r.Get(ctx, surgeKey(configMap, owner), &current); err != nil {
+		return 0, batchError(owner, err)
+	}
+	// A rollout spreads the surge across ReplicaSets, so the old pods
+	// might not be the ones removed afterwards
+	if current.Status.ObservedGeneration < current.Generation || current.Status.UpdatedReplicas != current.Status.Replicas {
+		return 0, batchError(owner, fmt.Errorf("deployment %s is rolling out", current.Name))
+	}
+
+	replicas := int32(1)
+	if current.Spec.Replicas != nil {
+		replicas = *current.Spec.Replicas
+	}
+	logger.Info("Surging deployment", "batch", owner.Batch+1, "replicas", replicas, "surge", len(owner.Pending))
+	if err := r.scaleDeployment(ctx, &current, replicas+int32(len(owner.Pending))); err != nil {
+		return 0, batchError(owner, err)
+	}
+	owner.Replicas = &replicas
+	// The surge has podReadyTimeout from now
+	setOwnerStep(owner, ownerRestartSurging)
+	return 0, nil
+}
+
+// awaitSurge surges the Deployment for the batch and waits until it has
+// Ready pods for it, then scales it back so the batch's pods are removed
+func (r *ConfigMapReconciler) awaitSurge(ctx context.Context, configMap *corev1.ConfigMap, state *restartState, owner *ownerProgress) (time.Duration, error) {
+	if owner.Replicas == nil {
+		return r.surgeBatch(ctx, configMap, owner)
+	}
+
+	key := surgeKey(configMap, owner)
+	replicas := *owner.Replicas
+	surged := replicas + int32(len(owner.Pending))
+
+	var deployment appsv1.Deployment
+	err := r.Get(ctx, key, &deployment)
+	if err == nil && deployment.Status.ReadyReplicas < surged {
+		if time.Since(owner.StepTime.Time) < podReadyTimeout {
+			return pollInterval, nil
+		}
+		err = fmt.Errorf("%d of %d pods Ready after %s", deployment.Status.ReadyReplicas, surged, podReadyTimeout)
+	}
+	if err != nil {
+		// Scaling back removes the newest pods, the unready surge first
+		r.restoreReplicas(ctx, key, replicas)
+		return 0, fmt.Errorf("batch %d surge not ready: %w", owner.Batch+1, err)
+	}
+
+	restarted := r.markForDeletion(ctx, state.batchPods(owner, owner.Pending))
+	if err := r.restoreReplicas(ctx, key, replicas); err != nil {
+		return 0, batchError(owner, err)
+	}
+	for _, pod := range restarted {
+		state.pods[pod.Name] = pod
+		r.recordPodRestarted(&pod, configMap)
+	}
+	owner.Pending, owner.Restarted, owner.Replicas = nil, podNames(restarted), nil
+	setOwnerStep(owner, ownerRestartDraining)
+	if err := r.afterBatch(ctx, configMap, restarted); err != nil {
+		return 0, batchError(owner, err)
+	}
+	return 0, nil
+}
+
+// awaitSurgeDrained waits until the batch's pods are deleted or terminating
+// before the next batch
+func (r *ConfigMapReconciler) awaitSurgeDrained(ctx context.Context, cfg operatorConfig, state *restartState, owner *ownerProgress) (time.Duration, error) {
+	remaining, err := r.podsRemaining(ctx, state.batchPods(owner, owner.Restarted))
+	if err == nil && remaining > 0 {
+		if time.Since(owner.StepTime.Time) < podReadyTimeout {
+			return pollInterval, nil
+		}
+		err = fmt.Errorf("%d pods still running after %s", remaining, podReadyTimeout)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("batch %d old pods not removed: %w", owner.Batch+1, err)
+	}
+	if len(owner.Restarted) > 0 && len(cfg.verificationProbes) > 0 {
+		owner.Probes = &probeProgress{}
+		setOwnerStep(owner, ownerRestartProbing)
+		return 0, nil
+	}
+	nextBatch(owner)
+	return 0, nil
+}
+
+// restoreSurges scales Deployments a batch surged back, for restarts that
+// stop before the batch finished
+func (r *ConfigMapReconciler) restoreSurges(ctx context.Context, namespace string, state *restartState) {
+	for _, owner := range state.progress.Owners {
+		if owner.Step == ownerRestartSurging && owner.Replicas != nil {
+			r.restoreReplicas(ctx, types.NamespacedName{Namespace: namespace, Name: owner.WorkloadName}, *owner.Replicas)
+		}
+	}
+}
+
+// surgeKey is the key of the Deployment an owner's Surge batches scale
+func surgeKey(configMap *corev1.ConfigMap, owner *ownerProgress) types.NamespacedName {
+	return types.NamespacedName{Namespace: configMap.Namespace, Name: owner.WorkloadName}
+}
+
+// scaleDeployment sets the Deployment's replicas
+func (r *ConfigMapReconciler) scaleDeployment(ctx context.Context, deployment *appsv1.Deployment, replicas int32) error {
+	patch := client.MergeFrom(deployment.DeepCopy())
+	deployment.Spec.Replicas = &replicas
+	return r.Patch(ctx, deployment, patch)
+}
+
+// restoreReplicas scales the Deployment back, even if the restart was
+// cancelled meanwhile
+func (r *ConfigMapReconciler) restoreReplicas(ctx context.Context, key types.NamespacedName, replicas int32) error {
+	ctx = context.WithoutCancel(ctx)
+
+	var deployment appsv1.Deployment
+	if err := r.Get(ctx, key, &deployment); err != nil {
+		return err
+	}
+	if err := r.scaleDeployment(ctx, &deployment, replicas); err != nil {
+		log.FromContext(ctx).Error(err, "Failed to scale deployment back", "deployment", key.Name, "replicas", replicas)
+		return err
+	}
+	return nil
+}
+
+// markForDeletion gives the pods the lowest deletion cost and returns the
+// ones that still exist
+func (r *ConfigMapReconciler) markForDeletion(ctx context.Context, pods []corev1.Pod) []corev1.Pod {
+	logger := log.FromContext(ctx)
+
+	var marked []corev1.Pod
+	for _, pod := range pods {
+		var current corev1.Pod
+		if err := r.Get(ctx, client.ObjectKeyFromObject(&pod), &current); err != nil || current.DeletionTimestamp != nil {
+			logger.V(1).Info("Pod no longer exists, skipping", "pod", pod.Name)
+			continue
+		}
+
+		patch := client.MergeFrom(current.DeepCopy())
+		if current.Annotations == nil {
+			current.Annotations = make(map[string]string)
+		}
+		current.Annotations[podDeletionCostAnnotation] = strconv.Itoa(math.MinInt32)
+		if err := r.Patch(ctx, &current, patch); err != nil {
+			logger.Error(err, "Failed to mark pod for deletion", "pod", pod.Name)
+			continue
+		}
+		marked = append(marked, current)
+	}
+	return marked
+}
+
+// podsRemaining counts the pods that are neither deleted nor terminating
+func (r *ConfigMapReconciler) podsRemaining(ctx context.Context, pods []corev1.Pod) (int, error) {
+	remaining := 0
+	for _, pod := range pods {
+		var current corev1.Pod
+		err := r.Get(ctx, client.ObjectKeyFromObject(&pod), &current)
+		if err == nil && current.DeletionTimestamp == nil {
+			remaining++
+		} else if err != nil && !apierrors.IsNotFound(err) {
+			return 0, err
+		}
+	}
+	return remaining, nil
+}
@@ -0,0 +1,169 @@
+package controller
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+
+	autoapplyv1alpha1 "github.com/manos/k8s-autoapply-operator/api/v1alpha1"
+)
+
+// setupSurgeTest returns a reconciler whose client plays the ReplicaSet
+// controller: scaling the Deployment down deletes the pods marked with the
+// lowest deletion cost. It records every replica count the Deployment is
+// scaled to.
+func setupSurgeTest(t *testing.T, deployment *appsv1.Deployment, pods ...*corev1.Pod) (*ConfigMapReconciler, client.Client, *[]int32) {
+	t.Helper()
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = autoapplyv1alpha1.AddToScheme(scheme)
+
+	var scaled []int32
+	builder := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(deployment).
+		WithInterceptorFuncs(interceptor.Funcs{
+			Patch: func(ctx context.Context, c client.WithWatch, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+				var before appsv1.Deployment
+				_, isDeployment := obj.(*appsv1.Deployment)
+				if isDeployment {
+					_ = c.Get(ctx, client.ObjectKeyFromObject(obj), &before)
+				}
+				if err := c.Patch(ctx, obj, patch, opts...); err != nil {
+					return err
+				}
+				if !isDeployment {
+					return nil
+				}
+				replicas := *obj.(*appsv1.Deployment).Spec.Replicas
+				scaled = append(scaled, replicas)
+				if replicas >= *before.Spec.Replicas {
+					return nil
+				}
+				var list corev1.PodList
+				_ = c.List(ctx, &list, client.InNamespace(obj.GetNamespace()))
+				for _, pod := range list.Items {
+					if pod.Annotations[podDeletionCostAnnotation] != "" {
+						_ = c.Delete(ctx, &pod)
+					}
+				}
+				return nil
+			},
+		})
+	for _, pod := range pods {
+		builder = builder.WithObjects(pod)
+	}
+	fakeClient := builder.Build()
+
+	return &ConfigMapReconciler{Client: fakeClient, Scheme: scheme, Recorder: record.NewFakeRecorder(100)}, fakeClient, &scaled
+}
+
+func surgeFixture(generation int64) (*appsv1.Deployment, []*corev1.Pod) {
+	replicas := int32(2)
+	trueVal := true
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default", Generation: generation},
+		Spec:       appsv1.DeploymentSpec{Replicas: &replicas},
+		// Surge pods are Ready right away
+		Status: appsv1.DeploymentStatus{ObservedGeneration: 1, Replicas: 2, UpdatedReplicas: 2, ReadyReplicas: 10},
+	}
+	var pods []*corev1.Pod
+	for _, name := range []string{"web-abc-1", "web-abc-2"} {
+		pods = append(pods, &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", OwnerReferences: []metav1.OwnerReference{
+			{APIVersion: "apps/v1", Kind: "ReplicaSet", Name: "web-abc", UID: "web-abc-uid", Controller: &trueVal},
+		}}})
+	}
+	return deployment, pods
+}
+
+// surgeReplicaSet is the ReplicaSet of the surgeFixture pods
+func surgeReplicaSet() *appsv1.ReplicaSet {
+	trueVal := true
+	return &appsv1.ReplicaSet{ObjectMeta: metav1.ObjectMeta{Name: "web-abc", Namespace: "default", UID: "web-abc-uid", OwnerReferences: []metav1.OwnerReference{
+		{APIVersion: "apps/v1", Kind: "Deployment", Name: "web", Controller: &trueVal},
+	}}}
+}
+
+// surgePods returns the pods to restart as the restart is handed them
+func surgePods(pods []*corev1.Pod) []corev1.Pod {
+	podsToRestart := make([]corev1.Pod, 0, len(pods))
+	for _, pod := range pods {
+		podsToRestart = append(podsToRestart, *pod)
+	}
+	return podsToRestart
+}
+
+func TestSurgeRestart(t *testing.T) {
+	deployment, pods := surgeFixture(1)
+	r, fakeClient, scaled := setupSurgeTest(t, deployment, pods...)
+	ctx := context.Background()
+	_ = fakeClient.Create(ctx, surgeReplicaSet())
+
+	// The two pods are surged one batch at a time
+	cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "app-config", Namespace: "default"}}
+	cfg := r.loadConfig(ctx, nil)
+	cfg.strategy = autoapplyv1alpha1.RestartStrategySurge
+	if err := runRestart(ctx, r, cfg, cm, "v2", surgePods(pods)); err != nil {
+		t.Fatalf("Surge restart failed: %v", err)
+	}
+
+	expected := []int32{3, 2, 3, 2}
+	if len(*scaled) != len(expected) {
+		t.Fatalf("Expected the deployment scaled to %v, got %v", expected, *scaled)
+	}
+	for i := range expected {
+		if (*scaled)[i] != expected[i] {
+			t.Fatalf("Expected the deployment scaled to %v, got %v", expected, *scaled)
+		}
+	}
+
+	var remaining corev1.PodList
+	_ = fakeClient.List(ctx, &remaining, client.InNamespace("default"))
+	if len(remaining.Items) != 0 {
+		t.Errorf("Expected the old pods to be removed, %d left", len(remaining.Items))
+	}
+}
+
+func TestSurgeRestart_RolloutInProgress(t *testing.T) {
+	deployment, pods := surgeFixture(2)
+	r, fakeClient, scaled := setupSurgeTest(t, deployment, pods...)
+	ctx := context.Background()
+	_ = fakeClient.Create(ctx, surgeReplicaSet())
+
+	cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "app-config", Namespace: "default"}}
+	cfg := r.loadConfig(ctx, nil)
+	cfg.strategy = autoapplyv1alpha1.RestartStrategySurge
+	err := runRestart(ctx, r, cfg, cm, "v2", surgePods(pods))
+	if err == nil || !strings.Contains(err.Error(), "rolling out") {
+		t.Fatalf("Expected a rollout in progress error, got %v", err)
+	}
+	if len(*scaled) != 0 {
+		t.Errorf("Expected no scaling during a rollout, got %v", *scaled)
+	}
+}
+
+func TestSurgeDeployment(t *testing.T) {
+	deployment, pods := surgeFixture(1)
+	r, fakeClient, _ := setupSurgeTest(t, deployment, pods...)
+	ctx := context.Background()
+	_ = fakeClient.Create(ctx, surgeReplicaSet())
+
+	if result := r.surgeDeployment(ctx, pods[0]); result == nil || result.Name != "web" {
+		t.Errorf("Expected deployment web, got %v", result)
+	}
+
+	standalone := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "standalone", Namespace: "default"}}
+	if result := r.surgeDeployment(ctx, standalone); result != nil {
+		t.Errorf("Expected no deployment for a standalone pod, got %s", result.Name)
+	}
+}
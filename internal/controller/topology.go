@@ -0,0 +1,76 @@
+package controller
+
+import (
+	"context"
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// spreadByTopology orders pods so that restart batches, which are cut from
+// the front, take each zone's and each node's pods evenly instead of all
+// replicas in one zone or on one node at once
+func (r *ConfigMapReconciler) spreadByTopology(ctx context.Context, pods []corev1.Pod) []corev1.Pod {
+	zones := make(map[string]string)
+	for _, pod := range pods {
+		node := pod.Spec.NodeName
+		if _, ok := zones[node]; ok || node == "" {
+			continue
+		}
+		zones[node] = r.nodeZone(ctx, node)
+	}
+
+	byNode := spreadPods(pods, func(pod *corev1.Pod) string { return pod.Spec.NodeName })
+	return spreadPods(byNode, func(pod *corev1.Pod) string { return zones[pod.Spec.NodeName] })
+}
+
+// nodeZone returns the topology zone label of a node, empty if unknown
+func (r *ConfigMapReconciler) nodeZone(ctx context.Context, nodeName string) string {
+	var node corev1.Node
+	if err := r.Get(ctx, types.NamespacedName{Name: nodeName}, &node); err != nil {
+		return ""
+	}
+	return node.Labels[corev1.LabelTopologyZone]
+}
+
+// spreadPods orders pods so each group's pods are spread evenly over the
+// result: the j-th of a group's k pods is placed at (j+½)/k of the way. Pods
+// of a group keep their relative order, so a single group is left as it is.
+func spreadPods(pods []corev1.Pod, group func(*corev1.Pod) string) []corev1.Pod {
+	type placedPod struct {
+		pod      corev1.Pod
+		group    string
+		position float64
+	}
+
+	counts := make(map[string]int)
+	for i := range pods {
+		counts[group(&pods[i])]++
+	}
+
+	seen := make(map[string]int)
+	placed := make([]placedPod, 0, len(pods))
+	for i := range pods {
+		key := group(&pods[i])
+		placed = append(placed, placedPod{
+			pod:      pods[i],
+			group:    key,
+			position: (float64(seen[key]) + 0.5) / float64(counts[key]),
+		})
+		seen[key]++
+	}
+
+	sort.SliceStable(placed, func(i, j int) bool {
+		if placed[i].position != placed[j].position {
+			return placed[i].position < placed[j].position
+		}
+		return placed[i].group < placed[j].group
+	})
+
+	spread := make([]corev1.Pod, 0, len(pods))
+	for _, p := range placed {
+		spread = append(spread, p.pod)
+	}
+	return spread
+}
@@ -0,0 +1,66 @@
+package controller
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func podOnNode(name, node string) corev1.Pod {
+	return corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+		Spec:       corev1.PodSpec{NodeName: node},
+	}
+}
+
+func TestSpreadPods(t *testing.T) {
+	pods := []corev1.Pod{podOnNode("a-1", "a"), podOnNode("a-2", "a"), podOnNode("a-3", "a"), podOnNode("a-4", "a"), podOnNode("b-1", "b"), podOnNode("b-2", "b")}
+	byNode := func(pod *corev1.Pod) string { return pod.Spec.NodeName }
+
+	spread := podNames(spreadPods(pods, byNode))
+	expected := []string{"a-1", "b-1", "a-2", "a-3", "b-2", "a-4"}
+	if !reflect.DeepEqual(spread, expected) {
+		t.Errorf("Expected %v, got %v", expected, spread)
+	}
+
+	// A single group keeps its order
+	single := podNames(spreadPods(pods[:4], byNode))
+	if !reflect.DeepEqual(single, []string{"a-1", "a-2", "a-3", "a-4"}) {
+		t.Errorf("Expected the order to be kept, got %v", single)
+	}
+}
+
+func TestSpreadByTopology(t *testing.T) {
+	r, fakeClient := setupTestReconciler()
+	ctx := context.Background()
+
+	for node, zone := range map[string]string{"n1": "zone-a", "n2": "zone-a", "n3": "zone-b", "n4": "zone-b"} {
+		_ = fakeClient.Create(ctx, &corev1.Node{ObjectMeta: metav1.ObjectMeta{
+			Name:   node,
+			Labels: map[string]string{corev1.LabelTopologyZone: zone},
+		}})
+	}
+	pods := []corev1.Pod{
+		podOnNode("a-1", "n1"), podOnNode("a-2", "n1"), podOnNode("a-3", "n2"), podOnNode("a-4", "n2"),
+		podOnNode("b-1", "n3"), podOnNode("b-2", "n4"),
+	}
+
+	spread := r.spreadByTopology(ctx, pods)
+	expected := []string{"a-1", "b-1", "a-3", "a-2", "b-2", "a-4"}
+	if names := podNames(spread); !reflect.DeepEqual(names, expected) {
+		t.Fatalf("Expected %v, got %v", expected, names)
+	}
+
+	// The first half takes half of each zone and one pod per node
+	first, _ := splitOwnerPods("", spread)
+	nodes := make(map[string]bool)
+	for _, pod := range first {
+		if nodes[pod.Spec.NodeName] {
+			t.Errorf("Expected one pod per node in the first batch, got %v", podNames(first))
+		}
+		nodes[pod.Spec.NodeName] = true
+	}
+}
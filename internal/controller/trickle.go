@@ -0,0 +1,319 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+const (
+	// pausedAnnotation on a ConfigMap pauses its trickle restart when set to "true"
+	pausedAnnotation = "autoapply.io/paused"
+	// trickleVersionAnnotation and trickleStartedAnnotation record the version
+	// an in-progress trickle restart rolls out and when it started, so a
+	// restarted operator doesn't restart pods created since
+	trickleVersionAnnotation = "autoapply.io/trickle-version"
+	trickleStartedAnnotation = "autoapply.io/trickle-started"
+	// Default number of pods a trickle restart evicts per interval
+	defaultTrickleBatchSize = 10
+	// Default time between trickle restart steps
+	defaultTrickleInterval = 1 * time.Minute
+	// trickleStallSteps is how many steps in a row may restart no pod, as
+	// when PDBs block every stale pod, before a trickle restart gives up
+	trickleStallSteps = 10
+)
+
+// trickleState tracks an in-progress trickle restart of one ConfigMap version
+type trickleState struct {
+	version string
+	// started is when the trickle began; pods created before it are stale
+	started   time.Time
+	restarted int
+	// jobs are the pre-restart Jobs run before the trickle is announced
+	jobs *preRestartJobProgress
+	// announced is set once the TriggeredRestart Event was emitted
+	announced bool
+	// probes are how far the verification probes of the probed pods, the
+	// last step's restarted ones, got. Set until they pass or fail.
+	probes *probeProgress
+	probed []corev1.Pod
+	// recreating are the last step's restarted pods, created again once gone
+	// if they have no controller. Set until none is left terminating.
+	recreating    []corev1.Pod
+	recreateSince time.Time
+	// summary describes the trickle for notifications once announced
+	summary restartSummary
+	// next is when the next step is due and resourceVersion the ConfigMap's
+	// after the last step. Reconciles before next for an unchanged ConfigMap,
+	// as the operator's own updates of it cause, don't take a step.
+	next            time.Time
+	resourceVersion string
+	// stalled counts the steps in a row that restarted no pod
+	stalled int
+}
+
+// trickleStep restarts the next slice of stale pods and requeues until every
+// pod using the ConfigMap was created after the trickle started
+func (r *ConfigMapReconciler) trickleStep(ctx context.Context, cfg operatorConfig, configMap *corev1.ConfigMap, key, version string) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+
+	var state trickleState
+	if value, ok := r.trickles.Load(key); ok && value.(trickleState).version == version {
+		state = value.(trickleState)
+	} else if started, ok := persistedTrickleStart(configMap, version); ok {
+		// Resumed after an operator restart, the restarted count starts over
+		logger.Info("Resuming trickle restart", "started", started)
+		state = trickleState{version: version, started: started, announced: true}
+	} else {
+		state = trickleState{version: version, started: time.Now(), jobs: &preRestartJobProgress{}}
+		r.persistTrickleStart(ctx, configMap, state)
+	}
+
+	// The last step's bare pods are created again before anything else
+	if len(state.recreating) > 0 {
+		terminating, err := r.recreateBarePods(ctx, configMap, state.recreating, state.recreateSince)
+		if err != nil {
+			logger.Error(err, "Trickle restart step failed")
+			r.reportRestartFailures(configMap, newOwnerRestartError(state.recreating, err))
+		}
+		state.recreating = terminating
+		if len(terminating) > 0 {
+			r.trickles.Store(key, state)
+			return ctrl.Result{RequeueAfter: pollInterval}, nil
+		}
+		if state.probes == nil {
+			state.next = time.Now().Add(cfg.trickleInterval)
+			r.trickles.Store(key, state)
+			return ctrl.Result{RequeueAfter: cfg.trickleInterval}, nil
+		}
+	}
+
+	// The last step's replacements must pass the verification probes before
+	// the next step
+	if state.probes != nil {
+		done, err := r.stepVerificationProbes(ctx, cfg, configMap, state.probes)
+		if err == nil && !done {
+			r.trickles.Store(key, state)
+			return ctrl.Result{RequeueAfter: pollInterval}, nil
+		}
+		if err != nil {
+			logger.Error(err, "Trickle restart step failed")
+			r.reportRestartFailures(configMap, newOwnerRestartError(state.probed, err))
+		}
+		state.probes, state.probed = nil, nil
+		state.next = time.Now().Add(cfg.trickleInterval)
+		r.trickles.Store(key, state)
+		return ctrl.Result{RequeueAfter: cfg.trickleInterval}, nil
+	}
+
+	var stale []corev1.Pod
+	for _, pod := range r.findPodsUsingConfigMap(ctx, configMap, cfg) {
+		if pod.CreationTimestamp.Time.Before(state.started) {
+			stale = append(stale, pod)
+		}
+	}
+	stale = trickleOrder(cfg, stale)
+
+	if len(stale) == 0 {
+		logger.Info("Trickle restart complete", "restarted", state.restarted)
+		r.trickles.Delete(key)
+		// Trickle steps pick up pods a PDB blocked themselves
+		r.pdbRetries.Delete(key)
+		trickleRestartedPods.delete(configMap.Namespace, configMap.Name)
+		trickleRemainingPods.delete(configMap.Namespace, configMap.Name)
+		if state.restarted > 0 {
+			r.Recorder.Eventf(configMap, corev1.EventTypeNormal, "TrickleRestartComplete",
+				"Restarted %d pods over %s", state.restarted, time.Since(state.started).Round(time.Second))
+		}
+		r.finishOperation(ctx, configMap, nil, r.configPropagated(configMap))
+		if state.announced {
+			summary := state.summary
+			summary.pods = state.restarted
+			r.notifyFinished(ctx, cfg, configMap, summary, nil)
+		}
+		r.clearTrickleStart(ctx, configMap)
+		r.persistVersion(ctx, cfg, configMap, version, state.started)
+		return ctrl.Result{}, nil
+	}
+
+	// Only a step taken too early is held back, finishing needs no wait
+	if wait := time.Until(state.next); wait > 0 && configMap.ResourceVersion == state.resourceVersion {
+		return ctrl.Result{RequeueAfter: wait}, nil
+	}
+
+	if configMap.Annotations[pausedAnnotation] == "true" {
+		logger.Info("Trickle restart paused", "remaining", len(stale))
+		r.trickles.Store(key, state)
+		trickleRemainingPods.set(configMap.Namespace, configMap.Name, float64(len(stale)))
+		return ctrl.Result{RequeueAfter: cfg.trickleInterval}, nil
+	}
+
+	if !state.announced {
+		// Pre-restart Jobs must succeed before any pod is restarted
+		done, err := r.stepPreRestartJobs(ctx, cfg, configMap, state.jobs)
+		if err != nil {
+			r.reportPreRestartJobFailed(ctx, configMap, err)
+			r.finishOperation(ctx, configMap, err, 0)
+			r.trickles.Delete(key)
+			r.clearTrickleStart(ctx, configMap)
+			r.persistVersion(ctx, cfg, configMap, version, state.started)
+			return ctrl.Result{}, nil
+		}
+		if !done {
+			r.trickles.Store(key, state)
+			return ctrl.Result{RequeueAfter: pollInterval}, nil
+		}
+		state.jobs = nil
+
+		changes := r.changedKeys(cfg, configMap)
+		r.Recorder.Event(configMap, corev1.EventTypeNormal, "TriggeredRestart", describeChange(fmt.Sprintf(
+			"Trickle restarting %d pods, %d every %s", len(stale), cfg.trickleBatchSize, cfg.trickleInterval), changes))
+		state.announced = true
+		r.startOperation(ctx, cfg, configMap, version, changes, stale)
+		state.summary = r.summarizeRestart(ctx, configMap, stale, state.started)
+		r.notifyStarted(ctx, cfg, configMap, state.summary)
+	}
+
+	batch := stale[:min(cfg.trickleBatchSize, len(stale))]
+	pass, err := r.trickleBatch(ctx, cfg, configMap, batch)
+	if err != nil {
+		logger.Error(err, "Trickle restart step failed")
+		r.reportRestartFailures(configMap, newOwnerRestartError(batch, err))
+	}
+	restarted := pass.restarted
+	state.restarted += len(restarted)
+	if len(restarted) == 0 && pass.wait == 0 {
+		state.stalled++
+	} else {
+		state.stalled = 0
+	}
+	if state.stalled >= trickleStallSteps {
+		r.abandonTrickle(ctx, cfg, configMap, key, state, len(stale))
+		return ctrl.Result{}, nil
+	}
+	// Pods the restart budget held back stay stale for a later step
+	next := max(cfg.trickleInterval, pass.wait)
+	if len(restarted) > 0 && cfg.recreateBarePods {
+		state.recreating, state.recreateSince = restarted, time.Now()
+		next = pollInterval
+	}
+	if len(restarted) > 0 && len(cfg.verificationProbes) > 0 {
+		// Probed first, then the interval starts
+		state.probes, state.probed = &probeProgress{}, restarted
+		next = pollInterval
+	}
+	state.next = time.Now().Add(next)
+	state.resourceVersion = configMap.ResourceVersion
+	r.trickles.Store(key, state)
+
+	trickleRestartedPods.set(configMap.Namespace, configMap.Name, float64(state.restarted))
+	trickleRemainingPods.set(configMap.Namespace, configMap.Name, float64(len(stale)-len(restarted)))
+
+	logger.Info("Trickle restart step done",
+		"restarted", len(restarted),
+		"remaining", len(stale)-len(restarted),
+		"next", next)
+	return ctrl.Result{RequeueAfter: next}, nil
+}
+
+// abandonTrickle gives up on a trickle restart that stalled with remaining
+// stale pods. The version is persisted like for a finished trickle, so the
+// pods left are only restarted by the next change.
+func (r *ConfigMapReconciler) abandonTrickle(ctx context.Context, cfg operatorConfig, configMap *corev1.ConfigMap, key string, state trickleState, remaining int) {
+	err := fmt.Errorf("no pod restarted in %d steps, %d pods left", state.stalled, remaining)
+	log.FromContext(ctx).Error(err, "Trickle restart stalled, giving up")
+	r.Recorder.Eventf(configMap, corev1.EventTypeWarning, "TrickleRestartStalled",
+		"Gave up on trickle restart after restarting %d pods: %v", state.restarted, err)
+
+	r.trickles.Delete(key)
+	r.pdbRetries.Delete(key)
+	trickleRestartedPods.delete(configMap.Namespace, configMap.Name)
+	trickleRemainingPods.delete(configMap.Namespace, configMap.Name)
+	r.finishOperation(ctx, configMap, err, 0)
+	summary := state.summary
+	summary.pods = state.restarted
+	r.notifyFinished(ctx, cfg, configMap, summary, err)
+	r.clearTrickleStart(ctx, configMap)
+	r.persistVersion(ctx, cfg, configMap, state.version, state.started)
+}
+
+// trickleBatch restarts the pods of one trickle step. Pods a PDB blocks stay
+// stale for a later step.
+func (r *ConfigMapReconciler) trickleBatch(ctx context.Context, cfg operatorConfig, configMap *corev1.ConfigMap, pods []corev1.Pod) (evictionPass, error) {
+	pods, err := r.beforeBatch(ctx, cfg, configMap, pods)
+	if err != nil {
+		return evictionPass{}, err
+	}
+	pass := r.evictPods(ctx, configMap, pods, false)
+	return pass, r.afterBatch(ctx, configMap, pass.restarted)
+}
+
+// persistedTrickleStart returns when the trickle restart of version recorded
+// on the ConfigMap started, if one is
+func persistedTrickleStart(configMap *corev1.ConfigMap, version string) (time.Time, bool) {
+	if configMap.Annotations[trickleVersionAnnotation] != version {
+		return time.Time{}, false
+	}
+	started, err := time.Parse(time.RFC3339, configMap.Annotations[trickleStartedAnnotation])
+	if err != nil {
+		return time.Time{}, false
+	}
+	return started, true
+}
+
+// persistTrickleStart records the trickle's version and start on the ConfigMap
+func (r *ConfigMapReconciler) persistTrickleStart(ctx context.Context, configMap *corev1.ConfigMap, state trickleState) {
+	patch := client.MergeFrom(configMap.DeepCopy())
+	if configMap.Annotations == nil {
+		configMap.Annotations = make(map[string]string)
+	}
+	configMap.Annotations[trickleVersionAnnotation] = state.version
+	configMap.Annotations[trickleStartedAnnotation] = state.started.UTC().Format(time.RFC3339)
+	if err := r.Patch(ctx, configMap, patch); err != nil {
+		log.FromContext(ctx).Error(err, "Failed to persist trickle restart start", "configmap", client.ObjectKeyFromObject(configMap))
+	}
+}
+
+// clearTrickleStart removes the trickle annotations once the trickle ended
+func (r *ConfigMapReconciler) clearTrickleStart(ctx context.Context, configMap *corev1.ConfigMap) {
+	if _, ok := configMap.Annotations[trickleVersionAnnotation]; !ok {
+		return
+	}
+	patch := client.MergeFrom(configMap.DeepCopy())
+	delete(configMap.Annotations, trickleVersionAnnotation)
+	delete(configMap.Annotations, trickleStartedAnnotation)
+	if err := r.Patch(ctx, configMap, patch); err != nil {
+		log.FromContext(ctx).Error(err, "Failed to clear trickle restart start", "configmap", client.ObjectKeyFromObject(configMap))
+	}
+}
+
+// trickleOrder orders pods for trickle steps, which are cut from the front,
+// so critical pods go last when restarting by priority
+func trickleOrder(cfg operatorConfig, pods []corev1.Pod) []corev1.Pod {
+	if cfg.restartByPriority {
+		sort.SliceStable(pods, func(i, j int) bool { return podPriority(&pods[i]) < podPriority(&pods[j]) })
+	}
+	return pods
+}
+
+// trickleBatches splits pods into the steps a trickle restart would take
+// if none of them were replaced meanwhile
+func trickleBatches(cfg operatorConfig, pods []corev1.Pod) [][]corev1.Pod {
+	pods = trickleOrder(cfg, slices.Clone(pods))
+	size := max(cfg.trickleBatchSize, 1)
+
+	var batches [][]corev1.Pod
+	for len(pods) > 0 {
+		n := min(size, len(pods))
+		batches = append(batches, pods[:n])
+		pods = pods[n:]
+	}
+	return batches
+}
@@ -0,0 +1,237 @@
+package controller
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+
+	autoapplyv1alpha1 "github.com/manos/k8s-autoapply-operator/api/v1alpha1"
+)
+
+func TestReconcile_Trickle(t *testing.T) {
+	r, fakeClient := setupTestReconciler()
+	ctx := context.Background()
+
+	req := ctrl.Request{
+		NamespacedName: types.NamespacedName{Name: "my-config", Namespace: "default"},
+	}
+	r.configMapVersions.Store(req.String(), "old-version")
+
+	_ = fakeClient.Create(ctx, &autoapplyv1alpha1.AutoApplyConfig{
+		ObjectMeta: metav1.ObjectMeta{Name: "trickle"},
+		Spec: autoapplyv1alpha1.AutoApplyConfigSpec{
+			Strategy:         autoapplyv1alpha1.RestartStrategyTrickle,
+			TrickleBatchSize: 2,
+			TrickleInterval:  &metav1.Duration{Duration: 5 * time.Minute},
+		},
+	})
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-config", Namespace: "default"},
+		Data:       map[string]string{"key": "value"},
+	}
+	_ = fakeClient.Create(ctx, cm)
+	for _, name := range []string{"pod-a", "pod-b", "pod-c"} {
+		_ = fakeClient.Create(ctx, configVolumePod(name, ""))
+	}
+
+	countPods := func() int {
+		var pods corev1.PodList
+		_ = fakeClient.List(ctx, &pods, client.InNamespace("default"))
+		return len(pods.Items)
+	}
+
+	// First step restarts one batch and requeues
+	result, err := r.Reconcile(ctx, req)
+	if err != nil {
+		t.Fatalf("Reconcile failed: %v", err)
+	}
+	if result.RequeueAfter != 5*time.Minute {
+		t.Errorf("Expected requeue after the trickle interval, got %v", result.RequeueAfter)
+	}
+	if countPods() != 1 {
+		t.Errorf("Expected one batch of 2 pods to be restarted, %d pods left", countPods())
+	}
+	labels := []string{"default", "my-config"}
+	if remaining := testutil.ToFloat64(trickleRemainingPods.WithLabelValues(labels...)); remaining != 1 {
+		t.Errorf("Expected 1 remaining pod in metrics, got %v", remaining)
+	}
+
+	// Pausing keeps the remaining pods
+	_ = fakeClient.Get(ctx, req.NamespacedName, cm)
+	cm.Annotations = map[string]string{pausedAnnotation: "true"}
+	_ = fakeClient.Update(ctx, cm)
+	if result, _ = r.Reconcile(ctx, req); result.RequeueAfter != 5*time.Minute || countPods() != 1 {
+		t.Errorf("Expected paused trickle to requeue without restarting, %d pods left", countPods())
+	}
+
+	// Resuming restarts the rest, then the trickle completes
+	delete(cm.Annotations, pausedAnnotation)
+	_ = fakeClient.Update(ctx, cm)
+	_, _ = r.Reconcile(ctx, req)
+	if countPods() != 0 {
+		t.Errorf("Expected remaining pod to be restarted, %d pods left", countPods())
+	}
+
+	if result, _ = r.Reconcile(ctx, req); result.RequeueAfter != 0 {
+		t.Errorf("Expected completed trickle not to requeue, got %v", result.RequeueAfter)
+	}
+	if _, trickling := r.trickles.Load(req.String()); trickling {
+		t.Error("Expected trickle state to be cleared")
+	}
+	_ = fakeClient.Get(ctx, req.NamespacedName, cm)
+	if version, _ := persistedVersion(cm); version != configMapVersion(cm) {
+		t.Error("Expected the version to be persisted once the trickle completed")
+	}
+}
+
+func TestReconcile_TrickleResumesAfterOperatorRestart(t *testing.T) {
+	r, fakeClient := setupTestReconciler()
+	ctx := context.Background()
+
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: "my-config", Namespace: "default"}}
+	_ = fakeClient.Create(ctx, &autoapplyv1alpha1.AutoApplyConfig{
+		ObjectMeta: metav1.ObjectMeta{Name: "trickle"},
+		Spec: autoapplyv1alpha1.AutoApplyConfigSpec{
+			Strategy:         autoapplyv1alpha1.RestartStrategyTrickle,
+			TrickleBatchSize: 1,
+		},
+	})
+
+	// A previous operator started the trickle ten minutes ago
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-config", Namespace: "default"},
+		Data:       map[string]string{"key": "value"},
+	}
+	cm.Annotations = map[string]string{
+		lastSeenVersionAnnotation: "old-version",
+		trickleVersionAnnotation:  configMapVersion(cm),
+		trickleStartedAnnotation:  time.Now().Add(-10 * time.Minute).UTC().Format(time.RFC3339),
+	}
+	_ = fakeClient.Create(ctx, cm)
+
+	for name, age := range map[string]time.Duration{"stale-a": time.Hour, "stale-b": time.Hour, "replaced": 5 * time.Minute} {
+		pod := configVolumePod(name, "")
+		pod.CreationTimestamp = metav1.NewTime(time.Now().Add(-age))
+		_ = fakeClient.Create(ctx, pod)
+	}
+
+	remaining := func() []string {
+		var pods corev1.PodList
+		_ = fakeClient.List(ctx, &pods, client.InNamespace("default"))
+		return podNames(pods.Items)
+	}
+
+	if _, err := r.Reconcile(ctx, req); err != nil {
+		t.Fatalf("Reconcile failed: %v", err)
+	}
+	if pods := remaining(); len(pods) != 2 || !slices.Contains(pods, "replaced") {
+		t.Fatalf("Expected one stale pod restarted and the replaced one kept, got %v", pods)
+	}
+
+	// Reconciles before the interval, like the ones the operator's own
+	// ConfigMap updates cause, don't take another step
+	if result, _ := r.Reconcile(ctx, req); result.RequeueAfter <= 0 || len(remaining()) != 2 {
+		t.Errorf("Expected an early reconcile to wait, requeue %v with pods %v", result.RequeueAfter, remaining())
+	}
+}
+
+func TestReconcile_TrickleGivesUpWhenStalled(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = autoapplyv1alpha1.AddToScheme(scheme)
+
+	// Once blocking, every eviction is rejected as a PDB would
+	blocking := false
+	fakeClient := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithIndex(&corev1.Pod{}, podConfigMapIndex, indexPodConfigMaps).
+		WithStatusSubresource(&autoapplyv1alpha1.RestartOperation{}).
+		WithInterceptorFuncs(interceptor.Funcs{
+			SubResourceCreate: func(ctx context.Context, c client.Client, subResourceName string, obj client.Object, subResource client.Object, opts ...client.SubResourceCreateOption) error {
+				if subResourceName == "eviction" && blocking {
+					return apierrors.NewTooManyRequests("Cannot evict pod as it would violate the pod's disruption budget.", 0)
+				}
+				return c.SubResource(subResourceName).Create(ctx, obj, subResource, opts...)
+			},
+		}).
+		Build()
+	recorder := record.NewFakeRecorder(100)
+	r := &ConfigMapReconciler{Client: fakeClient, Scheme: scheme, Recorder: recorder, RecordOperations: true}
+	ctx := context.Background()
+
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: "my-config", Namespace: "default"}}
+	r.configMapVersions.Store(req.String(), "old-version")
+	_ = fakeClient.Create(ctx, &autoapplyv1alpha1.AutoApplyConfig{
+		ObjectMeta: metav1.ObjectMeta{Name: "trickle"},
+		Spec: autoapplyv1alpha1.AutoApplyConfigSpec{
+			Strategy:         autoapplyv1alpha1.RestartStrategyTrickle,
+			TrickleBatchSize: 1,
+		},
+	})
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-config", Namespace: "default"},
+		Data:       map[string]string{"key": "value"},
+	}
+	_ = fakeClient.Create(ctx, cm)
+	for _, name := range []string{"pod-a", "pod-b", "pod-c"} {
+		pod := configVolumePod(name, "")
+		pod.CreationTimestamp = metav1.NewTime(time.Now().Add(-time.Hour))
+		_ = fakeClient.Create(ctx, pod)
+	}
+
+	// The first step restarts a pod, then every eviction is blocked
+	if _, err := r.Reconcile(ctx, req); err != nil {
+		t.Fatalf("Reconcile failed: %v", err)
+	}
+	blocking = true
+	for step := 0; step < trickleStallSteps; step++ {
+		value, ok := r.trickles.Load(req.String())
+		if !ok {
+			t.Fatalf("Expected the trickle to go on until step %d", step)
+		}
+		state := value.(trickleState)
+		state.next = time.Time{}
+		r.trickles.Store(req.String(), state)
+		if _, err := r.Reconcile(ctx, req); err != nil {
+			t.Fatalf("Reconcile failed: %v", err)
+		}
+	}
+
+	if _, trickling := r.trickles.Load(req.String()); trickling {
+		t.Error("Expected the stalled trickle to be abandoned")
+	}
+	ops := listOperations(t, fakeClient)
+	if len(ops) != 1 || ops[0].Status.Phase != autoapplyv1alpha1.RestartOperationPartiallyCompleted {
+		t.Errorf("Expected a PartiallyCompleted operation, got %+v", ops)
+	}
+	close(recorder.Events)
+	stalled := false
+	for event := range recorder.Events {
+		stalled = stalled || strings.HasPrefix(event, "Warning TrickleRestartStalled Gave up on trickle restart after restarting 1 pods")
+	}
+	if !stalled {
+		t.Error("Expected a TrickleRestartStalled Event")
+	}
+	_ = fakeClient.Get(ctx, req.NamespacedName, cm)
+	if _, ok := cm.Annotations[trickleVersionAnnotation]; ok {
+		t.Error("Expected the trickle annotations to be cleared")
+	}
+	if version, _ := persistedVersion(cm); version != configMapVersion(cm) {
+		t.Error("Expected the version to be persisted once the trickle was abandoned")
+	}
+}
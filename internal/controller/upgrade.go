@@ -0,0 +1,76 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	coordinationv1 "k8s.io/api/coordination/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// +kubebuilder:rbac:groups=coordination.k8s.io,resources=leases,verbs=get;list;watch
+
+// clusterUpgrade returns why a cluster upgrade looks to be in progress, or ""
+// if none does. Upgrades are signaled by the UpgradeConfigMap existing, the
+// UpgradeLease being held, or with DetectNodeUpgrades by nodes running
+// different kubelet versions while node pools are replaced.
+func (r *ConfigMapReconciler) clusterUpgrade(ctx context.Context) (string, error) {
+	if key := r.UpgradeConfigMap; key.Name != "" {
+		var configMap corev1.ConfigMap
+		err := r.Get(ctx, key, &configMap)
+		if err == nil {
+			return fmt.Sprintf("a cluster upgrade is in progress (ConfigMap %s exists)", key), nil
+		}
+		if !apierrors.IsNotFound(err) {
+			return "", err
+		}
+	}
+
+	if key := r.UpgradeLease; key.Name != "" {
+		var lease coordinationv1.Lease
+		err := r.Get(ctx, key, &lease)
+		if err == nil && leaseHeld(&lease, time.Now()) {
+			return fmt.Sprintf("a cluster upgrade is in progress (Lease %s is held by %s)", key, *lease.Spec.HolderIdentity), nil
+		}
+		if err != nil && !apierrors.IsNotFound(err) {
+			return "", err
+		}
+	}
+
+	if r.DetectNodeUpgrades {
+		var nodes corev1.NodeList
+		if err := r.List(ctx, &nodes); err != nil {
+			return "", err
+		}
+		versions := make(map[string]bool)
+		for _, node := range nodes.Items {
+			versions[node.Status.NodeInfo.KubeletVersion] = true
+		}
+		if len(versions) > 1 {
+			names := make([]string, 0, len(versions))
+			for version := range versions {
+				names = append(names, version)
+			}
+			sort.Strings(names)
+			return fmt.Sprintf("nodes are being upgraded (kubelet versions %s)", strings.Join(names, ", ")), nil
+		}
+	}
+
+	return "", nil
+}
+
+// leaseHeld checks if the lease has a holder that renewed it recently enough
+func leaseHeld(lease *coordinationv1.Lease, now time.Time) bool {
+	if lease.Spec.HolderIdentity == nil || *lease.Spec.HolderIdentity == "" {
+		return false
+	}
+	if lease.Spec.RenewTime == nil || lease.Spec.LeaseDurationSeconds == nil {
+		return true
+	}
+	expires := lease.Spec.RenewTime.Add(time.Duration(*lease.Spec.LeaseDurationSeconds) * time.Second)
+	return now.Before(expires)
+}
@@ -0,0 +1,93 @@
+package controller
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	coordinationv1 "k8s.io/api/coordination/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/ptr"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+func TestClusterUpgrade(t *testing.T) {
+	r, fakeClient := setupTestReconciler()
+	ctx := context.Background()
+	r.UpgradeConfigMap = types.NamespacedName{Namespace: "kube-system", Name: "upgrade-in-progress"}
+	r.UpgradeLease = types.NamespacedName{Namespace: "kube-system", Name: "cluster-upgrade"}
+	r.DetectNodeUpgrades = true
+
+	for _, node := range []struct{ name, version string }{{"node-a", "v1.30.2"}, {"node-b", "v1.30.2"}} {
+		_ = fakeClient.Create(ctx, &corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: node.name},
+			Status:     corev1.NodeStatus{NodeInfo: corev1.NodeSystemInfo{KubeletVersion: node.version}},
+		})
+	}
+	// An expired lease isn't held
+	lease := &coordinationv1.Lease{
+		ObjectMeta: metav1.ObjectMeta{Name: "cluster-upgrade", Namespace: "kube-system"},
+		Spec: coordinationv1.LeaseSpec{
+			HolderIdentity:       ptr.To("upgrader"),
+			LeaseDurationSeconds: ptr.To(int32(60)),
+			RenewTime:            &metav1.MicroTime{Time: time.Now().Add(-time.Hour)},
+		},
+	}
+	_ = fakeClient.Create(ctx, lease)
+
+	if reason, err := r.clusterUpgrade(ctx); err != nil || reason != "" {
+		t.Fatalf("Expected no upgrade, got %q, %v", reason, err)
+	}
+
+	lease.Spec.RenewTime = &metav1.MicroTime{Time: time.Now()}
+	_ = fakeClient.Update(ctx, lease)
+	if reason, _ := r.clusterUpgrade(ctx); !strings.Contains(reason, "Lease kube-system/cluster-upgrade is held by upgrader") {
+		t.Errorf("Expected the held lease to signal an upgrade, got %q", reason)
+	}
+	_ = fakeClient.Delete(ctx, lease)
+
+	_ = fakeClient.Create(ctx, &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "node-c"},
+		Status:     corev1.NodeStatus{NodeInfo: corev1.NodeSystemInfo{KubeletVersion: "v1.31.0"}},
+	})
+	if reason, _ := r.clusterUpgrade(ctx); reason != "nodes are being upgraded (kubelet versions v1.30.2, v1.31.0)" {
+		t.Errorf("Expected mixed kubelet versions to signal an upgrade, got %q", reason)
+	}
+
+	_ = fakeClient.Create(ctx, &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "upgrade-in-progress", Namespace: "kube-system"}})
+	if reason, _ := r.clusterUpgrade(ctx); !strings.Contains(reason, "ConfigMap kube-system/upgrade-in-progress exists") {
+		t.Errorf("Expected the ConfigMap to signal an upgrade, got %q", reason)
+	}
+}
+
+func TestReconcile_ResumesAfterUpgrade(t *testing.T) {
+	r, fakeClient := setupTestReconciler()
+	ctx := context.Background()
+	r.UpgradeConfigMap = types.NamespacedName{Namespace: "kube-system", Name: "upgrade-in-progress"}
+	recorder := r.Recorder.(*record.FakeRecorder)
+
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: "test-config", Namespace: "default"}}
+	r.configMapVersions.Store(req.String(), "old-version")
+	_ = fakeClient.Create(ctx, &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "test-config", Namespace: "default"}})
+	upgrade := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "upgrade-in-progress", Namespace: "kube-system"}}
+	_ = fakeClient.Create(ctx, upgrade)
+
+	if result, err := r.Reconcile(ctx, req); err != nil || result.RequeueAfter != nodeDisruptionRetryInterval {
+		t.Fatalf("Expected the restart paused, got %+v, %v", result, err)
+	}
+	if event := <-recorder.Events; !strings.HasPrefix(event, "Warning RestartPaused Restart paused while a cluster upgrade is in progress") {
+		t.Errorf("Unexpected event %q", event)
+	}
+
+	_ = fakeClient.Delete(ctx, upgrade)
+	if _, err := r.Reconcile(ctx, req); err != nil {
+		t.Fatalf("Reconcile failed: %v", err)
+	}
+	if event := <-recorder.Events; !strings.HasPrefix(event, "Normal RestartResumed") {
+		t.Errorf("Expected the restart resumed, got %q", event)
+	}
+}
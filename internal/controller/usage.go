@@ -0,0 +1,241 @@
+package controller
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// configMapUsage describes how a pod consumes a ConfigMap
+type configMapUsage struct {
+	// volume is set for full volume mounts, which kubelet refreshes in place
+	volume bool
+	// subPath is set for subPath mounts, which kubelet never refreshes
+	subPath bool
+	// env is set for env and envFrom, which are only read at container start
+	env bool
+	// required is set if any reference isn't marked optional. Pods only
+	// referencing the ConfigMap optionally treat it as a soft dependency.
+	required bool
+}
+
+// any checks if the pod uses the ConfigMap at all
+func (u configMapUsage) any() bool {
+	return u.volume || u.subPath || u.env
+}
+
+// restartRequired checks if the pod only sees changes after a restart
+func (u configMapUsage) restartRequired() bool {
+	return u.subPath || u.env
+}
+
+// addReference records a reference's optional flag
+func (u *configMapUsage) addReference(optional *bool) {
+	if optional == nil || !*optional {
+		u.required = true
+	}
+}
+
+// podConfigMapUsage reports how a pod consumes the given ConfigMap
+func podConfigMapUsage(pod *corev1.Pod, configMapName string) configMapUsage {
+	var usage configMapUsage
+
+	// Volumes sourcing the ConfigMap, directly or projected
+	volumes := make(map[string]bool)
+	for _, vol := range pod.Spec.Volumes {
+		if vol.ConfigMap != nil && vol.ConfigMap.Name == configMapName {
+			volumes[vol.Name] = true
+			usage.addReference(vol.ConfigMap.Optional)
+		}
+		if vol.Projected != nil {
+			for _, src := range vol.Projected.Sources {
+				if src.ConfigMap != nil && src.ConfigMap.Name == configMapName {
+					volumes[vol.Name] = true
+					usage.addReference(src.ConfigMap.Optional)
+				}
+			}
+		}
+	}
+
+	mounted := make(map[string]bool)
+	for _, container := range podContainers(pod) {
+		for _, mount := range container.VolumeMounts {
+			if !volumes[mount.Name] {
+				continue
+			}
+			mounted[mount.Name] = true
+			if mount.SubPath != "" || mount.SubPathExpr != "" {
+				usage.subPath = true
+			} else {
+				usage.volume = true
+			}
+		}
+
+		for _, envFrom := range container.EnvFrom {
+			if envFrom.ConfigMapRef != nil && envFrom.ConfigMapRef.Name == configMapName {
+				usage.env = true
+				usage.addReference(envFrom.ConfigMapRef.Optional)
+			}
+		}
+		for _, env := range container.Env {
+			if env.ValueFrom != nil && env.ValueFrom.ConfigMapKeyRef != nil &&
+				env.ValueFrom.ConfigMapKeyRef.Name == configMapName {
+				usage.env = true
+				usage.addReference(env.ValueFrom.ConfigMapKeyRef.Optional)
+			}
+		}
+	}
+
+	// A volume no container mounts still counts as using the ConfigMap
+	if len(mounted) < len(volumes) {
+		usage.volume = true
+	}
+
+	return usage
+}
+
+// podContainers returns the pod's init, regular and ephemeral containers.
+// Ephemeral containers, such as debugging sidecars, can read config too.
+func podContainers(pod *corev1.Pod) []corev1.Container {
+	containers := append(append([]corev1.Container{}, pod.Spec.InitContainers...), pod.Spec.Containers...)
+	for _, ephemeral := range pod.Spec.EphemeralContainers {
+		containers = append(containers, corev1.Container(ephemeral.EphemeralContainerCommon))
+	}
+	return containers
+}
+
+// podConfigMapKeys returns the keys of the ConfigMap the pod consumes. all
+// is set if any reference takes the whole ConfigMap, an envFrom or a volume
+// without items, in which case keys is incomplete.
+func podConfigMapKeys(pod *corev1.Pod, configMapName string) (keys map[string]bool, all bool) {
+	keys = make(map[string]bool)
+	addItems := func(items []corev1.KeyToPath) {
+		if len(items) == 0 {
+			all = true
+		}
+		for _, item := range items {
+			keys[item.Key] = true
+		}
+	}
+
+	for _, vol := range pod.Spec.Volumes {
+		if vol.ConfigMap != nil && vol.ConfigMap.Name == configMapName {
+			addItems(vol.ConfigMap.Items)
+		}
+		if vol.Projected != nil {
+			for _, src := range vol.Projected.Sources {
+				if src.ConfigMap != nil && src.ConfigMap.Name == configMapName {
+					addItems(src.ConfigMap.Items)
+				}
+			}
+		}
+	}
+
+	for _, container := range podContainers(pod) {
+		for _, envFrom := range container.EnvFrom {
+			if envFrom.ConfigMapRef != nil && envFrom.ConfigMapRef.Name == configMapName {
+				all = true
+			}
+		}
+		for _, env := range container.Env {
+			if env.ValueFrom != nil && env.ValueFrom.ConfigMapKeyRef != nil &&
+				env.ValueFrom.ConfigMapKeyRef.Name == configMapName {
+				keys[env.ValueFrom.ConfigMapKeyRef.Key] = true
+			}
+		}
+	}
+
+	return keys, all
+}
+
+// podConfigMapIndex indexes pods by the names of the ConfigMaps they reference
+const podConfigMapIndex = "autoapply.io/configmaps"
+
+// podConfigMapNames returns the names of all ConfigMaps a pod references
+func podConfigMapNames(pod *corev1.Pod) []string {
+	seen := make(map[string]bool)
+	var names []string
+	add := func(name string) {
+		if name != "" && !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+
+	for _, vol := range pod.Spec.Volumes {
+		if vol.ConfigMap != nil {
+			add(vol.ConfigMap.Name)
+		}
+		if vol.Projected != nil {
+			for _, src := range vol.Projected.Sources {
+				if src.ConfigMap != nil {
+					add(src.ConfigMap.Name)
+				}
+			}
+		}
+	}
+
+	for _, container := range podContainers(pod) {
+		for _, envFrom := range container.EnvFrom {
+			if envFrom.ConfigMapRef != nil {
+				add(envFrom.ConfigMapRef.Name)
+			}
+		}
+		for _, env := range container.Env {
+			if env.ValueFrom != nil && env.ValueFrom.ConfigMapKeyRef != nil {
+				add(env.ValueFrom.ConfigMapKeyRef.Name)
+			}
+		}
+	}
+
+	return names
+}
+
+// podSecretNames returns the names of all Secrets a pod references for
+// config, leaving out image pull secrets
+func podSecretNames(pod *corev1.Pod) []string {
+	seen := make(map[string]bool)
+	var names []string
+	add := func(name string) {
+		if name != "" && !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+
+	for _, vol := range pod.Spec.Volumes {
+		if vol.Secret != nil {
+			add(vol.Secret.SecretName)
+		}
+		if vol.Projected != nil {
+			for _, src := range vol.Projected.Sources {
+				if src.Secret != nil {
+					add(src.Secret.Name)
+				}
+			}
+		}
+	}
+
+	for _, container := range podContainers(pod) {
+		for _, envFrom := range container.EnvFrom {
+			if envFrom.SecretRef != nil {
+				add(envFrom.SecretRef.Name)
+			}
+		}
+		for _, env := range container.Env {
+			if env.ValueFrom != nil && env.ValueFrom.SecretKeyRef != nil {
+				add(env.ValueFrom.SecretKeyRef.Name)
+			}
+		}
+	}
+
+	return names
+}
+
+// indexPodConfigMaps is the field indexer for podConfigMapIndex
+func indexPodConfigMaps(obj client.Object) []string {
+	pod, ok := obj.(*corev1.Pod)
+	if !ok {
+		return nil
+	}
+	return podConfigMapNames(pod)
+}
@@ -0,0 +1,162 @@
+package controller
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+)
+
+// configVolumePod returns a pod mounting my-config as a volume, optionally via subPath
+func configVolumePod(name, subPath string) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{
+				Name:         "app",
+				VolumeMounts: []corev1.VolumeMount{{Name: "config", MountPath: "/etc/app", SubPath: subPath}},
+			}},
+			Volumes: []corev1.Volume{{
+				Name: "config",
+				VolumeSource: corev1.VolumeSource{
+					ConfigMap: &corev1.ConfigMapVolumeSource{
+						LocalObjectReference: corev1.LocalObjectReference{Name: "my-config"},
+					},
+				},
+			}},
+		},
+		Status: corev1.PodStatus{Phase: corev1.PodRunning},
+	}
+}
+
+func TestPodConfigMapUsage(t *testing.T) {
+	envPod := &corev1.Pod{Spec: corev1.PodSpec{Containers: []corev1.Container{{
+		Name: "app",
+		EnvFrom: []corev1.EnvFromSource{{
+			ConfigMapRef: &corev1.ConfigMapEnvSource{LocalObjectReference: corev1.LocalObjectReference{Name: "my-config"}},
+		}},
+	}}}}
+
+	optionalEnvPod := envPod.DeepCopy()
+	optionalEnvPod.Spec.Containers[0].EnvFrom[0].ConfigMapRef.Optional = ptr.To(true)
+
+	debugPod := &corev1.Pod{Spec: corev1.PodSpec{EphemeralContainers: []corev1.EphemeralContainer{{
+		EphemeralContainerCommon: corev1.EphemeralContainerCommon{
+			Name: "debugger",
+			Env: []corev1.EnvVar{{Name: "LEVEL", ValueFrom: &corev1.EnvVarSource{ConfigMapKeyRef: &corev1.ConfigMapKeySelector{
+				LocalObjectReference: corev1.LocalObjectReference{Name: "my-config"}, Key: "level",
+			}}}},
+		},
+	}}}}
+
+	tests := []struct {
+		name     string
+		pod      *corev1.Pod
+		expected configMapUsage
+	}{
+		{"full volume mount", configVolumePod("full", ""), configMapUsage{volume: true, required: true}},
+		{"ephemeral container env", debugPod, configMapUsage{env: true, required: true}},
+		{"subPath mount", configVolumePod("sub", "app.yaml"), configMapUsage{subPath: true, required: true}},
+		{"env", envPod, configMapUsage{env: true, required: true}},
+		{"optional env", optionalEnvPod, configMapUsage{env: true}},
+		{"unrelated", &corev1.Pod{}, configMapUsage{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := podConfigMapUsage(tt.pod, "my-config"); result != tt.expected {
+				t.Errorf("podConfigMapUsage() = %+v, expected %+v", result, tt.expected)
+			}
+		})
+	}
+
+	// Indexed too, so the pod is found when the ConfigMap changes
+	if names := podConfigMapNames(debugPod); len(names) != 1 || names[0] != "my-config" {
+		t.Errorf("Expected the ephemeral container's ConfigMap to be indexed, got %v", names)
+	}
+}
+
+func TestFindPodsUsingConfigMap_SkipRefreshableMounts(t *testing.T) {
+	r, fakeClient := setupTestReconciler()
+	ctx := context.Background()
+
+	_ = fakeClient.Create(ctx, configVolumePod("full", ""))
+	_ = fakeClient.Create(ctx, configVolumePod("sub", "app.yaml"))
+
+	cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "my-config", Namespace: "default"}}
+
+	if pods := r.findPodsUsingConfigMap(ctx, cm, operatorConfig{}); len(pods) != 2 {
+		t.Errorf("Expected both pods by default, got %v", podNames(pods))
+	}
+
+	pods := r.findPodsUsingConfigMap(ctx, cm, operatorConfig{skipRefreshableMounts: true})
+	if len(pods) != 1 || pods[0].Name != "sub" {
+		t.Errorf("Expected only the subPath pod, got %v", podNames(pods))
+	}
+}
+
+func TestFindPodsUsingConfigMap_SkipOptionalReferences(t *testing.T) {
+	r, fakeClient := setupTestReconciler()
+	ctx := context.Background()
+
+	optional := configVolumePod("optional", "")
+	optional.Spec.Volumes[0].ConfigMap.Optional = ptr.To(true)
+	_ = fakeClient.Create(ctx, optional)
+
+	// Any required reference makes the pod depend on the ConfigMap
+	mixed := configVolumePod("mixed", "")
+	mixed.Spec.Volumes[0].ConfigMap.Optional = ptr.To(true)
+	mixed.Spec.Containers[0].EnvFrom = []corev1.EnvFromSource{{
+		ConfigMapRef: &corev1.ConfigMapEnvSource{LocalObjectReference: corev1.LocalObjectReference{Name: "my-config"}},
+	}}
+	_ = fakeClient.Create(ctx, mixed)
+
+	cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "my-config", Namespace: "default"}}
+
+	if pods := r.findPodsUsingConfigMap(ctx, cm, operatorConfig{}); len(pods) != 2 {
+		t.Errorf("Expected both pods by default, got %v", podNames(pods))
+	}
+
+	pods := r.findPodsUsingConfigMap(ctx, cm, operatorConfig{skipOptionalReferences: true})
+	if len(pods) != 1 || pods[0].Name != "mixed" {
+		t.Errorf("Expected only the pod with a required reference, got %v", podNames(pods))
+	}
+}
+
+func TestPodConfigMapNames(t *testing.T) {
+	pod := configVolumePod("mixed", "")
+	pod.Spec.Volumes = append(pod.Spec.Volumes, corev1.Volume{
+		Name: "projected",
+		VolumeSource: corev1.VolumeSource{Projected: &corev1.ProjectedVolumeSource{
+			Sources: []corev1.VolumeProjection{{ConfigMap: &corev1.ConfigMapProjection{
+				LocalObjectReference: corev1.LocalObjectReference{Name: "projected-config"},
+			}}},
+		}},
+	})
+	pod.Spec.InitContainers = []corev1.Container{{
+		Name: "init",
+		EnvFrom: []corev1.EnvFromSource{{ConfigMapRef: &corev1.ConfigMapEnvSource{
+			LocalObjectReference: corev1.LocalObjectReference{Name: "init-config"},
+		}}},
+	}}
+	pod.Spec.Containers[0].Env = []corev1.EnvVar{{
+		Name: "KEY",
+		ValueFrom: &corev1.EnvVarSource{ConfigMapKeyRef: &corev1.ConfigMapKeySelector{
+			LocalObjectReference: corev1.LocalObjectReference{Name: "my-config"},
+			Key:                  "key",
+		}},
+	}}
+
+	names := podConfigMapNames(pod)
+	expected := []string{"my-config", "projected-config", "init-config"}
+	if len(names) != len(expected) {
+		t.Fatalf("podConfigMapNames() = %v, expected %v", names, expected)
+	}
+	for i := range expected {
+		if names[i] != expected[i] {
+			t.Errorf("podConfigMapNames() = %v, expected %v", names, expected)
+		}
+	}
+}
@@ -0,0 +1,244 @@
+package controller
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/yaml"
+
+	autoapplyv1alpha1 "github.com/manos/k8s-autoapply-operator/api/v1alpha1"
+)
+
+// ChangeDetector computes the version of a ConfigMap that change detection
+// compares: pods are only restarted when the version differs from the last
+// handled one. Versions other than the default data hash should start with
+// a "scheme:" prefix (the scheme may contain colons itself); when the scheme
+// of a ConfigMap's version changes, the new version is only tracked instead
+// of restarting pods.
+type ChangeDetector interface {
+	Version(configMap *corev1.ConfigMap) string
+}
+
+// ChangeDetectorFunc adapts a function to a ChangeDetector
+type ChangeDetectorFunc func(configMap *corev1.ConfigMap) string
+
+func (f ChangeDetectorFunc) Version(configMap *corev1.ConfigMap) string {
+	return f(configMap)
+}
+
+// changeDetectionSensitivity orders change detection modes when several
+// configs disagree; the more sensitive mode wins
+var changeDetectionSensitivity = map[autoapplyv1alpha1.ChangeDetectionMode]int{
+	autoapplyv1alpha1.ChangeDetectionKeys: 0,
+	autoapplyv1alpha1.ChangeDetectionFull: 1,
+}
+
+// mergeChangeDetection merges a config's change detection into c
+func (c *operatorConfig) mergeChangeDetection(detection *autoapplyv1alpha1.ChangeDetection) {
+	mode := detection.Mode
+	if mode == "" {
+		mode = autoapplyv1alpha1.ChangeDetectionFull
+	}
+	// Raw comparison is more sensitive, so semantic needs all configs to agree
+	if c.changeDetection == "" {
+		c.semanticChangeDetection = detection.Semantic
+	} else {
+		c.semanticChangeDetection = c.semanticChangeDetection && detection.Semantic
+	}
+	if c.changeDetection == "" || changeDetectionSensitivity[mode] > changeDetectionSensitivity[c.changeDetection] {
+		c.changeDetection = mode
+	}
+	if mode == autoapplyv1alpha1.ChangeDetectionKeys {
+		c.changeDetectionKeys = append(c.changeDetectionKeys, detection.Keys...)
+	}
+}
+
+// changeDetector returns how versions of a ConfigMap with cfg are computed
+func (r *ConfigMapReconciler) changeDetector(cfg operatorConfig) ChangeDetector {
+	if r.ChangeDetector != nil {
+		return r.ChangeDetector
+	}
+	var detector ChangeDetector = ChangeDetectorFunc(configMapVersion)
+	if cfg.changeDetection == autoapplyv1alpha1.ChangeDetectionKeys {
+		detector = keysDetector{keys: cfg.changeDetectionKeys}
+	}
+	if cfg.semanticChangeDetection {
+		detector = semanticDetector{next: detector}
+	}
+	return detector
+}
+
+// keysDetector only compares some keys of a ConfigMap
+type keysDetector struct {
+	keys []string
+}
+
+func (d keysDetector) Version(configMap *corev1.ConfigMap) string {
+	selected := &corev1.ConfigMap{Data: map[string]string{}, BinaryData: map[string][]byte{}}
+	for _, key := range d.keys {
+		if value, ok := configMap.Data[key]; ok {
+			selected.Data[key] = value
+		}
+		if value, ok := configMap.BinaryData[key]; ok {
+			selected.BinaryData[key] = value
+		}
+	}
+	return "keys:" + configMapVersion(selected)
+}
+
+// semanticDetector compares the parsed content of YAML and JSON values, so
+// re-rendering identical config with other formatting, comments or key order
+// is not a change
+type semanticDetector struct {
+	next ChangeDetector
+}
+
+func (d semanticDetector) Version(configMap *corev1.ConfigMap) string {
+	normalized := &corev1.ConfigMap{Data: make(map[string]string, len(configMap.Data)), BinaryData: configMap.BinaryData}
+	for key, value := range configMap.Data {
+		normalized.Data[key] = normalizeYAML(value)
+	}
+	return "semantic:" + d.next.Version(normalized)
+}
+
+// normalizeYAML returns the canonical JSON of every document in value.
+// Values that aren't YAML maps or lists are returned unchanged, so plain
+// strings like "01" and "1" stay different.
+func normalizeYAML(value string) string {
+	reader := utilyaml.NewYAMLReader(bufio.NewReader(strings.NewReader(value)))
+	var docs []string
+	for {
+		doc, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return value
+		}
+		raw, err := yaml.YAMLToJSON(doc)
+		if err != nil {
+			return value
+		}
+		// Comment-only documents and separators
+		if string(raw) == "null" {
+			continue
+		}
+		// Numbers are kept as written; float64 would round large ints
+		var parsed interface{}
+		decoder := json.NewDecoder(bytes.NewReader(raw))
+		decoder.UseNumber()
+		if err := decoder.Decode(&parsed); err != nil {
+			return value
+		}
+		switch parsed.(type) {
+		case map[string]interface{}, []interface{}:
+		default:
+			return value
+		}
+		// Marshalling sorts map keys
+		canonical, err := json.Marshal(parsed)
+		if err != nil {
+			return value
+		}
+		docs = append(docs, string(canonical))
+	}
+	if len(docs) == 0 {
+		return value
+	}
+	return strings.Join(docs, "\n")
+}
+
+// versionScheme returns the scheme prefix of a version, empty for the
+// default data hash
+func versionScheme(version string) string {
+	i := strings.LastIndex(version, ":")
+	if i < 0 {
+		return ""
+	}
+	return version[:i]
+}
+
+// configMapVersion returns a hash of the ConfigMap's data. Unlike the
+// ResourceVersion it only changes when the contents change, so metadata
+// updates (including our own version annotation) aren't treated as changes.
+func configMapVersion(configMap *corev1.ConfigMap) string {
+	h := sha256.New()
+
+	keys := make([]string, 0, len(configMap.Data))
+	for k := range configMap.Data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		h.Write([]byte("data\x00" + k + "\x00" + configMap.Data[k] + "\x00"))
+	}
+
+	keys = keys[:0]
+	for k := range configMap.BinaryData {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		h.Write([]byte("binaryData\x00" + k + "\x00"))
+		h.Write(configMap.BinaryData[k])
+		h.Write([]byte("\x00"))
+	}
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// persistedVersion returns the last handled version stored on the ConfigMap
+func persistedVersion(configMap *corev1.ConfigMap) (string, bool) {
+	version, ok := configMap.Annotations[lastSeenVersionAnnotation]
+	return version, ok
+}
+
+// configMapInUse checks if any pod references the ConfigMap
+func (r *ConfigMapReconciler) configMapInUse(ctx context.Context, configMap *corev1.ConfigMap) bool {
+	var pods corev1.PodList
+	if err := r.List(ctx, &pods, client.InNamespace(configMap.Namespace),
+		client.MatchingFields{podConfigMapIndex: configMap.Name}); err != nil {
+		log.FromContext(ctx).Error(err, "Failed to list pods")
+		return false
+	}
+	return len(pods.Items) > 0
+}
+
+// persistVersion stores the handled version on the ConfigMap so change
+// detection survives operator restarts. A non-zero appliedAt is stored as
+// the time the version began rolling out. The ConfigMap's keys become the
+// baseline that the next change's keys are compared against.
+func (r *ConfigMapReconciler) persistVersion(ctx context.Context, cfg operatorConfig, configMap *corev1.ConfigMap, version string, appliedAt time.Time) {
+	logger := log.FromContext(ctx)
+
+	r.recordHandledKeys(r.changeDetector(cfg), configMap)
+
+	if current, _ := persistedVersion(configMap); current == version {
+		return
+	}
+
+	patch := client.MergeFrom(configMap.DeepCopy())
+	if configMap.Annotations == nil {
+		configMap.Annotations = make(map[string]string)
+	}
+	configMap.Annotations[lastSeenVersionAnnotation] = version
+	if !appliedAt.IsZero() {
+		configMap.Annotations[appliedAtAnnotation] = appliedAt.UTC().Format(time.RFC3339)
+	}
+
+	if err := r.Patch(ctx, configMap, patch); err != nil {
+		logger.Error(err, "Failed to persist ConfigMap version", "configmap", client.ObjectKeyFromObject(configMap))
+	}
+}
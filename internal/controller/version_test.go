@@ -0,0 +1,342 @@
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	autoapplyv1alpha1 "github.com/manos/k8s-autoapply-operator/api/v1alpha1"
+)
+
+func TestConfigMapVersion(t *testing.T) {
+	base := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-config", ResourceVersion: "1"},
+		Data:       map[string]string{"a": "1", "b": "2"},
+	}
+	version := configMapVersion(base)
+
+	metadataOnly := base.DeepCopy()
+	metadataOnly.ResourceVersion = "2"
+	metadataOnly.Annotations = map[string]string{lastSeenVersionAnnotation: version}
+	if configMapVersion(metadataOnly) != version {
+		t.Error("Metadata changes should not change the version")
+	}
+
+	dataChanged := base.DeepCopy()
+	dataChanged.Data["b"] = "3"
+	if configMapVersion(dataChanged) == version {
+		t.Error("Data changes should change the version")
+	}
+
+	binaryAdded := base.DeepCopy()
+	binaryAdded.BinaryData = map[string][]byte{"c": []byte("bytes")}
+	if configMapVersion(binaryAdded) == version {
+		t.Error("BinaryData changes should change the version")
+	}
+
+	// Moving a value between keys must not collide
+	shifted := &corev1.ConfigMap{Data: map[string]string{"a": "12", "b": ""}}
+	if configMapVersion(shifted) == configMapVersion(&corev1.ConfigMap{Data: map[string]string{"a": "1", "b": "2"}}) {
+		t.Error("Different data should produce different versions")
+	}
+}
+
+func TestReconcile_PersistedVersionSurvivesOperatorRestart(t *testing.T) {
+	r, fakeClient := setupTestReconciler()
+	ctx := context.Background()
+
+	req := ctrl.Request{
+		NamespacedName: types.NamespacedName{Name: "test-config", Namespace: "default"},
+	}
+
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-config", Namespace: "default"},
+		Data:       map[string]string{"key": "value"},
+	}
+	_ = fakeClient.Create(ctx, cm)
+	_ = fakeClient.Create(ctx, &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-pod", Namespace: "default"},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{Name: "app", Image: "nginx"}},
+			Volumes: []corev1.Volume{{
+				Name: "config",
+				VolumeSource: corev1.VolumeSource{
+					ConfigMap: &corev1.ConfigMapVolumeSource{
+						LocalObjectReference: corev1.LocalObjectReference{Name: "test-config"},
+					},
+				},
+			}},
+		},
+		Status: corev1.PodStatus{Phase: corev1.PodRunning},
+	})
+
+	// First sighting persists the version
+	if _, err := r.Reconcile(ctx, req); err != nil {
+		t.Fatalf("Reconcile failed: %v", err)
+	}
+	_ = fakeClient.Get(ctx, req.NamespacedName, cm)
+	if _, ok := persistedVersion(cm); !ok {
+		t.Fatal("Expected version to be persisted on first sighting")
+	}
+
+	// Change the data while the "operator" is down
+	cm.Data["key"] = "new-value"
+	_ = fakeClient.Update(ctx, cm)
+
+	// A fresh reconciler has no in-memory state but still detects the change
+	restarted := &ConfigMapReconciler{Client: fakeClient, Scheme: r.Scheme, Recorder: r.Recorder}
+	if _, err := restarted.Reconcile(ctx, req); err != nil {
+		t.Fatalf("Reconcile failed: %v", err)
+	}
+
+	var pods corev1.PodList
+	_ = fakeClient.List(ctx, &pods, client.InNamespace("default"))
+	if len(pods.Items) != 0 {
+		t.Errorf("Expected pod to be restarted after operator restart, found %d pods", len(pods.Items))
+	}
+
+	_ = fakeClient.Get(ctx, req.NamespacedName, cm)
+	if version, _ := persistedVersion(cm); version != configMapVersion(cm) {
+		t.Error("Expected the handled version to be persisted")
+	}
+}
+
+func TestReconcile_ExcludedNamespaceNotAnnotated(t *testing.T) {
+	r, fakeClient := setupTestReconciler()
+	ctx := context.Background()
+
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-config", Namespace: "kube-system"},
+	}
+	_ = fakeClient.Create(ctx, cm)
+
+	req := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(cm)}
+	if _, err := r.Reconcile(ctx, req); err != nil {
+		t.Fatalf("Reconcile failed: %v", err)
+	}
+
+	_ = fakeClient.Get(ctx, req.NamespacedName, cm)
+	if _, ok := persistedVersion(cm); ok {
+		t.Error("ConfigMaps in excluded namespaces should not be annotated")
+	}
+}
+
+func TestReconcile_UnusedConfigMapNotAnnotated(t *testing.T) {
+	r, fakeClient := setupTestReconciler()
+	ctx := context.Background()
+
+	cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "test-config", Namespace: "default"}}
+	_ = fakeClient.Create(ctx, cm)
+
+	req := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(cm)}
+	if _, err := r.Reconcile(ctx, req); err != nil {
+		t.Fatalf("Reconcile failed: %v", err)
+	}
+
+	_ = fakeClient.Get(ctx, req.NamespacedName, cm)
+	if _, ok := persistedVersion(cm); ok {
+		t.Error("ConfigMaps no pod uses should not be annotated on first sighting")
+	}
+}
+
+func TestKeysDetector(t *testing.T) {
+	detector := keysDetector{keys: []string{"app.yaml", "cert"}}
+
+	cm := &corev1.ConfigMap{
+		Data:       map[string]string{"app.yaml": "a: 1", "generated-at": "monday"},
+		BinaryData: map[string][]byte{"cert": []byte("pem")},
+	}
+	version := detector.Version(cm)
+	if versionScheme(version) != "keys" {
+		t.Errorf("Expected a keys: version, got %q", version)
+	}
+
+	cm.Data["generated-at"] = "tuesday"
+	if detector.Version(cm) != version {
+		t.Error("Expected changes to other keys to be ignored")
+	}
+
+	cm.BinaryData["cert"] = []byte("rotated")
+	if detector.Version(cm) == version {
+		t.Error("Expected changes to listed keys to change the version")
+	}
+}
+
+func TestLoadConfig_ChangeDetection(t *testing.T) {
+	r, fakeClient := setupTestReconciler()
+	ctx := context.Background()
+
+	if cfg := r.loadConfig(ctx, nil); cfg.changeDetection != "" {
+		t.Fatalf("Expected default change detection, got %q", cfg.changeDetection)
+	}
+
+	keys := func(name string, keys ...string) *autoapplyv1alpha1.AutoApplyConfig {
+		return &autoapplyv1alpha1.AutoApplyConfig{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec: autoapplyv1alpha1.AutoApplyConfigSpec{ChangeDetection: &autoapplyv1alpha1.ChangeDetection{
+				Mode: autoapplyv1alpha1.ChangeDetectionKeys, Keys: keys,
+			}},
+		}
+	}
+	_ = fakeClient.Create(ctx, keys("a", "app.yaml"))
+	_ = fakeClient.Create(ctx, keys("b", "cert"))
+
+	cfg := r.loadConfig(ctx, nil)
+	if cfg.changeDetection != autoapplyv1alpha1.ChangeDetectionKeys || len(cfg.changeDetectionKeys) != 2 {
+		t.Errorf("Expected Keys mode with combined keys, got %q %v", cfg.changeDetection, cfg.changeDetectionKeys)
+	}
+
+	// Full is more sensitive and wins
+	_ = fakeClient.Create(ctx, &autoapplyv1alpha1.AutoApplyConfig{
+		ObjectMeta: metav1.ObjectMeta{Name: "full"},
+		Spec:       autoapplyv1alpha1.AutoApplyConfigSpec{ChangeDetection: &autoapplyv1alpha1.ChangeDetection{}},
+	})
+	if cfg := r.loadConfig(ctx, nil); cfg.changeDetection != autoapplyv1alpha1.ChangeDetectionFull {
+		t.Errorf("Expected Full mode to win, got %q", cfg.changeDetection)
+	}
+}
+
+func TestReconcile_ChangeDetectionKeys(t *testing.T) {
+	r, fakeClient := setupTestReconciler()
+	ctx := context.Background()
+
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: "test-config", Namespace: "default"}}
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-config", Namespace: "default"},
+		Data:       map[string]string{"app.yaml": "a: 1", "generated-at": "monday"},
+	}
+	pod := podUsingConfigMap("test-pod", "test-config", time.Now())
+	_ = fakeClient.Create(ctx, cm)
+	_ = fakeClient.Create(ctx, pod)
+
+	// Tracked with the default data hash
+	if _, err := r.Reconcile(ctx, req); err != nil {
+		t.Fatalf("Reconcile failed: %v", err)
+	}
+
+	_ = fakeClient.Create(ctx, &autoapplyv1alpha1.AutoApplyConfig{
+		ObjectMeta: metav1.ObjectMeta{Name: "keys"},
+		Spec: autoapplyv1alpha1.AutoApplyConfigSpec{ChangeDetection: &autoapplyv1alpha1.ChangeDetection{
+			Mode: autoapplyv1alpha1.ChangeDetectionKeys, Keys: []string{"app.yaml"},
+		}},
+	})
+
+	podCount := func() int {
+		var pods corev1.PodList
+		_ = fakeClient.List(ctx, &pods, client.InNamespace("default"))
+		return len(pods.Items)
+	}
+
+	// Switching modes only re-tracks the ConfigMap, even with a change
+	_ = fakeClient.Get(ctx, req.NamespacedName, cm)
+	cm.Data["generated-at"] = "tuesday"
+	_ = fakeClient.Update(ctx, cm)
+	if _, err := r.Reconcile(ctx, req); err != nil {
+		t.Fatalf("Reconcile failed: %v", err)
+	}
+	_ = fakeClient.Get(ctx, req.NamespacedName, cm)
+	if version, _ := persistedVersion(cm); versionScheme(version) != "keys" {
+		t.Errorf("Expected the keys version to be persisted, got %q", version)
+	}
+	if podCount() != 1 {
+		t.Fatal("Expected no restart when change detection switched")
+	}
+
+	// Other keys are ignored
+	cm.Data["generated-at"] = "wednesday"
+	_ = fakeClient.Update(ctx, cm)
+	if _, err := r.Reconcile(ctx, req); err != nil {
+		t.Fatalf("Reconcile failed: %v", err)
+	}
+	if podCount() != 1 {
+		t.Fatal("Expected no restart for a change of an ignored key")
+	}
+
+	cm.Data["app.yaml"] = "a: 2"
+	_ = fakeClient.Update(ctx, cm)
+	if _, err := r.Reconcile(ctx, req); err != nil {
+		t.Fatalf("Reconcile failed: %v", err)
+	}
+	if podCount() != 0 {
+		t.Error("Expected a restart for a change of a compared key")
+	}
+}
+
+func TestSemanticDetector(t *testing.T) {
+	detector := semanticDetector{next: ChangeDetectorFunc(configMapVersion)}
+	version := func(value string) string {
+		return detector.Version(&corev1.ConfigMap{Data: map[string]string{"app.yaml": value}})
+	}
+
+	base := version("server:\n  port: 8080\n  host: example.com\nfeatures: [a, b]\n")
+	if versionScheme(base) != "semantic" {
+		t.Errorf("Expected a semantic: version, got %q", base)
+	}
+
+	same := []string{
+		"# rendered by helm\nfeatures:\n  - a\n  - b\nserver: {host: example.com, port: 8080}\n",
+		`{"features": ["a", "b"], "server": {"port": 8080, "host": "example.com"}}`,
+		"---\nserver:\n    host: example.com\n    port: 8080\nfeatures: [a, b]\n",
+	}
+	for _, value := range same {
+		if version(value) != base {
+			t.Errorf("Expected %q to be semantically unchanged", value)
+		}
+	}
+
+	changed := []string{
+		"server:\n  port: 8081\n  host: example.com\nfeatures: [a, b]\n",
+		"server:\n  port: 8080\n  host: example.com\nfeatures: [b, a]\n",
+		"server:\n  port: 8080\n  host: example.com\nfeatures: [a, b]\n---\nextra: true\n",
+	}
+	if version("id: 12345678901234567890\n") == version("id: 12345678901234567891\n") {
+		t.Error("Expected large ints to be compared exactly")
+	}
+	for _, value := range changed {
+		if version(value) == base {
+			t.Errorf("Expected %q to be a change", value)
+		}
+	}
+
+	// Scalars and text that isn't YAML are compared as they are
+	if version("01") == version("1") {
+		t.Error("Expected scalar values to be compared raw")
+	}
+	if version("key: [unclosed") == version("key: [unclosed ") {
+		t.Error("Expected invalid YAML to be compared raw")
+	}
+}
+
+func TestLoadConfig_SemanticChangeDetection(t *testing.T) {
+	r, fakeClient := setupTestReconciler()
+	ctx := context.Background()
+
+	semantic := func(name string, semantic bool) *autoapplyv1alpha1.AutoApplyConfig {
+		return &autoapplyv1alpha1.AutoApplyConfig{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec: autoapplyv1alpha1.AutoApplyConfigSpec{ChangeDetection: &autoapplyv1alpha1.ChangeDetection{
+				Semantic: semantic,
+			}},
+		}
+	}
+	_ = fakeClient.Create(ctx, semantic("a", true))
+	cfg := r.loadConfig(ctx, nil)
+	if !cfg.semanticChangeDetection {
+		t.Fatal("Expected semantic change detection")
+	}
+	if versionScheme(r.changeDetector(cfg).Version(&corev1.ConfigMap{})) != "semantic" {
+		t.Error("Expected the semantic detector")
+	}
+
+	// Raw comparison is more sensitive and wins
+	_ = fakeClient.Create(ctx, semantic("b", false))
+	if cfg := r.loadConfig(ctx, nil); cfg.semanticChangeDetection {
+		t.Error("Expected raw comparison when configs disagree")
+	}
+}
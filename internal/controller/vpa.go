@@ -0,0 +1,114 @@
+package controller
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// vpaListGVK is the VerticalPodAutoscaler list kind. VPAs are read as
+// unstructured objects so the operator doesn't depend on the VPA API module.
+var vpaListGVK = schema.GroupVersionKind{
+	Group:   "autoscaling.k8s.io",
+	Version: "v1",
+	Kind:    "VerticalPodAutoscalerList",
+}
+
+// +kubebuilder:rbac:groups=autoscaling.k8s.io,resources=verticalpodautoscalers,verbs=get;list;watch
+// +kubebuilder:rbac:groups=apps,resources=replicasets,verbs=get;list;watch
+
+// splitPodsPendingVPAEviction separates pods that VPA is about to evict from the rest
+// If VPA isn't installed all pods are returned as rest
+func (r *ConfigMapReconciler) splitPodsPendingVPAEviction(ctx context.Context, namespace string, pods []corev1.Pod) (pending, rest []corev1.Pod) {
+	logger := log.FromContext(ctx)
+
+	vpas := &unstructured.UnstructuredList{}
+	vpas.SetGroupVersionKind(vpaListGVK)
+	if err := r.List(ctx, vpas, client.InNamespace(namespace)); err != nil {
+		if !meta.IsNoMatchError(err) && !apierrors.IsNotFound(err) {
+			logger.Error(err, "Failed to list VerticalPodAutoscalers")
+		}
+		return nil, pods
+	}
+	if len(vpas.Items) == 0 {
+		return nil, pods
+	}
+
+	for _, pod := range pods {
+		workload, err := r.resolveWorkload(ctx, &pod)
+		if err != nil || workload == nil {
+			rest = append(rest, pod)
+			continue
+		}
+
+		evicting := false
+		for i := range vpas.Items {
+			if vpaTargets(&vpas.Items[i], workload) && vpaWouldEvict(&vpas.Items[i], &pod) {
+				evicting = true
+				break
+			}
+		}
+
+		if evicting {
+			logger.V(1).Info("Pod pending VPA eviction", "pod", pod.Name)
+			pending = append(pending, pod)
+		} else {
+			rest = append(rest, pod)
+		}
+	}
+
+	return pending, rest
+}
+
+// vpaTargets checks if the VPA evicts pods and targets the given workload
+func vpaTargets(vpa *unstructured.Unstructured, workload *workloadRef) bool {
+	mode, found, _ := unstructured.NestedString(vpa.Object, "spec", "updatePolicy", "updateMode")
+	// Auto is the default update mode; Off/Initial never evict and in-place
+	// resizes don't restart containers
+	if found && mode != "Auto" && mode != "Recreate" {
+		return false
+	}
+
+	kind, _, _ := unstructured.NestedString(vpa.Object, "spec", "targetRef", "kind")
+	name, _, _ := unstructured.NestedString(vpa.Object, "spec", "targetRef", "name")
+	return kind == workload.Kind && name == workload.Name
+}
+
+// vpaWouldEvict checks if any container's requests fall outside the VPA
+// recommendation bounds, which is when the VPA updater evicts a pod
+func vpaWouldEvict(vpa *unstructured.Unstructured, pod *corev1.Pod) bool {
+	recommendations, _, _ := unstructured.NestedSlice(vpa.Object, "status", "recommendation", "containerRecommendations")
+
+	for _, item := range recommendations {
+		rec, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		containerName, _, _ := unstructured.NestedString(rec, "containerName")
+		lowerBound, _, _ := unstructured.NestedStringMap(rec, "lowerBound")
+		upperBound, _, _ := unstructured.NestedStringMap(rec, "upperBound")
+
+		for _, container := range pod.Spec.Containers {
+			if container.Name != containerName {
+				continue
+			}
+			for name, request := range container.Resources.Requests {
+				if lower, err := resource.ParseQuantity(lowerBound[string(name)]); err == nil && request.Cmp(lower) < 0 {
+					return true
+				}
+				if upper, err := resource.ParseQuantity(upperBound[string(name)]); err == nil && request.Cmp(upper) > 0 {
+					return true
+				}
+			}
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,159 @@
+package controller
+
+import (
+	"context"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func newTestVPA(name, targetKind, targetName, mode string) *unstructured.Unstructured {
+	vpa := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "autoscaling.k8s.io/v1",
+		"kind":       "VerticalPodAutoscaler",
+		"metadata": map[string]interface{}{
+			"name":      name,
+			"namespace": "default",
+		},
+		"spec": map[string]interface{}{
+			"targetRef": map[string]interface{}{
+				"apiVersion": "apps/v1",
+				"kind":       targetKind,
+				"name":       targetName,
+			},
+		},
+		"status": map[string]interface{}{
+			"recommendation": map[string]interface{}{
+				"containerRecommendations": []interface{}{
+					map[string]interface{}{
+						"containerName": "app",
+						"lowerBound":    map[string]interface{}{"cpu": "200m", "memory": "128Mi"},
+						"target":        map[string]interface{}{"cpu": "250m", "memory": "256Mi"},
+						"upperBound":    map[string]interface{}{"cpu": "500m", "memory": "512Mi"},
+					},
+				},
+			},
+		},
+	}}
+	if mode != "" {
+		_ = unstructured.SetNestedField(vpa.Object, mode, "spec", "updatePolicy", "updateMode")
+	}
+	return vpa
+}
+
+func podWithCPURequest(cpu string) *corev1.Pod {
+	return &corev1.Pod{
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{
+				Name: "app",
+				Resources: corev1.ResourceRequirements{
+					Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse(cpu)},
+				},
+			}},
+		},
+	}
+}
+
+func TestVPAWouldEvict(t *testing.T) {
+	vpa := newTestVPA("vpa", "Deployment", "web", "")
+
+	tests := []struct {
+		name     string
+		cpu      string
+		expected bool
+	}{
+		{"within bounds", "300m", false},
+		{"below lower bound", "100m", true},
+		{"above upper bound", "1", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := vpaWouldEvict(vpa, podWithCPURequest(tt.cpu))
+			if result != tt.expected {
+				t.Errorf("vpaWouldEvict() = %v, expected %v", result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestVPATargets(t *testing.T) {
+	workload := &workloadRef{Kind: "Deployment", Name: "web"}
+
+	tests := []struct {
+		name     string
+		vpa      *unstructured.Unstructured
+		expected bool
+	}{
+		{"default mode", newTestVPA("vpa", "Deployment", "web", ""), true},
+		{"auto mode", newTestVPA("vpa", "Deployment", "web", "Auto"), true},
+		{"recreate mode", newTestVPA("vpa", "Deployment", "web", "Recreate"), true},
+		{"off mode", newTestVPA("vpa", "Deployment", "web", "Off"), false},
+		{"initial mode", newTestVPA("vpa", "Deployment", "web", "Initial"), false},
+		{"other workload", newTestVPA("vpa", "Deployment", "api", ""), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := vpaTargets(tt.vpa, workload)
+			if result != tt.expected {
+				t.Errorf("vpaTargets() = %v, expected %v", result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestSplitPodsPendingVPAEviction(t *testing.T) {
+	r, fakeClient := setupTestReconciler()
+	ctx := context.Background()
+	trueVal := true
+
+	rs := &appsv1.ReplicaSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "web-abc",
+			Namespace: "default",
+			OwnerReferences: []metav1.OwnerReference{
+				{APIVersion: "apps/v1", Kind: "Deployment", Name: "web", UID: "web-uid", Controller: &trueVal},
+			},
+		},
+	}
+	_ = fakeClient.Create(ctx, rs)
+	_ = fakeClient.Create(ctx, newTestVPA("web-vpa", "Deployment", "web", "Auto"))
+
+	ownedBy := []metav1.OwnerReference{
+		{APIVersion: "apps/v1", Kind: "ReplicaSet", Name: "web-abc", UID: "rs-uid", Controller: &trueVal},
+	}
+
+	oversized := *podWithCPURequest("2")
+	oversized.ObjectMeta = metav1.ObjectMeta{Name: "web-1", Namespace: "default", OwnerReferences: ownedBy}
+	rightSized := *podWithCPURequest("300m")
+	rightSized.ObjectMeta = metav1.ObjectMeta{Name: "web-2", Namespace: "default", OwnerReferences: ownedBy}
+	standalone := *podWithCPURequest("2")
+	standalone.ObjectMeta = metav1.ObjectMeta{Name: "standalone", Namespace: "default"}
+
+	pending, rest := r.splitPodsPendingVPAEviction(ctx, "default", []corev1.Pod{oversized, rightSized, standalone})
+
+	if len(pending) != 1 || pending[0].Name != "web-1" {
+		t.Errorf("Expected only web-1 pending VPA eviction, got %v", podNames(pending))
+	}
+	if len(rest) != 2 {
+		t.Errorf("Expected 2 pods left to restart, got %v", podNames(rest))
+	}
+}
+
+func TestSplitPodsPendingVPAEviction_NoVPAs(t *testing.T) {
+	r, _ := setupTestReconciler()
+	ctx := context.Background()
+
+	pod := *podWithCPURequest("2")
+	pod.ObjectMeta = metav1.ObjectMeta{Name: "web-1", Namespace: "default"}
+
+	pending, rest := r.splitPodsPendingVPAEviction(ctx, "default", []corev1.Pod{pod})
+	if len(pending) != 0 || len(rest) != 1 {
+		t.Errorf("Expected all pods to be restarted without VPAs, got pending=%d rest=%d", len(pending), len(rest))
+	}
+}
@@ -0,0 +1,108 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// restartWaveAnnotation on a pod or workload orders restarts: every owner in
+// a lower wave is restarted and healthy before higher waves are touched
+const restartWaveAnnotation = "autoapply.io/restart-wave"
+
+// restartWave is the owners restarted together before the next wave starts
+type restartWave struct {
+	number int
+	// priority is set when waves are split by the owners' pod priority
+	priority *int32
+	owners   map[types.UID][]corev1.Pod
+}
+
+// String names the wave in logs and errors
+func (w restartWave) String() string {
+	if w.priority != nil {
+		return fmt.Sprintf("%d (priority %d)", w.number, *w.priority)
+	}
+	return strconv.Itoa(w.number)
+}
+
+// pods returns every pod of the wave
+func (w restartWave) pods() []corev1.Pod {
+	var pods []corev1.Pod
+	for _, ownerPods := range w.owners {
+		pods = append(pods, ownerPods...)
+	}
+	return pods
+}
+
+// restartWaves groups the pods' owners by their restart-wave annotation, in
+// ascending order. Owners without a valid one are in wave 0.
+func (r *ConfigMapReconciler) restartWaves(ctx context.Context, pods []corev1.Pod) []restartWave {
+	logger := log.FromContext(ctx)
+
+	byNumber := make(map[int]map[types.UID][]corev1.Pod)
+	annotationCache := make(workloadAnnotationCache)
+	for ownerUID, ownerPods := range podsByOwner(pods) {
+		number := 0
+		annotations := r.resolvePodAnnotations(ctx, &ownerPods[0], annotationCache)
+		if value, ok := annotations[restartWaveAnnotation]; ok {
+			parsed, err := strconv.Atoi(value)
+			if err != nil {
+				logger.Info("Ignoring invalid restart wave", "pod", ownerPods[0].Name, "value", value)
+			} else {
+				number = parsed
+			}
+		}
+
+		if byNumber[number] == nil {
+			byNumber[number] = make(map[types.UID][]corev1.Pod)
+		}
+		byNumber[number][ownerUID] = ownerPods
+	}
+
+	waves := make([]restartWave, 0, len(byNumber))
+	for number, owners := range byNumber {
+		waves = append(waves, restartWave{number: number, owners: owners})
+	}
+	sort.Slice(waves, func(i, j int) bool { return waves[i].number < waves[j].number })
+	return waves
+}
+
+// podPriority returns the pod's resolved PriorityClass value, 0 without one
+func podPriority(pod *corev1.Pod) int32 {
+	if pod.Spec.Priority == nil {
+		return 0
+	}
+	return *pod.Spec.Priority
+}
+
+// splitWavesByPriority splits each wave by its owners' pod priority, lowest
+// first, so less important workloads prove the change before critical ones
+func splitWavesByPriority(waves []restartWave) []restartWave {
+	var split []restartWave
+	for _, wave := range waves {
+		byPriority := make(map[int32]map[types.UID][]corev1.Pod)
+		for ownerUID, ownerPods := range wave.owners {
+			priority := podPriority(&ownerPods[0])
+			if byPriority[priority] == nil {
+				byPriority[priority] = make(map[types.UID][]corev1.Pod)
+			}
+			byPriority[priority][ownerUID] = ownerPods
+		}
+
+		priorities := make([]int32, 0, len(byPriority))
+		for priority := range byPriority {
+			priorities = append(priorities, priority)
+		}
+		sort.Slice(priorities, func(i, j int) bool { return priorities[i] < priorities[j] })
+		for _, priority := range priorities {
+			split = append(split, restartWave{number: wave.number, priority: &priority, owners: byPriority[priority]})
+		}
+	}
+	return split
+}
@@ -0,0 +1,154 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// wavePod returns a running pod controlled by the named StatefulSet
+func wavePod(name, owner string, annotations map[string]string) corev1.Pod {
+	trueVal := true
+	return corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Namespace:   "default",
+			Annotations: annotations,
+			OwnerReferences: []metav1.OwnerReference{
+				{APIVersion: "apps/v1", Kind: "StatefulSet", Name: owner, UID: types.UID(owner + "-uid"), Controller: &trueVal},
+			},
+		},
+		Status: corev1.PodStatus{Phase: corev1.PodRunning},
+	}
+}
+
+func TestRestartWaves(t *testing.T) {
+	r, fakeClient := setupTestReconciler()
+	ctx := context.Background()
+
+	// The wave may also be set on the workload
+	_ = fakeClient.Create(ctx, &appsv1.StatefulSet{ObjectMeta: metav1.ObjectMeta{
+		Name: "frontend", Namespace: "default", Annotations: map[string]string{restartWaveAnnotation: "2"},
+	}})
+
+	waves := r.restartWaves(ctx, []corev1.Pod{
+		wavePod("frontend-0", "frontend", nil),
+		wavePod("backend-0", "backend", map[string]string{restartWaveAnnotation: "1"}),
+		wavePod("backend-1", "backend", map[string]string{restartWaveAnnotation: "1"}),
+		wavePod("cache-0", "cache", nil),
+		wavePod("odd-0", "odd", map[string]string{restartWaveAnnotation: "first"}),
+	})
+
+	expected := []struct {
+		number int
+		pods   int
+	}{{0, 2}, {1, 2}, {2, 1}}
+	if len(waves) != len(expected) {
+		t.Fatalf("Expected %d waves, got %d", len(expected), len(waves))
+	}
+	for i, wave := range waves {
+		if wave.number != expected[i].number || len(wave.pods()) != expected[i].pods {
+			t.Errorf("Wave %d: expected number %d with %d pods, got %d with %d",
+				i, expected[i].number, expected[i].pods, wave.number, len(wave.pods()))
+		}
+	}
+}
+
+// failingHook fails every batch containing a pod with the given prefix
+type failingHook struct {
+	NoopRestartHook
+	prefix string
+}
+
+func (h *failingHook) BeforeBatch(_ context.Context, _ *corev1.ConfigMap, pods []corev1.Pod) error {
+	for _, pod := range pods {
+		if strings.HasPrefix(pod.Name, h.prefix) {
+			return errors.New("refused")
+		}
+	}
+	return nil
+}
+
+func TestRestart_FailedWaveStopsLaterWaves(t *testing.T) {
+	r, fakeClient := setupTestReconciler()
+	r.Hooks = []RestartHook{&failingHook{prefix: "backend"}}
+	ctx := context.Background()
+
+	cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "test-config", Namespace: "default"}}
+	pods := []corev1.Pod{
+		wavePod("backend-0", "backend", map[string]string{restartWaveAnnotation: "1"}),
+		wavePod("frontend-0", "frontend", map[string]string{restartWaveAnnotation: "2"}),
+	}
+	for i := range pods {
+		_ = fakeClient.Create(ctx, &pods[i])
+	}
+
+	err := runRestart(ctx, r, r.loadConfig(ctx, nil), cm, "v2", pods)
+	if err == nil {
+		t.Fatal("Expected the failed wave to fail the restart")
+	}
+
+	owners := make(map[string]bool)
+	for _, ownerErr := range ownerRestartErrors(err) {
+		owners[ownerErr.owner()] = true
+	}
+	if !owners["StatefulSet/backend"] || !owners["StatefulSet/frontend"] {
+		t.Errorf("Expected failures for backend and the skipped frontend, got %v", err)
+	}
+
+	var remaining corev1.PodList
+	_ = fakeClient.List(ctx, &remaining, client.InNamespace("default"))
+	if len(remaining.Items) != 2 {
+		t.Errorf("Expected no pod to be restarted, got %v", podNames(remaining.Items))
+	}
+}
+
+func TestSplitWavesByPriority(t *testing.T) {
+	r, _ := setupTestReconciler()
+	ctx := context.Background()
+
+	withPriority := func(pod corev1.Pod, priority int32) corev1.Pod {
+		pod.Spec.Priority = &priority
+		return pod
+	}
+	waves := splitWavesByPriority(r.restartWaves(ctx, []corev1.Pod{
+		withPriority(wavePod("dns-0", "dns", nil), 2000000000),
+		wavePod("batch-0", "batch", nil),
+		withPriority(wavePod("api-0", "api", nil), 1000),
+		withPriority(wavePod("web-0", "web", map[string]string{restartWaveAnnotation: "1"}), 1000),
+	}))
+
+	expected := []string{"0 (priority 0)", "0 (priority 1000)", "0 (priority 2000000000)", "1 (priority 1000)"}
+	if len(waves) != len(expected) {
+		t.Fatalf("Expected %d waves, got %v", len(expected), waves)
+	}
+	for i, wave := range waves {
+		if wave.String() != expected[i] || len(wave.owners) != 1 {
+			t.Errorf("Wave %d: expected %s with one owner, got %s with %d", i, expected[i], wave, len(wave.owners))
+		}
+	}
+}
+
+func TestFindPodsUsingConfigMap_MaxRestartPriority(t *testing.T) {
+	r, fakeClient := setupTestReconciler()
+	ctx := context.Background()
+
+	critical := podUsingConfigMap("critical", "my-config", metav1.Now().Time)
+	critical.Spec.Priority = ptr.To(int32(2000000000))
+	_ = fakeClient.Create(ctx, critical)
+	_ = fakeClient.Create(ctx, podUsingConfigMap("regular", "my-config", metav1.Now().Time))
+
+	cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "my-config", Namespace: "default"}}
+	pods := r.findPodsUsingConfigMap(ctx, cm, operatorConfig{maxRestartPriority: ptr.To(int32(1000000000))})
+	if len(pods) != 1 || pods[0].Name != "regular" {
+		t.Errorf("Expected only the regular pod, got %v", podNames(pods))
+	}
+}
@@ -0,0 +1,156 @@
+package controller
+
+import (
+	"context"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// restartedOwner groups the deleted pods of one controller for health checks
+type restartedOwner struct {
+	uid  types.UID
+	pods []corev1.Pod
+	// workload manages the pods, nil for bare pods or if it can't be resolved
+	workload *workloadRef
+}
+
+// restartedOwners groups deleted pods by controller and resolves each
+// controller's workload once per check
+func (r *ConfigMapReconciler) restartedOwners(ctx context.Context, deletedPods []corev1.Pod) []*restartedOwner {
+	byUID := make(map[types.UID]*restartedOwner)
+	var owners []*restartedOwner
+	for _, pod := range deletedPods {
+		uid := controllerUID(&pod)
+		owner, ok := byUID[uid]
+		if !ok {
+			owner = &restartedOwner{uid: uid}
+			if uid != "" {
+				owner.workload, _ = r.resolveWorkload(ctx, &pod)
+			}
+			byUID[uid] = owner
+			owners = append(owners, owner)
+		}
+		owner.pods = append(owner.pods, pod)
+	}
+	return owners
+}
+
+// ownerHealthy checks if an owner's restarted pods were replaced. Workloads
+// with a rollout status must have every replica updated and available, and
+// the deleted pods must be gone so the status can't predate their deletion.
+// Other controllers need one of their pods available, found by scanning the
+// namespace, and bare pods are checked one by one. Restarted pods stuck
+// terminating are force-deleted first, since a StatefulSet can't roll out
+// while one of its pods is.
+func (r *ConfigMapReconciler) ownerHealthy(ctx context.Context, owner *restartedOwner, minReady time.Duration) (bool, error) {
+	r.forceDeleteStuckPods(ctx, owner.pods)
+
+	if owner.uid == "" {
+		for i := range owner.pods {
+			if healthy, err := r.checkOwnerPodsHealthy(ctx, &owner.pods[i], minReady); !healthy || err != nil {
+				return false, err
+			}
+		}
+		return true, nil
+	}
+
+	if owner.workload != nil {
+		rolledOut, supported, err := r.workloadRolledOut(ctx, owner.pods[0].Namespace, owner.workload)
+		if err != nil && !apierrors.IsNotFound(err) {
+			return false, err
+		}
+		if supported && err == nil {
+			if !rolledOut {
+				return false, nil
+			}
+			return r.podsGone(ctx, owner.pods)
+		}
+	}
+
+	return r.checkOwnerPodsHealthy(ctx, &owner.pods[0], minReady)
+}
+
+// workloadRolledOut checks a workload's status for every replica being
+// updated and available, observed for its latest spec. supported is false for
+// kinds without such a status.
+func (r *ConfigMapReconciler) workloadRolledOut(ctx context.Context, namespace string, workload *workloadRef) (rolledOut, supported bool, err error) {
+	key := client.ObjectKey{Namespace: namespace, Name: workload.Name}
+	switch workload.Kind {
+	case "Deployment":
+		var deployment appsv1.Deployment
+		if err := r.Get(ctx, key, &deployment); err != nil {
+			return false, true, err
+		}
+		replicas := replicasOrDefault(deployment.Spec.Replicas)
+		status := deployment.Status
+		return observed(&deployment.ObjectMeta, status.ObservedGeneration) &&
+			status.UpdatedReplicas == replicas && status.AvailableReplicas >= replicas && status.Replicas == replicas, true, nil
+	case "ReplicaSet":
+		var replicaSet appsv1.ReplicaSet
+		if err := r.Get(ctx, key, &replicaSet); err != nil {
+			return false, true, err
+		}
+		replicas := replicasOrDefault(replicaSet.Spec.Replicas)
+		status := replicaSet.Status
+		return observed(&replicaSet.ObjectMeta, status.ObservedGeneration) &&
+			status.AvailableReplicas >= replicas && status.Replicas == replicas, true, nil
+	case "StatefulSet":
+		var statefulSet appsv1.StatefulSet
+		if err := r.Get(ctx, key, &statefulSet); err != nil {
+			return false, true, err
+		}
+		replicas := replicasOrDefault(statefulSet.Spec.Replicas)
+		status := statefulSet.Status
+		return observed(&statefulSet.ObjectMeta, status.ObservedGeneration) &&
+			status.ReadyReplicas == replicas && status.AvailableReplicas >= replicas &&
+			(status.UpdateRevision == "" || status.CurrentRevision == status.UpdateRevision), true, nil
+	case "DaemonSet":
+		var daemonSet appsv1.DaemonSet
+		if err := r.Get(ctx, key, &daemonSet); err != nil {
+			return false, true, err
+		}
+		status := daemonSet.Status
+		return observed(&daemonSet.ObjectMeta, status.ObservedGeneration) &&
+			status.UpdatedNumberScheduled == status.DesiredNumberScheduled &&
+			status.NumberAvailable == status.DesiredNumberScheduled, true, nil
+	}
+	return false, false, nil
+}
+
+// observed checks if a workload's controller has seen its latest spec
+func observed(meta *metav1.ObjectMeta, observedGeneration int64) bool {
+	return observedGeneration >= meta.Generation
+}
+
+// replicasOrDefault returns spec.replicas, which defaults to 1
+func replicasOrDefault(replicas *int32) int32 {
+	if replicas == nil {
+		return 1
+	}
+	return *replicas
+}
+
+// podsGone checks that none of the pods exist anymore. A pod of the same
+// name with a new UID, as StatefulSets create, is a replacement.
+func (r *ConfigMapReconciler) podsGone(ctx context.Context, pods []corev1.Pod) (bool, error) {
+	for _, pod := range pods {
+		var current corev1.Pod
+		err := r.Get(ctx, client.ObjectKeyFromObject(&pod), &current)
+		if apierrors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return false, err
+		}
+		if current.UID == pod.UID {
+			return false, nil
+		}
+	}
+	return true, nil
+}
@@ -0,0 +1,68 @@
+package controller
+
+import (
+	"context"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+)
+
+func TestOwnerHealthy_DeploymentStatus(t *testing.T) {
+	r, fakeClient := setupTestReconciler()
+	ctx := context.Background()
+
+	deploy := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default", UID: "web-uid", Generation: 2},
+		Spec:       appsv1.DeploymentSpec{Replicas: ptr.To(int32(2))},
+		Status:     appsv1.DeploymentStatus{ObservedGeneration: 2, Replicas: 2, UpdatedReplicas: 2, AvailableReplicas: 1},
+	}
+	ownerRefs := createDeploymentWithReplicaSet(ctx, fakeClient, deploy)
+	ownerRefs[0].UID = "rs-uid"
+	restarted := corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "web-abc-1", Namespace: "default", UID: "old-uid", OwnerReferences: ownerRefs}}
+
+	owners := r.restartedOwners(ctx, []corev1.Pod{restarted})
+	if len(owners) != 1 || owners[0].workload == nil || owners[0].workload.Kind != "Deployment" {
+		t.Fatalf("Expected the Deployment resolved once, got %+v", owners)
+	}
+
+	if healthy, err := r.ownerHealthy(ctx, owners[0], 0); err != nil || healthy {
+		t.Errorf("Expected unhealthy with a replica unavailable, got %v, %v", healthy, err)
+	}
+
+	deploy.Status.AvailableReplicas = 2
+	_ = fakeClient.Status().Update(ctx, deploy)
+	if healthy, err := r.ownerHealthy(ctx, owners[0], 0); err != nil || !healthy {
+		t.Errorf("Expected healthy once every replica is available, got %v, %v", healthy, err)
+	}
+
+	// A status from before the restarted pod was deleted doesn't count
+	_ = fakeClient.Create(ctx, &restarted)
+	if healthy, _ := r.ownerHealthy(ctx, owners[0], 0); healthy {
+		t.Error("Expected unhealthy while the restarted pod still exists")
+	}
+}
+
+func TestOwnerHealthy_StatefulSetReplacement(t *testing.T) {
+	r, fakeClient := setupTestReconciler()
+	ctx := context.Background()
+
+	statefulSet := &appsv1.StatefulSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "default", UID: "db-uid"},
+		Spec:       appsv1.StatefulSetSpec{Replicas: ptr.To(int32(1))},
+		Status:     appsv1.StatefulSetStatus{ReadyReplicas: 1, AvailableReplicas: 1},
+	}
+	_ = fakeClient.Create(ctx, statefulSet)
+	ownerRefs := []metav1.OwnerReference{{APIVersion: "apps/v1", Kind: "StatefulSet", Name: "db", UID: statefulSet.UID, Controller: ptr.To(true)}}
+
+	// The replacement reuses the name with a new UID
+	_ = fakeClient.Create(ctx, &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "db-0", Namespace: "default", UID: "new-uid", OwnerReferences: ownerRefs}})
+	restarted := corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "db-0", Namespace: "default", UID: "old-uid", OwnerReferences: ownerRefs}}
+
+	owners := r.restartedOwners(ctx, []corev1.Pod{restarted})
+	if healthy, err := r.ownerHealthy(ctx, owners[0], 0); err != nil || !healthy {
+		t.Errorf("Expected the replaced StatefulSet pod to be healthy, got %v, %v", healthy, err)
+	}
+}